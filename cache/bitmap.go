@@ -0,0 +1,497 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"strconv"
+	"strings"
+)
+
+// maximum bit offset, strings used as bitmaps are limited to 512MB
+const maxBitOffset = 1<<32 - 1
+
+var errBitOffset = errors.New("bit offset is not an integer or out of range")
+var errNotInteger = errors.New("value is not an integer or out of range")
+
+// Mutex must be locked before calling getBytes. Bitmaps are stored as strings, bits are numbered
+// from the most significant bit of the first byte. Returns nil if key is not set
+func (c *cache) getBytes(key string) (b []byte, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case string:
+		b = []byte(stored.(string))
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+
+// grow returns b extended with zero bytes so that it contains the bit with the given offset
+func grow(b []byte, bit uint64) []byte {
+	if need := int(bit/8) + 1; need > len(b) {
+		b = append(b, make([]byte, need-len(b))...)
+	}
+	return b
+}
+func getBit(b []byte, bit uint64) uint64 {
+	if bit/8 >= uint64(len(b)) {
+		return 0
+	}
+	return uint64(b[bit/8]>>(7-bit%8)) & 1
+}
+func setBit(b []byte, bit uint64, value uint64) {
+	if value == 1 {
+		b[bit/8] |= 1 << (7 - bit%8)
+	} else {
+		b[bit/8] &^= 1 << (7 - bit%8)
+	}
+}
+func parseBitOffset(arg string) (uint64, error) {
+	offset, err := strconv.ParseUint(arg, 10, 64)
+	if err != nil || offset > maxBitOffset {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+func (c *cache) SetBit(args []string) (response interface{}, err error) {
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return
+	}
+	if args[2] != "0" && args[2] != "1" {
+		err = errors.New("bit is not an integer or out of range")
+		return
+	}
+	value, _ := strconv.ParseUint(args[2], 10, 64)
+	c.m.Lock()
+	defer c.m.Unlock()
+	b, err := c.getBytes(args[0])
+	if err != nil {
+		return
+	}
+	b = grow(b, offset)
+	old := getBit(b, offset)
+	setBit(b, offset, value)
+	c.write(args[0], string(b))
+	c.notify(stringClass, "setbit", args[0])
+	response = int64(old)
+	return
+}
+func (c *cache) GetBit(args []string) (response interface{}, err error) {
+	offset, err := parseBitOffset(args[1])
+	if err != nil {
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	b, err := c.getBytes(args[0])
+	if err != nil {
+		return
+	}
+	response = int64(getBit(b, offset))
+	return
+}
+
+// bitRange converts the start and end arguments given in bytes or bits to an inclusive range of bits.
+// Negative values count from the end. ok is false if the range is empty
+func bitRange(args []string, length int) (start, end int64, ok bool, err error) {
+	unit := int64(8)
+	if len(args) == 3 {
+		switch strings.ToUpper(args[2]) {
+		case "BIT":
+			unit = 1
+		case "BYTE":
+		default:
+			err = errors.New("syntax error")
+			return
+		}
+	}
+	size := int64(length) * 8 / unit
+	start, err = strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		err = errNotInteger
+		return
+	}
+	end = size - 1
+	if len(args) > 1 {
+		end, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil {
+			err = errNotInteger
+			return
+		}
+	}
+	if start < 0 {
+		start += size
+	}
+	if end < 0 {
+		end += size
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= size {
+		end = size - 1
+	}
+	if start > end {
+		return
+	}
+	return start * unit, end*unit + unit - 1, true, nil
+}
+func (c *cache) BitCount(args []string) (response interface{}, err error) {
+	if len(args) != 1 && len(args) != 3 && len(args) != 4 {
+		err = syntaxError("BITCOUNT")
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	b, err := c.getBytes(args[0])
+	if err != nil {
+		return
+	}
+	start, end := int64(0), int64(len(b))*8-1
+	if len(args) > 1 {
+		var ok bool
+		start, end, ok, err = bitRange(args[1:], len(b))
+		if err != nil {
+			return
+		}
+		if !ok {
+			response = int64(0)
+			return
+		}
+	}
+	count := 0
+	for bit := start; bit <= end; {
+		if bit%8 == 0 && bit+7 <= end {
+			count += bits.OnesCount8(b[bit/8])
+			bit += 8
+			continue
+		}
+		count += int(getBit(b, uint64(bit)))
+		bit += 1
+	}
+	response = int64(count)
+	return
+}
+func (c *cache) BitPos(args []string) (response interface{}, err error) {
+	if len(args) < 2 || len(args) > 5 {
+		err = syntaxError("BITPOS")
+		return
+	}
+	if args[1] != "0" && args[1] != "1" {
+		err = errors.New("The bit argument must be 1 or 0.")
+		return
+	}
+	bit, _ := strconv.ParseUint(args[1], 10, 64)
+	c.m.RLock()
+	defer c.m.RUnlock()
+	b, err := c.getBytes(args[0])
+	if err != nil {
+		return
+	}
+	if b == nil {
+		response = int64(-int(bit))
+		return
+	}
+	start, end := int64(0), int64(len(b))*8-1
+	if len(args) > 2 {
+		var ok bool
+		start, end, ok, err = bitRange(args[2:], len(b))
+		if err != nil {
+			return
+		}
+		if !ok {
+			response = int64(-1)
+			return
+		}
+	}
+	for i := start; i <= end; i += 1 {
+		if getBit(b, uint64(i)) == bit {
+			response = int64(i)
+			return
+		}
+	}
+	// looking for a clear bit without an explicit end, the string is considered padded with zeros
+	if bit == 0 && len(args) <= 3 {
+		response = int64(len(b) * 8)
+		return
+	}
+	response = int64(-1)
+	return
+}
+func (c *cache) BitOp(args []string) (response interface{}, err error) {
+	operation := strings.ToUpper(args[0])
+	switch operation {
+	case "AND", "OR", "XOR":
+	case "NOT":
+		if len(args) != 3 {
+			err = errors.New("BITOP NOT must be called with a single source key.")
+			return
+		}
+	default:
+		err = errors.New("syntax error")
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	sources := make([][]byte, len(args)-2)
+	length := 0
+	for i, key := range args[2:] {
+		sources[i], err = c.getBytes(key)
+		if err != nil {
+			return
+		}
+		if len(sources[i]) > length {
+			length = len(sources[i])
+		}
+	}
+	// shorter strings are considered padded with zeros
+	for i := range sources {
+		sources[i] = append(sources[i], make([]byte, length-len(sources[i]))...)
+	}
+	res := make([]byte, length)
+	copy(res, sources[0])
+	for i := range res {
+		switch operation {
+		case "NOT":
+			res[i] = ^res[i]
+		case "AND":
+			for _, source := range sources[1:] {
+				res[i] &= source[i]
+			}
+		case "OR":
+			for _, source := range sources[1:] {
+				res[i] |= source[i]
+			}
+		case "XOR":
+			for _, source := range sources[1:] {
+				res[i] ^= source[i]
+			}
+		}
+	}
+	if length == 0 {
+		if c.delete(args[1]) {
+			c.notify(genericClass, "del", args[1])
+		}
+	} else {
+		c.write(args[1], string(res))
+		c.notify(stringClass, "set", args[1])
+	}
+	response = int64(length)
+	return
+}
+
+// bitfieldType is an integer type of BITFIELD such as i8 or u16
+type bitfieldType struct {
+	signed bool
+	bits   uint
+}
+
+func parseBitfieldType(arg string) (t bitfieldType, err error) {
+	errType := errors.New("Invalid bitfield type. Use something like i16 u8. Note that u64 is not supported but i64 is.")
+	if len(arg) < 2 || (arg[0] != 'i' && arg[0] != 'u') {
+		err = errType
+		return
+	}
+	t.signed = arg[0] == 'i'
+	n, err := strconv.Atoi(arg[1:])
+	if err != nil || n < 1 || n > 64 || (!t.signed && n == 64) {
+		err = errType
+		return
+	}
+	t.bits = uint(n)
+	return
+}
+
+// parseBitfieldOffset parses an offset in bits or a "#N" offset in multiples of the type width
+func parseBitfieldOffset(arg string, t bitfieldType) (uint64, error) {
+	multiply := strings.HasPrefix(arg, "#")
+	offset, err := strconv.ParseUint(strings.TrimPrefix(arg, "#"), 10, 64)
+	if err != nil {
+		return 0, errBitOffset
+	}
+	if multiply {
+		offset *= uint64(t.bits)
+	}
+	if offset+uint64(t.bits)-1 > maxBitOffset {
+		return 0, errBitOffset
+	}
+	return offset, nil
+}
+func (t bitfieldType) min() int64 {
+	if !t.signed {
+		return 0
+	}
+	return -1 << (t.bits - 1)
+}
+func (t bitfieldType) max() int64 {
+	if !t.signed {
+		return 1<<t.bits - 1
+	}
+	return 1<<(t.bits-1) - 1
+}
+
+// wrap truncates the value to the width of the type, sign extending it for signed types
+func (t bitfieldType) wrap(value uint64) int64 {
+	if t.bits == 64 {
+		return int64(value)
+	}
+	value &= 1<<t.bits - 1
+	if t.signed && value&(1<<(t.bits-1)) != 0 {
+		value |= math.MaxUint64 << t.bits
+	}
+	return int64(value)
+}
+func (t bitfieldType) get(b []byte, offset uint64) int64 {
+	var value uint64
+	for i := uint64(0); i < uint64(t.bits); i += 1 {
+		value = value<<1 | getBit(b, offset+i)
+	}
+	return t.wrap(value)
+}
+func (t bitfieldType) set(b []byte, offset uint64, value int64) {
+	for i := uint64(0); i < uint64(t.bits); i += 1 {
+		setBit(b, offset+i, uint64(value)>>(uint64(t.bits)-1-i)&1)
+	}
+}
+
+// overflow applies the overflow behaviour to the result of old + increment, ok is false for FAIL
+func (t bitfieldType) overflow(old, increment int64, behaviour string) (value int64, ok bool) {
+	up := increment > 0 && old > t.max()-increment
+	down := increment < 0 && old < t.min()-increment
+	if !up && !down {
+		return old + increment, true
+	}
+	switch behaviour {
+	case "SAT":
+		if up {
+			return t.max(), true
+		}
+		return t.min(), true
+	case "FAIL":
+		return 0, false
+	default:
+		return t.wrap(uint64(old) + uint64(increment)), true
+	}
+}
+func (c *cache) bitfield(args []string, readonly bool) (response interface{}, err error) {
+	type operation struct {
+		name      string
+		t         bitfieldType
+		offset    uint64
+		value     int64
+		overflow  string
+		setsValue bool
+	}
+	operations := make([]operation, 0)
+	overflow := "WRAP"
+	for i := 1; i < len(args); i += 1 {
+		name := strings.ToUpper(args[i])
+		if name == "OVERFLOW" && i+1 < len(args) && !readonly {
+			overflow = strings.ToUpper(args[i+1])
+			if overflow != "WRAP" && overflow != "SAT" && overflow != "FAIL" {
+				err = errors.New("Invalid OVERFLOW type specified")
+				return
+			}
+			i += 1
+			continue
+		}
+		op := operation{name: name, overflow: overflow}
+		switch {
+		case name == "GET" && i+2 < len(args):
+		case (name == "SET" || name == "INCRBY") && i+3 < len(args) && !readonly:
+			op.setsValue = true
+		default:
+			if readonly {
+				err = errors.New("BITFIELD_RO only supports the GET subcommand")
+			} else {
+				err = errors.New("syntax error")
+			}
+			return
+		}
+		op.t, err = parseBitfieldType(args[i+1])
+		if err != nil {
+			return
+		}
+		op.offset, err = parseBitfieldOffset(args[i+2], op.t)
+		if err != nil {
+			return
+		}
+		i += 2
+		if op.setsValue {
+			op.value, err = strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				err = errNotInteger
+				return
+			}
+			i += 1
+		}
+		operations = append(operations, op)
+	}
+
+	if readonly {
+		c.m.RLock()
+		defer c.m.RUnlock()
+	} else {
+		c.m.Lock()
+		defer c.m.Unlock()
+	}
+	b, err := c.getBytes(args[0])
+	if err != nil {
+		return
+	}
+	results := make([]interface{}, len(operations))
+	changed := false
+	for i, op := range operations {
+		old := op.t.get(b, op.offset)
+		switch op.name {
+		case "GET":
+			results[i] = old
+			continue
+		case "SET":
+			value := op.value
+			if value < op.t.min() || value > op.t.max() {
+				switch op.overflow {
+				case "FAIL":
+					results[i] = nil
+					continue
+				case "SAT":
+					value = op.t.max()
+					if op.value < op.t.min() {
+						value = op.t.min()
+					}
+				default:
+					value = op.t.wrap(uint64(value))
+				}
+			}
+			b = grow(b, op.offset+uint64(op.t.bits)-1)
+			op.t.set(b, op.offset, value)
+			results[i] = old
+		case "INCRBY":
+			value, ok := op.t.overflow(old, op.value, op.overflow)
+			if !ok {
+				results[i] = nil
+				continue
+			}
+			b = grow(b, op.offset+uint64(op.t.bits)-1)
+			op.t.set(b, op.offset, value)
+			results[i] = value
+		}
+		changed = true
+	}
+	if changed {
+		c.write(args[0], string(b))
+		c.notify(stringClass, "setbit", args[0])
+	}
+	response = results
+	return
+}
+func (c *cache) BitField(args []string) (response interface{}, err error) {
+	return c.bitfield(args, false)
+}
+func (c *cache) BitFieldRO(args []string) (response interface{}, err error) {
+	return c.bitfield(args, true)
+}
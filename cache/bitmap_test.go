@@ -0,0 +1,194 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestSetBit(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.SetBit([]string{"bitmap", "7", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.SetBit([]string{"bitmap", "7", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	c.SetBit([]string{"bitmap", "1", "1"})
+	c.SetBit([]string{"bitmap", "17", "1"})
+	if value := c.read("bitmap").(string); value != "@\x00@" {
+		t.Errorf("expected @\\x00@, got %q", value)
+	}
+	resp, err = text(c.GetBit([]string{"bitmap", "17"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.GetBit([]string{"bitmap", "1000"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	_, err = text(c.SetBit([]string{"bitmap", "-1", "1"}))
+	if err != errBitOffset {
+		t.Errorf("expected %v, got %v", errBitOffset, err)
+	}
+
+	c.Set([]string{"string", "a"})
+	resp, err = text(c.GetBit([]string{"string", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+}
+
+func TestBitCountPos(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.Set([]string{"key", "foobar"})
+
+	expected := map[string][]string{
+		"(integer) 26": {"key"},
+		"(integer) 4":  {"key", "0", "0"},
+		"(integer) 6":  {"key", "1", "1"},
+		"(integer) 18": {"key", "1", "-2"},
+		"(integer) 17": {"key", "5", "30", "BIT"},
+	}
+	for exp, args := range expected {
+		resp, err := text(c.BitCount(args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != exp {
+			t.Errorf("BITCOUNT %v: expected %v, got %v", args, exp, resp)
+		}
+	}
+
+	c.Set([]string{"ones", "\xff\xf0\x00"})
+	expected = map[string][]string{
+		"(integer) 12": {"ones", "0"},
+		"(integer) 0":  {"ones", "1"},
+		"(integer) 16": {"ones", "0", "2"},
+		"(integer) -1": {"ones", "1", "2", "-1"},
+		"(integer) 7":  {"ones", "1", "7", "15", "BIT"},
+	}
+	for exp, args := range expected {
+		resp, err := text(c.BitPos(args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != exp {
+			t.Errorf("BITPOS %v: expected %v, got %v", args, exp, resp)
+		}
+	}
+	c.Set([]string{"full", "\xff"})
+	resp, err := text(c.BitPos([]string{"full", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 8" {
+		t.Errorf("expected (integer) 8, got %v", resp)
+	}
+	resp, err = text(c.BitPos([]string{"missing", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) -1" {
+		t.Errorf("expected (integer) -1, got %v", resp)
+	}
+}
+
+func TestBitOp(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.Set([]string{"first", "\x0f\xff"})
+	c.Set([]string{"second", "\xf1"})
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"AND", "dest", "first", "second"}, "\x01\x00"},
+		{[]string{"OR", "dest", "first", "second"}, "\xff\xff"},
+		{[]string{"XOR", "dest", "first", "second", "missing"}, "\xfe\xff"},
+		{[]string{"NOT", "dest", "second"}, "\x0e"},
+	}
+	for _, test := range tests {
+		resp, err := text(c.BitOp(test.args))
+		if err != nil {
+			t.Error(err)
+		}
+		if value := c.read("dest").(string); value != test.expected {
+			t.Errorf("BITOP %v: expected %q, got %q (%v)", test.args, test.expected, value, resp)
+		}
+	}
+	_, err := text(c.BitOp([]string{"NOT", "dest", "first", "second"}))
+	if err == nil {
+		t.Error("expected error on BITOP NOT with several keys")
+	}
+	resp, err := text(c.BitOp([]string{"AND", "dest", "missing"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" || c.read("dest") != nil {
+		t.Errorf("expected empty result to delete the key, got %v", resp)
+	}
+}
+
+func TestBitField(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.BitField([]string{"bf", "SET", "i8", "0", "100", "GET", "u4", "0", "INCRBY", "i5", "100", "1", "GET", "u4", "#1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) (integer) 0\n2) (integer) 6\n3) (integer) 1\n4) (integer) 4"
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.BitField([]string{"counter", "INCRBY", "u2", "0", "5", "OVERFLOW", "SAT", "INCRBY", "u2", "2", "5", "OVERFLOW", "FAIL", "INCRBY", "u2", "4", "5"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) (integer) 1\n2) (integer) 3\n3) (nil)"
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.BitField([]string{"signed", "SET", "i8", "0", "127", "INCRBY", "i8", "0", "1", "OVERFLOW", "SAT", "INCRBY", "i8", "0", "-10"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) (integer) 0\n2) (integer) -128\n3) (integer) -128"
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.BitFieldRO([]string{"signed", "GET", "i8", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) -128" {
+		t.Errorf("expected 1) (integer) -128, got %v", resp)
+	}
+	_, err = text(c.BitFieldRO([]string{"signed", "SET", "i8", "0", "1"}))
+	if err == nil {
+		t.Error("expected error on BITFIELD_RO SET")
+	}
+	_, err = text(c.BitField([]string{"signed", "GET", "u64", "0"}))
+	if err == nil {
+		t.Error("expected error on u64 type")
+	}
+}
@@ -0,0 +1,272 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+const (
+	bloomDefaultErrorRate = 0.01
+	bloomDefaultCapacity  = 100
+	bloomDefaultExpansion = 2
+	// every new layer of a scalable filter has the error rate multiplied by this ratio,
+	// so the compound error rate stays below the requested one
+	bloomTighteningRatio = 0.5
+)
+
+// BloomLayer is a fixed size bloom filter
+type BloomLayer struct {
+	Bits      []byte
+	Size      uint64
+	Hashes    int
+	Capacity  int
+	Count     int
+	ErrorRate float64
+}
+
+func NewBloomLayer(capacity int, errorRate float64) *BloomLayer {
+	size := uint64(math.Ceil(-float64(capacity) * math.Log(errorRate) / (math.Ln2 * math.Ln2)))
+	if size < 8 {
+		size = 8
+	}
+	hashes := int(math.Ceil(math.Log2(1 / errorRate)))
+	return &BloomLayer{make([]byte, (size+7)/8), size, hashes, capacity, 0, errorRate}
+}
+
+// bloomHashes returns two independent hashes of the item, positions are derived from them by double hashing
+func bloomHashes(item string) (uint64, uint64) {
+	return murmurHash64A([]byte(item), 0xc70f6907), murmurHash64A([]byte(item), 0x97c29b3a)
+}
+func (l *BloomLayer) has(h1, h2 uint64) bool {
+	for i := 0; i < l.Hashes; i += 1 {
+		bit := (h1 + uint64(i)*h2) % l.Size
+		if l.Bits[bit/8]&(1<<(bit%8)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+func (l *BloomLayer) add(h1, h2 uint64) {
+	for i := 0; i < l.Hashes; i += 1 {
+		bit := (h1 + uint64(i)*h2) % l.Size
+		l.Bits[bit/8] |= 1 << (bit % 8)
+	}
+	l.Count += 1
+}
+
+// BloomFilter is a scalable bloom filter: when the last layer is full a new larger layer is added,
+// unless the filter was created with NONSCALING
+type BloomFilter struct {
+	Layers    []*BloomLayer
+	ErrorRate float64
+	Expansion int
+}
+
+func NewBloomFilter(errorRate float64, capacity, expansion int) *BloomFilter {
+	return &BloomFilter{[]*BloomLayer{NewBloomLayer(capacity, errorRate)}, errorRate, expansion}
+}
+
+func (f *BloomFilter) Exists(item string) bool {
+	h1, h2 := bloomHashes(item)
+	for _, layer := range f.Layers {
+		if layer.has(h1, h2) {
+			return true
+		}
+	}
+	return false
+}
+
+// Add adds the item, returns false if the item may have been added before
+func (f *BloomFilter) Add(item string) (bool, error) {
+	h1, h2 := bloomHashes(item)
+	for _, layer := range f.Layers {
+		if layer.has(h1, h2) {
+			return false, nil
+		}
+	}
+	last := f.Layers[len(f.Layers)-1]
+	if last.Count >= last.Capacity {
+		if f.Expansion == 0 {
+			return false, errors.New("non scaling filter is full")
+		}
+		last = NewBloomLayer(last.Capacity*f.Expansion, last.ErrorRate*bloomTighteningRatio)
+		f.Layers = append(f.Layers, last)
+	}
+	last.add(h1, h2)
+	return true, nil
+}
+func (f *BloomFilter) capacity() int {
+	capacity := 0
+	for _, layer := range f.Layers {
+		capacity += layer.Capacity
+	}
+	return capacity
+}
+func (f *BloomFilter) count() int {
+	count := 0
+	for _, layer := range f.Layers {
+		count += layer.Count
+	}
+	return count
+}
+func (f *BloomFilter) size() int {
+	size := 0
+	for _, layer := range f.Layers {
+		size += len(layer.Bits)
+	}
+	return size
+}
+
+// Mutex must be locked before calling getBloomFilter. Returns nil if create is false and key is not set
+func (c *cache) getBloomFilter(key string, create bool) (filter *BloomFilter, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+		if create {
+			filter = NewBloomFilter(bloomDefaultErrorRate, bloomDefaultCapacity, bloomDefaultExpansion)
+			c.write(key, filter)
+		}
+	case *BloomFilter:
+		filter = stored.(*BloomFilter)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) BFReserve(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("BF.RESERVE")
+	errorRate, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || errorRate <= 0 || errorRate >= 1 {
+		err = errors.New("error rate should be between 0 and 1")
+		return
+	}
+	capacity, err := strconv.Atoi(args[2])
+	if err != nil || capacity <= 0 {
+		err = errors.New("capacity should be larger than 0")
+		return
+	}
+	expansion := bloomDefaultExpansion
+	for i := 3; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "EXPANSION" && i+1 < len(args) {
+			expansion, err = strconv.Atoi(args[i+1])
+			if err != nil || expansion < 1 {
+				err = errors.New("expansion should be greater or equal to 1")
+				return
+			}
+			i += 1
+		} else if option == "NONSCALING" {
+			expansion = 0
+		} else {
+			err = formatErr
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.read(args[0]) != nil {
+		err = errors.New("item exists")
+		return
+	}
+	c.write(args[0], NewBloomFilter(errorRate, capacity, expansion))
+	response = formatted("OK")
+	return
+}
+
+// bfAdd adds items to the filter creating it if needed, the result contains 1 or 0 for each item
+func (c *cache) bfAdd(key string, items []string) (added []interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	filter, err := c.getBloomFilter(key, true)
+	if err != nil {
+		return
+	}
+	added = make([]interface{}, len(items))
+	for i, item := range items {
+		var ok bool
+		ok, err = filter.Add(item)
+		if err != nil {
+			return
+		}
+		added[i] = 0
+		if ok {
+			added[i] = 1
+			c.modified(key)
+			c.notify(moduleClass, "bf.add", key)
+		}
+	}
+	return
+}
+func (c *cache) BFAdd(args []string) (response interface{}, err error) {
+	added, err := c.bfAdd(args[0], args[1:])
+	if err != nil {
+		return
+	}
+	response = added[0]
+	return
+}
+func (c *cache) BFMAdd(args []string) (response interface{}, err error) {
+	added, err := c.bfAdd(args[0], args[1:])
+	if err != nil {
+		return
+	}
+	response = added
+	return
+}
+
+// bfExists checks the items, the result contains 1 or 0 for each item
+func (c *cache) bfExists(key string, items []string) (exist []interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	filter, err := c.getBloomFilter(key, false)
+	if err != nil {
+		return
+	}
+	exist = make([]interface{}, len(items))
+	for i, item := range items {
+		exist[i] = 0
+		if filter != nil && filter.Exists(item) {
+			exist[i] = 1
+		}
+	}
+	return
+}
+func (c *cache) BFExists(args []string) (response interface{}, err error) {
+	exist, err := c.bfExists(args[0], args[1:])
+	if err != nil {
+		return
+	}
+	response = exist[0]
+	return
+}
+func (c *cache) BFMExists(args []string) (response interface{}, err error) {
+	exist, err := c.bfExists(args[0], args[1:])
+	if err != nil {
+		return
+	}
+	response = exist
+	return
+}
+func (c *cache) BFInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	filter, err := c.getBloomFilter(args[0], false)
+	if err != nil {
+		return
+	}
+	if filter == nil {
+		err = errors.New("not found")
+		return
+	}
+	response = []interface{}{
+		"Capacity", filter.capacity(),
+		"Size", filter.size(),
+		"Number of filters", len(filter.Layers),
+		"Number of items inserted", filter.count(),
+		"Expansion rate", filter.Expansion,
+	}
+	return
+}
@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestBFAdd(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.BFAdd([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.BFAdd([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.BFMAdd([]string{"filter", "item", "other"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 0\n2) (integer) 1" {
+		t.Errorf("expected 1) (integer) 0\n2) (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.BFMExists([]string{"filter", "item", "missing"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 1\n2) (integer) 0" {
+		t.Errorf("expected 1) (integer) 1\n2) (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.BFExists([]string{"missingfilter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+}
+
+func TestBFReserve(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.BFReserve([]string{"filter", "2", "100"}))
+	if err == nil {
+		t.Error("expected error on invalid error rate")
+	}
+	resp, err := text(c.BFReserve([]string{"filter", "0.001", "100", "NONSCALING"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	_, err = text(c.BFReserve([]string{"filter", "0.001", "100"}))
+	if err == nil || err.Error() != "item exists" {
+		t.Error(err)
+	}
+	for i := 0; i < 100; i += 1 {
+		_, err = text(c.BFAdd([]string{"filter", fmt.Sprintf("item%v", i)}))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	_, err = text(c.BFAdd([]string{"filter", "overflow"}))
+	if err == nil || err.Error() != "non scaling filter is full" {
+		t.Error(err)
+	}
+}
+
+func TestBloomFilterScaling(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.BFReserve([]string{"filter", "0.01", "1000", "EXPANSION", "2"})
+
+	n := 10000
+	for i := 0; i < n; i += 1 {
+		c.BFAdd([]string{"filter", fmt.Sprintf("item%v", i)})
+	}
+	filter := c.read("filter").(*BloomFilter)
+	if len(filter.Layers) < 2 {
+		t.Errorf("expected filter to grow, got %v layers", len(filter.Layers))
+	}
+	for i := 0; i < n; i += 1 {
+		resp, _ := text(c.BFExists([]string{"filter", fmt.Sprintf("item%v", i)}))
+		if resp != "(integer) 1" {
+			t.Errorf("false negative for item%v", i)
+		}
+	}
+	falsePositives := 0
+	for i := 0; i < n; i += 1 {
+		resp, _ := text(c.BFExists([]string{"filter", fmt.Sprintf("missing%v", i)}))
+		if resp == "(integer) 1" {
+			falsePositives += 1
+		}
+	}
+	if float64(falsePositives)/float64(n) > 0.02 {
+		t.Errorf("false positive rate %v is too high", float64(falsePositives)/float64(n))
+	}
+}
+
+func TestBloomFilterSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.BFMAdd([]string{"filter", "a", "b", "c"})
+
+	_, err := text(c.Save([]string{"bloomsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"bloomsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.BFMExists([]string{"filter", "a", "b", "c", "d"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 1\n2) (integer) 1\n3) (integer) 1\n4) (integer) 0" {
+		t.Errorf("unexpected response %v", resp)
+	}
+
+	err = os.Remove("./saves/bloomsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -47,6 +47,8 @@ func NewRList() RList {
 	return RList{list.New()}
 }
 
+// Cache is a database of keys. Command methods expect the number of arguments to be checked against the command
+// table by HandleRequest
 type Cache interface {
 	//must lock objects recieved from read
 	read(string) interface{}
@@ -56,32 +58,118 @@ type Cache interface {
 
 	StartCleaner()
 
+	// HandleRequest executes the command and formats its reply as text
 	HandleRequest(method string, args []string) (string, error)
-	Keys(args []string) (response string, err error)
-	Del(args []string) (response string, err error)
-	Get(args []string) (response string, err error)
-	Set(args []string) (response string, err error)
-	HSet(args []string) (response string, err error)
-	HGet(args []string) (response string, err error)
-	LPush(args []string) (response string, err error)
-	RPush(args []string) (response string, err error)
-	LPop(args []string) (response string, err error)
-	RPop(args []string) (response string, err error)
-	LSet(args []string) (response string, err error)
-	LGet(args []string) (response string, err error)
-	Expire(args []string) (response string, err error)
-	Save(args []string) (response string, err error)
-	Load(args []string) (response string, err error)
+	Keys(args []string) (response interface{}, err error)
+	Del(args []string) (response interface{}, err error)
+	Get(args []string) (response interface{}, err error)
+	Set(args []string) (response interface{}, err error)
+	HSet(args []string) (response interface{}, err error)
+	HGet(args []string) (response interface{}, err error)
+	LPush(args []string) (response interface{}, err error)
+	RPush(args []string) (response interface{}, err error)
+	LPop(args []string) (response interface{}, err error)
+	RPop(args []string) (response interface{}, err error)
+	LSet(args []string) (response interface{}, err error)
+	LGet(args []string) (response interface{}, err error)
+	Expire(args []string) (response interface{}, err error)
+	Save(args []string) (response interface{}, err error)
+	Load(args []string) (response interface{}, err error)
+	XAdd(args []string) (response interface{}, err error)
+	XLen(args []string) (response interface{}, err error)
+	XRange(args []string) (response interface{}, err error)
+	XRevRange(args []string) (response interface{}, err error)
+	XTrim(args []string) (response interface{}, err error)
+	XRead(args []string) (response interface{}, err error)
+	XGroup(args []string) (response interface{}, err error)
+	XReadGroup(args []string) (response interface{}, err error)
+	XAck(args []string) (response interface{}, err error)
+	XPending(args []string) (response interface{}, err error)
+	XClaim(args []string) (response interface{}, err error)
+	XAutoClaim(args []string) (response interface{}, err error)
+	PFAdd(args []string) (response interface{}, err error)
+	PFCount(args []string) (response interface{}, err error)
+	PFMerge(args []string) (response interface{}, err error)
+	BFReserve(args []string) (response interface{}, err error)
+	BFAdd(args []string) (response interface{}, err error)
+	BFMAdd(args []string) (response interface{}, err error)
+	BFExists(args []string) (response interface{}, err error)
+	BFMExists(args []string) (response interface{}, err error)
+	BFInfo(args []string) (response interface{}, err error)
+	CFReserve(args []string) (response interface{}, err error)
+	CFAdd(args []string) (response interface{}, err error)
+	CFAddNX(args []string) (response interface{}, err error)
+	CFDel(args []string) (response interface{}, err error)
+	CFExists(args []string) (response interface{}, err error)
+	CFCount(args []string) (response interface{}, err error)
+	CFInfo(args []string) (response interface{}, err error)
+	CMSInitByDim(args []string) (response interface{}, err error)
+	CMSInitByProb(args []string) (response interface{}, err error)
+	CMSIncrBy(args []string) (response interface{}, err error)
+	CMSQuery(args []string) (response interface{}, err error)
+	CMSMerge(args []string) (response interface{}, err error)
+	CMSInfo(args []string) (response interface{}, err error)
+	TopKReserve(args []string) (response interface{}, err error)
+	TopKAdd(args []string) (response interface{}, err error)
+	TopKIncrBy(args []string) (response interface{}, err error)
+	TopKQuery(args []string) (response interface{}, err error)
+	TopKCount(args []string) (response interface{}, err error)
+	TopKList(args []string) (response interface{}, err error)
+	TopKInfo(args []string) (response interface{}, err error)
+	SetBit(args []string) (response interface{}, err error)
+	GetBit(args []string) (response interface{}, err error)
+	BitCount(args []string) (response interface{}, err error)
+	BitPos(args []string) (response interface{}, err error)
+	BitOp(args []string) (response interface{}, err error)
+	BitField(args []string) (response interface{}, err error)
+	BitFieldRO(args []string) (response interface{}, err error)
+	JSONSet(args []string) (response interface{}, err error)
+	JSONGet(args []string) (response interface{}, err error)
+	JSONDel(args []string) (response interface{}, err error)
+	JSONNumIncrBy(args []string) (response interface{}, err error)
+	JSONArrAppend(args []string) (response interface{}, err error)
+	TSCreate(args []string) (response interface{}, err error)
+	TSAdd(args []string) (response interface{}, err error)
+	TSGet(args []string) (response interface{}, err error)
+	TSRange(args []string) (response interface{}, err error)
+	TSRevRange(args []string) (response interface{}, err error)
+	TSMRange(args []string) (response interface{}, err error)
+	TSCreateRule(args []string) (response interface{}, err error)
+	TSDeleteRule(args []string) (response interface{}, err error)
+	TSInfo(args []string) (response interface{}, err error)
+	VAdd(args []string) (response interface{}, err error)
+	VRem(args []string) (response interface{}, err error)
+	VSim(args []string) (response interface{}, err error)
+	VEmb(args []string) (response interface{}, err error)
+	VCard(args []string) (response interface{}, err error)
+	VInfo(args []string) (response interface{}, err error)
+	FTCreate(args []string) (response interface{}, err error)
+	FTSearch(args []string) (response interface{}, err error)
+	FTDropIndex(args []string) (response interface{}, err error)
+	FTInfo(args []string) (response interface{}, err error)
+	Scan(args []string) (response interface{}, err error)
+	HScan(args []string) (response interface{}, err error)
+	Exists(args []string) (response interface{}, err error)
+	Type(args []string) (response interface{}, err error)
+	Rename(args []string) (response interface{}, err error)
+	RenameNX(args []string) (response interface{}, err error)
+	Copy(args []string) (response interface{}, err error)
+	RandomKey(args []string) (response interface{}, err error)
+	DBSize(args []string) (response interface{}, err error)
+	Touch(args []string) (response interface{}, err error)
+	Unlink(args []string) (response interface{}, err error)
+	FlushDB(args []string) (response interface{}, err error)
+	FlushAll(args []string) (response interface{}, err error)
 }
 
 func NewCache() Cache {
-	return &cache{Fields: make(map[string]interface{}), m: &sync.RWMutex{}, Exps: NewExpirations()}
+	return &cache{Fields: make(map[string]interface{}), m: &sync.RWMutex{}, Exps: NewExpirations(), blocked: newWaiters(), timeSeries: make(map[string]bool), indexes: make(map[string]*SearchIndex), slots: make([]map[string]bool, keySlots), watched: make(map[string]*watchedKey)}
 }
 
 type Expirations struct {
 	Expirations []expiration
 	Indexes     map[string]int
-	m           *sync.Mutex
+	m           sync.Locker
 }
 
 func NewExpirations() Expirations {
@@ -115,20 +203,65 @@ type expiration struct {
 	Field   string
 	Expires time.Time
 }
-type cache struct {
-	Fields map[string]interface{}
-	m      *sync.RWMutex
-	Exps   Expirations
-}
 
-// Mutex must be rlocked before calling read
+// rwLocker is the lock of the cache. Transactions replace it with noLock while executing queued commands
+type rwLocker interface {
+	sync.Locker
+	RLock()
+	RUnlock()
+}
+type cache struct {
+	Fields  map[string]interface{}
+	m       rwLocker
+	Exps    Expirations
+	blocked waiters
+	// keys of time series, retention of which is checked by the cleaner
+	timeSeries map[string]bool
+	// search indexes by name, updated on every write and delete
+	indexes map[string]*SearchIndex
+	// keys by slot, iterated by SCAN
+	slots []map[string]bool
+	// set while executing queued commands of a transaction, blocking commands return immediately
+	transaction bool
+	// keys watched by sessions with their versions, changed on every modification of the key
+	watched map[string]*watchedKey
+	// number of modifications of keys, used to tell if a script has written anything
+	dirty uint64
+	// index of the database, keyspace notifications and tracking of keys by clients, events and tracking are nil
+	// for caches created outside of databases
+	index    int
+	events   *keyspaceEvents
+	tracking *tracking
+	// reader is the session tracking keys read through a view returned by unlocked, whose keys are not known
+	// from the arguments of the command
+	reader Session
+}
+
+// Mutex must be rlocked before calling read. Keys read through a view with a reader are tracked for the reader
 func (c *cache) read(key string) interface{} {
+	c.tracked(key)
 	return c.Fields[key]
 }
 
+// tracked remembers the key for the reader of the view, keys listed by commands are tracked like keys which are read
+func (c *cache) tracked(key string) {
+	if c.reader != nil {
+		c.tracking.read(c.reader, []string{key})
+	}
+}
+
 // Mutex must be locked before calling write
 func (c *cache) write(key string, val interface{}) {
+	if _, ok := c.Fields[key]; !ok {
+		c.addToSlot(key)
+		c.notify(newKeyClass, "new", key)
+	}
 	c.Fields[key] = val
+	if _, ok := val.(*TimeSeries); ok {
+		c.timeSeries[key] = true
+	}
+	c.reindex(key)
+	c.modified(key)
 }
 
 // Mutex must be locked before calling delete
@@ -136,6 +269,9 @@ func (c *cache) delete(key string) bool {
 	_, ok := c.Fields[key]
 	if ok {
 		delete(c.Fields, key)
+		c.removeFromSlot(key)
+		c.reindex(key)
+		c.modified(key)
 	}
 	return ok
 }
@@ -157,67 +293,60 @@ func (c *cache) setExpiration(key string, expires time.Duration) {
 func (c *cache) StartCleaner() {
 	for {
 		time.Sleep(50 * time.Millisecond)
-		c.Exps.m.Lock()
-		if c.Exps.Len() != 0 {
-			// closest expiration
-			expiration := c.Exps.Expirations[0]
-			for ; expiration.Expires.Before(time.Now()); expiration = c.Exps.Expirations[0] {
-				if expiration.Expires.Before(time.Now()) {
-					heap.Pop(&c.Exps)
-					c.m.Lock()
-					c.delete(expiration.Field)
-					c.m.Unlock()
-					if c.Exps.Len() == 0 {
-						break
-					}
+		c.clean()
+	}
+}
+
+// clean deletes the expired fields and trims time series
+func (c *cache) clean() {
+	c.Exps.m.Lock()
+	if c.Exps.Len() != 0 {
+		// closest expiration
+		expiration := c.Exps.Expirations[0]
+		for ; expiration.Expires.Before(time.Now()); expiration = c.Exps.Expirations[0] {
+			if expiration.Expires.Before(time.Now()) {
+				heap.Pop(&c.Exps)
+				c.m.Lock()
+				c.expire(expiration.Field)
+				c.m.Unlock()
+				if c.Exps.Len() == 0 {
+					break
 				}
 			}
 		}
-		c.Exps.m.Unlock()
 	}
+	c.Exps.m.Unlock()
+	c.trimRetention()
 }
-func (c *cache) Keys(args []string) (response string, err error) {
-	if len(args) != 1 {
-		err = ArgsError{"Expected format: KEYS pattern"}
-		return
-	}
+func (c *cache) Keys(args []string) (response interface{}, err error) {
 	glob := glob.MustCompile(args[0])
-	var keys []string
-	i := 1
+	keys := []interface{}{}
 	c.m.RLock()
 	for key := range c.Fields {
-		match := glob.Match(key)
-		if match {
-			keys = append(keys, fmt.Sprintf("%v) \"%v\"", i, key))
-			i += 1
+		if glob.Match(key) {
+			c.tracked(key)
+			keys = append(keys, key)
 		}
 	}
 	c.m.RUnlock()
-	response = strings.Join(keys, "\n")
+	response = keys
 	return
 }
-func (c *cache) Del(args []string) (response string, err error) {
-	if len(args) == 0 {
-		err = ArgsError{"Expected format: DEL key [key ...]"}
-		return
-	}
+func (c *cache) Del(args []string) (response interface{}, err error) {
 	counter := 0
 	c.m.Lock()
 	for i := range args {
 		deleted := c.delete(args[i])
 		if deleted {
+			c.notify(genericClass, "del", args[i])
 			counter += 1
 		}
 	}
 	c.m.Unlock()
-	response = fmt.Sprintf("(integer) %v", counter)
+	response = int64(counter)
 	return
 }
-func (c *cache) Get(args []string) (response string, err error) {
-	if len(args) != 1 {
-		err = ArgsError{"Expected format: GET key"}
-		return
-	}
+func (c *cache) Get(args []string) (response interface{}, err error) {
 	c.m.RLock()
 	defer c.m.RUnlock()
 	value := c.read(args[0])
@@ -225,15 +354,15 @@ func (c *cache) Get(args []string) (response string, err error) {
 	case string:
 		response = value.(string)
 	case nil:
-		response = "(nil)"
+		response = nil
 	default:
 		err = errors.New(fmt.Sprintf("Requested field is of type %T", value))
 	}
 	return
 }
-func (c *cache) Set(args []string) (response string, err error) {
+func (c *cache) Set(args []string) (response interface{}, err error) {
 	if len(args) != 2 && len(args) != 4 {
-		err = ArgsError{"Expected format: SET key value [EX seconds]"}
+		err = syntaxError("SET")
 		return
 	}
 
@@ -241,6 +370,7 @@ func (c *cache) Set(args []string) (response string, err error) {
 	c.m.Lock()
 
 	c.write(args[0], args[1])
+	c.notify(stringClass, "set", args[0])
 	if len(args) == 4 {
 		var exp time.Duration
 		var secs int
@@ -253,28 +383,25 @@ func (c *cache) Set(args []string) (response string, err error) {
 			exp = time.Duration(secs * int(time.Second) / int(time.Nanosecond))
 			if exp != 0 {
 				c.setExpiration(args[0], exp) // Setting the correct expiration
+				c.notify(genericClass, "expire", args[0])
 			}
 		} else {
-			err = ArgsError{"Expected format: METHOD [EX seconds] key [arguments]"}
+			err = syntaxError("SET")
 			return
 		}
 	}
 	c.m.Unlock()
 	c.Exps.m.Unlock()
 
-	response = "OK"
+	response = formatted("OK")
 	return
 }
-func (c *cache) Expire(args []string) (response string, err error) {
-	if len(args) != 2 {
-		err = ArgsError{"Expected format: EXPIRE key seconds"}
-		return
-	}
+func (c *cache) Expire(args []string) (response interface{}, err error) {
 	c.m.RLock()
 	stored := c.read(args[0])
 	c.m.RUnlock()
 	if stored == nil {
-		response = "(integer) 0"
+		response = int64(0)
 	} else {
 		var exp time.Duration
 		var secs int
@@ -284,16 +411,20 @@ func (c *cache) Expire(args []string) (response string, err error) {
 		}
 		exp = time.Duration(secs * int(time.Second) / int(time.Nanosecond))
 		c.Exps.m.Lock()
+		c.m.Lock()
 		c.setExpiration(args[0], exp)
+		c.modified(args[0])
+		c.notify(genericClass, "expire", args[0])
+		c.m.Unlock()
 		c.Exps.m.Unlock()
-		response = "(integer) 1"
+		response = int64(1)
 	}
 	return
 }
-func (c *cache) HSet(args []string) (response string, err error) {
+func (c *cache) HSet(args []string) (response interface{}, err error) {
 	n := len(args)
 	if n < 3 || n%2 == 0 {
-		err = ArgsError{"Expected format: HSET key field value [field value ...]"}
+		err = syntaxError("HSET")
 		return
 	}
 	counter := 0
@@ -318,15 +449,14 @@ func (c *cache) HSet(args []string) (response string, err error) {
 		value.Hashmap[args[i]] = args[i+1]
 		counter += 1
 	}
+	c.reindex(args[0])
+	c.modified(args[0])
+	c.notify(hashClass, "hset", args[0])
 
-	response = fmt.Sprintf("(integer) %v", counter)
+	response = int64(counter)
 	return
 }
-func (c *cache) HGet(args []string) (response string, err error) {
-	if len(args) != 2 {
-		err = ArgsError{"Expected format: HGET key field"}
-		return
-	}
+func (c *cache) HGet(args []string) (response interface{}, err error) {
 	c.m.RLock()
 	defer c.m.RUnlock()
 	stored := c.read(args[0])
@@ -335,23 +465,19 @@ func (c *cache) HGet(args []string) (response string, err error) {
 		hmap := stored.(Hashmap)
 		val, ok := hmap.Read(args[1])
 		if !ok {
-			response = "(nil)"
+			response = nil
 		} else {
 			response = val
 		}
 	case nil:
-		response = "(nil)"
+		response = nil
 	default:
 		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
 		return
 	}
 	return
 }
-func (c *cache) LPush(args []string) (response string, err error) {
-	if len(args) < 2 {
-		err = ArgsError{"Expected format: LPUSH key element [element ...]"}
-		return
-	}
+func (c *cache) LPush(args []string) (response interface{}, err error) {
 	c.m.Lock()
 	defer c.m.Unlock()
 	stored := c.read(args[0])
@@ -370,15 +496,13 @@ func (c *cache) LPush(args []string) (response string, err error) {
 	for i := 1; i < len(args); i += 1 {
 		list.Value.PushFront(args[i])
 	}
+	c.modified(args[0])
+	c.notify(listClass, "lpush", args[0])
 
-	response = fmt.Sprintf("(integer) %v", list.Value.Len())
+	response = int64(list.Value.Len())
 	return
 }
-func (c *cache) RPush(args []string) (response string, err error) {
-	if len(args) < 2 {
-		err = ArgsError{"Expected format: RPUSH key element [element ...]"}
-		return
-	}
+func (c *cache) RPush(args []string) (response interface{}, err error) {
 	c.m.Lock()
 	defer c.m.Unlock()
 	stored := c.read(args[0])
@@ -397,13 +521,15 @@ func (c *cache) RPush(args []string) (response string, err error) {
 	for i := 1; i < len(args); i += 1 {
 		list.Value.PushBack(args[i])
 	}
+	c.modified(args[0])
+	c.notify(listClass, "rpush", args[0])
 
-	response = fmt.Sprintf("(integer) %v", list.Value.Len())
+	response = int64(list.Value.Len())
 	return
 }
-func (c *cache) LPop(args []string) (response string, err error) {
+func (c *cache) LPop(args []string) (response interface{}, err error) {
 	if len(args) < 1 || len(args) > 3 {
-		err = ArgsError{"Expected format: LPOP key [count]"}
+		err = syntaxError("LPOP")
 		return
 	}
 	c.m.Lock()
@@ -411,7 +537,7 @@ func (c *cache) LPop(args []string) (response string, err error) {
 	stored := c.read(args[0])
 	switch stored.(type) {
 	case nil:
-		response = "(nil)"
+		response = nil
 	case RList:
 		list := stored.(RList)
 		if len(args) > 1 {
@@ -422,8 +548,11 @@ func (c *cache) LPop(args []string) (response string, err error) {
 		} else {
 			response = list.Value.Remove(list.Value.Front()).(string)
 		}
+		c.modified(args[0])
+		c.notify(listClass, "lpop", args[0])
 		if list.Value.Len() == 0 {
 			c.delete(args[0])
+			c.notify(genericClass, "del", args[0])
 		}
 	default:
 		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
@@ -431,9 +560,9 @@ func (c *cache) LPop(args []string) (response string, err error) {
 	}
 	return
 }
-func (c *cache) RPop(args []string) (response string, err error) {
+func (c *cache) RPop(args []string) (response interface{}, err error) {
 	if len(args) < 1 || len(args) > 3 {
-		err = ArgsError{"Expected format: RPOP key [count]"}
+		err = syntaxError("RPOP")
 		return
 	}
 	c.m.Lock()
@@ -441,7 +570,7 @@ func (c *cache) RPop(args []string) (response string, err error) {
 	stored := c.read(args[0])
 	switch stored.(type) {
 	case nil:
-		response = "(nil)"
+		response = nil
 	case RList:
 		list := stored.(RList)
 		if len(args) > 1 {
@@ -452,8 +581,11 @@ func (c *cache) RPop(args []string) (response string, err error) {
 		} else {
 			response = list.Value.Remove(list.Value.Front()).(string)
 		}
+		c.modified(args[0])
+		c.notify(listClass, "rpop", args[0])
 		if list.Value.Len() == 0 {
 			c.delete(args[0])
+			c.notify(genericClass, "del", args[0])
 		}
 	default:
 		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
@@ -461,7 +593,7 @@ func (c *cache) RPop(args []string) (response string, err error) {
 	}
 	return
 }
-func (l *RList) poprange(args []string, method string) (response string, err error) {
+func (l *RList) poprange(args []string, method string) (response interface{}, err error) {
 	var popped strings.Builder
 	var start int
 	var end int
@@ -568,6 +700,57 @@ func addElement(builder *strings.Builder, str *string, i int) error {
 	}
 	return nil
 }
+
+// formatted is a reply that is already formatted and is put into arrays as is
+type formatted string
+
+// formatReply formats a reply of a command as text, strings are returned as they are
+func formatReply(reply interface{}) string {
+	switch reply.(type) {
+	case []interface{}:
+		return formatArray(reply.([]interface{}))
+	case int, int64, uint64:
+		return fmt.Sprintf("(integer) %v", reply)
+	case nil:
+		return "(nil)"
+	case error:
+		return fmt.Sprintf("(error) %v", reply)
+	}
+	return fmt.Sprint(reply)
+}
+
+// formatArray formats a reply consisting of strings, integers, nils and nested arrays
+func formatArray(items []interface{}) string {
+	if len(items) == 0 {
+		return "(empty array)"
+	}
+	var builder strings.Builder
+	for i := range items {
+		prefix := fmt.Sprintf("%v) ", i+1)
+		var element string
+		switch items[i].(type) {
+		case []interface{}:
+			element = formatArray(items[i].([]interface{}))
+		case int, int64, uint64:
+			element = fmt.Sprintf("(integer) %v", items[i])
+		case nil:
+			element = "(nil)"
+		case error:
+			element = fmt.Sprintf("(error) %v", items[i])
+		case formatted:
+			element = string(items[i].(formatted))
+		default:
+			element = fmt.Sprintf("\"%v\"", items[i])
+		}
+		element = strings.ReplaceAll(element, "\n", "\n"+strings.Repeat(" ", len(prefix)))
+		if i > 0 {
+			builder.WriteString("\n")
+		}
+		builder.WriteString(prefix)
+		builder.WriteString(element)
+	}
+	return builder.String()
+}
 func (l *RList) get(index int) *list.Element {
 	if index < 0 || index > l.Value.Len() {
 		return nil
@@ -598,11 +781,7 @@ func formatIndex(index, length int) (int, error) {
 	}
 	return index, nil
 }
-func (c *cache) LSet(args []string) (response string, err error) {
-	if len(args) != 3 {
-		err = ArgsError{"Expected format: LSET key index element"}
-		return
-	}
+func (c *cache) LSet(args []string) (response interface{}, err error) {
 	c.m.Lock()
 	defer c.m.Unlock()
 	stored := c.read(args[0])
@@ -621,18 +800,16 @@ func (c *cache) LSet(args []string) (response string, err error) {
 		}
 
 		elem.Value = args[2]
-		response = "OK"
+		c.modified(args[0])
+		c.notify(listClass, "lset", args[0])
+		response = formatted("OK")
 	default:
 		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
 		return
 	}
 	return
 }
-func (c *cache) LGet(args []string) (response string, err error) {
-	if len(args) != 2 {
-		err = ArgsError{"Expected format: LGET key index"}
-		return
-	}
+func (c *cache) LGet(args []string) (response interface{}, err error) {
 	c.m.RLock()
 	defer c.m.RUnlock()
 	stored := c.read(args[0])
@@ -657,68 +834,55 @@ func (c *cache) LGet(args []string) (response string, err error) {
 	}
 	return
 }
-func (c *cache) Save(args []string) (response string, err error) {
-	if len(args) != 1 {
-		err = ArgsError{"Expected format: SAVE name"}
-		return
-	}
+func (c *cache) Save(args []string) (response interface{}, err error) {
+	// expirations are saved with the keys
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
 	c.m.RLock()
 	defer c.m.RUnlock()
 	err = Save(c, savepath, args[0])
 	if err != nil {
 		return
 	}
-	response = "OK"
+	response = formatted("OK")
 	return
 }
-func (c *cache) Load(args []string) (response string, err error) {
-	if len(args) != 1 {
-		err = ArgsError{"Expected format: LOAD name"}
-		return
-	}
+func (c *cache) Load(args []string) (response interface{}, err error) {
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
 	c.m.Lock()
 	defer c.m.Unlock()
 	err = Load(c, savepath, args[0])
 	if err != nil {
 		return
 	}
-	response = "OK"
+	response = formatted("OK")
 	return
 }
+
 func (c *cache) HandleRequest(method string, args []string) (response string, err error) {
-	switch method {
-	case "KEYS":
-		return c.Keys(args)
-	case "DEL":
-		return c.Del(args)
-	case "GET":
-		return c.Get(args)
-	case "SET":
-		return c.Set(args)
-	case "HGET":
-		return c.HGet(args)
-	case "HSET":
-		return c.HSet(args)
-	case "LPUSH":
-		return c.LPush(args)
-	case "RPUSH":
-		return c.RPush(args)
-	case "LPOP":
-		return c.LPop(args)
-	case "RPOP":
-		return c.RPop(args)
-	case "LGET":
-		return c.LGet(args)
-	case "LSET":
-		return c.LSet(args)
-	case "EXPIRE":
-		return c.Expire(args)
-	case "SAVE":
-		return c.Save(args)
-	case "LOAD":
-		return c.Load(args)
-	default:
+	reply, err := c.call(method, args)
+	if err != nil {
+		return
+	}
+	response = formatReply(reply)
+	return
+}
+
+// call executes the command and returns its reply as a string, an integer, nil, an error or an array of them
+func (c *cache) call(method string, args []string) (response interface{}, err error) {
+	command, ok := commands[method]
+	if !ok {
 		err = errors.New("method does not exist")
 		return
 	}
+	if command.run == nil {
+		err = errors.New(fmt.Sprintf("%v is not allowed in transactions and scripts", method))
+		return
+	}
+	if err = command.check(args); err != nil {
+		return
+	}
+	c.expireArgs(command.keys(args))
+	return command.run(c, args)
 }
@@ -32,12 +32,12 @@ func BenchmarkSet(b *testing.B) {
 		value := fmt.Sprintf("value%v", i)
 		args := []string{key, value}
 		b.StartTimer()
-		r, err = c.Set(args)
+		r, err = text(c.Set(args))
 		b.StopTimer()
 		if err != nil {
 			b.Error(err)
 		}
-		_, err = c.Del([]string{key})
+		_, err = text(c.Del([]string{key}))
 		if err != nil {
 			b.Error(err)
 		}
@@ -53,7 +53,7 @@ func BenchmarkGet(b *testing.B) {
 		key := fmt.Sprintf("field%v", i)
 		value := fmt.Sprintf("value%v", i)
 		args := []string{key, value}
-		r, err = c.Set(args)
+		r, err = text(c.Set(args))
 		if err != nil {
 			b.Error(err)
 		}
@@ -64,7 +64,7 @@ func BenchmarkGet(b *testing.B) {
 		key := fmt.Sprintf("field%v", i)
 		args := []string{key}
 		b.StartTimer()
-		r, err = c.Get(args)
+		r, err = text(c.Get(args))
 		b.StopTimer()
 		if err != nil {
 			b.Error(err)
@@ -82,7 +82,7 @@ func BenchmarkGetConcurrent(b *testing.B) {
 		key := fmt.Sprintf("field%v", i)
 		value := fmt.Sprintf("value%v", i)
 		args := []string{key, value}
-		r, err = c.Set(args)
+		r, err = text(c.Set(args))
 		if err != nil {
 			b.Error(err)
 		}
@@ -116,7 +116,7 @@ func TestKeys(t *testing.T) {
 	}
 
 	exp := `1) "age"`
-	resp, err := c.Keys([]string{`a??`})
+	resp, err := text(c.Keys([]string{`a??`}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -132,7 +132,7 @@ func TestDel(t *testing.T) {
 		//c.write(keys[i], fields[i], time.Time{})
 	}
 
-	response, err := c.Del([]string{keys[0], "nonexistant", keys[2]})
+	response, err := text(c.Del([]string{keys[0], "nonexistant", keys[2]}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -145,7 +145,7 @@ func TestDel(t *testing.T) {
 		}
 	}
 
-	response, err = c.Del([]string{})
+	response, err = c.HandleRequest("DEL", []string{})
 	switch err.(type) {
 	case ArgsError:
 		break
@@ -162,14 +162,14 @@ func TestGet(t *testing.T) {
 		c.Set([]string{keys[i], fields[i].(string)})
 	}
 
-	resp, err := c.Get([]string{keys[0], keys[1]})
+	resp, err := c.HandleRequest("GET", []string{keys[0], keys[1]})
 	switch err.(type) {
 	case ArgsError:
 		break
 	default:
 		t.Error(err)
 	}
-	resp, err = c.Get([]string{})
+	resp, err = c.HandleRequest("GET", []string{})
 	switch err.(type) {
 	case ArgsError:
 		break
@@ -177,14 +177,14 @@ func TestGet(t *testing.T) {
 		t.Error(err)
 	}
 
-	resp, err = c.Get([]string{keys[0]})
+	resp, err = text(c.Get([]string{keys[0]}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != fields[0] {
 		t.Errorf("expected %v, got %v", fields[0], resp)
 	}
-	resp, err = c.Get([]string{"nonexistant"})
+	resp, err = text(c.Get([]string{"nonexistant"}))
 	if resp != "(nil)" {
 		t.Errorf("expected (nil), got %v", resp)
 	}
@@ -197,7 +197,7 @@ func TestSet(t *testing.T) {
 
 	for i := range keys {
 		referenceMap[keys[i]] = fields[i]
-		resp, err := c.Set([]string{keys[i], fields[i].(string)})
+		resp, err := text(c.Set([]string{keys[i], fields[i].(string)}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -207,7 +207,7 @@ func TestSet(t *testing.T) {
 	}
 
 	referenceMap[keys[2]] = "27"
-	resp, err := c.Set([]string{keys[2], "27"})
+	resp, err := text(c.Set([]string{keys[2], "27"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -215,7 +215,7 @@ func TestSet(t *testing.T) {
 		t.Errorf("expected OK, got %v", resp)
 	}
 
-	_, err = c.Set([]string{"age"})
+	_, err = text(c.Set([]string{"age"}))
 	switch err.(type) {
 	case ArgsError:
 		break
@@ -234,7 +234,7 @@ func TestHSet(t *testing.T) {
 	c := (NewCache()).(*cache)
 
 	for i := range keys {
-		_, err := c.HSet([]string{keys[i], fields[i].(string)})
+		_, err := text(c.HSet([]string{keys[i], fields[i].(string)}))
 		switch err.(type) {
 		case ArgsError:
 			break
@@ -242,7 +242,7 @@ func TestHSet(t *testing.T) {
 			t.Error(err)
 		}
 	}
-	_, err := c.HSet([]string{"key", "hash1", "val1", "hash2"})
+	_, err := text(c.HSet([]string{"key", "hash1", "val1", "hash2"}))
 	switch err.(type) {
 	case ArgsError:
 		break
@@ -254,7 +254,7 @@ func TestHSet(t *testing.T) {
 	h := []string{"hash1", "hash2"}
 	v := []string{"value1", "value2", "value3"}
 
-	resp, err := c.HSet([]string{k[0], h[0], v[0]})
+	resp, err := text(c.HSet([]string{k[0], h[0], v[0]}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -267,7 +267,7 @@ func TestHSet(t *testing.T) {
 		t.Errorf("expected %v, got %v", v[0], val)
 	}
 
-	resp, err = c.HSet([]string{k[0], h[1], v[1], h[0], v[2]})
+	resp, err = text(c.HSet([]string{k[0], h[1], v[1], h[0], v[2]}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -288,7 +288,7 @@ func TestHSet(t *testing.T) {
 func TestHGet(t *testing.T) {
 	c := (NewCache()).(*cache)
 
-	_, err := c.HGet([]string{"key", "hash1", "val1", "hash2"})
+	_, err := c.HandleRequest("HGET", []string{"key", "hash1", "val1", "hash2"})
 	switch err.(type) {
 	case ArgsError:
 		break
@@ -297,7 +297,7 @@ func TestHGet(t *testing.T) {
 	}
 
 	c.Set([]string{keys[0], fields[0].(string)})
-	_, err = c.HGet([]string{keys[0], fields[0].(string)})
+	_, err = text(c.HGet([]string{keys[0], fields[0].(string)}))
 	if err == nil || err.Error() != "Requested field is of type string" {
 		t.Error(err)
 	}
@@ -306,7 +306,7 @@ func TestHGet(t *testing.T) {
 	h := []string{"hash1", "hash2"}
 	v := []string{"value1", "value2", "value3"}
 
-	response, err := c.HGet([]string{k[0], h[0]})
+	response, err := text(c.HGet([]string{k[0], h[0]}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -316,7 +316,7 @@ func TestHGet(t *testing.T) {
 
 	c.HSet([]string{k[0], h[0], v[0]})
 
-	response, err = c.HGet([]string{k[0], h[0]})
+	response, err = text(c.HGet([]string{k[0], h[0]}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -326,11 +326,11 @@ func TestHGet(t *testing.T) {
 
 	c.HSet([]string{k[0], h[1], v[1], h[0], v[2]})
 
-	response, err = c.HGet([]string{k[0], h[0]})
+	response, err = text(c.HGet([]string{k[0], h[0]}))
 	if response != v[2] {
 		t.Errorf("expected %v, got %v", v[2], response)
 	}
-	response, err = c.HGet([]string{k[0], h[1]})
+	response, err = text(c.HGet([]string{k[0], h[1]}))
 	if response != v[1] {
 		t.Errorf("expected %v, got %v", v[1], response)
 	}
@@ -344,14 +344,14 @@ func TestList(t *testing.T) {
 	var arr2 []string
 	n := 10
 	for i := 0; i < n; i += 1 {
-		resp, err := c.LPush([]string{lists[0], fmt.Sprint(n - i - 1)})
+		resp, err := text(c.LPush([]string{lists[0], fmt.Sprint(n - i - 1)}))
 		if err != nil {
 			t.Error(err)
 		}
 		if resp != fmt.Sprintf("(integer) %v", i+1) {
 			t.Errorf("expected resoponse %v, got %v", fmt.Sprintf("(integer) %v", i+1), resp)
 		}
-		resp, err = c.RPush([]string{lists[2], fmt.Sprint(i)})
+		resp, err = text(c.RPush([]string{lists[2], fmt.Sprint(i)}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -361,14 +361,14 @@ func TestList(t *testing.T) {
 		arr1 = append(arr1, fmt.Sprint(i))
 		arr2 = append(arr2, fmt.Sprint(n-i-1))
 	}
-	resp, err := c.LPush(append([]string{lists[1]}, arr2...))
+	resp, err := text(c.LPush(append([]string{lists[1]}, arr2...)))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != fmt.Sprintf("(integer) %v", n) {
 		t.Errorf("expected resoponse %v, got %v", fmt.Sprintf("(integer) %v", n), resp)
 	}
-	resp, err = c.RPush(append([]string{lists[3]}, arr1...))
+	resp, err = text(c.RPush(append([]string{lists[3]}, arr1...)))
 	if err != nil {
 		t.Error(err)
 	}
@@ -379,7 +379,7 @@ func TestList(t *testing.T) {
 	for i := 0; i < n; i += 1 {
 		var prevResp string
 		for j := 0; j < len(lists); j += 1 {
-			resp, err := c.LGet([]string{lists[j], fmt.Sprint(i)})
+			resp, err := text(c.LGet([]string{lists[j], fmt.Sprint(i)}))
 			if err != nil {
 				t.Error(err)
 			}
@@ -390,7 +390,7 @@ func TestList(t *testing.T) {
 			}
 			prevResp = resp
 
-			resp, err = c.LSet([]string{lists[j], fmt.Sprint(i), fmt.Sprint(i * 10)})
+			resp, err = text(c.LSet([]string{lists[j], fmt.Sprint(i), fmt.Sprint(i * 10)}))
 			if err != nil {
 				t.Error(err)
 			}
@@ -403,7 +403,7 @@ func TestList(t *testing.T) {
 	var prevResp string
 	for i := 0; i < n; i += 1 {
 		for j := 0; j < len(lists)/2; j += 1 {
-			resp, err := c.LPop([]string{lists[j]})
+			resp, err := text(c.LPop([]string{lists[j]}))
 			if err != nil {
 				t.Error(err)
 			}
@@ -416,7 +416,7 @@ func TestList(t *testing.T) {
 		}
 	}
 	for j := 0; j < len(lists)/2; j += 1 {
-		resp, err := c.LPop([]string{lists[j]})
+		resp, err := text(c.LPop([]string{lists[j]}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -427,7 +427,7 @@ func TestList(t *testing.T) {
 
 	for i := 0; i < n; i += 1 {
 		for j := len(lists) / 2; j < len(lists); j += 1 {
-			resp, err := c.RPop([]string{lists[j]})
+			resp, err := text(c.RPop([]string{lists[j]}))
 			if err != nil {
 				t.Error(err)
 			}
@@ -440,7 +440,7 @@ func TestList(t *testing.T) {
 		}
 	}
 	for j := len(lists) / 2; j < len(lists); j += 1 {
-		resp, err := c.RPop([]string{lists[j]})
+		resp, err := text(c.RPop([]string{lists[j]}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -460,14 +460,14 @@ func TestRangePop(t *testing.T) {
 		c.RPush([]string{lists[1], fmt.Sprint(i)})
 	}
 
-	resp, err := c.LPop([]string{lists[0], "2"})
+	resp, err := text(c.LPop([]string{lists[0], "2"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "1)0\n2)1\n" {
 		t.Errorf("expected 1)0\n2)1\n got %v", resp)
 	}
-	resp, err = c.RPop([]string{lists[1], "2"})
+	resp, err = text(c.RPop([]string{lists[1], "2"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -475,14 +475,14 @@ func TestRangePop(t *testing.T) {
 		t.Errorf("expected 1)0\n2)1\n got %v", resp)
 	}
 
-	resp, err = c.LPop([]string{lists[0], "0", "-2"})
+	resp, err = text(c.LPop([]string{lists[0], "0", "-2"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "1)2\n2)3\n3)4\n4)5\n5)6\n6)7\n7)8\n" {
 		t.Errorf("expected 1)2\n2)3\n3)4\n4)5\n5)6\n6)7\n7)8\n got %v", resp)
 	}
-	resp, err = c.RPop([]string{lists[1], "0", "-2"})
+	resp, err = text(c.RPop([]string{lists[1], "0", "-2"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -490,23 +490,23 @@ func TestRangePop(t *testing.T) {
 		t.Errorf("expected 1)6\n2)5\n3)4\n4)3\n5)2\n6)1\n7)0\n got %v", resp)
 	}
 
-	resp, err = c.LPop([]string{lists[0], "-1"})
+	resp, err = text(c.LPop([]string{lists[0], "-1"}))
 	if err == nil || err.Error() != "count must be positive" {
 		t.Error(err)
 	}
-	resp, err = c.RPop([]string{lists[1], "-1"})
+	resp, err = text(c.RPop([]string{lists[1], "-1"}))
 	if err == nil || err.Error() != "count must be positive" {
 		t.Error(err)
 	}
 
-	resp, err = c.LPop([]string{lists[0], "2"})
+	resp, err = text(c.LPop([]string{lists[0], "2"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "1)9\n" {
 		t.Errorf("expected 1)9\n got %v", resp)
 	}
-	resp, err = c.RPop([]string{lists[1], "2"})
+	resp, err = text(c.RPop([]string{lists[1], "2"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -520,7 +520,7 @@ func TestExpire(t *testing.T) {
 
 	expireIn := 1
 	for i := range keys {
-		resp, err := c.Set([]string{keys[i], fields[i].(string), "EX", fmt.Sprint(expireIn)})
+		resp, err := text(c.Set([]string{keys[i], fields[i].(string), "EX", fmt.Sprint(expireIn)}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -536,7 +536,7 @@ func TestExpire(t *testing.T) {
 	c.Expire([]string{"list", fmt.Sprint(3 * expireIn)})
 
 	for i := range keys {
-		resp, err := c.Get([]string{keys[i]})
+		resp, err := text(c.Get([]string{keys[i]}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -545,7 +545,7 @@ func TestExpire(t *testing.T) {
 		}
 	}
 
-	resp, err := c.HGet([]string{"hashmap", "hash"})
+	resp, err := text(c.HGet([]string{"hashmap", "hash"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -553,7 +553,7 @@ func TestExpire(t *testing.T) {
 		t.Errorf("expected %v got %v", "value", resp)
 	}
 
-	resp, err = c.LGet([]string{"list", "0"})
+	resp, err = text(c.LGet([]string{"list", "0"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -564,7 +564,7 @@ func TestExpire(t *testing.T) {
 	time.Sleep(time.Duration(2 * expireIn * int(time.Second)))
 
 	for i := range keys {
-		resp, err := c.Get([]string{keys[i]})
+		resp, err := text(c.Get([]string{keys[i]}))
 		if err != nil {
 			t.Error(err)
 		}
@@ -572,7 +572,7 @@ func TestExpire(t *testing.T) {
 			t.Errorf("expected %v got %v", "(nil)", resp)
 		}
 	}
-	resp, err = c.HGet([]string{"hashmap", "hash"})
+	resp, err = text(c.HGet([]string{"hashmap", "hash"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -580,7 +580,7 @@ func TestExpire(t *testing.T) {
 		t.Errorf("expected %v got %v", "(nil)", resp)
 	}
 
-	resp, err = c.LGet([]string{"list", "0"})
+	resp, err = text(c.LGet([]string{"list", "0"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -590,7 +590,7 @@ func TestExpire(t *testing.T) {
 
 	time.Sleep(time.Duration(2 * int(time.Second)))
 
-	resp, err = c.LPop([]string{"list", "0", "-1"})
+	resp, err = text(c.LPop([]string{"list", "0", "-1"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -600,28 +600,28 @@ func TestExpire(t *testing.T) {
 }
 func TestSaveLoad(t *testing.T) {
 	c := (NewCache()).(*cache)
-	resp, err := c.Set([]string{"key", "value"})
+	resp, err := text(c.Set([]string{"key", "value"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "OK" {
 		t.Errorf("expected OK, got %v", resp)
 	}
-	resp, err = c.HSet([]string{"hashmap", "hash1", "val1", "hash2", "val2"})
+	resp, err = text(c.HSet([]string{"hashmap", "hash1", "val1", "hash2", "val2"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "(integer) 2" {
 		t.Errorf("expected (integer) 1, got %v", resp)
 	}
-	resp, err = c.RPush([]string{"list", "1", "2", "3"})
+	resp, err = text(c.RPush([]string{"list", "1", "2", "3"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "(integer) 3" {
 		t.Errorf("expected (integer) 3, got %v", resp)
 	}
-	resp, err = c.Expire([]string{"key", "2000"})
+	resp, err = text(c.Expire([]string{"key", "2000"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -629,7 +629,7 @@ func TestSaveLoad(t *testing.T) {
 		t.Errorf("expected (integer) 1, got %v", resp)
 	}
 
-	resp, err = c.Save([]string{"save1"})
+	resp, err = text(c.Save([]string{"save1"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -638,7 +638,7 @@ func TestSaveLoad(t *testing.T) {
 	}
 
 	c = (NewCache()).(*cache)
-	resp, err = c.Load([]string{"save1"})
+	resp, err = text(c.Load([]string{"save1"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -647,28 +647,28 @@ func TestSaveLoad(t *testing.T) {
 	}
 	go c.StartCleaner()
 
-	resp, err = c.Get([]string{"key"})
+	resp, err = text(c.Get([]string{"key"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "value" {
 		t.Errorf("expected %v, got %v", "value", resp)
 	}
-	resp, err = c.HGet([]string{"hashmap", "hash1"})
+	resp, err = text(c.HGet([]string{"hashmap", "hash1"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "val1" {
 		t.Errorf("expected %v, got %v", "val1", resp)
 	}
-	resp, err = c.HGet([]string{"hashmap", "hash2"})
+	resp, err = text(c.HGet([]string{"hashmap", "hash2"}))
 	if err != nil {
 		t.Error(err)
 	}
 	if resp != "val2" {
 		t.Errorf("expected %v, got %v", "val2", resp)
 	}
-	resp, err = c.LPop([]string{"list", "0", "-1"})
+	resp, err = text(c.LPop([]string{"list", "0", "-1"}))
 	if err != nil {
 		t.Error(err)
 	}
@@ -0,0 +1,238 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+var errCMSNotFound = errors.New("CMS: key does not exist")
+
+// CountMinSketch estimates item frequencies, estimations are never lower than the real counts
+type CountMinSketch struct {
+	Width    uint64
+	Depth    uint64
+	Counters []uint64
+	Count    uint64
+}
+
+func NewCountMinSketch(width, depth uint64) *CountMinSketch {
+	return &CountMinSketch{width, depth, make([]uint64, width*depth), 0}
+}
+
+// index returns the position of the item counter in the given row
+func (s *CountMinSketch) index(h1, h2 uint64, row uint64) uint64 {
+	return row*s.Width + (h1+row*h2)%s.Width
+}
+func (s *CountMinSketch) IncrBy(item string, increment uint64) uint64 {
+	h1, h2 := bloomHashes(item)
+	min := uint64(math.MaxUint64)
+	for row := uint64(0); row < s.Depth; row += 1 {
+		i := s.index(h1, h2, row)
+		s.Counters[i] += increment
+		if s.Counters[i] < min {
+			min = s.Counters[i]
+		}
+	}
+	s.Count += increment
+	return min
+}
+func (s *CountMinSketch) Query(item string) uint64 {
+	h1, h2 := bloomHashes(item)
+	min := uint64(math.MaxUint64)
+	for row := uint64(0); row < s.Depth; row += 1 {
+		if counter := s.Counters[s.index(h1, h2, row)]; counter < min {
+			min = counter
+		}
+	}
+	return min
+}
+
+// Mutex must be locked before calling getCountMinSketch. Returns nil if key is not set
+func (c *cache) getCountMinSketch(key string) (sketch *CountMinSketch, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case *CountMinSketch:
+		sketch = stored.(*CountMinSketch)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) cmsInit(key string, width, depth uint64) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.read(key) != nil {
+		err = errors.New("CMS: key already exists")
+		return
+	}
+	c.write(key, NewCountMinSketch(width, depth))
+	response = formatted("OK")
+	return
+}
+func (c *cache) CMSInitByDim(args []string) (response interface{}, err error) {
+	width, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil || width == 0 {
+		err = errors.New("CMS: invalid width")
+		return
+	}
+	depth, err := strconv.ParseUint(args[2], 10, 64)
+	if err != nil || depth == 0 {
+		err = errors.New("CMS: invalid depth")
+		return
+	}
+	return c.cmsInit(args[0], width, depth)
+}
+
+// CMSInitByProb creates a sketch where the overestimation is at most error * total count
+// with the given probability of failure
+func (c *cache) CMSInitByProb(args []string) (response interface{}, err error) {
+	errorRate, err := strconv.ParseFloat(args[1], 64)
+	if err != nil || errorRate <= 0 || errorRate >= 1 {
+		err = errors.New("CMS: invalid overestimation value")
+		return
+	}
+	probability, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || probability <= 0 || probability >= 1 {
+		err = errors.New("CMS: invalid prob value")
+		return
+	}
+	width := uint64(math.Ceil(2 / errorRate))
+	depth := uint64(math.Ceil(math.Log10(probability) / math.Log10(0.5)))
+	return c.cmsInit(args[0], width, depth)
+}
+func (c *cache) CMSIncrBy(args []string) (response interface{}, err error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		err = syntaxError("CMS.INCRBY")
+		return
+	}
+	increments := make([]uint64, len(args)/2)
+	for i := range increments {
+		increments[i], err = strconv.ParseUint(args[2+2*i], 10, 64)
+		if err != nil {
+			err = errors.New("CMS: Cannot parse number")
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	sketch, err := c.getCountMinSketch(args[0])
+	if err != nil {
+		return
+	}
+	if sketch == nil {
+		err = errCMSNotFound
+		return
+	}
+	counts := make([]interface{}, len(increments))
+	for i := range increments {
+		counts[i] = sketch.IncrBy(args[1+2*i], increments[i])
+	}
+	c.modified(args[0])
+	c.notify(moduleClass, "cms.incrby", args[0])
+	response = counts
+	return
+}
+func (c *cache) CMSQuery(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	sketch, err := c.getCountMinSketch(args[0])
+	if err != nil {
+		return
+	}
+	if sketch == nil {
+		err = errCMSNotFound
+		return
+	}
+	counts := make([]interface{}, len(args)-1)
+	for i, item := range args[1:] {
+		counts[i] = sketch.Query(item)
+	}
+	response = counts
+	return
+}
+
+// CMSMerge sums sketches of the same dimensions into destination, multiplying counters by weights
+func (c *cache) CMSMerge(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("CMS.MERGE")
+	numKeys, err := strconv.Atoi(args[1])
+	if err != nil || numKeys < 1 || len(args) < 2+numKeys {
+		err = formatErr
+		return
+	}
+	sources := args[2 : 2+numKeys]
+	weights := make([]uint64, numKeys)
+	for i := range weights {
+		weights[i] = 1
+	}
+	if rest := args[2+numKeys:]; len(rest) != 0 {
+		if strings.ToUpper(rest[0]) != "WEIGHTS" || len(rest) != numKeys+1 {
+			err = formatErr
+			return
+		}
+		for i := range weights {
+			weights[i], err = strconv.ParseUint(rest[i+1], 10, 64)
+			if err != nil {
+				err = errors.New("CMS: invalid weight value")
+				return
+			}
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	dest, err := c.getCountMinSketch(args[0])
+	if err != nil {
+		return
+	}
+	if dest == nil {
+		err = errCMSNotFound
+		return
+	}
+	sketches := make([]*CountMinSketch, numKeys)
+	for i, key := range sources {
+		sketches[i], err = c.getCountMinSketch(key)
+		if err != nil {
+			return
+		}
+		if sketches[i] == nil {
+			err = errCMSNotFound
+			return
+		}
+		if sketches[i].Width != dest.Width || sketches[i].Depth != dest.Depth {
+			err = errors.New("CMS: width/depth is not equal")
+			return
+		}
+	}
+	// destination may be one of the sources, so the sum is computed separately
+	counters := make([]uint64, len(dest.Counters))
+	count := uint64(0)
+	for i, sketch := range sketches {
+		for j := range counters {
+			counters[j] += sketch.Counters[j] * weights[i]
+		}
+		count += sketch.Count * weights[i]
+	}
+	dest.Counters = counters
+	dest.Count = count
+	c.modified(args[0])
+	c.notify(moduleClass, "cms.merge", args[0])
+	response = formatted("OK")
+	return
+}
+func (c *cache) CMSInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	sketch, err := c.getCountMinSketch(args[0])
+	if err != nil {
+		return
+	}
+	if sketch == nil {
+		err = errCMSNotFound
+		return
+	}
+	response = []interface{}{"width", sketch.Width, "depth", sketch.Depth, "count", sketch.Count}
+	return
+}
@@ -0,0 +1,116 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCMSIncrBy(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.CMSIncrBy([]string{"sketch", "item", "1"}))
+	if err != errCMSNotFound {
+		t.Errorf("expected %v, got %v", errCMSNotFound, err)
+	}
+	resp, err := text(c.CMSInitByDim([]string{"sketch", "2000", "5"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	resp, err = text(c.CMSIncrBy([]string{"sketch", "a", "5", "b", "3"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 5\n2) (integer) 3" {
+		t.Errorf("expected 1) (integer) 5\n2) (integer) 3, got %v", resp)
+	}
+	c.CMSIncrBy([]string{"sketch", "a", "1"})
+	resp, err = text(c.CMSQuery([]string{"sketch", "a", "b", "c"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 6\n2) (integer) 3\n3) (integer) 0" {
+		t.Errorf("expected 1) (integer) 6\n2) (integer) 3\n3) (integer) 0, got %v", resp)
+	}
+}
+
+func TestCMSInitByProb(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.CMSInitByProb([]string{"sketch", "0.001", "0.01"}))
+	if err != nil {
+		t.Error(err)
+	}
+	sketch := c.read("sketch").(*CountMinSketch)
+	if sketch.Width != 2000 || sketch.Depth != 7 {
+		t.Errorf("expected 2000x7 sketch, got %vx%v", sketch.Width, sketch.Depth)
+	}
+	for i := 0; i < 1000; i += 1 {
+		c.CMSIncrBy([]string{"sketch", fmt.Sprintf("item%v", i%100), "1"})
+	}
+	for i := 0; i < 100; i += 1 {
+		if count := sketch.Query(fmt.Sprintf("item%v", i)); count < 10 || count > 11 {
+			t.Errorf("expected estimation of 10, got %v", count)
+		}
+	}
+}
+
+func TestCMSMerge(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.CMSInitByDim([]string{"first", "100", "5"})
+	c.CMSInitByDim([]string{"second", "100", "5"})
+	c.CMSInitByDim([]string{"dest", "100", "5"})
+	c.CMSInitByDim([]string{"small", "10", "5"})
+	c.CMSIncrBy([]string{"first", "a", "2"})
+	c.CMSIncrBy([]string{"second", "a", "3", "b", "1"})
+
+	resp, err := text(c.CMSMerge([]string{"dest", "2", "first", "second", "WEIGHTS", "1", "10"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	resp, err = text(c.CMSQuery([]string{"dest", "a", "b"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 32\n2) (integer) 10" {
+		t.Errorf("expected 1) (integer) 32\n2) (integer) 10, got %v", resp)
+	}
+	_, err = text(c.CMSMerge([]string{"dest", "1", "small"}))
+	if err == nil || err.Error() != "CMS: width/depth is not equal" {
+		t.Error(err)
+	}
+}
+
+func TestCMSSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.CMSInitByDim([]string{"sketch", "100", "5"})
+	c.CMSIncrBy([]string{"sketch", "a", "7"})
+
+	_, err := text(c.Save([]string{"cmssave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"cmssave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.CMSQuery([]string{"sketch", "a"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 7" {
+		t.Errorf("expected 1) (integer) 7, got %v", resp)
+	}
+
+	err = os.Remove("./saves/cmssave")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,399 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// CommandFlags describe how a command accesses keys. They are returned by COMMAND INFO and used by client
+// tracking; built-in commands take the locks they need themselves, only commands of modules are locked by their flags
+type CommandFlags int
+
+const (
+	// FlagWrite commands may change keys. Modules with this flag run with the database locked exclusively
+	FlagWrite CommandFlags = 1 << iota
+	// FlagReadOnly commands only read keys. Modules with this flag run under the read lock and can not change keys
+	FlagReadOnly
+	// FlagFast commands take constant or logarithmic time
+	FlagFast
+	// FlagBlocking commands may wait for other clients
+	FlagBlocking
+	// FlagMovableKeys commands find their keys by parsing arguments, key positions of them are not complete
+	FlagMovableKeys
+)
+
+// flagNames are the names of flags in replies of COMMAND
+var flagNames = []struct {
+	flag CommandFlags
+	name string
+}{
+	{FlagWrite, "write"},
+	{FlagReadOnly, "readonly"},
+	{FlagFast, "fast"},
+	{FlagBlocking, "blocking"},
+	{FlagMovableKeys, "movablekeys"},
+}
+
+// Command describes a command of the command table. Arity counts the name of the command, a negative arity is
+// the minimal number of arguments. FirstKey, LastKey and KeyStep are positions of keys in the same numbering,
+// LastKey may count from the end with -1 being the last argument, FirstKey is 0 for commands without keys.
+// Syntax is returned by HELP and in errors of malformed requests. Commands of modules are run by Handler, which
+// returns a string, an integer, nil, an error or an array of them as the reply
+type Command struct {
+	Name     string
+	Arity    int
+	Flags    CommandFlags
+	FirstKey int
+	LastKey  int
+	KeyStep  int
+	Syntax   string
+	Handler  func(keyspace Keyspace, args []string) (interface{}, error)
+	// run executes the command on a database, serve executes it on all databases for the session. A command has
+	// one of them, commands with serve are not allowed in scripts and only some of them are queued in transactions
+	run   func(c *cache, args []string) (interface{}, error)
+	serve func(d *databases, session Session, args []string) (interface{}, error)
+}
+
+// commands is the command table. It is filled by init, because commands refer to functions using it
+var commands = make(map[string]*Command)
+
+// keys of commands given as FirstKey, LastKey and KeyStep
+var (
+	noKeys    = [3]int{0, 0, 0}
+	firstKey  = [3]int{1, 1, 1}
+	allKeys   = [3]int{1, -1, 1}
+	secondKey = [3]int{2, 2, 1}
+	twoKeys   = [3]int{1, 2, 1}
+)
+
+// newCommand describes a command, its name is the first word of the syntax
+func newCommand(syntax string, arity int, flags CommandFlags, keys [3]int) *Command {
+	return &Command{
+		Name:     strings.Fields(syntax)[0],
+		Arity:    arity,
+		Flags:    flags,
+		FirstKey: keys[0],
+		LastKey:  keys[1],
+		KeyStep:  keys[2],
+		Syntax:   syntax,
+	}
+}
+func (command *Command) runs(run func(c *cache, args []string) (interface{}, error)) *Command {
+	command.run = run
+	return command
+}
+func (command *Command) serves(serve func(d *databases, session Session, args []string) (interface{}, error)) *Command {
+	command.serve = serve
+	return command
+}
+
+func init() {
+	for _, command := range []*Command{
+		newCommand("KEYS pattern", 2, FlagReadOnly, noKeys).runs((*cache).Keys),
+		newCommand("DEL key [key ...]", -2, FlagWrite, allKeys).runs((*cache).Del),
+		newCommand("GET key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).Get),
+		newCommand("SET key value [EX seconds]", -3, FlagWrite, firstKey).runs((*cache).Set),
+		newCommand("HGET key field", 3, FlagReadOnly|FlagFast, firstKey).runs((*cache).HGet),
+		newCommand("HSET key field value [field value ...]", -4, FlagWrite|FlagFast, firstKey).runs((*cache).HSet),
+		newCommand("LPUSH key element [element ...]", -3, FlagWrite|FlagFast, firstKey).runs((*cache).LPush),
+		newCommand("RPUSH key element [element ...]", -3, FlagWrite|FlagFast, firstKey).runs((*cache).RPush),
+		newCommand("LPOP key [count]", -2, FlagWrite|FlagFast, firstKey).runs((*cache).LPop),
+		newCommand("RPOP key [count]", -2, FlagWrite|FlagFast, firstKey).runs((*cache).RPop),
+		newCommand("LGET key index", 3, FlagReadOnly, firstKey).runs((*cache).LGet),
+		newCommand("LSET key index element", 4, FlagWrite, firstKey).runs((*cache).LSet),
+		newCommand("EXPIRE key seconds", 3, FlagWrite|FlagFast, firstKey).runs((*cache).Expire),
+		newCommand("SAVE name", 2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.Save(args)
+		}),
+		newCommand("LOAD name", 2, FlagWrite, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.Load(args)
+		}),
+
+		newCommand("XADD key [NOMKSTREAM] [MAXLEN|MINID [=|~] threshold [LIMIT count]] *|id field value [field value ...]", -5, FlagWrite|FlagFast, firstKey).runs((*cache).XAdd),
+		newCommand("XLEN key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).XLen),
+		newCommand("XRANGE key start end [COUNT count]", -4, FlagReadOnly, firstKey).runs((*cache).XRange),
+		newCommand("XREVRANGE key end start [COUNT count]", -4, FlagReadOnly, firstKey).runs((*cache).XRevRange),
+		newCommand("XTRIM key MAXLEN|MINID [=|~] threshold [LIMIT count]", -4, FlagWrite, firstKey).runs((*cache).XTrim),
+		newCommand("XREAD [COUNT count] [BLOCK milliseconds] STREAMS key [key ...] id [id ...]", -4, FlagReadOnly|FlagBlocking|FlagMovableKeys, noKeys).runs((*cache).XRead),
+		newCommand("XGROUP CREATE key group id|$ [MKSTREAM] | SETID key group id|$ | DESTROY key group | CREATECONSUMER key group consumer | DELCONSUMER key group consumer", -4, FlagWrite, secondKey).runs((*cache).XGroup),
+		newCommand("XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]", -7, FlagWrite|FlagBlocking|FlagMovableKeys, noKeys).runs((*cache).XReadGroup),
+		newCommand("XACK key group id [id ...]", -4, FlagWrite|FlagFast, firstKey).runs((*cache).XAck),
+		newCommand("XPENDING key group [[IDLE min-idle-time] start end count [consumer]]", -3, FlagReadOnly, firstKey).runs((*cache).XPending),
+		newCommand("XCLAIM key group consumer min-idle-time id [id ...] [IDLE ms] [TIME unix-time-milliseconds] [RETRYCOUNT count] [FORCE] [JUSTID]", -6, FlagWrite|FlagFast, firstKey).runs((*cache).XClaim),
+		newCommand("XAUTOCLAIM key group consumer min-idle-time start [COUNT count] [JUSTID]", -6, FlagWrite|FlagFast, firstKey).runs((*cache).XAutoClaim),
+
+		newCommand("PFADD key [element [element ...]]", -2, FlagWrite|FlagFast, firstKey).runs((*cache).PFAdd),
+		newCommand("PFCOUNT key [key ...]", -2, FlagReadOnly, allKeys).runs((*cache).PFCount),
+		newCommand("PFMERGE destkey [sourcekey [sourcekey ...]]", -2, FlagWrite, allKeys).runs((*cache).PFMerge),
+
+		newCommand("BF.RESERVE key error_rate capacity [EXPANSION expansion] [NONSCALING]", -4, FlagWrite, firstKey).runs((*cache).BFReserve),
+		newCommand("BF.ADD key item", 3, FlagWrite|FlagFast, firstKey).runs((*cache).BFAdd),
+		newCommand("BF.MADD key item [item ...]", -3, FlagWrite|FlagFast, firstKey).runs((*cache).BFMAdd),
+		newCommand("BF.EXISTS key item", 3, FlagReadOnly|FlagFast, firstKey).runs((*cache).BFExists),
+		newCommand("BF.MEXISTS key item [item ...]", -3, FlagReadOnly|FlagFast, firstKey).runs((*cache).BFMExists),
+		newCommand("BF.INFO key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).BFInfo),
+
+		newCommand("CF.RESERVE key capacity [BUCKETSIZE bucketsize] [MAXITERATIONS maxiterations] [EXPANSION expansion]", -3, FlagWrite, firstKey).runs((*cache).CFReserve),
+		newCommand("CF.ADD key item", 3, FlagWrite|FlagFast, firstKey).runs((*cache).CFAdd),
+		newCommand("CF.ADDNX key item", 3, FlagWrite|FlagFast, firstKey).runs((*cache).CFAddNX),
+		newCommand("CF.DEL key item", 3, FlagWrite|FlagFast, firstKey).runs((*cache).CFDel),
+		newCommand("CF.EXISTS key item", 3, FlagReadOnly|FlagFast, firstKey).runs((*cache).CFExists),
+		newCommand("CF.COUNT key item", 3, FlagReadOnly|FlagFast, firstKey).runs((*cache).CFCount),
+		newCommand("CF.INFO key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).CFInfo),
+
+		newCommand("CMS.INITBYDIM key width depth", 4, FlagWrite|FlagFast, firstKey).runs((*cache).CMSInitByDim),
+		newCommand("CMS.INITBYPROB key error probability", 4, FlagWrite|FlagFast, firstKey).runs((*cache).CMSInitByProb),
+		newCommand("CMS.INCRBY key item increment [item increment ...]", -4, FlagWrite|FlagFast, firstKey).runs((*cache).CMSIncrBy),
+		newCommand("CMS.QUERY key item [item ...]", -3, FlagReadOnly|FlagFast, firstKey).runs((*cache).CMSQuery),
+		newCommand("CMS.MERGE destination numKeys source [source ...] [WEIGHTS weight [weight ...]]", -4, FlagWrite|FlagMovableKeys, firstKey).runs((*cache).CMSMerge),
+		newCommand("CMS.INFO key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).CMSInfo),
+
+		newCommand("TOPK.RESERVE key topk [width depth decay]", -3, FlagWrite, firstKey).runs((*cache).TopKReserve),
+		newCommand("TOPK.ADD key item [item ...]", -3, FlagWrite, firstKey).runs((*cache).TopKAdd),
+		newCommand("TOPK.INCRBY key item increment [item increment ...]", -4, FlagWrite, firstKey).runs((*cache).TopKIncrBy),
+		newCommand("TOPK.QUERY key item [item ...]", -3, FlagReadOnly, firstKey).runs((*cache).TopKQuery),
+		newCommand("TOPK.COUNT key item [item ...]", -3, FlagReadOnly, firstKey).runs((*cache).TopKCount),
+		newCommand("TOPK.LIST key [WITHCOUNT]", -2, FlagReadOnly, firstKey).runs((*cache).TopKList),
+		newCommand("TOPK.INFO key", 2, FlagReadOnly, firstKey).runs((*cache).TopKInfo),
+
+		newCommand("SETBIT key offset value", 4, FlagWrite, firstKey).runs((*cache).SetBit),
+		newCommand("GETBIT key offset", 3, FlagReadOnly|FlagFast, firstKey).runs((*cache).GetBit),
+		newCommand("BITCOUNT key [start end [BYTE|BIT]]", -2, FlagReadOnly, firstKey).runs((*cache).BitCount),
+		newCommand("BITPOS key bit [start [end [BYTE|BIT]]]", -3, FlagReadOnly, firstKey).runs((*cache).BitPos),
+		newCommand("BITOP AND|OR|XOR|NOT destkey key [key ...]", -4, FlagWrite, [3]int{2, -1, 1}).runs((*cache).BitOp),
+		newCommand("BITFIELD key [GET type offset] [SET type offset value] [INCRBY type offset increment] [OVERFLOW WRAP|SAT|FAIL]", -2, FlagWrite, firstKey).runs((*cache).BitField),
+		newCommand("BITFIELD_RO key [GET type offset ...]", -2, FlagReadOnly|FlagFast, firstKey).runs((*cache).BitFieldRO),
+
+		newCommand("JSON.SET key path value [NX|XX]", -4, FlagWrite, firstKey).runs((*cache).JSONSet),
+		newCommand("JSON.GET key [path [path ...]]", -2, FlagReadOnly, firstKey).runs((*cache).JSONGet),
+		newCommand("JSON.DEL key [path]", -2, FlagWrite, firstKey).runs((*cache).JSONDel),
+		newCommand("JSON.NUMINCRBY key path value", 4, FlagWrite, firstKey).runs((*cache).JSONNumIncrBy),
+		newCommand("JSON.ARRAPPEND key path value [value ...]", -4, FlagWrite, firstKey).runs((*cache).JSONArrAppend),
+
+		newCommand("TS.CREATE key [RETENTION retentionPeriod] [DUPLICATE_POLICY policy] [LABELS label value ...]", -2, FlagWrite, firstKey).runs((*cache).TSCreate),
+		newCommand("TS.ADD key timestamp|* value [RETENTION retentionPeriod] [ON_DUPLICATE policy] [LABELS label value ...]", -4, FlagWrite, firstKey).runs((*cache).TSAdd),
+		newCommand("TS.GET key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).TSGet),
+		newCommand("TS.RANGE key fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]", -4, FlagReadOnly, firstKey).runs((*cache).TSRange),
+		newCommand("TS.REVRANGE key fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration]", -4, FlagReadOnly, firstKey).runs((*cache).TSRevRange),
+		newCommand("TS.MRANGE fromTimestamp toTimestamp [COUNT count] [AGGREGATION aggregator bucketDuration] [WITHLABELS] FILTER filter ...", -3, FlagReadOnly, noKeys).runs((*cache).TSMRange),
+		newCommand("TS.CREATERULE sourceKey destKey AGGREGATION aggregator bucketDuration", 6, FlagWrite, twoKeys).runs((*cache).TSCreateRule),
+		newCommand("TS.DELETERULE sourceKey destKey", 3, FlagWrite, twoKeys).runs((*cache).TSDeleteRule),
+		newCommand("TS.INFO key", 2, FlagReadOnly, firstKey).runs((*cache).TSInfo),
+
+		newCommand("VADD key VALUES dim value [value ...] element [DISTANCE COSINE|L2|IP] [ALGORITHM FLAT|HNSW] [M m] [EF ef]", -5, FlagWrite, firstKey).runs((*cache).VAdd),
+		newCommand("VREM key element", 3, FlagWrite, firstKey).runs((*cache).VRem),
+		newCommand("VSIM key ELE element|VALUES dim value [value ...] [WITHSCORES] [COUNT count] [EF ef]", -4, FlagReadOnly, firstKey).runs((*cache).VSim),
+		newCommand("VEMB key element", 3, FlagReadOnly, firstKey).runs((*cache).VEmb),
+		newCommand("VCARD key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).VCard),
+		newCommand("VINFO key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).VInfo),
+
+		newCommand("FT.CREATE index [ON HASH] [PREFIX count prefix [prefix ...]] [STOPWORDS count [word ...]] SCHEMA field TAG [SEPARATOR separator]|NUMERIC|TEXT [WEIGHT weight] [NOSTEM] [field ...]", -4, FlagWrite, noKeys).runs((*cache).FTCreate),
+		newCommand("FT.SEARCH index query [NOCONTENT] [WITHSCORES] [RETURN count field [field ...]] [SORTBY field [ASC|DESC]] [LIMIT offset num]", -3, FlagReadOnly, noKeys).runs((*cache).FTSearch),
+		newCommand("FT.DROPINDEX index [DD]", -2, FlagWrite, noKeys).runs((*cache).FTDropIndex),
+		newCommand("FT.INFO index", 2, FlagReadOnly, noKeys).runs((*cache).FTInfo),
+
+		newCommand("SCAN cursor [MATCH pattern] [COUNT count] [TYPE type]", -2, FlagReadOnly, noKeys).runs((*cache).Scan),
+		newCommand("HSCAN key cursor [MATCH pattern] [COUNT count] [NOVALUES]", -3, FlagReadOnly, firstKey).runs((*cache).HScan),
+		newCommand("EXISTS key [key ...]", -2, FlagReadOnly|FlagFast, allKeys).runs((*cache).Exists),
+		newCommand("TYPE key", 2, FlagReadOnly|FlagFast, firstKey).runs((*cache).Type),
+		newCommand("RENAME key newkey", 3, FlagWrite, twoKeys).runs((*cache).Rename),
+		newCommand("RENAMENX key newkey", 3, FlagWrite|FlagFast, twoKeys).runs((*cache).RenameNX),
+		newCommand("COPY source destination [REPLACE]", -3, FlagWrite, twoKeys).runs((*cache).Copy),
+		newCommand("RANDOMKEY", 1, FlagReadOnly, noKeys).runs((*cache).RandomKey),
+		newCommand("DBSIZE", 1, FlagReadOnly|FlagFast, noKeys).runs((*cache).DBSize),
+		newCommand("TOUCH key [key ...]", -2, FlagReadOnly|FlagFast, allKeys).runs((*cache).Touch),
+		newCommand("UNLINK key [key ...]", -2, FlagWrite|FlagFast, allKeys).runs((*cache).Unlink),
+		newCommand("FLUSHDB [ASYNC|SYNC]", -1, FlagWrite, noKeys).runs((*cache).FlushDB),
+		newCommand("FLUSHALL [ASYNC|SYNC]", -1, FlagWrite, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.FlushAll(args)
+		}),
+		newCommand("SWAPDB index1 index2", 3, FlagWrite|FlagFast, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.SwapDB(args)
+		}),
+		newCommand("MOVE key db", 3, FlagWrite|FlagFast, firstKey).serves((*databases).Move),
+
+		newCommand("MULTI", 1, FlagFast, noKeys).serves((*databases).Multi),
+		newCommand("EXEC", 1, 0, noKeys).serves((*databases).Exec),
+		newCommand("DISCARD", 1, FlagFast, noKeys).serves((*databases).Discard),
+		newCommand("SELECT index", 2, FlagFast, noKeys).serves((*databases).Select),
+		newCommand("WATCH key [key ...]", -2, FlagFast, allKeys).serves((*databases).Watch),
+		newCommand("UNWATCH", 1, FlagFast, noKeys).serves((*databases).Unwatch),
+
+		newCommand("SUBSCRIBE channel [channel ...]", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.Subscribe(session, args)
+		}),
+		newCommand("PSUBSCRIBE pattern [pattern ...]", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.PSubscribe(session, args)
+		}),
+		newCommand("SSUBSCRIBE shardchannel [shardchannel ...]", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.SSubscribe(session, args)
+		}),
+		newCommand("UNSUBSCRIBE [channel [channel ...]]", -1, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.Unsubscribe(session, args)
+		}),
+		newCommand("PUNSUBSCRIBE [pattern [pattern ...]]", -1, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.PUnsubscribe(session, args)
+		}),
+		newCommand("SUNSUBSCRIBE [shardchannel [shardchannel ...]]", -1, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.SUnsubscribe(session, args)
+		}),
+		newCommand("PUBLISH channel message", 3, FlagFast, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.Publish(args)
+		}),
+		newCommand("SPUBLISH shardchannel message", 3, FlagFast, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.SPublish(args)
+		}),
+		newCommand("PUBSUB CHANNELS|SHARDCHANNELS [pattern]", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.pubsub.PubSub(args)
+		}),
+		newCommand("CONFIG GET notify-keyspace-events | CONFIG SET notify-keyspace-events classes", -3, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.events.Config(args)
+		}),
+
+		newCommand("CLIENT TRACKING ON [BCAST] [PREFIX prefix [PREFIX prefix ...]] | CLIENT TRACKING OFF | CLIENT TRACKINGINFO", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.tracking.Client(session, args)
+		}),
+
+		newCommand("EVAL script numkeys [key ...] [arg ...]", -3, FlagMovableKeys, noKeys).serves((*databases).Eval),
+		newCommand("EVALSHA sha1 numkeys [key ...] [arg ...]", -3, FlagMovableKeys, noKeys).serves((*databases).EvalSHA),
+		newCommand("FCALL function numkeys [key ...] [arg ...]", -3, FlagMovableKeys, noKeys).serves((*databases).FCall),
+		newCommand("SCRIPT LOAD script | SCRIPT EXISTS sha1 [sha1 ...] | SCRIPT FLUSH [ASYNC|SYNC] | SCRIPT KILL", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.scripts.Script(args)
+		}),
+		newCommand("FUNCTION LOAD [REPLACE] code | FUNCTION DELETE library | FUNCTION FLUSH [ASYNC|SYNC] | FUNCTION LIST [LIBRARYNAME pattern] [WITHCODE] | FUNCTION KILL", -2, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return d.scripts.Function(args)
+		}),
+
+		newCommand("COMMAND [COUNT | LIST | INFO [command ...] | DOCS [command ...]]", -1, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return commandCommand(args)
+		}),
+		newCommand("HELP [command ...]", -1, 0, noKeys).serves(func(d *databases, session Session, args []string) (interface{}, error) {
+			return help(args)
+		}),
+	} {
+		commands[command.Name] = command
+	}
+}
+
+// syntaxError returns the error of a malformed request of the command
+func syntaxError(name string) error {
+	command, ok := commands[name]
+	if !ok || command.Syntax == "" {
+		return ArgsError{fmt.Sprintf("wrong number of arguments for '%v' command", strings.ToLower(name))}
+	}
+	return ArgsError{"Expected format: " + command.Syntax}
+}
+
+// check returns an error if the number of arguments does not match the arity of the command
+func (command *Command) check(args []string) error {
+	if command.Arity > 0 && len(args)+1 != command.Arity || command.Arity < 0 && len(args)+1 < -command.Arity {
+		return syntaxError(command.Name)
+	}
+	return nil
+}
+
+// keys returns the arguments of the command at key positions. For commands with movable keys only keys at fixed
+// positions are returned, other keys are found by the commands themselves
+func (command *Command) keys(args []string) []string {
+	if command.FirstKey == 0 {
+		return nil
+	}
+	last := command.LastKey
+	if last < 0 {
+		last = len(args) + 1 + last
+	}
+	var keys []string
+	for i := command.FirstKey; i <= last && i <= len(args); i += command.KeyStep {
+		keys = append(keys, args[i-1])
+	}
+	return keys
+}
+
+// info describes the command in replies of COMMAND as the name, arity, flags and key positions
+func (command *Command) info() []interface{} {
+	flags := make([]interface{}, 0)
+	for _, flag := range flagNames {
+		if command.Flags&flag.flag != 0 {
+			flags = append(flags, flag.name)
+		}
+	}
+	return []interface{}{strings.ToLower(command.Name), command.Arity, flags, command.FirstKey, command.LastKey, command.KeyStep}
+}
+
+// sortedCommands returns the commands given by names or all commands sorted by name. Unknown names give nil
+func sortedCommands(names []string) []*Command {
+	if len(names) > 0 {
+		res := make([]*Command, len(names))
+		for i, name := range names {
+			res[i] = commands[strings.ToUpper(name)]
+		}
+		return res
+	}
+	res := make([]*Command, 0, len(commands))
+	for _, command := range commands {
+		res = append(res, command)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Name < res[j].Name
+	})
+	return res
+}
+
+// commandCommand describes commands of the command table
+func commandCommand(args []string) (response interface{}, err error) {
+	subcommand := ""
+	if len(args) > 0 {
+		subcommand = strings.ToUpper(args[0])
+	}
+	var res []interface{}
+	switch {
+	case subcommand == "":
+		for _, command := range sortedCommands(nil) {
+			res = append(res, command.info())
+		}
+	case subcommand == "COUNT" && len(args) == 1:
+		response = int64(len(commands))
+		return
+	case subcommand == "LIST" && len(args) == 1:
+		for _, command := range sortedCommands(nil) {
+			res = append(res, strings.ToLower(command.Name))
+		}
+	case subcommand == "INFO":
+		for _, command := range sortedCommands(args[1:]) {
+			if command == nil {
+				res = append(res, nil)
+			} else {
+				res = append(res, command.info())
+			}
+		}
+	case subcommand == "DOCS":
+		for _, command := range sortedCommands(args[1:]) {
+			if command != nil {
+				res = append(res, strings.ToLower(command.Name), []interface{}{"syntax", command.Syntax})
+			}
+		}
+	default:
+		err = syntaxError("COMMAND")
+		return
+	}
+	response = res
+	return
+}
+
+// help returns the syntax of the given commands or of all commands
+func help(args []string) (response interface{}, err error) {
+	var res []interface{}
+	for i, command := range sortedCommands(args) {
+		if command == nil {
+			err = errors.New(fmt.Sprintf("unknown command '%v'", args[i]))
+			return
+		}
+		if command.Syntax != "" {
+			res = append(res, command.Syntax)
+		}
+	}
+	response = res
+	return
+}
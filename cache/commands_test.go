@@ -0,0 +1,113 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCommandTable(t *testing.T) {
+	for name, command := range commands {
+		if (command.run == nil) == (command.serve == nil) {
+			t.Errorf("%v must have either run or serve", name)
+		}
+		if command.Syntax != "" && strings.Fields(command.Syntax)[0] != name {
+			t.Errorf("%v has syntax of another command: %v", name, command.Syntax)
+		}
+		if command.Arity == 0 {
+			t.Errorf("%v has no arity", name)
+		}
+	}
+	keys := []struct {
+		method   string
+		args     []string
+		expected []string
+	}{
+		{"GET", []string{"a"}, []string{"a"}},
+		{"DEL", []string{"a", "b", "c"}, []string{"a", "b", "c"}},
+		{"BITOP", []string{"AND", "dest", "a", "b"}, []string{"dest", "a", "b"}},
+		{"TS.CREATERULE", []string{"src", "dst", "AGGREGATION", "avg", "10"}, []string{"src", "dst"}},
+		{"KEYS", []string{"*"}, nil},
+		{"XREAD", []string{"STREAMS", "s", "0"}, nil},
+		{"CMS.MERGE", []string{"dest", "2", "a", "b", "WEIGHTS", "1", "2"}, []string{"dest"}},
+	}
+	for _, test := range keys {
+		if res := commands[test.method].keys(test.args); strings.Join(res, " ") != strings.Join(test.expected, " ") {
+			t.Errorf("%v %v: expected keys %v, got %v", test.method, test.args, test.expected, res)
+		}
+	}
+}
+
+func TestCommandValidation(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+		err      string
+	}{
+		{"GET", nil, "", "Expected format: GET key"},
+		{"GET", []string{"a", "b"}, "", "Expected format: GET key"},
+		{"SET", []string{"a"}, "", "Expected format: SET key value [EX seconds]"},
+		{"XREADGROUP", []string{"GROUP", "g"}, "", "Expected format: XREADGROUP GROUP group consumer [COUNT count] [BLOCK milliseconds] [NOACK] STREAMS key [key ...] id [id ...]"},
+		{"PUBLISH", []string{"channel"}, "", "Expected format: PUBLISH channel message"},
+		{"NOSUCHCOMMAND", nil, "", "method does not exist"},
+		{"MULTI", nil, "OK", ""},
+		{"SET", []string{"a", "1"}, "QUEUED", ""},
+		{"HGET", []string{"a"}, "", "Expected format: HGET key field"},
+		{"EXEC", nil, "", errExecAbort.Error()},
+		{"MULTI", nil, "OK", ""},
+		{"FLUSHALL", nil, "", "FLUSHALL is not allowed in transactions"},
+		{"DISCARD", nil, "OK", ""},
+		{"EVAL", []string{"return cache.pcall('GET').err", "0"}, "Expected format: GET key", ""},
+		{"EVAL", []string{"return cache.pcall('SELECT', 0).err", "0"}, "SELECT is not allowed in scripts", ""},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if resp != step.expected || (err == nil) != (step.err == "") || err != nil && err.Error() != step.err {
+			t.Errorf("%v %v: expected %v %v, got %v %v", step.method, step.args, step.expected, step.err, resp, err)
+		}
+	}
+}
+
+func TestCommandInfo(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	steps := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"INFO", "get", "missing"}, "1) 1) \"get\"\n   2) (integer) 2\n   3) 1) \"readonly\"\n      2) \"fast\"\n   4) (integer) 1\n   5) (integer) 1\n   6) (integer) 1\n2) (nil)"},
+		{[]string{"INFO", "mset"}, "1) (nil)"},
+		{[]string{"DOCS", "del"}, "1) \"del\"\n2) 1) \"syntax\"\n   2) \"DEL key [key ...]\""},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, "COMMAND", step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v: expected %v, got %v %v", step.args, step.expected, resp, err)
+		}
+	}
+	resp, err := d.HandleRequest(session, "COMMAND", []string{"LIST"})
+	if err != nil || !strings.HasPrefix(resp, "1) \"bf.add\"") || !strings.Contains(resp, "\"xread\"") {
+		t.Errorf("unexpected command list %v %v", resp, err)
+	}
+	resp, err = d.HandleRequest(session, "COMMAND", []string{"COUNT"})
+	if err != nil || resp == "(integer) 0" {
+		t.Errorf("unexpected command count %v %v", resp, err)
+	}
+	if _, err = d.HandleRequest(session, "COMMAND", []string{"NOSUCHSUBCOMMAND"}); err == nil {
+		t.Errorf("expected syntax error")
+	}
+
+	resp, err = d.HandleRequest(session, "HELP", []string{"get", "SET"})
+	if err != nil || resp != "1) \"GET key\"\n2) \"SET key value [EX seconds]\"" {
+		t.Errorf("unexpected help %v %v", resp, err)
+	}
+	if _, err = d.HandleRequest(session, "HELP", []string{"missing"}); err == nil {
+		t.Errorf("expected unknown command error")
+	}
+	resp, err = d.HandleRequest(session, "HELP", nil)
+	if err != nil || !strings.Contains(resp, "\"XADD key [NOMKSTREAM]") {
+		t.Errorf("unexpected help %v %v", resp, err)
+	}
+}
@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+	"strconv"
+	"strings"
+)
+
+const (
+	cuckooDefaultCapacity      = 1024
+	cuckooDefaultBucketSize    = 2
+	cuckooDefaultMaxIterations = 20
+	cuckooDefaultExpansion     = 1
+)
+
+// CuckooLayer is a fixed size cuckoo filter storing 8 bit fingerprints, zero marks an empty slot.
+// The number of buckets is a power of two so the alternative bucket can be computed from either one
+type CuckooLayer struct {
+	Slots      []byte
+	NumBuckets uint64
+}
+
+func NewCuckooLayer(capacity, bucketSize int) *CuckooLayer {
+	numBuckets := uint64(1)
+	for numBuckets*uint64(bucketSize) < uint64(capacity) {
+		numBuckets <<= 1
+	}
+	return &CuckooLayer{make([]byte, numBuckets*uint64(bucketSize)), numBuckets}
+}
+
+// cuckooHash returns the hash of the item and its fingerprint
+func cuckooHash(item string) (uint64, byte) {
+	hash := murmurHash64A([]byte(item), 0x5f4e3d2c)
+	return hash, byte((hash>>32)%255 + 1)
+}
+func (l *CuckooLayer) index(hash uint64) uint64 {
+	return hash & (l.NumBuckets - 1)
+}
+func (l *CuckooLayer) altIndex(index uint64, fp byte) uint64 {
+	return (index ^ (uint64(fp) * 0x5bd1e995)) & (l.NumBuckets - 1)
+}
+func (l *CuckooLayer) bucket(index uint64, bucketSize int) []byte {
+	return l.Slots[index*uint64(bucketSize) : (index+1)*uint64(bucketSize)]
+}
+func (l *CuckooLayer) count(hash uint64, fp byte, bucketSize int) int {
+	i1 := l.index(hash)
+	i2 := l.altIndex(i1, fp)
+	n := 0
+	for _, slot := range l.bucket(i1, bucketSize) {
+		if slot == fp {
+			n += 1
+		}
+	}
+	if i2 != i1 {
+		for _, slot := range l.bucket(i2, bucketSize) {
+			if slot == fp {
+				n += 1
+			}
+		}
+	}
+	return n
+}
+func (l *CuckooLayer) remove(hash uint64, fp byte, bucketSize int) bool {
+	i1 := l.index(hash)
+	for _, index := range []uint64{i1, l.altIndex(i1, fp)} {
+		bucket := l.bucket(index, bucketSize)
+		for i := range bucket {
+			if bucket[i] == fp {
+				bucket[i] = 0
+				return true
+			}
+		}
+	}
+	return false
+}
+func (l *CuckooLayer) tryInsert(index uint64, fp byte, bucketSize int) bool {
+	bucket := l.bucket(index, bucketSize)
+	for i := range bucket {
+		if bucket[i] == 0 {
+			bucket[i] = fp
+			return true
+		}
+	}
+	return false
+}
+
+// insert places the fingerprint, relocating other fingerprints at most maxIterations times.
+// If no place is found all relocations are reverted and false is returned
+func (l *CuckooLayer) insert(hash uint64, fp byte, bucketSize, maxIterations int) bool {
+	i1 := l.index(hash)
+	i2 := l.altIndex(i1, fp)
+	if l.tryInsert(i1, fp, bucketSize) || l.tryInsert(i2, fp, bucketSize) {
+		return true
+	}
+	type relocation struct {
+		slot uint64
+		fp   byte
+	}
+	path := make([]relocation, 0, maxIterations)
+	index := i1
+	if rand.Intn(2) == 1 {
+		index = i2
+	}
+	for n := 0; n < maxIterations; n += 1 {
+		slot := index*uint64(bucketSize) + uint64(rand.Intn(bucketSize))
+		path = append(path, relocation{slot, l.Slots[slot]})
+		fp, l.Slots[slot] = l.Slots[slot], fp
+		index = l.altIndex(index, fp)
+		if l.tryInsert(index, fp, bucketSize) {
+			return true
+		}
+	}
+	for i := len(path) - 1; i >= 0; i -= 1 {
+		l.Slots[path[i].slot] = path[i].fp
+	}
+	return false
+}
+
+// CuckooFilter is a scalable cuckoo filter supporting deletion: when an item can not be placed
+// a new layer is added, unless expansion is 0
+type CuckooFilter struct {
+	Layers        []*CuckooLayer
+	BucketSize    int
+	MaxIterations int
+	Expansion     int
+	Items         int
+	Deleted       int
+}
+
+func NewCuckooFilter(capacity, bucketSize, maxIterations, expansion int) *CuckooFilter {
+	return &CuckooFilter{
+		Layers:        []*CuckooLayer{NewCuckooLayer(capacity, bucketSize)},
+		BucketSize:    bucketSize,
+		MaxIterations: maxIterations,
+		Expansion:     expansion,
+	}
+}
+
+func (f *CuckooFilter) Count(item string) int {
+	hash, fp := cuckooHash(item)
+	n := 0
+	for _, layer := range f.Layers {
+		n += layer.count(hash, fp, f.BucketSize)
+	}
+	return n
+}
+func (f *CuckooFilter) Add(item string) error {
+	hash, fp := cuckooHash(item)
+	for i := len(f.Layers) - 1; i >= 0; i -= 1 {
+		if f.Layers[i].insert(hash, fp, f.BucketSize, f.MaxIterations) {
+			f.Items += 1
+			return nil
+		}
+	}
+	if f.Expansion == 0 {
+		return errors.New("Filter is full")
+	}
+	last := f.Layers[len(f.Layers)-1]
+	capacity := int(last.NumBuckets) * f.BucketSize * f.Expansion
+	layer := NewCuckooLayer(capacity, f.BucketSize)
+	f.Layers = append(f.Layers, layer)
+	if !layer.insert(hash, fp, f.BucketSize, f.MaxIterations) {
+		return errors.New("Filter is full")
+	}
+	f.Items += 1
+	return nil
+}
+
+// Delete removes one copy of the item, starting from the newest layer
+func (f *CuckooFilter) Delete(item string) bool {
+	hash, fp := cuckooHash(item)
+	for i := len(f.Layers) - 1; i >= 0; i -= 1 {
+		if f.Layers[i].remove(hash, fp, f.BucketSize) {
+			f.Items -= 1
+			f.Deleted += 1
+			return true
+		}
+	}
+	return false
+}
+
+// Mutex must be locked before calling getCuckooFilter. Returns nil if create is false and key is not set
+func (c *cache) getCuckooFilter(key string, create bool) (filter *CuckooFilter, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+		if create {
+			filter = NewCuckooFilter(cuckooDefaultCapacity, cuckooDefaultBucketSize, cuckooDefaultMaxIterations, cuckooDefaultExpansion)
+			c.write(key, filter)
+		}
+	case *CuckooFilter:
+		filter = stored.(*CuckooFilter)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) CFReserve(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("CF.RESERVE")
+	if len(args) < 2 || len(args)%2 != 0 {
+		err = formatErr
+		return
+	}
+	capacity, err := strconv.Atoi(args[1])
+	if err != nil || capacity <= 0 {
+		err = errors.New("Bad capacity")
+		return
+	}
+	bucketSize, maxIterations, expansion := cuckooDefaultBucketSize, cuckooDefaultMaxIterations, cuckooDefaultExpansion
+	for i := 2; i < len(args); i += 2 {
+		var value int
+		value, err = strconv.Atoi(args[i+1])
+		if err != nil || value < 0 {
+			err = errors.New(fmt.Sprintf("Bad %v", strings.ToLower(args[i])))
+			return
+		}
+		switch strings.ToUpper(args[i]) {
+		case "BUCKETSIZE":
+			if value < 1 || value > 255 {
+				err = errors.New("Bad bucketsize")
+				return
+			}
+			bucketSize = value
+		case "MAXITERATIONS":
+			if value < 1 {
+				err = errors.New("Bad maxiterations")
+				return
+			}
+			maxIterations = value
+		case "EXPANSION":
+			expansion = value
+		default:
+			err = formatErr
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.read(args[0]) != nil {
+		err = errors.New("item exists")
+		return
+	}
+	c.write(args[0], NewCuckooFilter(capacity, bucketSize, maxIterations, expansion))
+	response = formatted("OK")
+	return
+}
+func (c *cache) cfAdd(args []string, method string, nx bool) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	filter, err := c.getCuckooFilter(args[0], true)
+	if err != nil {
+		return
+	}
+	if nx && filter.Count(args[1]) > 0 {
+		response = int64(0)
+		return
+	}
+	err = filter.Add(args[1])
+	if err != nil {
+		return
+	}
+	c.modified(args[0])
+	c.notify(moduleClass, strings.ToLower(method), args[0])
+	response = int64(1)
+	return
+}
+func (c *cache) CFAdd(args []string) (response interface{}, err error) {
+	return c.cfAdd(args, "CF.ADD", false)
+}
+func (c *cache) CFAddNX(args []string) (response interface{}, err error) {
+	return c.cfAdd(args, "CF.ADDNX", true)
+}
+func (c *cache) CFDel(args []string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	filter, err := c.getCuckooFilter(args[0], false)
+	if err != nil {
+		return
+	}
+	if filter == nil {
+		err = errors.New("Not found")
+		return
+	}
+	response = int64(0)
+	if filter.Delete(args[1]) {
+		c.modified(args[0])
+		c.notify(moduleClass, "cf.del", args[0])
+		response = int64(1)
+	}
+	return
+}
+
+// cfCount returns the number of copies of the item, 0 if the filter does not exist
+func (c *cache) cfCount(key, item string) (n int, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	filter, err := c.getCuckooFilter(key, false)
+	if err != nil || filter == nil {
+		return
+	}
+	n = filter.Count(item)
+	return
+}
+func (c *cache) CFExists(args []string) (response interface{}, err error) {
+	n, err := c.cfCount(args[0], args[1])
+	if err != nil {
+		return
+	}
+	response = int64(0)
+	if n > 0 {
+		response = int64(1)
+	}
+	return
+}
+func (c *cache) CFCount(args []string) (response interface{}, err error) {
+	n, err := c.cfCount(args[0], args[1])
+	if err != nil {
+		return
+	}
+	response = int64(n)
+	return
+}
+func (c *cache) CFInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	filter, err := c.getCuckooFilter(args[0], false)
+	if err != nil {
+		return
+	}
+	if filter == nil {
+		err = errors.New("Not found")
+		return
+	}
+	size, buckets := 0, 0
+	for _, layer := range filter.Layers {
+		size += len(layer.Slots)
+		buckets += int(layer.NumBuckets)
+	}
+	response = []interface{}{
+		"Size", size,
+		"Number of buckets", buckets,
+		"Number of filters", len(filter.Layers),
+		"Number of items inserted", filter.Items,
+		"Number of items deleted", filter.Deleted,
+		"Bucket size", filter.BucketSize,
+		"Expansion rate", filter.Expansion,
+		"Max iterations", filter.MaxIterations,
+	}
+	return
+}
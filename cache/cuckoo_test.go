@@ -0,0 +1,145 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"testing"
+)
+
+func TestCFAddDel(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.CFAdd([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.CFAddNX([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	c.CFAdd([]string{"filter", "item"})
+	resp, err = text(c.CFCount([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 2" {
+		t.Errorf("expected (integer) 2, got %v", resp)
+	}
+
+	resp, err = text(c.CFDel([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.CFExists([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	c.CFDel([]string{"filter", "item"})
+	resp, err = text(c.CFExists([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.CFDel([]string{"filter", "item"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	_, err = text(c.CFDel([]string{"missing", "item"}))
+	if err == nil || err.Error() != "Not found" {
+		t.Error(err)
+	}
+}
+
+func TestCuckooFilterScaling(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.CFReserve([]string{"fixed", "64", "EXPANSION", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	full := false
+	for i := 0; i < 200; i += 1 {
+		_, err = text(c.CFAdd([]string{"fixed", fmt.Sprintf("item%v", i)}))
+		if err != nil {
+			full = err.Error() == "Filter is full"
+			break
+		}
+	}
+	if !full {
+		t.Error("expected non scaling filter to get full")
+	}
+
+	c.CFReserve([]string{"filter", "1000", "BUCKETSIZE", "4"})
+	n := 10000
+	for i := 0; i < n; i += 1 {
+		_, err = text(c.CFAdd([]string{"filter", fmt.Sprintf("item%v", i)}))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	filter := c.read("filter").(*CuckooFilter)
+	if len(filter.Layers) < 2 {
+		t.Errorf("expected filter to grow, got %v layers", len(filter.Layers))
+	}
+	for i := 0; i < n; i += 1 {
+		resp, _ := text(c.CFExists([]string{"filter", fmt.Sprintf("item%v", i)}))
+		if resp != "(integer) 1" {
+			t.Errorf("false negative for item%v", i)
+		}
+	}
+}
+
+func TestCuckooFilterSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.CFAdd([]string{"filter", "a"})
+	c.CFAdd([]string{"filter", "b"})
+
+	_, err := text(c.Save([]string{"cuckoosave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"cuckoosave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.CFExists([]string{"filter", "b"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.CFDel([]string{"filter", "a"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+
+	err = os.Remove("./saves/cuckoosave")
+	if err != nil {
+		t.Error(err)
+	}
+}
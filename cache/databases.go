@@ -0,0 +1,347 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const DefaultDatabases = 16
+
+var errDBIndex = errors.New("DB index is out of range")
+
+// Session is the state of a client connection kept by the server between requests
+type Session interface {
+	DB() int
+	SetDB(db int)
+	// Multi starts a transaction, Queued returns commands queued since then or nil outside of a transaction
+	Multi()
+	Queued() [][]string
+	Enqueue(command []string)
+	// Abort marks the transaction to be discarded on EXEC, Discard ends the transaction
+	Abort()
+	Aborted() bool
+	Discard()
+	// OnClose registers a function called when the connection of the session is closed
+	OnClose(f func())
+	// Send pushes a message to the connection without blocking, returns false if the message was dropped
+	Send(message string) bool
+}
+
+// Databases are numbered caches with their own keys and expirations. Commands are executed
+// on the database selected by the session
+type Databases interface {
+	StartCleaner()
+	HandleRequest(session Session, method string, args []string) (string, error)
+}
+
+type databases struct {
+	// m protects the order of databases, which is changed by SWAPDB and LOAD. The number of databases never changes
+	m        *sync.RWMutex
+	dbs      []*cache
+	watches  sessionWatches
+	pubsub   pubsub
+	events   *keyspaceEvents
+	scripts  scripts
+	tracking *tracking
+}
+
+func NewDatabases(n int) Databases {
+	d := &databases{&sync.RWMutex{}, make([]*cache, n), sessionWatches{&sync.Mutex{}, make(map[Session][]watch)}, newPubSub(), nil, newScripts(), newTracking()}
+	d.events = newKeyspaceEvents(&d.pubsub)
+	for i := range d.dbs {
+		d.dbs[i] = NewCache().(*cache)
+		d.dbs[i].index, d.dbs[i].events, d.dbs[i].tracking = i, d.events, d.tracking
+	}
+	return d
+}
+
+func (d *databases) db(index int) *cache {
+	d.m.RLock()
+	defer d.m.RUnlock()
+	return d.dbs[index]
+}
+
+// parseDBIndex parses the index of a database
+func (d *databases) parseDBIndex(arg string) (index int, err error) {
+	index, err = strconv.Atoi(arg)
+	if err != nil || index < 0 || index >= len(d.dbs) {
+		err = errDBIndex
+	}
+	return
+}
+
+// Starts cleaning the expired fields of all databases, function blocks
+func (d *databases) StartCleaner() {
+	for {
+		time.Sleep(50 * time.Millisecond)
+		for i := range d.dbs {
+			d.db(i).clean()
+		}
+	}
+}
+
+// Select changes the database of the session
+func (d *databases) Select(session Session, args []string) (response interface{}, err error) {
+	index, err := d.parseDBIndex(args[0])
+	if err != nil {
+		return
+	}
+	session.SetDB(index)
+	response = formatted("OK")
+	return
+}
+
+// SwapDB swaps the contents of two databases, so that sessions selecting one of them see the other one
+func (d *databases) SwapDB(args []string) (response interface{}, err error) {
+	first, err := d.parseDBIndex(args[0])
+	if err != nil {
+		return
+	}
+	second, err := d.parseDBIndex(args[1])
+	if err != nil {
+		return
+	}
+	if first == second {
+		response = formatted("OK")
+		return
+	}
+	d.m.Lock()
+	defer d.m.Unlock()
+	// indexes are used by keyspace notifications of commands running on the databases
+	for _, db := range []*cache{d.dbs[first], d.dbs[second]} {
+		db.m.Lock()
+		defer db.m.Unlock()
+	}
+	d.dbs[first], d.dbs[second] = d.dbs[second], d.dbs[first]
+	d.dbs[first].index, d.dbs[second].index = first, second
+	d.tracking.invalidateAll()
+	response = formatted("OK")
+	return
+}
+
+// Move moves the key with its expiration to another database if the key does not exist there
+func (d *databases) Move(session Session, args []string) (response interface{}, err error) {
+	target, err := d.parseDBIndex(args[1])
+	if err != nil {
+		return
+	}
+	if target == session.DB() {
+		err = errors.New("source and destination objects are the same")
+		return
+	}
+	// databases are locked in the order of their indexes and can not be swapped meanwhile
+	d.m.RLock()
+	defer d.m.RUnlock()
+	src, dst := d.dbs[session.DB()], d.dbs[target]
+	first, second := src, dst
+	if target < session.DB() {
+		first, second = dst, src
+	}
+	first.Exps.m.Lock()
+	defer first.Exps.m.Unlock()
+	second.Exps.m.Lock()
+	defer second.Exps.m.Unlock()
+	first.m.Lock()
+	defer first.m.Unlock()
+	second.m.Lock()
+	defer second.m.Unlock()
+	value := src.read(args[0])
+	response = int64(0)
+	if value == nil || dst.read(args[0]) != nil {
+		return
+	}
+	expires, ok := src.expiresAt(args[0])
+	src.setExpiration(args[0], 0)
+	src.delete(args[0])
+	dst.setExpiration(args[0], 0)
+	dst.write(args[0], value)
+	if ok {
+		dst.setExpiresAt(args[0], expires)
+	}
+	dst.signal(args[0])
+	src.notify(genericClass, "move_from", args[0])
+	dst.notify(genericClass, "move_to", args[0])
+	response = int64(1)
+	return
+}
+
+// FlushAll removes all keys of all databases
+func (d *databases) FlushAll(args []string) (response interface{}, err error) {
+	for i := range d.dbs {
+		response, err = d.db(i).FlushAll(args)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// snapshot is the saved state of all databases and the codes of function libraries
+type snapshot struct {
+	Databases []json.RawMessage
+	Libraries []string `json:",omitempty"`
+}
+
+// Save saves all databases into one snapshot with a section per database, function libraries are saved with them
+func (d *databases) Save(args []string) (response interface{}, err error) {
+	d.m.RLock()
+	defer d.m.RUnlock()
+	var saved snapshot
+	// all databases stay locked until the snapshot is written, so that it is consistent. Expirations are saved
+	// with the keys, the locks are taken in the same order as in Load
+	for _, db := range d.dbs {
+		db.Exps.m.Lock()
+		defer db.Exps.m.Unlock()
+	}
+	for _, db := range d.dbs {
+		db.m.RLock()
+		defer db.m.RUnlock()
+	}
+	for _, db := range d.dbs {
+		var b []byte
+		b, err = json.Marshal(db)
+		if err != nil {
+			return
+		}
+		saved.Databases = append(saved.Databases, b)
+	}
+	saved.Libraries = d.scripts.codes()
+	b, err := json.Marshal(saved)
+	if err != nil {
+		return
+	}
+	err = writeSnapshot(savepath, args[0], b)
+	if err != nil {
+		return
+	}
+	response = formatted("OK")
+	return
+}
+
+// Load replaces all databases and function libraries with the saved ones. Snapshots of a single database are loaded
+// into database 0
+func (d *databases) Load(args []string) (response interface{}, err error) {
+	b, err := readSnapshot(savepath, args[0])
+	if err != nil {
+		return
+	}
+	var saved snapshot
+	err = json.Unmarshal(b, &saved)
+	if err != nil {
+		return
+	}
+	if saved.Databases == nil {
+		saved.Databases = []json.RawMessage{b}
+	}
+	if len(saved.Databases) > len(d.dbs) {
+		err = errors.New(fmt.Sprintf("Snapshot has %v databases, but only %v are configured", len(saved.Databases), len(d.dbs)))
+		return
+	}
+	loaded := make([]*cache, len(d.dbs))
+	for i := range loaded {
+		loaded[i] = NewCache().(*cache)
+		if i < len(saved.Databases) {
+			err = json.Unmarshal(saved.Databases[i], loaded[i])
+			if err != nil {
+				return
+			}
+		}
+	}
+	libraries, functions, err := compileLibraries(saved.Libraries)
+	if err != nil {
+		return
+	}
+	// databases are locked in the order of their indexes like in Move, their contents are replaced so that
+	// sessions, blocked clients and watched keys keep working with the same databases
+	d.m.Lock()
+	defer d.m.Unlock()
+	for _, db := range d.dbs {
+		db.Exps.m.Lock()
+		defer db.Exps.m.Unlock()
+	}
+	for _, db := range d.dbs {
+		db.m.Lock()
+		defer db.m.Unlock()
+	}
+	for i, db := range d.dbs {
+		db.replace(loaded[i])
+	}
+	d.scripts.setLibraries(libraries, functions)
+	response = formatted("OK")
+	return
+}
+
+// HandleRequest executes the command for the session and formats its reply as text
+func (d *databases) HandleRequest(session Session, method string, args []string) (response string, err error) {
+	reply, err := d.call(session, method, args)
+	if err != nil {
+		return
+	}
+	response = formatReply(reply)
+	return
+}
+
+// call looks up the command in the command table and checks its arguments before executing it
+func (d *databases) call(session Session, method string, args []string) (response interface{}, err error) {
+	if !subscriberCommands[method] && d.pubsub.subscribed(session) {
+		err = errors.New(fmt.Sprintf("Can't execute '%v': only (P|S)SUBSCRIBE / (P|S)UNSUBSCRIBE are allowed in this context", strings.ToLower(method)))
+		return
+	}
+	command, ok := commands[method]
+	if !ok {
+		err = errors.New("method does not exist")
+	} else {
+		err = command.check(args)
+	}
+	if err != nil {
+		// malformed commands abort the transaction
+		if session.Queued() != nil {
+			session.Abort()
+		}
+		return
+	}
+	if session.Queued() != nil && !transactionCommands[method] {
+		return d.enqueue(session, command, args)
+	}
+	if command.serve != nil {
+		return command.serve(d, session, args)
+	}
+	db := d.db(session.DB())
+	if err = d.scripts.busy(db); err != nil {
+		return
+	}
+	if command.Flags&FlagReadOnly == 0 || !d.tracking.tracks(session) {
+		return db.call(method, args)
+	}
+	return d.trackedCall(session, db, method, command, args)
+}
+
+// trackedCall executes the read only command for a session tracking keys. Keys at fixed positions and streams of
+// XREAD are tracked before the command is executed, other commands run on a view of the database which tracks keys
+// as they are read
+func (d *databases) trackedCall(session Session, db *cache, method string, command *Command, args []string) (response interface{}, err error) {
+	if method == "XREAD" {
+		if opts, err := parseStreamRead(args, 0, false); err == nil {
+			d.tracking.read(session, opts.keys)
+		}
+		return db.call(method, args)
+	}
+	if command.FirstKey != 0 && command.Flags&FlagMovableKeys == 0 {
+		d.tracking.read(session, command.keys(args))
+		return db.call(method, args)
+	}
+	// the view is locked exclusively like a script, because expired keys of the arguments are deleted by call
+	db.Exps.m.Lock()
+	defer db.Exps.m.Unlock()
+	db.m.Lock()
+	defer db.m.Unlock()
+	view := db.unlocked()
+	view.reader = session
+	defer db.update(view)
+	return view.call(method, args)
+}
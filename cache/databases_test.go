@@ -0,0 +1,222 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+type testSession struct {
+	db       int
+	queued   [][]string
+	aborted  bool
+	closers  []func()
+	messages []string
+}
+
+func (s *testSession) DB() int {
+	return s.db
+}
+func (s *testSession) SetDB(db int) {
+	s.db = db
+}
+func (s *testSession) Multi() {
+	s.queued, s.aborted = make([][]string, 0), false
+}
+func (s *testSession) Queued() [][]string {
+	return s.queued
+}
+func (s *testSession) Enqueue(command []string) {
+	s.queued = append(s.queued, command)
+}
+func (s *testSession) Abort() {
+	s.aborted = true
+}
+func (s *testSession) Aborted() bool {
+	return s.aborted
+}
+func (s *testSession) Discard() {
+	s.queued, s.aborted = nil, false
+}
+func (s *testSession) OnClose(f func()) {
+	s.closers = append(s.closers, f)
+}
+func (s *testSession) Send(message string) bool {
+	s.messages = append(s.messages, message)
+	return true
+}
+
+// text formats the reply of a command called directly the same way as HandleRequest does
+func text(reply interface{}, err error) (string, error) {
+	if err != nil {
+		return "", err
+	}
+	return formatReply(reply), nil
+}
+
+func TestSelect(t *testing.T) {
+	d := NewDatabases(4)
+	first, second := &testSession{}, &testSession{}
+	d.HandleRequest(first, "SET", []string{"key", "zero"})
+	resp, err := d.HandleRequest(second, "SELECT", []string{"2"})
+	if err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	if resp, _ = d.HandleRequest(second, "GET", []string{"key"}); resp != "(nil)" {
+		t.Errorf("expected databases to be separate, got %v", resp)
+	}
+	d.HandleRequest(second, "SET", []string{"key", "two"})
+	if resp, _ = d.HandleRequest(first, "GET", []string{"key"}); resp != "zero" {
+		t.Errorf("expected zero, got %v", resp)
+	}
+	for _, index := range []string{"4", "-1", "x"} {
+		if _, err = d.HandleRequest(first, "SELECT", []string{index}); err != errDBIndex {
+			t.Errorf("SELECT %v: expected %v, got %v", index, errDBIndex, err)
+		}
+	}
+
+	resp, err = d.HandleRequest(first, "SWAPDB", []string{"0", "2"})
+	if err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	if resp, _ = d.HandleRequest(first, "GET", []string{"key"}); resp != "two" {
+		t.Errorf("expected swapped database, got %v", resp)
+	}
+	if resp, _ = d.HandleRequest(second, "GET", []string{"key"}); resp != "zero" {
+		t.Errorf("expected swapped database, got %v", resp)
+	}
+
+	d.HandleRequest(first, "SET", []string{"other", "value"})
+	d.HandleRequest(second, "FLUSHALL", []string{})
+	if resp, _ = d.HandleRequest(first, "DBSIZE", []string{}); resp != "(integer) 0" {
+		t.Errorf("expected FLUSHALL to clear all databases, got %v", resp)
+	}
+}
+
+func TestMove(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	d.HandleRequest(session, "SET", []string{"key", "value", "EX", "100"})
+	d.HandleRequest(session, "SET", []string{"taken", "zero"})
+	resp, err := d.HandleRequest(session, "MOVE", []string{"key", "1"})
+	if err != nil || resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v %v", resp, err)
+	}
+	if resp, _ = d.HandleRequest(session, "EXISTS", []string{"key"}); resp != "(integer) 0" {
+		t.Errorf("expected the key to be moved, got %v", resp)
+	}
+	db := d.(*databases).db(1)
+	db.Exps.m.Lock()
+	expires, ok := db.expiresAt("key")
+	db.Exps.m.Unlock()
+	if !ok || time.Until(expires) < 90*time.Second {
+		t.Errorf("expected the expiration to be moved, got %v", expires)
+	}
+
+	d.HandleRequest(session, "SELECT", []string{"1"})
+	d.HandleRequest(session, "SET", []string{"taken", "one"})
+	if resp, _ = d.HandleRequest(session, "MOVE", []string{"taken", "0"}); resp != "(integer) 0" {
+		t.Errorf("expected existing key not to be replaced, got %v", resp)
+	}
+	if resp, _ = d.HandleRequest(session, "MOVE", []string{"missing", "0"}); resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	if _, err = d.HandleRequest(session, "MOVE", []string{"key", "1"}); err == nil {
+		t.Errorf("expected error on moving to the same database")
+	}
+}
+
+func TestDatabasesSaveLoad(t *testing.T) {
+	d := NewDatabases(3)
+	session := &testSession{}
+	d.HandleRequest(session, "SET", []string{"key", "zero"})
+	session.SetDB(2)
+	d.HandleRequest(session, "HSET", []string{"key", "field", "two"})
+	resp, err := d.HandleRequest(session, "SAVE", []string{"dbsave"})
+	if err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+
+	d = NewDatabases(3)
+	resp, err = d.HandleRequest(session, "LOAD", []string{"dbsave"})
+	if err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	if resp, _ = d.HandleRequest(session, "HGET", []string{"key", "field"}); resp != "two" {
+		t.Errorf("expected two, got %v", resp)
+	}
+	if resp, _ = d.HandleRequest(&testSession{}, "GET", []string{"key"}); resp != "zero" {
+		t.Errorf("expected zero, got %v", resp)
+	}
+	if resp, _ = d.HandleRequest(&testSession{db: 1}, "DBSIZE", []string{}); resp != "(integer) 0" {
+		t.Errorf("expected empty database, got %v", resp)
+	}
+	if _, err = NewDatabases(2).HandleRequest(session, "LOAD", []string{"dbsave"}); err == nil {
+		t.Errorf("expected error on loading more databases than configured")
+	}
+
+	// snapshots of a single database are loaded into database 0
+	c := (NewCache()).(*cache)
+	c.Set([]string{"old", "value"})
+	c.Save([]string{"dbsave"})
+	d.HandleRequest(session, "LOAD", []string{"dbsave"})
+	if resp, _ = d.HandleRequest(&testSession{}, "GET", []string{"old"}); resp != "value" {
+		t.Errorf("expected value, got %v", resp)
+	}
+	if resp, _ = d.HandleRequest(session, "DBSIZE", []string{}); resp != "(integer) 0" {
+		t.Errorf("expected other databases to be empty, got %v", resp)
+	}
+
+	// clients blocked on the replaced databases read the loaded keys
+	done := make(chan string)
+	go func() {
+		resp, _ := d.HandleRequest(&testSession{}, "XREAD", []string{"BLOCK", "0", "STREAMS", "stream", "$"})
+		done <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.XAdd([]string{"stream", "1-0", "field", "value"})
+	c.Save([]string{"dbsave"})
+	d.HandleRequest(session, "LOAD", []string{"dbsave"})
+	select {
+	case resp = <-done:
+		expected := "1) 1) \"stream\"\n   2) 1) 1) \"1-0\"\n         2) 1) \"field\"\n            2) \"value\""
+		if resp != expected {
+			t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+		}
+	case <-time.After(time.Second):
+		t.Error("blocked XREAD was not woken up by LOAD")
+	}
+
+	err = os.Remove("./saves/dbsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
+
+func TestDatabasesSaveExpire(t *testing.T) {
+	d := NewDatabases(2)
+	saver := &testSession{}
+	db := d.(*databases).db(0)
+	done := make(chan bool)
+	// the cleaner changes expirations under their own lock only while databases are saved, the race detector
+	// reports saving them without that lock
+	go func() {
+		for i := 0; i < 100; i += 1 {
+			db.Exps.m.Lock()
+			db.setExpiration("key", time.Microsecond)
+			db.Exps.m.Unlock()
+			time.Sleep(time.Millisecond)
+			db.clean()
+		}
+		close(done)
+	}()
+	for i := 0; i < 10; i += 1 {
+		if resp, err := d.HandleRequest(saver, "SAVE", []string{"expiresave"}); err != nil || resp != "OK" {
+			t.Fatalf("expected OK, got %v %v", resp, err)
+		}
+	}
+	<-done
+	if err := os.Remove("./saves/expiresave"); err != nil {
+		t.Error(err)
+	}
+}
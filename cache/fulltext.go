@@ -0,0 +1,120 @@
+package cache
+
+import (
+	"math"
+	"strings"
+)
+
+// BM25 parameters, k1 limits the effect of term frequency and b the normalization by field length
+const (
+	bm25K1 = 1.2
+	bm25B  = 0.75
+	// prefix queries shorter than this would match most of the index
+	minPrefixLength  = 2
+	maxFuzzyDistance = 3
+)
+
+// defaultStopWords are not indexed and are ignored in queries unless the index sets its own list
+var defaultStopWords = []string{
+	"a", "is", "the", "an", "and", "are", "as", "at", "be", "but", "by", "for", "if", "in", "into", "it",
+	"no", "not", "of", "on", "or", "such", "that", "their", "then", "there", "these", "they", "this", "to",
+	"was", "will", "with",
+}
+
+// textMatch is a term of a TEXT field matched by the query, used for scoring
+type textMatch struct {
+	field string
+	term  string
+}
+
+func (f SearchField) weight() float64 {
+	// snapshots made before weights were added have zero weights
+	if f.Weight == 0 {
+		return 1
+	}
+	return f.Weight
+}
+
+// stem returns the key under which the term is grouped with its word forms in the field
+func (f SearchField) stem(term string) string {
+	if f.NoStem {
+		return term
+	}
+	return porterStem(term)
+}
+
+// levenshtein returns the edit distance between the words, stopping early once it exceeds the limit
+func levenshtein(a, b string, limit int) int {
+	ra, rb := []rune(a), []rune(b)
+	if d := len(ra) - len(rb); d > limit || -d > limit {
+		return limit + 1
+	}
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i += 1 {
+		cur[0] = i
+		rowMin := cur[0]
+		for j := 1; j <= len(rb); j += 1 {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = minInt(minInt(prev[j]+1, cur[j-1]+1), prev[j-1]+cost)
+			rowMin = minInt(rowMin, cur[j])
+		}
+		if rowMin > limit {
+			return limit + 1
+		}
+		prev, cur = cur, prev
+	}
+	return prev[len(rb)]
+}
+
+// expand returns the indexed terms of the field matching the query word. Words ending with * match terms
+// with the prefix, words surrounded by one to three % match terms within that edit distance,
+// other words match all terms with the same stem
+func (idx *SearchIndex) expand(field SearchField, word string, prefix bool, distance int) []string {
+	var res []string
+	switch {
+	case prefix:
+		for term := range idx.terms[field.Name] {
+			if strings.HasPrefix(term, word) {
+				res = append(res, term)
+			}
+		}
+	case distance > 0:
+		for term := range idx.terms[field.Name] {
+			if levenshtein(word, term, distance) <= distance {
+				res = append(res, term)
+			}
+		}
+	default:
+		for term := range idx.stems[field.Name][field.stem(word)] {
+			res = append(res, term)
+		}
+	}
+	return res
+}
+
+// score returns the BM25 score of the document for the matched terms, multiplied by the weights of the fields
+func (idx *SearchIndex) score(key string, matches map[textMatch]bool) float64 {
+	doc := idx.docs[key]
+	total := 0.0
+	for match := range matches {
+		frequency := doc.terms[match.field][match.term]
+		if frequency == 0 {
+			continue
+		}
+		field, _ := idx.field(match.field)
+		n := float64(len(idx.terms[match.field][match.term]))
+		idf := math.Log(1 + (float64(len(idx.docs))-n+0.5)/(n+0.5))
+		avgLength := float64(idx.lengths[match.field]) / float64(len(idx.docs))
+		tf := float64(frequency)
+		norm := 1 - bm25B + bm25B*float64(doc.lengths[match.field])/avgLength
+		total += field.weight() * idf * tf * (bm25K1 + 1) / (tf + bm25K1*norm)
+	}
+	return total
+}
@@ -0,0 +1,138 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func newCatalogueCache(t *testing.T) *cache {
+	c := (NewCache()).(*cache)
+	c.HSet([]string{"product:1", "title", "Running shoes for trail runners", "description", "Light shoes", "price", "90"})
+	c.HSet([]string{"product:2", "title", "Leather shoes", "description", "Classic leather shoes with laces, shoes for the office", "price", "120"})
+	c.HSet([]string{"product:3", "title", "Trail backpack", "description", "Waterproof backpack for running", "price", "60"})
+	_, err := text(c.FTCreate([]string{"products", "PREFIX", "1", "product:", "SCHEMA",
+		"title", "TEXT", "WEIGHT", "2", "description", "TEXT", "price", "NUMERIC"}))
+	if err != nil {
+		t.Error(err)
+	}
+	return c
+}
+
+func TestFTFullText(t *testing.T) {
+	c := newCatalogueCache(t)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		// running in the title weighs more than in the description
+		{[]string{"products", "run", "NOCONTENT"}, "1) (integer) 2\n2) \"product:1\"\n3) \"product:3\""},
+		// the shorter title is more relevant
+		{[]string{"products", "@title:shoe", "NOCONTENT"}, "1) (integer) 2\n2) \"product:2\"\n3) \"product:1\""},
+		{[]string{"products", "the", "NOCONTENT"}, "1) (integer) 3\n2) \"product:1\"\n3) \"product:2\"\n4) \"product:3\""},
+		{[]string{"products", "the trail", "NOCONTENT"}, "1) (integer) 2\n2) \"product:3\"\n3) \"product:1\""},
+		{[]string{"products", "back*", "NOCONTENT"}, "1) (integer) 1\n2) \"product:3\""},
+		{[]string{"products", "%lether%", "NOCONTENT"}, "1) (integer) 1\n2) \"product:2\""},
+		{[]string{"products", "%%bakpak%%", "NOCONTENT"}, "1) (integer) 1\n2) \"product:3\""},
+		{[]string{"products", "%bakpak%", "NOCONTENT"}, "1) (integer) 0"},
+		{[]string{"products", "shoes -leather @price:[0 100]", "NOCONTENT"}, "1) (integer) 1\n2) \"product:1\""},
+		{[]string{"products", "shoes", "NOCONTENT", "SORTBY", "price", "DESC"}, "1) (integer) 2\n2) \"product:2\"\n3) \"product:1\""},
+	}
+	for _, test := range tests {
+		resp, err := text(c.FTSearch(test.args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != test.expected {
+			t.Errorf("FT.SEARCH %v: expected:\n%v, got:\n%v", test.args, test.expected, resp)
+		}
+	}
+
+	for _, query := range []string{"b*", "%shoe", "%%%%shoe%%%%", "%two words%"} {
+		_, err := text(c.FTSearch([]string{"products", query}))
+		if err == nil {
+			t.Errorf("expected error on query %v", query)
+		}
+	}
+
+	resp, err := text(c.FTSearch([]string{"products", "leather", "WITHSCORES", "RETURN", "1", "price"}))
+	if err != nil {
+		t.Error(err)
+	}
+	lines := strings.Split(resp, "\n")
+	if len(lines) != 5 || lines[1] != "2) \"product:2\"" || lines[3] != "4) 1) \"price\"" {
+		t.Fatalf("unexpected response %v", resp)
+	}
+	score, err := strconv.ParseFloat(strings.Trim(lines[2][len("3) "):], "\""), 64)
+	if err != nil || score <= 0 {
+		t.Errorf("expected positive score, got %v", lines[2])
+	}
+}
+
+func TestFTStopWordsAndNoStem(t *testing.T) {
+	c := newCatalogueCache(t)
+	_, err := text(c.FTCreate([]string{"titles", "PREFIX", "1", "product:", "STOPWORDS", "1", "Trail", "SCHEMA", "title", "TEXT", "NOSTEM"}))
+	if err != nil {
+		t.Error(err)
+	}
+
+	tests := map[string]string{
+		"for":    "1) (integer) 1\n2) \"product:1\"",
+		"shoe":   "1) (integer) 0",
+		"shoes":  "1) (integer) 2\n2) \"product:2\"\n3) \"product:1\"",
+		"trail":  "1) (integer) 3\n2) \"product:1\"\n3) \"product:2\"\n4) \"product:3\"",
+		"runne*": "1) (integer) 1\n2) \"product:1\"",
+	}
+	for query, expected := range tests {
+		resp, err := text(c.FTSearch([]string{"titles", query, "NOCONTENT"}))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != expected {
+			t.Errorf("FT.SEARCH titles %v: expected:\n%v, got:\n%v", query, expected, resp)
+		}
+	}
+
+	resp, _ := text(c.FTInfo([]string{"titles"}))
+	if !strings.Contains(resp, "\"NOSTEM\"") || !strings.Contains(resp, "\"stopwords_list\"") {
+		t.Errorf("expected NOSTEM and stop words in info, got:\n%v", resp)
+	}
+}
+
+func TestFTFullTextUpdates(t *testing.T) {
+	c := newCatalogueCache(t)
+	idx := c.indexes["products"]
+	search := func(query string) string {
+		resp, err := text(c.FTSearch([]string{"products", query, "NOCONTENT"}))
+		if err != nil {
+			t.Error(err)
+		}
+		return resp
+	}
+
+	c.HSet([]string{"product:3", "title", "Trail bag"})
+	if resp := search("@title:backpack"); resp != "1) (integer) 0" {
+		t.Errorf("expected HSET to update the index, got:\n%v", resp)
+	}
+	c.Del([]string{"product:2"})
+	if resp := search("leather"); resp != "1) (integer) 0" {
+		t.Errorf("expected DEL to update the index, got:\n%v", resp)
+	}
+	if _, ok := idx.stems["title"]["leather"]; ok {
+		t.Errorf("expected stems of deleted terms to be removed")
+	}
+
+	c.Exps.m.Lock()
+	c.setExpiration("product:1", 10*time.Millisecond)
+	c.Exps.m.Unlock()
+	go c.StartCleaner()
+	time.Sleep(200 * time.Millisecond)
+	if resp := search("run"); resp != "1) (integer) 1\n2) \"product:3\"" {
+		t.Errorf("expected expired key to be removed from the index, got:\n%v", resp)
+	}
+	if idx.lengths["title"] != 2 || idx.lengths["description"] != 3 {
+		t.Errorf("expected lengths of the remaining document, got %v", idx.lengths)
+	}
+}
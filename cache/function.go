@@ -0,0 +1,352 @@
+package cache
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/gobwas/glob"
+	lua "github.com/yuin/gopher-lua"
+)
+
+var (
+	errFunctionNotFound = errors.New("Function not found")
+	errLibraryName      = errors.New("Library names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+	errFunctionName     = errors.New("Function names can only contain letters, numbers, or underscores(_) and must be at least one character long")
+)
+
+// library is a named set of functions loaded from one code. The code is executed again on each call of
+// its functions, so that functions keep no state between calls
+type library struct {
+	name      string
+	code      string
+	proto     *lua.FunctionProto
+	functions []string
+}
+
+// validName checks names of libraries and functions
+func validName(name string) bool {
+	if name == "" {
+		return false
+	}
+	for _, r := range name {
+		if !(r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '_') {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLibraryHeader parses the first line of the code in the form of #!lua name=<library>
+func parseLibraryHeader(code string) (name string, err error) {
+	header := code
+	if end := strings.IndexByte(code, '\n'); end >= 0 {
+		header = code[:end]
+	}
+	if !strings.HasPrefix(header, "#!") {
+		err = errors.New("Missing library metadata")
+		return
+	}
+	fields := strings.Fields(header[2:])
+	if len(fields) == 0 || fields[0] != "lua" {
+		err = errors.New("Engine not found, only lua is supported")
+		return
+	}
+	for _, field := range fields[1:] {
+		if !strings.HasPrefix(field, "name=") {
+			err = errors.New(fmt.Sprintf("Invalid metadata value given: %v", field))
+			return
+		}
+		name = strings.TrimPrefix(field, "name=")
+	}
+	if !validName(name) {
+		err = errLibraryName
+	}
+	return
+}
+
+// registerFunctions executes the code of the library with cache.register_function, which takes either the name
+// and the callback, or a table with function_name and callback fields. Returns the registered callbacks
+func registerFunctions(L *lua.LState, proto *lua.FunctionProto) (callbacks map[string]*lua.LFunction, err error) {
+	lib, ok := L.GetGlobal("cache").(*lua.LTable)
+	if !ok {
+		lib = L.NewTable()
+		L.SetGlobal("cache", lib)
+	}
+	callbacks = make(map[string]*lua.LFunction)
+	lib.RawSetString("register_function", L.NewFunction(func(L *lua.LState) int {
+		var name string
+		var callback *lua.LFunction
+		switch L.GetTop() {
+		case 1:
+			table := L.CheckTable(1)
+			name = lua.LVAsString(table.RawGetString("function_name"))
+			callback, _ = table.RawGetString("callback").(*lua.LFunction)
+		case 2:
+			name = L.CheckString(1)
+			callback, _ = L.Get(2).(*lua.LFunction)
+		default:
+			L.RaiseError("wrong number of arguments to cache.register_function")
+		}
+		if callback == nil {
+			L.RaiseError("callback argument given to cache.register_function must be a function")
+		}
+		if !validName(name) {
+			L.RaiseError("%v", errFunctionName)
+		}
+		if _, ok := callbacks[name]; ok {
+			L.RaiseError("Function already exists in the library")
+		}
+		callbacks[name] = callback
+		return 0
+	}))
+	L.Push(L.NewFunctionFromProto(proto))
+	err = L.PCall(0, 0, nil)
+	// functions can be registered only while the library is loaded
+	lib.RawSetString("register_function", lua.LNil)
+	if apiErr, ok := err.(*lua.ApiError); ok {
+		err = errors.New(fmt.Sprintf("Error registering functions: %v", apiErr.Object))
+	}
+	return
+}
+
+// compileLibrary checks the header of the code and executes it to find the functions of the library. Loading
+// must finish within the time limit of scripts
+func compileLibrary(code string) (lib *library, err error) {
+	name, err := parseLibraryHeader(code)
+	if err != nil {
+		return
+	}
+	// the header is replaced by an empty line to keep line numbers of errors
+	body := ""
+	if end := strings.IndexByte(code, '\n'); end >= 0 {
+		body = code[end:]
+	}
+	proto, err := compileScript("@user_function", body)
+	if err != nil {
+		return
+	}
+	L := newScriptState()
+	defer L.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), scriptTimeLimit)
+	defer cancel()
+	L.SetContext(ctx)
+	callbacks, err := registerFunctions(L, proto)
+	if ctx.Err() != nil {
+		err = errors.New("FUNCTION LOAD timed out")
+	}
+	if err != nil {
+		return
+	}
+	if len(callbacks) == 0 {
+		err = errors.New("No functions registered")
+		return
+	}
+	lib = &library{name: name, code: code, proto: proto}
+	for function := range callbacks {
+		lib.functions = append(lib.functions, function)
+	}
+	sort.Strings(lib.functions)
+	return
+}
+
+// addLibrary adds the library, replacing the library with the same name only with replace set.
+// Names of functions must be unique among all libraries. s.m must be locked before calling addLibrary
+func (s *scripts) addLibrary(lib *library, replace bool) error {
+	old, ok := s.libraries[lib.name]
+	if ok && !replace {
+		return errors.New(fmt.Sprintf("Library '%v' already exists", lib.name))
+	}
+	for _, function := range lib.functions {
+		if owner, ok := s.functions[function]; ok && owner != old {
+			return errors.New(fmt.Sprintf("Function %v already exists", function))
+		}
+	}
+	if ok {
+		s.deleteLibrary(old)
+	}
+	s.libraries[lib.name] = lib
+	for _, function := range lib.functions {
+		s.functions[function] = lib
+	}
+	return nil
+}
+
+// s.m must be locked before calling deleteLibrary
+func (s *scripts) deleteLibrary(lib *library) {
+	delete(s.libraries, lib.name)
+	for _, function := range lib.functions {
+		delete(s.functions, function)
+	}
+}
+
+// codes returns the codes of all libraries ordered by their names, they are saved in snapshots
+func (s *scripts) codes() []string {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	names := make([]string, 0, len(s.libraries))
+	for name := range s.libraries {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	codes := make([]string, len(names))
+	for i, name := range names {
+		codes[i] = s.libraries[name].code
+	}
+	return codes
+}
+
+// compileLibraries compiles the saved libraries, so that they can replace the current ones with setLibraries
+func compileLibraries(codes []string) (libraries map[string]*library, functions map[string]*library, err error) {
+	libraries, functions = make(map[string]*library), make(map[string]*library)
+	s := scripts{libraries: libraries, functions: functions}
+	for _, code := range codes {
+		var lib *library
+		lib, err = compileLibrary(code)
+		if err == nil {
+			err = s.addLibrary(lib, false)
+		}
+		if err != nil {
+			return
+		}
+	}
+	return
+}
+
+// setLibraries replaces all libraries
+func (s *scripts) setLibraries(libraries map[string]*library, functions map[string]*library) {
+	s.m.Lock()
+	s.libraries, s.functions = libraries, functions
+	s.m.Unlock()
+}
+
+// FCall calls the function of a loaded library with the keys and the arguments as two tables
+func (d *databases) FCall(session Session, args []string) (response interface{}, err error) {
+	keys, argv, err := parseScriptArgs(args[1:])
+	if err != nil {
+		return
+	}
+	d.scripts.m.RLock()
+	lib, ok := d.scripts.functions[args[0]]
+	d.scripts.m.RUnlock()
+	if !ok {
+		err = errFunctionNotFound
+		return
+	}
+	db := d.db(session.DB())
+	if err = d.scripts.busy(db); err != nil {
+		return
+	}
+	return d.scripts.run(db, session, func(L *lua.LState) error {
+		callbacks, err := registerFunctions(L, lib.proto)
+		if err != nil {
+			return err
+		}
+		L.Push(callbacks[args[0]])
+		L.Push(stringTable(L, keys))
+		L.Push(stringTable(L, argv))
+		return L.PCall(2, 1, nil)
+	})
+}
+
+// Function manages libraries: LOAD adds a library and returns its name, DELETE removes a library, FLUSH removes
+// all of them, LIST describes libraries with names matching the pattern and KILL stops running functions like SCRIPT KILL
+func (s *scripts) Function(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("FUNCTION")
+	switch strings.ToUpper(args[0]) {
+	case "LOAD":
+		replace := len(args) == 3 && strings.ToUpper(args[1]) == "REPLACE"
+		if len(args) != 2 && !replace {
+			err = formatErr
+			return
+		}
+		var lib *library
+		lib, err = compileLibrary(args[len(args)-1])
+		if err != nil {
+			return
+		}
+		s.m.Lock()
+		defer s.m.Unlock()
+		if err = s.addLibrary(lib, replace); err != nil {
+			return
+		}
+		response = lib.name
+	case "DELETE":
+		if len(args) != 2 {
+			err = formatErr
+			return
+		}
+		s.m.Lock()
+		defer s.m.Unlock()
+		lib, ok := s.libraries[args[1]]
+		if !ok {
+			err = errors.New("Library not found")
+			return
+		}
+		s.deleteLibrary(lib)
+		response = formatted("OK")
+	case "FLUSH":
+		if len(args) > 2 || (len(args) == 2 && strings.ToUpper(args[1]) != "ASYNC" && strings.ToUpper(args[1]) != "SYNC") {
+			err = formatErr
+			return
+		}
+		s.setLibraries(make(map[string]*library), make(map[string]*library))
+		response = formatted("OK")
+	case "LIST":
+		return s.functionList(args[1:], formatErr)
+	case "KILL":
+		if len(args) != 1 {
+			err = formatErr
+			return
+		}
+		return s.Script(args)
+	default:
+		err = formatErr
+	}
+	return
+}
+func (s *scripts) functionList(args []string, formatErr error) (response interface{}, err error) {
+	var pattern glob.Glob
+	withCode := false
+	for i := 0; i < len(args); i += 1 {
+		switch {
+		case strings.ToUpper(args[i]) == "WITHCODE":
+			withCode = true
+		case strings.ToUpper(args[i]) == "LIBRARYNAME" && i+1 < len(args):
+			pattern, err = glob.Compile(args[i+1])
+			if err != nil {
+				return
+			}
+			i += 1
+		default:
+			err = formatErr
+			return
+		}
+	}
+	s.m.RLock()
+	defer s.m.RUnlock()
+	names := make([]string, 0, len(s.libraries))
+	for name := range s.libraries {
+		if pattern == nil || pattern.Match(name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	res := make([]interface{}, 0, len(names))
+	for _, name := range names {
+		lib := s.libraries[name]
+		functions := make([]interface{}, len(lib.functions))
+		for i, function := range lib.functions {
+			functions[i] = []interface{}{"name", function}
+		}
+		item := []interface{}{"library_name", lib.name, "engine", "LUA", "functions", functions}
+		if withCode {
+			item = append(item, "library_code", lib.code)
+		}
+		res = append(res, item)
+	}
+	response = res
+	return
+}
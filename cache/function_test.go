@@ -0,0 +1,93 @@
+package cache
+
+import (
+	"testing"
+)
+
+const counterLibrary = `#!lua name=counter
+local function add(keys, args)
+  local value = tonumber(cache.call('GET', keys[1]) or '0') + tonumber(args[1])
+  cache.call('SET', keys[1], value)
+  return value
+end
+cache.register_function('add', add)
+cache.register_function{function_name = 'get', callback = function(keys) return cache.call('GET', keys[1]) end}`
+
+func TestFunction(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"FUNCTION", []string{"LOAD", counterLibrary}, "counter"},
+		{"FCALL", []string{"add", "1", "c", "5"}, "(integer) 5"},
+		{"FCALL", []string{"add", "1", "c", "2"}, "(integer) 7"},
+		{"FCALL", []string{"get", "1", "c"}, "7"},
+		{"FUNCTION", []string{"LIST"}, "1) 1) \"library_name\"\n   2) \"counter\"\n   3) \"engine\"\n   4) \"LUA\"\n   5) \"functions\"\n" +
+			"   6) 1) 1) \"name\"\n         2) \"add\"\n      2) 1) \"name\"\n         2) \"get\""},
+		{"FUNCTION", []string{"LOAD", "REPLACE", "#!lua name=counter\ncache.register_function('add', function() return 0 end)"}, "counter"},
+		{"FCALL", []string{"add", "0"}, "(integer) 0"},
+		{"FUNCTION", []string{"LOAD", "#!lua name=other\ncache.register_function('other', function() return 1 end)"}, "other"},
+		{"FUNCTION", []string{"LIST", "LIBRARYNAME", "oth*", "WITHCODE"}, "1) 1) \"library_name\"\n   2) \"other\"\n   3) \"engine\"\n   4) \"LUA\"\n" +
+			"   5) \"functions\"\n   6) 1) 1) \"name\"\n         2) \"other\"\n   7) \"library_code\"\n" +
+			"   8) \"#!lua name=other\n      cache.register_function('other', function() return 1 end)\""},
+		{"FUNCTION", []string{"DELETE", "counter"}, "OK"},
+		{"FUNCTION", []string{"FLUSH"}, "OK"},
+		{"FUNCTION", []string{"LIST"}, "(empty array)"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+
+	d.HandleRequest(session, "FUNCTION", []string{"LOAD", counterLibrary})
+	errors := []struct {
+		method string
+		args   []string
+	}{
+		{"FCALL", []string{"missing", "0"}},
+		{"FUNCTION", []string{"LOAD", counterLibrary}},
+		{"FUNCTION", []string{"LOAD", "#!lua name=copy\ncache.register_function('add', function() end)"}},
+		{"FUNCTION", []string{"LOAD", "cache.register_function('f', function() end)"}},
+		{"FUNCTION", []string{"LOAD", "#!js name=js\n"}},
+		{"FUNCTION", []string{"LOAD", "#!lua name=empty\nlocal x = 1"}},
+		{"FUNCTION", []string{"LOAD", "#!lua name=twice\ncache.register_function('f', print)\ncache.register_function('f', print)"}},
+		{"FUNCTION", []string{"LOAD", "#!lua name=bad-name\ncache.register_function('f', function() end)"}},
+		{"FUNCTION", []string{"DELETE", "missing"}},
+	}
+	for _, step := range errors {
+		if _, err := d.HandleRequest(session, step.method, step.args); err == nil {
+			t.Errorf("%v %v: expected error", step.method, step.args)
+		}
+	}
+	// libraries that failed to load leave the loaded ones untouched
+	if resp, err := d.HandleRequest(session, "FCALL", []string{"add", "1", "n", "1"}); err != nil || resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v %v", resp, err)
+	}
+}
+
+func TestFunctionSnapshot(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	d.HandleRequest(session, "FUNCTION", []string{"LOAD", counterLibrary})
+	d.HandleRequest(session, "FCALL", []string{"add", "1", "c", "3"})
+	if resp, err := d.HandleRequest(session, "SAVE", []string{"functionsave"}); err != nil || resp != "OK" {
+		t.Fatalf("expected OK, got %v %v", resp, err)
+	}
+
+	restored := NewDatabases(2)
+	restored.HandleRequest(session, "FUNCTION", []string{"LOAD", "#!lua name=stale\ncache.register_function('stale', function() end)"})
+	if resp, err := restored.HandleRequest(session, "LOAD", []string{"functionsave"}); err != nil || resp != "OK" {
+		t.Fatalf("expected OK, got %v %v", resp, err)
+	}
+	if resp, err := restored.HandleRequest(session, "FCALL", []string{"add", "1", "c", "1"}); err != nil || resp != "(integer) 4" {
+		t.Errorf("expected (integer) 4, got %v %v", resp, err)
+	}
+	if _, err := restored.HandleRequest(session, "FCALL", []string{"stale", "0"}); err != errFunctionNotFound {
+		t.Errorf("expected libraries to be replaced, got %v", err)
+	}
+}
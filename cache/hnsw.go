@@ -0,0 +1,254 @@
+package cache
+
+import (
+	"container/heap"
+	"math"
+	"math/rand"
+	"sort"
+)
+
+type hnswNode struct {
+	element string
+	vector  []float32
+	// neighbors on every level the node belongs to, starting from level 0
+	neighbors [][]*hnswNode
+}
+
+// hnswCandidate is a node with its distance to the query
+type hnswCandidate struct {
+	node     *hnswNode
+	distance float64
+}
+
+// hnswQueue is a heap of candidates, the closest candidate is on top unless farthest is set
+type hnswQueue struct {
+	items    []hnswCandidate
+	farthest bool
+}
+
+func (q hnswQueue) Len() int {
+	return len(q.items)
+}
+func (q hnswQueue) Less(i, j int) bool {
+	if q.farthest {
+		return q.items[i].distance > q.items[j].distance
+	}
+	return q.items[i].distance < q.items[j].distance
+}
+func (q hnswQueue) Swap(i, j int) {
+	q.items[i], q.items[j] = q.items[j], q.items[i]
+}
+func (q *hnswQueue) Push(val interface{}) {
+	q.items = append(q.items, val.(hnswCandidate))
+}
+func (q *hnswQueue) Pop() interface{} {
+	n := len(q.items)
+	res := q.items[n-1]
+	q.items = q.items[:n-1]
+	return res
+}
+
+// hnswIndex is a Hierarchical Navigable Small World graph for approximate nearest neighbour search.
+// Every node is added to level 0 and to each next level with probability 1/M, searches start
+// from the entry point on the top level and descend greedily
+type hnswIndex struct {
+	m              int
+	efConstruction int
+	levelFactor    float64
+	distance       func(a, b []float32) float64
+	nodes          map[string]*hnswNode
+	entry          *hnswNode
+}
+
+func newHNSWIndex(m, efConstruction int, distance func(a, b []float32) float64) *hnswIndex {
+	return &hnswIndex{
+		m:              m,
+		efConstruction: efConstruction,
+		levelFactor:    1 / math.Log(float64(m)),
+		distance:       distance,
+		nodes:          make(map[string]*hnswNode),
+	}
+}
+
+// maxNeighbors returns how many links a node may have on the level, level 0 is denser
+func (h *hnswIndex) maxNeighbors(level int) int {
+	if level == 0 {
+		return 2 * h.m
+	}
+	return h.m
+}
+
+// searchLevel returns up to ef nodes of the level closest to the query, starting from the entry points
+func (h *hnswIndex) searchLevel(query []float32, entries []hnswCandidate, ef, level int) []hnswCandidate {
+	visited := make(map[*hnswNode]bool, ef*4)
+	candidates := &hnswQueue{}
+	results := &hnswQueue{farthest: true}
+	for _, entry := range entries {
+		visited[entry.node] = true
+		heap.Push(candidates, entry)
+		heap.Push(results, entry)
+		if results.Len() > ef {
+			heap.Pop(results)
+		}
+	}
+	for candidates.Len() != 0 {
+		closest := heap.Pop(candidates).(hnswCandidate)
+		if results.Len() >= ef && closest.distance > results.items[0].distance {
+			break
+		}
+		for _, neighbor := range closest.node.neighbors[level] {
+			if visited[neighbor] {
+				continue
+			}
+			visited[neighbor] = true
+			distance := h.distance(query, neighbor.vector)
+			if results.Len() < ef || distance < results.items[0].distance {
+				heap.Push(candidates, hnswCandidate{neighbor, distance})
+				heap.Push(results, hnswCandidate{neighbor, distance})
+				if results.Len() > ef {
+					heap.Pop(results)
+				}
+			}
+		}
+	}
+	sort.Slice(results.items, func(i, j int) bool {
+		return results.items[i].distance < results.items[j].distance
+	})
+	return results.items
+}
+
+// descend searches greedily from the entry point down to the given level and returns the closest node found
+func (h *hnswIndex) descend(query []float32, level int) []hnswCandidate {
+	entries := []hnswCandidate{{h.entry, h.distance(query, h.entry.vector)}}
+	for l := len(h.entry.neighbors) - 1; l > level; l -= 1 {
+		entries = h.searchLevel(query, entries, 1, l)
+	}
+	return entries
+}
+
+// shrink keeps the closest maxNeighbors links of the node on the level
+func (h *hnswIndex) shrink(node *hnswNode, level int) {
+	links := node.neighbors[level]
+	if len(links) <= h.maxNeighbors(level) {
+		return
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return h.distance(node.vector, links[i].vector) < h.distance(node.vector, links[j].vector)
+	})
+	node.neighbors[level] = links[:h.maxNeighbors(level)]
+}
+
+// connect links the node with the closest candidates in both directions
+func (h *hnswIndex) connect(node *hnswNode, candidates []hnswCandidate, level int) {
+	for _, candidate := range candidates {
+		if len(node.neighbors[level]) == h.m {
+			break
+		}
+		if candidate.node == node {
+			continue
+		}
+		node.neighbors[level] = append(node.neighbors[level], candidate.node)
+		candidate.node.neighbors[level] = append(candidate.node.neighbors[level], node)
+		h.shrink(candidate.node, level)
+	}
+}
+
+func (h *hnswIndex) insert(element string, vector []float32) {
+	level := int(-math.Log(1-rand.Float64()) * h.levelFactor)
+	node := &hnswNode{element, vector, make([][]*hnswNode, level+1)}
+	h.nodes[element] = node
+	if h.entry == nil {
+		h.entry = node
+		return
+	}
+	top := len(h.entry.neighbors) - 1
+	entries := h.descend(vector, level)
+	for l := minInt(level, top); l >= 0; l -= 1 {
+		entries = h.searchLevel(vector, entries, h.efConstruction, l)
+		h.connect(node, entries, l)
+	}
+	if level > top {
+		h.entry = node
+	}
+}
+
+// remove deletes the node and reconnects nodes that linked to it with its neighbors, so the graph stays navigable.
+// Links are not always mutual, so all nodes are checked for links to the removed node
+func (h *hnswIndex) remove(element string) {
+	node, ok := h.nodes[element]
+	if !ok {
+		return
+	}
+	delete(h.nodes, element)
+	for _, other := range h.nodes {
+		for level := 0; level < len(other.neighbors) && level < len(node.neighbors); level += 1 {
+			kept := other.neighbors[level][:0]
+			for _, link := range other.neighbors[level] {
+				if link != node {
+					kept = append(kept, link)
+				}
+			}
+			if len(kept) == len(other.neighbors[level]) {
+				continue
+			}
+			other.neighbors[level] = kept
+			h.reconnect(other, node.neighbors[level], level)
+		}
+	}
+	if h.entry == node {
+		h.entry = nil
+		for _, other := range h.nodes {
+			if h.entry == nil || len(other.neighbors) > len(h.entry.neighbors) {
+				h.entry = other
+			}
+		}
+	}
+}
+
+// reconnect links the node that lost a neighbor with the closest of the candidates it is not linked to yet
+func (h *hnswIndex) reconnect(node *hnswNode, candidates []*hnswNode, level int) {
+	closest := make([]hnswCandidate, 0, len(candidates))
+	for _, candidate := range candidates {
+		if candidate != node && !hnswLinked(node, candidate, level) {
+			closest = append(closest, hnswCandidate{candidate, h.distance(node.vector, candidate.vector)})
+		}
+	}
+	sort.Slice(closest, func(i, j int) bool {
+		return closest[i].distance < closest[j].distance
+	})
+	for _, candidate := range closest {
+		if len(node.neighbors[level]) >= h.maxNeighbors(level) {
+			break
+		}
+		node.neighbors[level] = append(node.neighbors[level], candidate.node)
+	}
+}
+func hnswLinked(a, b *hnswNode, level int) bool {
+	for _, link := range a.neighbors[level] {
+		if link == b {
+			return true
+		}
+	}
+	return false
+}
+
+// search returns up to k nodes closest to the query, ef is the size of the candidate list on level 0
+func (h *hnswIndex) search(query []float32, k, ef int) []hnswCandidate {
+	if h.entry == nil {
+		return nil
+	}
+	if ef < k {
+		ef = k
+	}
+	results := h.searchLevel(query, h.descend(query, 0), ef, 0)
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,60 @@
+package cache
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+)
+
+func randomVectors(r *rand.Rand, n, dim int) [][]float32 {
+	vectors := make([][]float32, n)
+	for i := range vectors {
+		vectors[i] = make([]float32, dim)
+		for j := range vectors[i] {
+			vectors[i][j] = r.Float32()*2 - 1
+		}
+	}
+	return vectors
+}
+
+// recall returns the share of exact nearest neighbours found by the HNSW index
+func recall(t *testing.T, flat, hnsw *VectorSet, queries [][]float32, k int) float64 {
+	found := 0
+	for _, query := range queries {
+		exact := make(map[string]bool)
+		for _, result := range flat.search(query, k, 0) {
+			exact[result.node.element] = true
+		}
+		for _, result := range hnsw.search(query, k, 50) {
+			if exact[result.node.element] {
+				found += 1
+			}
+		}
+	}
+	return float64(found) / float64(len(queries)*k)
+}
+
+func TestHNSWRecall(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	flat := NewVectorSet(16, "L2", "FLAT", 0, 0)
+	hnsw := NewVectorSet(16, "L2", "HNSW", 16, 100)
+	for i, vector := range randomVectors(r, 2000, 16) {
+		flat.add(fmt.Sprint(i), vector)
+		hnsw.add(fmt.Sprint(i), vector)
+	}
+	queries := randomVectors(r, 50, 16)
+	if res := recall(t, flat, hnsw, queries, 10); res < 0.9 {
+		t.Errorf("expected recall of at least 0.9, got %v", res)
+	}
+
+	for i := 0; i < 1000; i += 1 {
+		flat.remove(fmt.Sprint(i))
+		hnsw.remove(fmt.Sprint(i))
+	}
+	if len(hnsw.index.nodes) != 1000 {
+		t.Errorf("expected 1000 nodes, got %v", len(hnsw.index.nodes))
+	}
+	if res := recall(t, flat, hnsw, queries, 10); res < 0.9 {
+		t.Errorf("expected recall of at least 0.9 after removals, got %v", res)
+	}
+}
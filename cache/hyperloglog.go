@@ -0,0 +1,310 @@
+package cache
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"math/bits"
+	"sort"
+)
+
+const (
+	hllP         = 14
+	hllQ         = 64 - hllP
+	hllRegisters = 1 << hllP
+	hllBits      = 6
+	hllMask      = 1<<hllBits - 1
+	// size of the dense representation, registers are packed 6 bits each
+	hllDenseSize = hllRegisters * hllBits / 8
+	// sparse representation is converted to dense when it grows larger than this number of registers
+	hllSparseMax = 1000
+)
+
+// HLLRegister is a non-zero register of the sparse representation
+type HLLRegister struct {
+	Index uint16
+	Value uint8
+}
+
+// HyperLogLog estimates the number of unique elements with the standard error of 0.81%.
+// While only a few registers are set they are stored sparsely, sorted by index,
+// later the value is converted to the dense representation with all registers packed
+type HyperLogLog struct {
+	Sparse []HLLRegister
+	Dense  []byte
+}
+
+func NewHyperLogLog() *HyperLogLog {
+	return &HyperLogLog{Sparse: make([]HLLRegister, 0)}
+}
+
+func (h *HyperLogLog) isDense() bool {
+	return h.Dense != nil
+}
+func (h *HyperLogLog) get(index uint16) uint8 {
+	if !h.isDense() {
+		i := h.searchSparse(index)
+		if i < len(h.Sparse) && h.Sparse[i].Index == index {
+			return h.Sparse[i].Value
+		}
+		return 0
+	}
+	bit := uint(index) * hllBits
+	b := bit / 8
+	shift := bit % 8
+	value := uint(h.Dense[b]) >> shift
+	if b+1 < hllDenseSize {
+		value |= uint(h.Dense[b+1]) << (8 - shift)
+	}
+	return uint8(value & hllMask)
+}
+func (h *HyperLogLog) setDense(index uint16, value uint8) {
+	bit := uint(index) * hllBits
+	b := bit / 8
+	shift := bit % 8
+	h.Dense[b] &^= byte(hllMask << shift)
+	h.Dense[b] |= byte(uint(value) << shift)
+	if b+1 < hllDenseSize {
+		h.Dense[b+1] &^= byte(hllMask >> (8 - shift))
+		h.Dense[b+1] |= byte(uint(value) >> (8 - shift))
+	}
+}
+func (h *HyperLogLog) searchSparse(index uint16) int {
+	return sort.Search(len(h.Sparse), func(i int) bool {
+		return h.Sparse[i].Index >= index
+	})
+}
+
+// set updates the register if the value is greater than the stored one, returns true if it was updated
+func (h *HyperLogLog) set(index uint16, value uint8) bool {
+	if h.get(index) >= value {
+		return false
+	}
+	if h.isDense() {
+		h.setDense(index, value)
+		return true
+	}
+	i := h.searchSparse(index)
+	if i < len(h.Sparse) && h.Sparse[i].Index == index {
+		h.Sparse[i].Value = value
+		return true
+	}
+	h.Sparse = append(h.Sparse, HLLRegister{})
+	copy(h.Sparse[i+1:], h.Sparse[i:])
+	h.Sparse[i] = HLLRegister{index, value}
+	if len(h.Sparse) > hllSparseMax {
+		h.toDense()
+	}
+	return true
+}
+func (h *HyperLogLog) toDense() {
+	sparse := h.Sparse
+	h.Dense = make([]byte, hllDenseSize)
+	h.Sparse = nil
+	for _, register := range sparse {
+		h.setDense(register.Index, register.Value)
+	}
+}
+
+// Add adds the element, returns true if the estimation may have changed
+func (h *HyperLogLog) Add(element string) bool {
+	hash := murmurHash64A([]byte(element), 0xadc83b19)
+	index := uint16(hash & (hllRegisters - 1))
+	// the bit at position hllQ guarantees the loop in TrailingZeros terminates
+	hash = hash>>hllP | 1<<hllQ
+	return h.set(index, uint8(bits.TrailingZeros64(hash)+1))
+}
+
+// Merge sets every register to the maximum of its value and the value in the other HyperLogLog
+func (h *HyperLogLog) Merge(other *HyperLogLog) {
+	if !other.isDense() {
+		for _, register := range other.Sparse {
+			h.set(register.Index, register.Value)
+		}
+		return
+	}
+	if !h.isDense() {
+		h.toDense()
+	}
+	for i := 0; i < hllRegisters; i += 1 {
+		if value := other.get(uint16(i)); value > h.get(uint16(i)) {
+			h.setDense(uint16(i), value)
+		}
+	}
+}
+
+// Count estimates cardinality using the improved estimator by Otmar Ertl
+func (h *HyperLogLog) Count() uint64 {
+	var histogram [hllQ + 2]int
+	if h.isDense() {
+		for i := 0; i < hllRegisters; i += 1 {
+			histogram[h.get(uint16(i))] += 1
+		}
+	} else {
+		histogram[0] = hllRegisters - len(h.Sparse)
+		for _, register := range h.Sparse {
+			histogram[register.Value] += 1
+		}
+	}
+	m := float64(hllRegisters)
+	z := m * hllTau((m-float64(histogram[hllQ+1]))/m)
+	for k := hllQ; k >= 1; k -= 1 {
+		z += float64(histogram[k])
+		z *= 0.5
+	}
+	z += m * hllSigma(float64(histogram[0])/m)
+	alpha := 0.5 / math.Ln2
+	return uint64(math.Round(alpha * m * m / z))
+}
+func hllSigma(x float64) float64 {
+	if x == 1 {
+		return math.Inf(1)
+	}
+	y := 1.0
+	z := x
+	for {
+		x *= x
+		prev := z
+		z += x * y
+		y += y
+		if prev == z {
+			return z
+		}
+	}
+}
+func hllTau(x float64) float64 {
+	if x == 0 || x == 1 {
+		return 0
+	}
+	y := 1.0
+	z := 1 - x
+	for {
+		x = math.Sqrt(x)
+		prev := z
+		y *= 0.5
+		z -= math.Pow(1-x, 2) * y
+		if prev == z {
+			return z / 3
+		}
+	}
+}
+
+// murmurHash64A is the 64 bit MurmurHash2 variant by Austin Appleby
+func murmurHash64A(key []byte, seed uint64) uint64 {
+	const m uint64 = 0xc6a4a7935bd1e995
+	const r = 47
+	h := seed ^ uint64(len(key))*m
+	for len(key) >= 8 {
+		k := binary.LittleEndian.Uint64(key)
+		k *= m
+		k ^= k >> r
+		k *= m
+		h ^= k
+		h *= m
+		key = key[8:]
+	}
+	if len(key) > 0 {
+		var tail uint64
+		for i := len(key) - 1; i >= 0; i -= 1 {
+			tail = tail<<8 | uint64(key[i])
+		}
+		h ^= tail
+		h *= m
+	}
+	h ^= h >> r
+	h *= m
+	h ^= h >> r
+	return h
+}
+
+// Mutex must be locked before calling getHyperLogLog. Returns nil if create is false and key is not set
+func (c *cache) getHyperLogLog(key string, create bool) (hll *HyperLogLog, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+		if create {
+			hll = NewHyperLogLog()
+			c.write(key, hll)
+		}
+	case *HyperLogLog:
+		hll = stored.(*HyperLogLog)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) PFAdd(args []string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	created := c.read(args[0]) == nil
+	hll, err := c.getHyperLogLog(args[0], true)
+	if err != nil {
+		return
+	}
+	updated := created
+	for _, element := range args[1:] {
+		if hll.Add(element) {
+			updated = true
+		}
+	}
+	if updated {
+		c.modified(args[0])
+		c.notify(stringClass, "pfadd", args[0])
+		response = int64(1)
+	} else {
+		response = int64(0)
+	}
+	return
+}
+func (c *cache) PFCount(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	union := NewHyperLogLog()
+	for _, key := range args {
+		var hll *HyperLogLog
+		hll, err = c.getHyperLogLog(key, false)
+		if err != nil {
+			return
+		}
+		if hll == nil {
+			continue
+		}
+		if len(args) == 1 {
+			union = hll
+			break
+		}
+		union.Merge(hll)
+	}
+	response = int64(union.Count())
+	return
+}
+func (c *cache) PFMerge(args []string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	// sources are checked before the destination is created so that a type error leaves no trace
+	sources := make([]*HyperLogLog, 0, len(args))
+	for _, key := range args {
+		var hll *HyperLogLog
+		hll, err = c.getHyperLogLog(key, false)
+		if err != nil {
+			return
+		}
+		if hll != nil {
+			sources = append(sources, hll)
+		}
+	}
+	dest, err := c.getHyperLogLog(args[0], true)
+	if err != nil {
+		return
+	}
+	for _, hll := range sources {
+		if hll != dest {
+			dest.Merge(hll)
+		}
+	}
+	c.modified(args[0])
+	c.notify(stringClass, "pfadd", args[0])
+	response = formatted("OK")
+	return
+}
@@ -0,0 +1,151 @@
+package cache
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func parseInteger(t *testing.T, resp string) int {
+	n, err := strconv.Atoi(strings.TrimPrefix(resp, "(integer) "))
+	if err != nil {
+		t.Error(err)
+	}
+	return n
+}
+
+func TestPFAdd(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	resp, err := text(c.PFAdd([]string{"hll"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.PFAdd([]string{"hll", "a", "b", "c"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.PFAdd([]string{"hll", "a", "b"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.PFCount([]string{"hll"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 3" {
+		t.Errorf("expected (integer) 3, got %v", resp)
+	}
+
+	c.Set([]string{"string", "value"})
+	_, err = text(c.PFAdd([]string{"string", "a"}))
+	if err == nil || err.Error() != "Requested field is of type string" {
+		t.Error(err)
+	}
+}
+
+func TestPFCountAccuracy(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	for _, n := range []int{100, 1000, 10000, 100000} {
+		key := fmt.Sprintf("hll%v", n)
+		for i := 0; i < n; i += 1 {
+			c.PFAdd([]string{key, fmt.Sprintf("element%v", i)})
+		}
+		resp, err := text(c.PFCount([]string{key}))
+		if err != nil {
+			t.Error(err)
+		}
+		estimate := parseInteger(t, resp)
+		if math.Abs(float64(estimate-n))/float64(n) > 0.03 {
+			t.Errorf("estimate %v is too far from %v", estimate, n)
+		}
+	}
+	if c.read("hll100").(*HyperLogLog).isDense() {
+		t.Error("small HyperLogLog must use sparse representation")
+	}
+	if !c.read("hll100000").(*HyperLogLog).isDense() {
+		t.Error("large HyperLogLog must use dense representation")
+	}
+}
+
+func TestPFMerge(t *testing.T) {
+	c := (NewCache()).(*cache)
+	for i := 0; i < 5000; i += 1 {
+		c.PFAdd([]string{"first", fmt.Sprintf("element%v", i)})
+		c.PFAdd([]string{"second", fmt.Sprintf("element%v", i+2500)})
+	}
+
+	resp, err := text(c.PFCount([]string{"first", "second", "missing"}))
+	if err != nil {
+		t.Error(err)
+	}
+	union := parseInteger(t, resp)
+	if math.Abs(float64(union-7500))/7500 > 0.03 {
+		t.Errorf("estimate %v is too far from %v", union, 7500)
+	}
+	resp, err = text(c.PFMerge([]string{"merged", "first", "second"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	resp, err = text(c.PFCount([]string{"merged"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if parseInteger(t, resp) != union {
+		t.Errorf("expected (integer) %v, got %v", union, resp)
+	}
+}
+
+func TestHyperLogLogSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	for i := 0; i < 10000; i += 1 {
+		c.PFAdd([]string{"dense", fmt.Sprintf("element%v", i)})
+	}
+	c.PFAdd([]string{"sparse", "a", "b", "c"})
+	dense, _ := text(c.PFCount([]string{"dense"}))
+
+	_, err := text(c.Save([]string{"hllsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"hllsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.PFCount([]string{"dense"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != dense {
+		t.Errorf("expected %v, got %v", dense, resp)
+	}
+	resp, err = text(c.PFCount([]string{"sparse"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 3" {
+		t.Errorf("expected (integer) 3, got %v", resp)
+	}
+
+	err = os.Remove("./saves/hllsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
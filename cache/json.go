@@ -0,0 +1,591 @@
+package cache
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+var errJSONPathNotFound = errors.New("Path does not exist")
+
+// JSONDocument is a parsed JSON value. Objects are stored as map[string]interface{}, arrays as []interface{},
+// numbers as json.Number so integers keep their precision
+type JSONDocument struct {
+	Value interface{}
+}
+
+// parseJSONValue parses a single JSON value keeping numbers as json.Number
+func parseJSONValue(data []byte) (value interface{}, err error) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&value)
+	if err != nil {
+		return nil, errors.New(fmt.Sprintf("expected JSON value: %v", err))
+	}
+	if _, err = decoder.Token(); err != io.EOF {
+		return nil, errors.New("expected JSON value: trailing characters")
+	}
+	return value, nil
+}
+
+// encodeJSON serializes the value without escaping HTML characters
+func encodeJSON(value interface{}) string {
+	var buffer bytes.Buffer
+	encoder := json.NewEncoder(&buffer)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(value)
+	return strings.TrimSuffix(buffer.String(), "\n")
+}
+
+// jsonSegment is one step of a path: an object key, an array index or a wildcard.
+// recursive segments are applied to the value and all of its descendants
+type jsonSegment struct {
+	key       string
+	index     int
+	isIndex   bool
+	wildcard  bool
+	recursive bool
+}
+
+// jsonPath is a parsed path. Paths starting with $ are JSONPath and may match any number of values,
+// other paths use the legacy syntax (.a.b[0]) and address a single value
+type jsonPath struct {
+	segments []jsonSegment
+	legacy   bool
+}
+
+func parseJSONPath(path string) (p jsonPath, err error) {
+	errSyntax := errors.New(fmt.Sprintf("invalid JSON path: %v", path))
+	rest := path
+	if strings.HasPrefix(rest, "$") {
+		rest = rest[1:]
+	} else {
+		p.legacy = true
+		if rest != "" && rest[0] != '.' && rest[0] != '[' {
+			rest = "." + rest
+		}
+		if rest == "." {
+			rest = ""
+		}
+	}
+	for rest != "" {
+		var segment jsonSegment
+		if strings.HasPrefix(rest, "..") {
+			segment.recursive = true
+			rest = rest[1:]
+			if len(rest) > 1 && rest[1] == '[' {
+				rest = rest[1:]
+			}
+		}
+		switch rest[0] {
+		case '.':
+			end := strings.IndexAny(rest[1:], ".[")
+			if end < 0 {
+				end = len(rest) - 1
+			}
+			name := rest[1 : end+1]
+			if name == "" {
+				return p, errSyntax
+			}
+			if name == "*" {
+				segment.wildcard = true
+			} else {
+				segment.key = name
+			}
+			rest = rest[end+1:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return p, errSyntax
+			}
+			inner := strings.TrimSpace(rest[1:end])
+			switch {
+			case inner == "*":
+				segment.wildcard = true
+			case len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0]:
+				segment.key = inner[1 : len(inner)-1]
+			default:
+				segment.index, err = strconv.Atoi(inner)
+				if err != nil {
+					return p, errSyntax
+				}
+				segment.isIndex = true
+			}
+			rest = rest[end+1:]
+		default:
+			return p, errSyntax
+		}
+		p.segments = append(p.segments, segment)
+	}
+	return p, nil
+}
+
+// jsonLocation addresses a value in a document through its parent, so the value can be replaced or deleted.
+// The root location has no parent
+type jsonLocation struct {
+	parent  *jsonLocation
+	key     string
+	index   int
+	isIndex bool
+}
+
+func (l *jsonLocation) get(d *JSONDocument) interface{} {
+	if l.parent == nil {
+		return d.Value
+	}
+	switch parent := l.parent.get(d).(type) {
+	case map[string]interface{}:
+		return parent[l.key]
+	case []interface{}:
+		return parent[l.index]
+	}
+	return nil
+}
+func (l *jsonLocation) set(d *JSONDocument, value interface{}) {
+	if l.parent == nil {
+		d.Value = value
+		return
+	}
+	switch parent := l.parent.get(d).(type) {
+	case map[string]interface{}:
+		parent[l.key] = value
+	case []interface{}:
+		parent[l.index] = value
+	}
+}
+func (l *jsonLocation) delete(d *JSONDocument) {
+	switch parent := l.parent.get(d).(type) {
+	case map[string]interface{}:
+		delete(parent, l.key)
+	case []interface{}:
+		l.parent.set(d, append(parent[:l.index:l.index], parent[l.index+1:]...))
+	}
+}
+func (l *jsonLocation) isDescendantOf(ancestors map[*jsonLocation]bool) bool {
+	for p := l.parent; p != nil; p = p.parent {
+		if ancestors[p] {
+			return true
+		}
+	}
+	return false
+}
+
+// children returns locations of all direct children of the value, keys of objects are sorted
+func (l *jsonLocation) children(d *JSONDocument) (res []*jsonLocation) {
+	switch value := l.get(d).(type) {
+	case map[string]interface{}:
+		for _, key := range sortedKeys(value) {
+			res = append(res, &jsonLocation{parent: l, key: key})
+		}
+	case []interface{}:
+		for i := range value {
+			res = append(res, &jsonLocation{parent: l, index: i, isIndex: true})
+		}
+	}
+	return
+}
+
+// descendants returns the location and all locations below it in pre-order
+func (l *jsonLocation) descendants(d *JSONDocument) []*jsonLocation {
+	res := []*jsonLocation{l}
+	for _, child := range l.children(d) {
+		res = append(res, child.descendants(d)...)
+	}
+	return res
+}
+func sortedKeys(m map[string]interface{}) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// apply returns the locations matched by the segment starting from the given location
+func (s jsonSegment) apply(d *JSONDocument, l *jsonLocation) (res []*jsonLocation) {
+	switch value := l.get(d).(type) {
+	case map[string]interface{}:
+		if s.wildcard {
+			return l.children(d)
+		}
+		if _, ok := value[s.key]; ok && !s.isIndex {
+			res = append(res, &jsonLocation{parent: l, key: s.key})
+		}
+	case []interface{}:
+		if s.wildcard {
+			return l.children(d)
+		}
+		index := s.index
+		if index < 0 {
+			index += len(value)
+		}
+		if s.isIndex && index >= 0 && index < len(value) {
+			res = append(res, &jsonLocation{parent: l, index: index, isIndex: true})
+		}
+	}
+	return
+}
+
+// find returns the locations of all values matched by the path
+func (d *JSONDocument) find(p jsonPath) []*jsonLocation {
+	locations := []*jsonLocation{{}}
+	for _, segment := range p.segments {
+		var next []*jsonLocation
+		for _, l := range locations {
+			sources := []*jsonLocation{l}
+			if segment.recursive {
+				sources = l.descendants(d)
+			}
+			for _, source := range sources {
+				next = append(next, segment.apply(d, source)...)
+			}
+		}
+		locations = next
+	}
+	return locations
+}
+
+// Mutex must be locked before calling getJSON. Returns nil if key is not set
+func (c *cache) getJSON(key string) (doc *JSONDocument, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case *JSONDocument:
+		doc = stored.(*JSONDocument)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) JSONSet(args []string) (response interface{}, err error) {
+	if len(args) != 3 && len(args) != 4 {
+		err = syntaxError("JSON.SET")
+		return
+	}
+	nx, xx := false, false
+	if len(args) == 4 {
+		switch strings.ToUpper(args[3]) {
+		case "NX":
+			nx = true
+		case "XX":
+			xx = true
+		default:
+			err = errors.New("syntax error")
+			return
+		}
+	}
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		return
+	}
+	value, err := parseJSONValue([]byte(args[2]))
+	if err != nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	doc, err := c.getJSON(args[0])
+	if err != nil {
+		return
+	}
+	response = nil
+	if doc == nil {
+		if len(path.segments) != 0 {
+			err = errors.New("new objects must be created at the root")
+			return
+		}
+		if !xx {
+			c.write(args[0], &JSONDocument{value})
+			c.notify(moduleClass, "json.set", args[0])
+			response = formatted("OK")
+		}
+		return
+	}
+	locations := doc.find(path)
+	if len(locations) != 0 {
+		if nx {
+			return
+		}
+		for _, l := range locations {
+			l.set(doc, copyJSONValue(value))
+		}
+		c.modified(args[0])
+		c.notify(moduleClass, "json.set", args[0])
+		response = formatted("OK")
+		return
+	}
+	// a missing object key can be added if its parent exists
+	last := path.segments[len(path.segments)-1]
+	if xx || last.wildcard || last.isIndex || last.recursive {
+		return
+	}
+	parents := doc.find(jsonPath{path.segments[:len(path.segments)-1], path.legacy})
+	for _, parent := range parents {
+		if object, ok := parent.get(doc).(map[string]interface{}); ok {
+			object[last.key] = copyJSONValue(value)
+			c.modified(args[0])
+			c.notify(moduleClass, "json.set", args[0])
+			response = formatted("OK")
+		}
+	}
+	if response == nil && path.legacy {
+		err = errJSONPathNotFound
+	}
+	return
+}
+
+// copyJSONValue returns a deep copy of the value, so that values set at several paths are independent
+func copyJSONValue(value interface{}) interface{} {
+	switch value := value.(type) {
+	case map[string]interface{}:
+		res := make(map[string]interface{}, len(value))
+		for key, item := range value {
+			res[key] = copyJSONValue(item)
+		}
+		return res
+	case []interface{}:
+		res := make([]interface{}, len(value))
+		for i, item := range value {
+			res[i] = copyJSONValue(item)
+		}
+		return res
+	}
+	return value
+}
+
+// JSONGet returns the value at the path serialized as JSON. JSONPath results are wrapped into an array
+// of all matches, several paths are returned as an object keyed by path
+func (c *cache) JSONGet(args []string) (response interface{}, err error) {
+	paths := make([]jsonPath, len(args)-1)
+	for i := range paths {
+		paths[i], err = parseJSONPath(args[i+1])
+		if err != nil {
+			return
+		}
+	}
+	if len(paths) == 0 {
+		paths = append(paths, jsonPath{legacy: true})
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	doc, err := c.getJSON(args[0])
+	if err != nil {
+		return
+	}
+	if doc == nil {
+		response = nil
+		return
+	}
+	results := make([]interface{}, len(paths))
+	for i, path := range paths {
+		locations := doc.find(path)
+		if path.legacy {
+			if len(locations) == 0 {
+				err = errJSONPathNotFound
+				return
+			}
+			results[i] = locations[0].get(doc)
+			continue
+		}
+		values := make([]interface{}, len(locations))
+		for j, l := range locations {
+			values[j] = l.get(doc)
+		}
+		results[i] = values
+	}
+	if len(results) == 1 {
+		response = encodeJSON(results[0])
+		return
+	}
+	object := make(map[string]interface{}, len(results))
+	for i, result := range results {
+		object[args[i+1]] = result
+	}
+	response = encodeJSON(object)
+	return
+}
+
+// JSONDel deletes the values at the path and returns their number, deleting the root deletes the key
+func (c *cache) JSONDel(args []string) (response interface{}, err error) {
+	if len(args) != 1 && len(args) != 2 {
+		err = syntaxError("JSON.DEL")
+		return
+	}
+	path := jsonPath{legacy: true}
+	if len(args) == 2 {
+		path, err = parseJSONPath(args[1])
+		if err != nil {
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	doc, err := c.getJSON(args[0])
+	if err != nil {
+		return
+	}
+	if doc == nil {
+		response = int64(0)
+		return
+	}
+	if len(path.segments) == 0 {
+		c.delete(args[0])
+		c.notify(moduleClass, "json.del", args[0])
+		response = int64(1)
+		return
+	}
+	locations := doc.find(path)
+	deleted := make(map[*jsonLocation]bool, len(locations))
+	for _, l := range locations {
+		deleted[l] = true
+	}
+	// values are deleted from the end, so indexes of array elements that are not deleted yet stay valid
+	count := 0
+	for i := len(locations) - 1; i >= 0; i -= 1 {
+		if locations[i].isDescendantOf(deleted) {
+			continue
+		}
+		locations[i].delete(doc)
+		count += 1
+	}
+	if count != 0 {
+		c.modified(args[0])
+		c.notify(moduleClass, "json.del", args[0])
+	}
+	response = int64(count)
+	return
+}
+
+// addJSONNumbers adds numbers keeping the result an integer if both arguments are integers
+func addJSONNumbers(a, b json.Number) (json.Number, error) {
+	x, errX := a.Int64()
+	y, errY := b.Int64()
+	if errX == nil && errY == nil {
+		return json.Number(strconv.FormatInt(x+y, 10)), nil
+	}
+	fx, errX := a.Float64()
+	fy, errY := b.Float64()
+	if errX != nil || errY != nil {
+		return "", errors.New("number is out of range")
+	}
+	return json.Number(encodeJSON(fx + fy)), nil
+}
+
+// JSONNumIncrBy increments the numbers at the path. For JSONPath the result is an array with
+// the new value of each match or null if the match is not a number
+func (c *cache) JSONNumIncrBy(args []string) (response interface{}, err error) {
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		return
+	}
+	value, err := parseJSONValue([]byte(args[2]))
+	if err != nil {
+		return
+	}
+	increment, ok := value.(json.Number)
+	if !ok {
+		err = errors.New("expected numeric value")
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	doc, err := c.getJSON(args[0])
+	if err != nil {
+		return
+	}
+	if doc == nil {
+		err = errors.New("could not perform this operation on a key that doesn't exist")
+		return
+	}
+	locations := doc.find(path)
+	results := make([]interface{}, len(locations))
+	for i, l := range locations {
+		number, ok := l.get(doc).(json.Number)
+		if !ok {
+			continue
+		}
+		number, err = addJSONNumbers(number, increment)
+		if err != nil {
+			return
+		}
+		l.set(doc, number)
+		c.modified(args[0])
+		c.notify(moduleClass, "json.numincrby", args[0])
+		results[i] = number
+	}
+	if path.legacy {
+		if len(results) == 0 {
+			err = errJSONPathNotFound
+			return
+		}
+		if results[0] == nil {
+			err = errors.New(fmt.Sprintf("Path '%v' does not contain a number", args[1]))
+			return
+		}
+		response = encodeJSON(results[0])
+		return
+	}
+	response = encodeJSON(results)
+	return
+}
+
+// JSONArrAppend appends values to the arrays at the path and returns their new lengths,
+// nil for matches that are not arrays
+func (c *cache) JSONArrAppend(args []string) (response interface{}, err error) {
+	path, err := parseJSONPath(args[1])
+	if err != nil {
+		return
+	}
+	values := make([]interface{}, len(args)-2)
+	for i := range values {
+		values[i], err = parseJSONValue([]byte(args[i+2]))
+		if err != nil {
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	doc, err := c.getJSON(args[0])
+	if err != nil {
+		return
+	}
+	if doc == nil {
+		err = errors.New("could not perform this operation on a key that doesn't exist")
+		return
+	}
+	locations := doc.find(path)
+	results := make([]interface{}, len(locations))
+	for i, l := range locations {
+		array, ok := l.get(doc).([]interface{})
+		if !ok {
+			continue
+		}
+		for _, value := range values {
+			array = append(array, copyJSONValue(value))
+		}
+		l.set(doc, array)
+		c.modified(args[0])
+		c.notify(moduleClass, "json.arrappend", args[0])
+		results[i] = len(array)
+	}
+	if path.legacy {
+		if len(results) == 0 {
+			err = errJSONPathNotFound
+			return
+		}
+		if results[0] == nil {
+			err = errors.New(fmt.Sprintf("Path '%v' does not contain an array", args[1]))
+			return
+		}
+		response = results[0]
+		return
+	}
+	response = results
+	return
+}
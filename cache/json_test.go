@@ -0,0 +1,187 @@
+package cache
+
+import (
+	"os"
+	"testing"
+)
+
+func TestJSONSetGet(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.JSONSet([]string{"doc", "$.a", "1"}))
+	if err == nil {
+		t.Error("expected error on creating a new document not at the root")
+	}
+	resp, err := text(c.JSONSet([]string{"doc", "$", `{"a":1,"b":{"a":"x","c":[1,2,3]},"url":"a<b>"}`}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"doc"}, `{"a":1,"b":{"a":"x","c":[1,2,3]},"url":"a<b>"}`},
+		{[]string{"doc", ".b.c[1]"}, `2`},
+		{[]string{"doc", "b['c'][-1]"}, `3`},
+		{[]string{"doc", "$..a"}, `[1,"x"]`},
+		{[]string{"doc", "$.b.c[*]"}, `[1,2,3]`},
+		{[]string{"doc", "$.missing"}, `[]`},
+		{[]string{"doc", "$.a", ".b.a"}, `{"$.a":[1],".b.a":"x"}`},
+	}
+	for _, test := range tests {
+		resp, err = text(c.JSONGet(test.args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != test.expected {
+			t.Errorf("JSON.GET %v: expected %v, got %v", test.args, test.expected, resp)
+		}
+	}
+	_, err = text(c.JSONGet([]string{"doc", ".missing"}))
+	if err != errJSONPathNotFound {
+		t.Errorf("expected %v, got %v", errJSONPathNotFound, err)
+	}
+
+	resp, err = text(c.JSONSet([]string{"doc", "$.b.d", `{"e":true}`}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	resp, err = text(c.JSONSet([]string{"doc", "$.a", "5", "NX"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+	resp, err = text(c.JSONSet([]string{"doc", "$.x.y", "5"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+	c.JSONSet([]string{"doc", "$..a", "null"})
+	resp, _ = text(c.JSONGet([]string{"doc", "$.b"}))
+	if resp != `[{"a":null,"c":[1,2,3],"d":{"e":true}}]` {
+		t.Errorf(`expected [{"a":null,"c":[1,2,3],"d":{"e":true}}], got %v`, resp)
+	}
+	_, err = text(c.JSONSet([]string{"doc", "$", "{"}))
+	if err == nil {
+		t.Error("expected error on invalid JSON")
+	}
+
+	c.Set([]string{"string", "value"})
+	_, err = text(c.JSONGet([]string{"string"}))
+	if err == nil {
+		t.Error("expected error on wrong type")
+	}
+}
+
+func TestJSONDel(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.JSONSet([]string{"doc", ".", `{"a":[1,2,3,4],"b":{"a":1},"c":2}`})
+
+	resp, err := text(c.JSONDel([]string{"doc", "$.a[*]"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 4" {
+		t.Errorf("expected (integer) 4, got %v", resp)
+	}
+	resp, err = text(c.JSONDel([]string{"doc", "$..a"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 2" {
+		t.Errorf("expected (integer) 2, got %v", resp)
+	}
+	resp, _ = text(c.JSONGet([]string{"doc"}))
+	if resp != `{"b":{},"c":2}` {
+		t.Errorf(`expected {"b":{},"c":2}, got %v`, resp)
+	}
+	resp, err = text(c.JSONDel([]string{"doc"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" || c.read("doc") != nil {
+		t.Errorf("expected the key to be deleted, got %v", resp)
+	}
+}
+
+func TestJSONNumIncrByArrAppend(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.JSONSet([]string{"doc", "$", `{"a":1,"b":{"a":"x"},"c":{"a":1.5},"big":9007199254740993,"list":[1]}`})
+
+	resp, err := text(c.JSONNumIncrBy([]string{"doc", "$..a", "2"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "[3,null,3.5]" {
+		t.Errorf("expected [3,null,3.5], got %v", resp)
+	}
+	resp, err = text(c.JSONNumIncrBy([]string{"doc", ".big", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "9007199254740994" {
+		t.Errorf("expected 9007199254740994, got %v", resp)
+	}
+	_, err = text(c.JSONNumIncrBy([]string{"doc", ".b", "1"}))
+	if err == nil {
+		t.Error("expected error on incrementing an object")
+	}
+
+	resp, err = text(c.JSONArrAppend([]string{"doc", ".list", "2", `{"x":1}`}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 3" {
+		t.Errorf("expected (integer) 3, got %v", resp)
+	}
+	resp, err = text(c.JSONArrAppend([]string{"doc", "$.*", `"y"`}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) (nil)\n2) (nil)\n3) (nil)\n4) (nil)\n5) (integer) 4"
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, _ = text(c.JSONGet([]string{"doc", "list"}))
+	if resp != `[1,2,{"x":1},"y"]` {
+		t.Errorf(`expected [1,2,{"x":1},"y"], got %v`, resp)
+	}
+}
+
+func TestJSONSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.JSONSet([]string{"doc", "$", `{"id":9007199254740993,"tags":["a","b"],"nested":{"ok":true}}`})
+
+	_, err := text(c.Save([]string{"jsonsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"jsonsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.JSONGet([]string{"doc"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := `{"id":9007199254740993,"nested":{"ok":true},"tags":["a","b"]}`
+	if resp != expected {
+		t.Errorf("expected %v, got %v", expected, resp)
+	}
+
+	err = os.Remove("./saves/jsonsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
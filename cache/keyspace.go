@@ -0,0 +1,309 @@
+package cache
+
+import (
+	"container/heap"
+	"errors"
+	"strings"
+	"time"
+)
+
+var errNoSuchKey = errors.New("no such key")
+
+// c.Exps.m must be locked before calling expiresAt. Returns the expiration time of the key if it is set
+func (c *cache) expiresAt(key string) (expires time.Time, ok bool) {
+	index, ok := c.Exps.Indexes[key]
+	if ok {
+		expires = c.Exps.Expirations[index].Expires
+	}
+	return
+}
+
+// c.Exps.m must be locked before calling setExpiresAt. Replaces the expiration of the key
+func (c *cache) setExpiresAt(key string, expires time.Time) {
+	c.setExpiration(key, 0)
+	heap.Push(&c.Exps, expiration{key, expires})
+}
+
+// copyValue returns a deep copy of the value. Typed values are copied through their snapshot encoding
+func copyValue(value interface{}) (interface{}, error) {
+	switch value.(type) {
+	case string:
+		return value, nil
+	case RList:
+		res := NewRList()
+		for iter := value.(RList).Value.Front(); iter != nil; iter = iter.Next() {
+			res.Value.PushBack(iter.Value)
+		}
+		return res, nil
+	case Hashmap:
+		res := NewHashmap()
+		for field, fieldValue := range value.(Hashmap).Hashmap {
+			res.Write(field, fieldValue)
+		}
+		return res, nil
+	}
+	b, err := marshalValue(value)
+	if err != nil {
+		return nil, err
+	}
+	return unmarshalTyped(valueType(value), b)
+}
+
+func (c *cache) Exists(args []string) (response interface{}, err error) {
+	counter := 0
+	c.m.RLock()
+	for _, key := range args {
+		if c.read(key) != nil {
+			counter += 1
+		}
+	}
+	c.m.RUnlock()
+	response = int64(counter)
+	return
+}
+func (c *cache) Type(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	response = valueType(c.read(args[0]))
+	return
+}
+
+// rename moves the value and the expiration of the key to the new name, removing the expiration of the new name.
+// Both mutexes must be locked before calling rename
+func (c *cache) rename(key, newKey string) {
+	if key == newKey {
+		return
+	}
+	value := c.read(key)
+	if series, ok := value.(*TimeSeries); ok {
+		c.renameSeries(series, key, newKey)
+	}
+	expires, ok := c.expiresAt(key)
+	c.setExpiration(key, 0)
+	c.setExpiration(newKey, 0)
+	c.delete(key)
+	c.write(newKey, value)
+	if ok {
+		c.setExpiresAt(newKey, expires)
+	}
+	c.signal(newKey)
+	c.notify(genericClass, "rename_from", key)
+	c.notify(genericClass, "rename_to", newKey)
+}
+
+// renameable returns an error if the key does not exist or is a time series with a compaction rule from or to
+// the new name. Mutex must be rlocked before calling renameable
+func (c *cache) renameable(key, newKey string) error {
+	value := c.read(key)
+	if value == nil {
+		return errNoSuchKey
+	}
+	if series, ok := value.(*TimeSeries); ok && key != newKey {
+		if series.SourceKey == newKey {
+			return errRenameToRule
+		}
+		for _, rule := range series.Rules {
+			if rule.Dest == newKey {
+				return errRenameToRule
+			}
+		}
+	}
+	return nil
+}
+func (c *cache) Rename(args []string) (response interface{}, err error) {
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if err = c.renameable(args[0], args[1]); err != nil {
+		return
+	}
+	c.rename(args[0], args[1])
+	response = formatted("OK")
+	return
+}
+func (c *cache) RenameNX(args []string) (response interface{}, err error) {
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	if err = c.renameable(args[0], args[1]); err != nil {
+		return
+	}
+	response = int64(0)
+	if c.read(args[1]) == nil {
+		c.rename(args[0], args[1])
+		response = int64(1)
+	}
+	return
+}
+
+// Copy copies the value and the expiration of the key, REPLACE allows overwriting an existing destination
+func (c *cache) Copy(args []string) (response interface{}, err error) {
+	if len(args) != 2 && !(len(args) == 3 && strings.ToUpper(args[2]) == "REPLACE") {
+		err = syntaxError("COPY")
+		return
+	}
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	value := c.read(args[0])
+	response = int64(0)
+	if value == nil || args[0] == args[1] || (len(args) == 2 && c.read(args[1]) != nil) {
+		return
+	}
+	value, err = copyValue(value)
+	if err != nil {
+		return
+	}
+	expires, ok := c.expiresAt(args[0])
+	c.setExpiration(args[1], 0)
+	c.write(args[1], value)
+	if ok {
+		c.setExpiresAt(args[1], expires)
+	}
+	c.signal(args[1])
+	c.notify(genericClass, "copy_to", args[1])
+	response = int64(1)
+	return
+}
+
+// RandomKey returns a key chosen by the random order of map iteration
+func (c *cache) RandomKey(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	response = nil
+	for key := range c.Fields {
+		c.tracked(key)
+		response = key
+		break
+	}
+	return
+}
+func (c *cache) DBSize(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	response = int64(len(c.Fields))
+	return
+}
+
+// Touch returns the number of existing keys. Keys have no access time, so nothing else is changed
+func (c *cache) Touch(args []string) (response interface{}, err error) {
+	return c.Exists(args)
+}
+
+// freeValues empties large containers of deleted values, so that releasing them does not happen under the lock
+func freeValues(values []interface{}) {
+	for _, value := range values {
+		switch value.(type) {
+		case Hashmap:
+			hmap := value.(Hashmap)
+			for field := range hmap.Hashmap {
+				delete(hmap.Hashmap, field)
+			}
+		case RList:
+			value.(RList).Value.Init()
+		}
+	}
+}
+
+// Unlink removes the keys like Del, but releases their values in the background
+func (c *cache) Unlink(args []string) (response interface{}, err error) {
+	var values []interface{}
+	c.m.Lock()
+	for _, key := range args {
+		value := c.read(key)
+		if c.delete(key) {
+			c.notify(genericClass, "del", key)
+			values = append(values, value)
+		}
+	}
+	c.m.Unlock()
+	go freeValues(values)
+	response = int64(len(values))
+	return
+}
+
+// flushed are the structures replaced by flush. Nothing refers to them after the flush, so they are emptied
+// without locks
+type flushed struct {
+	fields      map[string]interface{}
+	slots       []map[string]bool
+	timeSeries  map[string]bool
+	expirations []expiration
+	indexes     map[string]int
+}
+
+// free empties the maps of the flushed cache and large containers among its values
+func (f flushed) free() {
+	values := make([]interface{}, 0, len(f.fields))
+	for key, value := range f.fields {
+		values = append(values, value)
+		delete(f.fields, key)
+	}
+	freeValues(values)
+	for _, slot := range f.slots {
+		for key := range slot {
+			delete(slot, key)
+		}
+	}
+	for key := range f.timeSeries {
+		delete(f.timeSeries, key)
+	}
+	for key := range f.indexes {
+		delete(f.indexes, key)
+	}
+	for i := range f.expirations {
+		f.expirations[i] = expiration{}
+	}
+}
+
+// flush replaces all keys and expirations with empty structures and empties search indexes.
+// Both mutexes must be locked before calling flush. Returns the replaced structures
+func (c *cache) flush() flushed {
+	for key := range c.watched {
+		if _, ok := c.Fields[key]; ok {
+			c.modified(key)
+		}
+	}
+	old := flushed{c.Fields, c.slots, c.timeSeries, c.Exps.Expirations, c.Exps.Indexes}
+	c.Fields = make(map[string]interface{})
+	c.slots = make([]map[string]bool, keySlots)
+	c.timeSeries = make(map[string]bool)
+	c.Exps.Expirations = make([]expiration, 0)
+	c.Exps.Indexes = make(map[string]int)
+	for _, idx := range c.indexes {
+		idx.reset()
+	}
+	if c.tracking != nil {
+		c.tracking.invalidateAll()
+	}
+	return old
+}
+
+// flushCommand removes all keys, with ASYNC the old structures are released in the background after replying
+func (c *cache) flushCommand(name string, args []string) (response interface{}, err error) {
+	if len(args) > 1 || (len(args) == 1 && strings.ToUpper(args[0]) != "ASYNC" && strings.ToUpper(args[0]) != "SYNC") {
+		err = syntaxError(name)
+		return
+	}
+	c.Exps.m.Lock()
+	c.m.Lock()
+	old := c.flush()
+	c.m.Unlock()
+	c.Exps.m.Unlock()
+	if len(args) == 1 && strings.ToUpper(args[0]) == "ASYNC" {
+		go old.free()
+	} else {
+		old.free()
+	}
+	response = formatted("OK")
+	return
+}
+func (c *cache) FlushDB(args []string) (response interface{}, err error) {
+	return c.flushCommand("FLUSHDB", args)
+}
+func (c *cache) FlushAll(args []string) (response interface{}, err error) {
+	return c.flushCommand("FLUSHALL", args)
+}
@@ -0,0 +1,208 @@
+package cache
+
+import (
+	"testing"
+	"time"
+)
+
+func TestExistsTypeDBSize(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.Set([]string{"string", "value"})
+	c.HSet([]string{"hash", "field", "value"})
+	c.RPush([]string{"list", "a"})
+	c.PFAdd([]string{"hll", "a"})
+
+	tests := []struct {
+		method   func([]string) (interface{}, error)
+		args     []string
+		expected string
+	}{
+		{c.Exists, []string{"string", "hash", "missing", "string"}, "(integer) 3"},
+		{c.Touch, []string{"list", "missing"}, "(integer) 1"},
+		{c.Type, []string{"string"}, "string"},
+		{c.Type, []string{"hash"}, "hash"},
+		{c.Type, []string{"list"}, "list"},
+		{c.Type, []string{"hll"}, "hyperloglog"},
+		{c.Type, []string{"missing"}, "none"},
+		{c.DBSize, []string{}, "(integer) 4"},
+	}
+	for _, test := range tests {
+		resp, err := text(test.method(test.args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != test.expected {
+			t.Errorf("%v: expected %v, got %v", test.args, test.expected, resp)
+		}
+	}
+
+	seen := make(map[string]bool)
+	for i := 0; i < 100; i += 1 {
+		key, _ := text(c.RandomKey(nil))
+		seen[key] = true
+	}
+	if len(seen) < 2 || seen["(nil)"] {
+		t.Errorf("expected random existing keys, got %v", seen)
+	}
+	if key, _ := text((NewCache()).RandomKey(nil)); key != "(nil)" {
+		t.Errorf("expected (nil) on empty cache, got %v", key)
+	}
+}
+
+func TestRename(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.Set([]string{"a", "1", "EX", "100"})
+	c.Set([]string{"b", "2", "EX", "1"})
+	c.Set([]string{"c", "3"})
+
+	resp, err := text(c.Rename([]string{"a", "b"}))
+	if err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	c.Exps.m.Lock()
+	expires, ok := c.expiresAt("b")
+	_, oldOk := c.expiresAt("a")
+	c.Exps.m.Unlock()
+	if !ok || time.Until(expires) < 90*time.Second || oldOk {
+		t.Errorf("expected the expiration to move with the key, got %v %v", expires, oldOk)
+	}
+	if resp, _ = text(c.Get([]string{"b"})); resp != "1" {
+		t.Errorf("expected 1, got %v", resp)
+	}
+	if resp, _ = text(c.Exists([]string{"a"})); resp != "(integer) 0" {
+		t.Errorf("expected a to be removed, got %v", resp)
+	}
+
+	if resp, _ = text(c.RenameNX([]string{"b", "c"})); resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+	if resp, _ = text(c.RenameNX([]string{"c", "d"})); resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	if _, err = text(c.Rename([]string{"missing", "x"})); err != errNoSuchKey {
+		t.Errorf("expected %v, got %v", errNoSuchKey, err)
+	}
+	if resp, _ = text(c.Rename([]string{"d", "d"})); resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	if resp, _ = text(c.Get([]string{"d"})); resp != "3" {
+		t.Errorf("expected 3, got %v", resp)
+	}
+}
+
+func TestCopy(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.HSet([]string{"hash", "field", "value"})
+	c.RPush([]string{"list", "a", "b"})
+	c.JSONSet([]string{"doc", "$", `{"a":[1,2]}`})
+	c.Exps.m.Lock()
+	c.setExpiration("hash", time.Minute)
+	c.Exps.m.Unlock()
+
+	for _, pair := range [][]string{{"hash", "hash2"}, {"list", "list2"}, {"doc", "doc2"}} {
+		resp, err := text(c.Copy(pair))
+		if err != nil || resp != "(integer) 1" {
+			t.Errorf("COPY %v: expected (integer) 1, got %v %v", pair, resp, err)
+		}
+	}
+	c.HSet([]string{"hash2", "field", "changed"})
+	c.RPush([]string{"list2", "c"})
+	c.JSONArrAppend([]string{"doc2", "$.a", "3"})
+	if resp, _ := text(c.HGet([]string{"hash", "field"})); resp != "value" {
+		t.Errorf("expected the copy to be independent, got %v", resp)
+	}
+	if length := c.read("list").(RList).Value.Len(); length != 2 {
+		t.Errorf("expected the copy to be independent, got length %v", length)
+	}
+	if resp, _ := text(c.JSONGet([]string{"doc"})); resp != `{"a":[1,2]}` {
+		t.Errorf("expected the copy to be independent, got %v", resp)
+	}
+	c.Exps.m.Lock()
+	_, ok := c.expiresAt("hash2")
+	c.Exps.m.Unlock()
+	if !ok {
+		t.Errorf("expected the expiration to be copied")
+	}
+
+	if resp, _ := text(c.Copy([]string{"list", "hash2"})); resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0 without REPLACE, got %v", resp)
+	}
+	if resp, _ := text(c.Copy([]string{"list", "hash2", "replace"})); resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1 with REPLACE, got %v", resp)
+	}
+	c.Exps.m.Lock()
+	_, ok = c.expiresAt("hash2")
+	c.Exps.m.Unlock()
+	if ok {
+		t.Errorf("expected the expiration of the replaced key to be removed")
+	}
+	if resp, _ := text(c.Type([]string{"hash2"})); resp != "list" {
+		t.Errorf("expected list, got %v", resp)
+	}
+	if resp, _ := text(c.Copy([]string{"missing", "x"})); resp != "(integer) 0" {
+		t.Errorf("expected (integer) 0, got %v", resp)
+	}
+}
+
+func TestUnlink(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.HSet([]string{"hash", "field", "value"})
+	c.Set([]string{"string", "value"})
+	resp, err := text(c.Unlink([]string{"hash", "string", "missing"}))
+	if err != nil || resp != "(integer) 2" {
+		t.Errorf("expected (integer) 2, got %v %v", resp, err)
+	}
+	if resp, _ = text(c.DBSize(nil)); resp != "(integer) 0" {
+		t.Errorf("expected empty cache, got %v", resp)
+	}
+}
+
+func TestFlushFree(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.HSet([]string{"hash", "field", "value"})
+	c.RPush([]string{"list", "a", "b"})
+	hash, list := c.read("hash").(Hashmap), c.read("list").(RList)
+	c.Exps.m.Lock()
+	c.m.Lock()
+	old := c.flush()
+	c.m.Unlock()
+	c.Exps.m.Unlock()
+	if len(old.fields) != 2 || len(c.Fields) != 0 {
+		t.Fatalf("expected the old keys to be returned, got %v %v", len(old.fields), len(c.Fields))
+	}
+	old.free()
+	if len(old.fields) != 0 || len(hash.Hashmap) != 0 || list.Value.Len() != 0 {
+		t.Errorf("expected the old structures to be emptied, got %v %v %v", len(old.fields), len(hash.Hashmap), list.Value.Len())
+	}
+}
+
+func TestFlush(t *testing.T) {
+	for _, mode := range []string{"SYNC", "ASYNC"} {
+		c := newSearchCache(t)
+		c.Set([]string{"temp", "value", "EX", "100"})
+		resp, err := text(c.FlushAll([]string{mode}))
+		if err != nil || resp != "OK" {
+			t.Errorf("expected OK, got %v %v", resp, err)
+		}
+		if resp, _ = text(c.DBSize(nil)); resp != "(integer) 0" {
+			t.Errorf("expected empty cache, got %v", resp)
+		}
+		if c.Exps.Len() != 0 {
+			t.Errorf("expected expirations to be removed, got %v", c.Exps.Len())
+		}
+		if resp, _ = text(c.FTSearch([]string{"users", "*"})); resp != "1) (integer) 0" {
+			t.Errorf("expected empty index, got %v", resp)
+		}
+		if resp, _ = text(c.Scan([]string{"0", "COUNT", "100000"})); resp != "1) \"0\"\n2) (empty array)" {
+			t.Errorf("expected empty scan, got %v", resp)
+		}
+		c.HSet([]string{"user:9", "name", "Dana"})
+		if resp, _ = text(c.FTSearch([]string{"users", "dana", "NOCONTENT"})); resp != "1) (integer) 1\n2) \"user:9\"" {
+			t.Errorf("expected the index to keep working, got %v", resp)
+		}
+	}
+	c := (NewCache()).(*cache)
+	if _, err := text(c.FlushDB([]string{"LATER"})); err == nil {
+		t.Errorf("expected error on unknown mode")
+	}
+}
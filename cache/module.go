@@ -0,0 +1,156 @@
+package cache
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+var errReadOnlyCommand = errors.New("write of a key from a read only command")
+
+// Keyspace gives commands of modules access to the keys of the database, which stays locked until the command returns.
+// Values changed in place must be set again, so that watching sessions, search indexes and notifications see the change
+type Keyspace interface {
+	// Get returns the value of the key or nil
+	Get(key string) interface{}
+	// Set stores the value, keeping the expiration of the key
+	Set(key string, value interface{}) error
+	// Delete removes the key with its expiration, returns false if it did not exist
+	Delete(key string) (bool, error)
+	// Notify sends a keyspace notification of the module class
+	Notify(event, key string)
+}
+
+// ValueType is a type of values added by a module. Value is any value of the type and identifies the Go type
+// of its values, Name is returned by TYPE. Encode and Decode convert values for snapshots, COPY uses them too
+type ValueType struct {
+	Name   string
+	Value  interface{}
+	Encode func(value interface{}) ([]byte, error)
+	Decode func(b []byte) (interface{}, error)
+}
+
+// types added by modules. They are registered before the server starts and not changed later
+var (
+	moduleTypes       = make(map[reflect.Type]*ValueType)
+	moduleTypesByName = make(map[string]*ValueType)
+)
+
+// RegisterCommand adds the command to the command table of all databases. The handler is required, Syntax is
+// optional. It must be called before the server starts
+func RegisterCommand(command Command) error {
+	command.Name = strings.ToUpper(command.Name)
+	if _, ok := commands[command.Name]; ok || command.Name == "" {
+		return errors.New(fmt.Sprintf("command '%v' already exists", command.Name))
+	}
+	if command.Arity == 0 || command.Handler == nil {
+		return errors.New("command must have an arity and a handler")
+	}
+	if command.Flags&(FlagWrite|FlagReadOnly) == 0 || command.Flags&FlagWrite != 0 && command.Flags&FlagReadOnly != 0 {
+		return errors.New("command must be either write or read only")
+	}
+	if command.Flags&(FlagBlocking|FlagMovableKeys) != 0 {
+		return errors.New("commands of modules can not block or have movable keys")
+	}
+	if command.FirstKey < 0 || command.FirstKey > 0 && (command.KeyStep < 1 || command.LastKey > 0 && command.LastKey < command.FirstKey) {
+		return errors.New("invalid key positions")
+	}
+	spec := command
+	spec.run = spec.call
+	commands[spec.Name] = &spec
+	return nil
+}
+
+// RegisterType adds the type of values, so that values of it are saved in snapshots. It must be called before
+// the server starts and before snapshots with values of the type are loaded
+func RegisterType(valueType ValueType) error {
+	if valueType.Name == "" || valueType.Value == nil || valueType.Encode == nil || valueType.Decode == nil {
+		return errors.New("type must have a name, a value and encode and decode functions")
+	}
+	kind := reflect.TypeOf(valueType.Value)
+	_, builtin := snapshotTypes[valueType.Name]
+	_, registered := moduleTypes[kind]
+	if builtin || registered || moduleTypesByName[valueType.Name] != nil || valueType.Name == "string" || valueType.Name == "list" ||
+		valueType.Name == "hash" || valueType.Name == "none" {
+		return errors.New(fmt.Sprintf("type '%v' already exists", valueType.Name))
+	}
+	moduleTypes[kind] = &valueType
+	moduleTypesByName[valueType.Name] = &valueType
+	return nil
+}
+
+// call runs the handler of a module command under the locks required by its flags
+func (command *Command) call(c *cache, args []string) (response interface{}, err error) {
+	if command.Flags&FlagReadOnly != 0 {
+		c.m.RLock()
+		defer c.m.RUnlock()
+		return command.Handler(moduleKeyspace{c, true}, args)
+	}
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	return command.Handler(moduleKeyspace{c, false}, args)
+}
+
+// moduleKeyspace is the Keyspace of a locked database
+type moduleKeyspace struct {
+	c        *cache
+	readOnly bool
+}
+
+func (k moduleKeyspace) Get(key string) interface{} {
+	return k.c.read(key)
+}
+func (k moduleKeyspace) Set(key string, value interface{}) error {
+	if k.readOnly {
+		return errReadOnlyCommand
+	}
+	k.c.write(key, value)
+	return nil
+}
+func (k moduleKeyspace) Delete(key string) (bool, error) {
+	if k.readOnly {
+		return false, errReadOnlyCommand
+	}
+	k.c.setExpiration(key, 0)
+	return k.c.delete(key), nil
+}
+func (k moduleKeyspace) Notify(event, key string) {
+	k.c.notify(moduleClass, event, key)
+}
+
+// moduleType returns the type of the value if it was added by a module
+func moduleType(value interface{}) (*ValueType, bool) {
+	valueType, ok := moduleTypes[reflect.TypeOf(value)]
+	return valueType, ok
+}
+
+// marshalValue encodes the value for snapshots. Values of module types are saved with the name of their type
+// and the output of Encode
+func marshalValue(value interface{}) ([]byte, error) {
+	valueType, ok := moduleType(value)
+	if !ok {
+		return json.Marshal(value)
+	}
+	b, err := valueType.Encode(value)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(struct {
+		Type  string
+		Value []byte
+	}{valueType.Name, b})
+}
+
+// unmarshalModuleValue decodes a value saved by marshalValue
+func unmarshalModuleValue(valueType *ValueType, b []byte) (interface{}, error) {
+	var saved struct{ Value []byte }
+	err := json.Unmarshal(b, &saved)
+	if err != nil {
+		return nil, err
+	}
+	return valueType.Decode(saved.Value)
+}
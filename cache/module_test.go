@@ -0,0 +1,134 @@
+package cache
+
+import (
+	"errors"
+	"strconv"
+	"testing"
+)
+
+// testCounter is a value type of the test module
+type testCounter struct {
+	value int64
+}
+
+func registerTestModule(t *testing.T) {
+	if _, ok := moduleTypesByName["counter"]; ok {
+		return
+	}
+	err := RegisterType(ValueType{
+		Name:  "counter",
+		Value: &testCounter{},
+		Encode: func(value interface{}) ([]byte, error) {
+			return []byte(strconv.FormatInt(value.(*testCounter).value, 10)), nil
+		},
+		Decode: func(b []byte) (interface{}, error) {
+			value, err := strconv.ParseInt(string(b), 10, 64)
+			return &testCounter{value}, err
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterCommand(Command{Name: "counter.incrby", Arity: 3, Flags: FlagWrite, FirstKey: 1, LastKey: 1, KeyStep: 1,
+		Handler: func(keyspace Keyspace, args []string) (interface{}, error) {
+			n, err := strconv.ParseInt(args[1], 10, 64)
+			if err != nil {
+				return nil, err
+			}
+			counter, ok := keyspace.Get(args[0]).(*testCounter)
+			if !ok && keyspace.Get(args[0]) != nil {
+				return nil, errors.New("not a counter")
+			}
+			if !ok {
+				counter = &testCounter{}
+			}
+			counter.value += n
+			keyspace.Set(args[0], counter)
+			keyspace.Notify("counter.incrby", args[0])
+			return counter.value, nil
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = RegisterCommand(Command{Name: "COUNTER.MGET", Arity: -2, Flags: FlagReadOnly, FirstKey: 1, LastKey: -1, KeyStep: 1,
+		Handler: func(keyspace Keyspace, args []string) (interface{}, error) {
+			res := make([]interface{}, len(args))
+			for i, key := range args {
+				if counter, ok := keyspace.Get(key).(*testCounter); ok {
+					res[i] = counter.value
+				}
+			}
+			if _, err := keyspace.Delete(args[0]); err != errReadOnlyCommand {
+				return nil, errors.New("expected read only keyspace")
+			}
+			return res, nil
+		}})
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestModule(t *testing.T) {
+	registerTestModule(t)
+	d := NewDatabases(2)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"COUNTER.INCRBY", []string{"c", "5"}, "(integer) 5"},
+		{"COUNTER.INCRBY", []string{"c", "-2"}, "(integer) 3"},
+		{"COUNTER.MGET", []string{"c", "missing"}, "1) (integer) 3\n2) (nil)"},
+		{"TYPE", []string{"c"}, "counter"},
+		{"COPY", []string{"c", "d"}, "(integer) 1"},
+		{"COUNTER.INCRBY", []string{"d", "1"}, "(integer) 4"},
+		{"COUNTER.MGET", []string{"c", "d"}, "1) (integer) 3\n2) (integer) 4"},
+		{"SAVE", []string{"modulesave"}, "OK"},
+		{"FLUSHALL", nil, "OK"},
+		{"LOAD", []string{"modulesave"}, "OK"},
+		{"COUNTER.MGET", []string{"c", "d"}, "1) (integer) 3\n2) (integer) 4"},
+		{"EVAL", []string{"return cache.call('counter.incrby', KEYS[1], 10)", "1", "c"}, "(integer) 13"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	if _, err := d.HandleRequest(session, "COUNTER.INCRBY", []string{"c"}); err == nil {
+		t.Errorf("expected arity error")
+	}
+	if _, err := d.HandleRequest(session, "COUNTER.MGET", nil); err == nil {
+		t.Errorf("expected arity error")
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	registerTestModule(t)
+	handler := func(keyspace Keyspace, args []string) (interface{}, error) { return "OK", nil }
+	for _, command := range []Command{
+		{Name: "GET", Arity: 2, Flags: FlagReadOnly, Handler: handler},
+		{Name: "select", Arity: 2, Flags: FlagWrite, Handler: handler},
+		{Name: "COUNTER.INCRBY", Arity: 3, Flags: FlagWrite, Handler: handler},
+		{Name: "NOARITY", Flags: FlagWrite, Handler: handler},
+		{Name: "NOFLAGS", Arity: 1, Handler: handler},
+		{Name: "BOTHFLAGS", Arity: 1, Flags: FlagWrite | FlagReadOnly, Handler: handler},
+		{Name: "NOSTEP", Arity: 2, Flags: FlagWrite, FirstKey: 1, LastKey: 1, Handler: handler},
+		{Name: "NOHANDLER", Arity: 1, Flags: FlagWrite},
+	} {
+		if err := RegisterCommand(command); err == nil {
+			t.Errorf("expected error registering %v", command.Name)
+		}
+	}
+	for _, valueType := range []ValueType{
+		{Name: "hash", Value: 0, Encode: func(interface{}) ([]byte, error) { return nil, nil }, Decode: func([]byte) (interface{}, error) { return nil, nil }},
+		{Name: "stream", Value: 0, Encode: func(interface{}) ([]byte, error) { return nil, nil }, Decode: func([]byte) (interface{}, error) { return nil, nil }},
+		{Name: "other", Value: &testCounter{}, Encode: func(interface{}) ([]byte, error) { return nil, nil }, Decode: func([]byte) (interface{}, error) { return nil, nil }},
+		{Name: "nohooks", Value: 0},
+	} {
+		if err := RegisterType(valueType); err == nil {
+			t.Errorf("expected error registering %v", valueType.Name)
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package cache
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Classes of keyspace events as in notify-keyspace-events, A is an alias for allEventClasses
+const (
+	keyspaceClass = 'K'
+	keyeventClass = 'E'
+	genericClass  = 'g'
+	stringClass   = '$'
+	listClass     = 'l'
+	hashClass     = 'h'
+	expiredClass  = 'x'
+	streamClass   = 't'
+	moduleClass   = 'd'
+	newKeyClass   = 'n'
+
+	allEventClasses = "g$lhxtd"
+)
+
+// keyspaceEvents publishes notifications about changes of keys to __keyspace@db__:key and __keyevent@db__:event
+// channels, the enabled classes are shared by all databases
+type keyspaceEvents struct {
+	m       *sync.RWMutex
+	classes string
+	pubsub  *pubsub
+}
+
+func newKeyspaceEvents(pubsub *pubsub) *keyspaceEvents {
+	return &keyspaceEvents{&sync.RWMutex{}, "", pubsub}
+}
+
+// parseEventClasses checks the classes and expands the A alias
+func parseEventClasses(flags string) (classes string, err error) {
+	for _, class := range flags {
+		switch {
+		case class == 'A':
+			classes += allEventClasses
+		case strings.ContainsRune(allEventClasses, class) || class == keyspaceClass || class == keyeventClass || class == newKeyClass:
+			classes += string(class)
+		default:
+			err = errors.New(fmt.Sprintf("Invalid event class character '%c'", class))
+			return
+		}
+	}
+	return
+}
+
+// Config gets and sets notify-keyspace-events, the only supported parameter
+func (e *keyspaceEvents) Config(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("CONFIG")
+	if len(args) < 2 || strings.ToLower(args[1]) != "notify-keyspace-events" {
+		err = formatErr
+		return
+	}
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if len(args) != 2 {
+			err = formatErr
+			return
+		}
+		e.m.RLock()
+		classes := e.classes
+		e.m.RUnlock()
+		response = []interface{}{"notify-keyspace-events", classes}
+	case "SET":
+		if len(args) != 3 {
+			err = formatErr
+			return
+		}
+		var classes string
+		classes, err = parseEventClasses(args[2])
+		if err != nil {
+			return
+		}
+		e.m.Lock()
+		e.classes = classes
+		e.m.Unlock()
+		response = formatted("OK")
+	default:
+		err = formatErr
+	}
+	return
+}
+
+// enabled returns which notifications are sent for events of the class
+func (e *keyspaceEvents) enabled(class byte) (keyspace, keyevent bool) {
+	e.m.RLock()
+	defer e.m.RUnlock()
+	if strings.IndexByte(e.classes, class) < 0 {
+		return
+	}
+	return strings.IndexByte(e.classes, keyspaceClass) >= 0, strings.IndexByte(e.classes, keyeventClass) >= 0
+}
+
+// Mutex must be locked before calling notify. Caches created outside of databases send no notifications
+func (c *cache) notify(class byte, event, key string) {
+	if c.events == nil {
+		return
+	}
+	keyspace, keyevent := c.events.enabled(class)
+	if keyspace {
+		c.events.pubsub.publish(fmt.Sprintf("__keyspace@%v__:%v", c.index, key), event)
+	}
+	if keyevent {
+		c.events.pubsub.publish(fmt.Sprintf("__keyevent@%v__:%v", c.index, event), key)
+	}
+}
+
+// expireArgs lazily deletes expired keys named by the arguments of a command, so that commands never see keys
+// the cleaner has not removed yet. Arguments that are not keys have no expirations and are skipped
+func (c *cache) expireArgs(args []string) {
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	now := time.Now()
+	for _, key := range args {
+		index, ok := c.Exps.Indexes[key]
+		if !ok || !c.Exps.Expirations[index].Expires.Before(now) {
+			continue
+		}
+		heap.Remove(&c.Exps, index)
+		c.m.Lock()
+		c.expire(key)
+		c.m.Unlock()
+	}
+}
+
+// expire deletes the expired key. Mutex must be locked before calling expire
+func (c *cache) expire(key string) {
+	if c.delete(key) {
+		c.notify(expiredClass, "expired", key)
+	}
+}
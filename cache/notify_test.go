@@ -0,0 +1,115 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// notifications returns pushed messages as "channel message" pairs and forgets them
+func notifications(session *testSession) []string {
+	res := make([]string, len(session.messages))
+	for i, message := range session.messages {
+		lines := strings.Split(message, "\n")
+		channel := strings.Trim(strings.TrimPrefix(lines[2], "3) "), "\"")
+		payload := strings.Trim(strings.TrimPrefix(lines[3], "4) "), "\"")
+		res[i] = channel + " " + payload
+	}
+	session.messages = nil
+	return res
+}
+
+func expectNotifications(t *testing.T, session *testSession, expected ...string) {
+	got := notifications(session)
+	if strings.Join(got, ", ") != strings.Join(expected, ", ") {
+		t.Errorf("expected %v, got %v", expected, got)
+	}
+}
+
+func TestKeyspaceNotifications(t *testing.T) {
+	d := NewDatabases(2)
+	session, subscriber := &testSession{}, &testSession{}
+	d.HandleRequest(subscriber, "PSUBSCRIBE", []string{"__key*__:*"})
+
+	d.HandleRequest(session, "SET", []string{"key", "value"})
+	expectNotifications(t, subscriber)
+
+	if resp, err := d.HandleRequest(session, "CONFIG", []string{"SET", "notify-keyspace-events", "KEA"}); err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	d.HandleRequest(session, "SET", []string{"key", "value", "EX", "100"})
+	expectNotifications(t, subscriber,
+		"__keyspace@0__:key set", "__keyevent@0__:set key",
+		"__keyspace@0__:key expire", "__keyevent@0__:expire key")
+	d.HandleRequest(session, "RPUSH", []string{"list", "a"})
+	d.HandleRequest(session, "RPOP", []string{"list"})
+	expectNotifications(t, subscriber,
+		"__keyspace@0__:list rpush", "__keyevent@0__:rpush list",
+		"__keyspace@0__:list rpop", "__keyevent@0__:rpop list",
+		"__keyspace@0__:list del", "__keyevent@0__:del list")
+	d.HandleRequest(session, "RENAME", []string{"key", "other"})
+	expectNotifications(t, subscriber,
+		"__keyspace@0__:key rename_from", "__keyevent@0__:rename_from key",
+		"__keyspace@0__:other rename_to", "__keyevent@0__:rename_to other")
+
+	d.HandleRequest(session, "CONFIG", []string{"SET", "notify-keyspace-events", "Khn"})
+	d.HandleRequest(session, "SELECT", []string{"1"})
+	d.HandleRequest(session, "HSET", []string{"hash", "field", "value"})
+	d.HandleRequest(session, "SET", []string{"string", "value"})
+	d.HandleRequest(session, "DEL", []string{"hash"})
+	expectNotifications(t, subscriber,
+		"__keyspace@1__:hash new", "__keyspace@1__:hash hset", "__keyspace@1__:string new")
+	// a failed XADD does not create the stream
+	if _, err := d.HandleRequest(session, "XADD", []string{"stream", "0-0", "field", "value"}); err == nil {
+		t.Errorf("expected error on id 0-0")
+	}
+	expectNotifications(t, subscriber)
+	if resp, _ := d.HandleRequest(session, "EXISTS", []string{"stream"}); resp != "(integer) 0" {
+		t.Errorf("expected no stream, got %v", resp)
+	}
+	d.HandleRequest(session, "SWAPDB", []string{"0", "1"})
+	d.HandleRequest(session, "HSET", []string{"hash", "field", "value"})
+	expectNotifications(t, subscriber, "__keyspace@1__:hash new", "__keyspace@1__:hash hset")
+
+	resp, _ := d.HandleRequest(session, "CONFIG", []string{"GET", "notify-keyspace-events"})
+	if resp != "1) \"notify-keyspace-events\"\n2) \"Khn\"" {
+		t.Errorf("unexpected config %v", resp)
+	}
+	if _, err := d.HandleRequest(session, "CONFIG", []string{"SET", "notify-keyspace-events", "Kq"}); err == nil {
+		t.Errorf("expected error on unknown class")
+	}
+}
+
+func TestExpiredNotifications(t *testing.T) {
+	d := NewDatabases(1)
+	session, subscriber := &testSession{}, &testSession{}
+	d.HandleRequest(session, "CONFIG", []string{"SET", "notify-keyspace-events", "Ex"})
+	d.HandleRequest(subscriber, "SUBSCRIBE", []string{"__keyevent@0__:expired"})
+	subscriber.messages = nil
+	db := d.(*databases).db(0)
+	expireNow := func(key string) {
+		d.HandleRequest(session, "SET", []string{key, "value"})
+		db.Exps.m.Lock()
+		db.setExpiresAt(key, time.Now().Add(-time.Millisecond))
+		db.Exps.m.Unlock()
+	}
+
+	expireNow("cleaned")
+	db.clean()
+	// lazy expiry removes the key when it is accessed before the cleaner runs
+	expireNow("accessed")
+	if resp, _ := d.HandleRequest(session, "GET", []string{"accessed"}); resp != "(nil)" {
+		t.Errorf("expected the expired key to be removed, got %v", resp)
+	}
+	if resp, _ := d.HandleRequest(session, "DBSIZE", nil); resp != "(integer) 0" {
+		t.Errorf("expected empty database, got %v", resp)
+	}
+	db.clean()
+	expected := []string{
+		"1) \"message\"\n2) \"__keyevent@0__:expired\"\n3) \"cleaned\"",
+		"1) \"message\"\n2) \"__keyevent@0__:expired\"\n3) \"accessed\"",
+	}
+	if strings.Join(subscriber.messages, "\n") != strings.Join(expected, "\n") {
+		t.Errorf("expected %v, got %v", expected, subscriber.messages)
+	}
+}
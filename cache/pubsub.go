@@ -0,0 +1,335 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/gobwas/glob"
+)
+
+// subscriberCommands are the only commands allowed for sessions subscribed to channels or patterns
+var subscriberCommands = map[string]bool{
+	"SUBSCRIBE":    true,
+	"PSUBSCRIBE":   true,
+	"UNSUBSCRIBE":  true,
+	"PUNSUBSCRIBE": true,
+	"SSUBSCRIBE":   true,
+	"SUNSUBSCRIBE": true,
+}
+
+var errCrossSlot = errors.New("CROSSSLOT Keys in request don't hash to the same slot")
+
+// subscriber are the channels, patterns and shard channels a session is subscribed to
+type subscriber struct {
+	channels      map[string]bool
+	patterns      map[string]bool
+	shardChannels map[string]bool
+}
+
+// count is the number of channels and patterns, shard channels are counted separately
+func (s *subscriber) count() int {
+	return len(s.channels) + len(s.patterns)
+}
+func (s *subscriber) shardCount() int {
+	return len(s.shardChannels)
+}
+
+// pattern is a compiled glob pattern with its subscribers
+type pattern struct {
+	glob     glob.Glob
+	sessions map[Session]bool
+}
+
+// pubsub routes published messages to sessions subscribed to channels and patterns. Messages are sent
+// without blocking, so that a slow subscriber does not stall publishers
+type pubsub struct {
+	m        *sync.RWMutex
+	channels map[string]map[Session]bool
+	patterns map[string]*pattern
+	// shard channels by the slot of the channel name, slots are the same as slots of keys. The server
+	// is the only node and owns all slots
+	shards []map[string]map[Session]bool
+	// subscribers are kept until their connection is closed, so that the close callback is registered once
+	subscribers map[Session]*subscriber
+}
+
+func newPubSub() pubsub {
+	return pubsub{&sync.RWMutex{}, make(map[string]map[Session]bool), make(map[string]*pattern), make([]map[string]map[Session]bool, keySlots), make(map[Session]*subscriber)}
+}
+
+// subscriber returns subscriptions of the session. Mutex must be locked before calling subscriber
+func (p *pubsub) subscriber(session Session) *subscriber {
+	s, ok := p.subscribers[session]
+	if !ok {
+		s = &subscriber{make(map[string]bool), make(map[string]bool), make(map[string]bool)}
+		p.subscribers[session] = s
+		session.OnClose(func() {
+			p.m.Lock()
+			defer p.m.Unlock()
+			for channel := range s.channels {
+				p.removeChannel(session, channel)
+			}
+			for name := range s.patterns {
+				p.removePattern(session, name)
+			}
+			for channel := range s.shardChannels {
+				p.removeShardChannel(session, channel)
+			}
+			delete(p.subscribers, session)
+		})
+	}
+	return s
+}
+
+// subscribed returns true if the session is subscribed to at least one channel or pattern
+func (p *pubsub) subscribed(session Session) bool {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	s, ok := p.subscribers[session]
+	return ok && s.count()+s.shardCount() != 0
+}
+
+// Mutex must be locked before calling removeChannel
+func (p *pubsub) removeChannel(session Session, channel string) {
+	delete(p.subscribers[session].channels, channel)
+	delete(p.channels[channel], session)
+	if len(p.channels[channel]) == 0 {
+		delete(p.channels, channel)
+	}
+}
+
+// Mutex must be locked before calling removePattern
+func (p *pubsub) removePattern(session Session, name string) {
+	delete(p.subscribers[session].patterns, name)
+	if pattern, ok := p.patterns[name]; ok {
+		delete(pattern.sessions, session)
+		if len(pattern.sessions) == 0 {
+			delete(p.patterns, name)
+		}
+	}
+}
+
+// Mutex must be locked before calling removeShardChannel
+func (p *pubsub) removeShardChannel(session Session, channel string) {
+	delete(p.subscribers[session].shardChannels, channel)
+	slot := keySlot(channel)
+	delete(p.shards[slot][channel], session)
+	if len(p.shards[slot][channel]) == 0 {
+		delete(p.shards[slot], channel)
+	}
+	if len(p.shards[slot]) == 0 {
+		p.shards[slot] = nil
+	}
+}
+
+// confirmations formats replies of subscribe and unsubscribe commands, one array per channel
+func confirmations(replies [][]interface{}) formatted {
+	arrays := make([]string, len(replies))
+	for i := range replies {
+		arrays[i] = formatArray(replies[i])
+	}
+	return formatted(strings.Join(arrays, "\n"))
+}
+
+// Subscribe subscribes the session to the channels, the session can only use subscribe commands afterwards
+func (p *pubsub) Subscribe(session Session, args []string) (response interface{}, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	s := p.subscriber(session)
+	replies := make([][]interface{}, len(args))
+	for i, channel := range args {
+		if p.channels[channel] == nil {
+			p.channels[channel] = make(map[Session]bool)
+		}
+		p.channels[channel][session] = true
+		s.channels[channel] = true
+		replies[i] = []interface{}{"subscribe", channel, s.count()}
+	}
+	response = confirmations(replies)
+	return
+}
+
+// PSubscribe subscribes the session to channels matching the glob patterns
+func (p *pubsub) PSubscribe(session Session, args []string) (response interface{}, err error) {
+	globs := make([]glob.Glob, len(args))
+	for i, name := range args {
+		globs[i], err = glob.Compile(name)
+		if err != nil {
+			return
+		}
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	s := p.subscriber(session)
+	replies := make([][]interface{}, len(args))
+	for i, name := range args {
+		if p.patterns[name] == nil {
+			p.patterns[name] = &pattern{globs[i], make(map[Session]bool)}
+		}
+		p.patterns[name].sessions[session] = true
+		s.patterns[name] = true
+		replies[i] = []interface{}{"psubscribe", name, s.count()}
+	}
+	response = confirmations(replies)
+	return
+}
+
+// unsubscribe removes subscriptions of the session to the given channels, patterns or shard channels
+// depending on the kind of the reply, or all of them if none are given
+func (p *pubsub) unsubscribe(session Session, args []string, kind string) (response interface{}, err error) {
+	p.m.Lock()
+	defer p.m.Unlock()
+	s := p.subscriber(session)
+	subscribed, remove, count := s.channels, p.removeChannel, s.count
+	switch kind {
+	case "punsubscribe":
+		subscribed, remove = s.patterns, p.removePattern
+	case "sunsubscribe":
+		subscribed, remove, count = s.shardChannels, p.removeShardChannel, s.shardCount
+	}
+	if len(args) == 0 {
+		for name := range subscribed {
+			args = append(args, name)
+		}
+		sort.Strings(args)
+	}
+	if len(args) == 0 {
+		response = []interface{}{kind, nil, count()}
+		return
+	}
+	replies := make([][]interface{}, len(args))
+	for i, name := range args {
+		remove(session, name)
+		replies[i] = []interface{}{kind, name, count()}
+	}
+	response = confirmations(replies)
+	return
+}
+func (p *pubsub) Unsubscribe(session Session, args []string) (response interface{}, err error) {
+	return p.unsubscribe(session, args, "unsubscribe")
+}
+func (p *pubsub) PUnsubscribe(session Session, args []string) (response interface{}, err error) {
+	return p.unsubscribe(session, args, "punsubscribe")
+}
+func (p *pubsub) SUnsubscribe(session Session, args []string) (response interface{}, err error) {
+	return p.unsubscribe(session, args, "sunsubscribe")
+}
+
+// SSubscribe subscribes the session to shard channels, which must be in the same slot. Messages of shard
+// channels are delivered only to subscribers of the exact channel, patterns do not match them
+func (p *pubsub) SSubscribe(session Session, args []string) (response interface{}, err error) {
+	slot := keySlot(args[0])
+	for _, channel := range args[1:] {
+		if keySlot(channel) != slot {
+			err = errCrossSlot
+			return
+		}
+	}
+	p.m.Lock()
+	defer p.m.Unlock()
+	s := p.subscriber(session)
+	if p.shards[slot] == nil {
+		p.shards[slot] = make(map[string]map[Session]bool)
+	}
+	replies := make([][]interface{}, len(args))
+	for i, channel := range args {
+		if p.shards[slot][channel] == nil {
+			p.shards[slot][channel] = make(map[Session]bool)
+		}
+		p.shards[slot][channel][session] = true
+		s.shardChannels[channel] = true
+		replies[i] = []interface{}{"ssubscribe", channel, s.shardCount()}
+	}
+	response = confirmations(replies)
+	return
+}
+
+// SPublish sends the message to subscribers of the shard channel in the slot of the channel
+func (p *pubsub) SPublish(args []string) (response interface{}, err error) {
+	p.m.RLock()
+	receivers := 0
+	for session := range p.shards[keySlot(args[0])][args[0]] {
+		session.Send(formatArray([]interface{}{"smessage", args[0], args[1]}))
+		receivers += 1
+	}
+	p.m.RUnlock()
+	response = int64(receivers)
+	return
+}
+
+// Publish sends the message to subscribers of the channel and of matching patterns. Returns the number
+// of receivers, messages to subscribers that do not keep up are dropped
+func (p *pubsub) Publish(args []string) (response interface{}, err error) {
+	response = int64(p.publish(args[0], args[1]))
+	return
+}
+func (p *pubsub) publish(channel, message string) int {
+	p.m.RLock()
+	defer p.m.RUnlock()
+	receivers := 0
+	for session := range p.channels[channel] {
+		session.Send(formatArray([]interface{}{"message", channel, message}))
+		receivers += 1
+	}
+	for name, pattern := range p.patterns {
+		if !pattern.glob.Match(channel) {
+			continue
+		}
+		for session := range pattern.sessions {
+			session.Send(formatArray([]interface{}{"pmessage", name, channel, message}))
+			receivers += 1
+		}
+	}
+	return receivers
+}
+
+// PubSub inspects the state of subscriptions. CHANNELS and SHARDCHANNELS return channels with at least
+// one subscriber
+func (p *pubsub) PubSub(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("PUBSUB")
+	subcommand := strings.ToUpper(args[0])
+	if subcommand != "CHANNELS" && subcommand != "SHARDCHANNELS" {
+		err = errors.New(fmt.Sprintf("Unknown subcommand '%v'", args[0]))
+		return
+	}
+	if len(args) > 2 {
+		err = formatErr
+		return
+	}
+	var match glob.Glob
+	if len(args) == 2 {
+		match, err = glob.Compile(args[1])
+		if err != nil {
+			return
+		}
+	}
+	names := make([]string, 0)
+	add := func(channel string) {
+		if match == nil || match.Match(channel) {
+			names = append(names, channel)
+		}
+	}
+	p.m.RLock()
+	if subcommand == "CHANNELS" {
+		for channel := range p.channels {
+			add(channel)
+		}
+	} else {
+		for _, shard := range p.shards {
+			for channel := range shard {
+				add(channel)
+			}
+		}
+	}
+	p.m.RUnlock()
+	sort.Strings(names)
+	channels := make([]interface{}, len(names))
+	for i := range names {
+		channels[i] = names[i]
+	}
+	response = channels
+	return
+}
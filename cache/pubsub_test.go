@@ -0,0 +1,124 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestPubSub(t *testing.T) {
+	d := NewDatabases(1)
+	first, second, publisher := &testSession{}, &testSession{}, &testSession{}
+	steps := []struct {
+		session  *testSession
+		method   string
+		args     []string
+		expected string
+	}{
+		{first, "SUBSCRIBE", []string{"news", "sport"}, "1) \"subscribe\"\n2) \"news\"\n3) (integer) 1\n1) \"subscribe\"\n2) \"sport\"\n3) (integer) 2"},
+		{second, "PSUBSCRIBE", []string{"n*"}, "1) \"psubscribe\"\n2) \"n*\"\n3) (integer) 1"},
+		{publisher, "PUBLISH", []string{"news", "hello"}, "(integer) 2"},
+		{publisher, "PUBLISH", []string{"sport", "goal"}, "(integer) 1"},
+		{publisher, "PUBLISH", []string{"weather", "rain"}, "(integer) 0"},
+		{publisher, "PUBSUB", []string{"CHANNELS"}, "1) \"news\"\n2) \"sport\""},
+		{publisher, "PUBSUB", []string{"CHANNELS", "s*"}, "1) \"sport\""},
+		{first, "UNSUBSCRIBE", []string{"news"}, "1) \"unsubscribe\"\n2) \"news\"\n3) (integer) 1"},
+		{publisher, "PUBLISH", []string{"news", "again"}, "(integer) 1"},
+		{first, "UNSUBSCRIBE", nil, "1) \"unsubscribe\"\n2) \"sport\"\n3) (integer) 0"},
+		{first, "UNSUBSCRIBE", nil, "1) \"unsubscribe\"\n2) (nil)\n3) (integer) 0"},
+		{first, "SET", []string{"key", "value"}, "OK"},
+		{second, "PUNSUBSCRIBE", []string{"n*"}, "1) \"punsubscribe\"\n2) \"n*\"\n3) (integer) 0"},
+		{publisher, "PUBSUB", []string{"CHANNELS"}, "(empty array)"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(step.session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	expected := [][]string{
+		{"1) \"message\"\n2) \"news\"\n3) \"hello\"", "1) \"message\"\n2) \"sport\"\n3) \"goal\""},
+		{"1) \"pmessage\"\n2) \"n*\"\n3) \"news\"\n4) \"hello\"", "1) \"pmessage\"\n2) \"n*\"\n3) \"news\"\n4) \"again\""},
+	}
+	for i, session := range []*testSession{first, second} {
+		if len(session.messages) != len(expected[i]) {
+			t.Errorf("expected %v, got %v", expected[i], session.messages)
+			continue
+		}
+		for j := range expected[i] {
+			if session.messages[j] != expected[i][j] {
+				t.Errorf("expected %v, got %v", expected[i][j], session.messages[j])
+			}
+		}
+	}
+}
+
+func TestSubscriberMode(t *testing.T) {
+	d := NewDatabases(1)
+	session, publisher := &testSession{}, &testSession{}
+	d.HandleRequest(session, "SUBSCRIBE", []string{"news"})
+	for _, method := range []string{"GET", "MULTI", "PUBLISH"} {
+		if _, err := d.HandleRequest(session, method, []string{"news"}); err == nil {
+			t.Errorf("expected error on %v in subscriber mode", method)
+		}
+	}
+	if _, err := d.HandleRequest(session, "PSUBSCRIBE", []string{"[a"}); err == nil {
+		t.Errorf("expected error on invalid pattern")
+	}
+	if len(session.closers) != 1 {
+		t.Fatalf("expected one close callback, got %v", len(session.closers))
+	}
+	session.closers[0]()
+	if resp, _ := d.HandleRequest(publisher, "PUBLISH", []string{"news", "hello"}); resp != "(integer) 0" {
+		t.Errorf("expected subscriptions to be removed when the connection is closed, got %v", resp)
+	}
+	if len(d.(*databases).pubsub.subscribers) != 0 {
+		t.Errorf("expected the subscriber to be removed")
+	}
+
+	// messages published in a transaction are sent by EXEC
+	d.HandleRequest(publisher, "MULTI", nil)
+	if resp, err := d.HandleRequest(publisher, "PUBLISH", []string{"news", "hello"}); err != nil || resp != "QUEUED" {
+		t.Errorf("expected PUBLISH to be queued, got %v %v", resp, err)
+	}
+	if resp, err := d.HandleRequest(publisher, "EXEC", nil); err != nil || resp != "1) (integer) 0" {
+		t.Errorf("expected the reply of PUBLISH, got %v %v", resp, err)
+	}
+}
+
+func TestShardPubSub(t *testing.T) {
+	d := NewDatabases(1)
+	session, other, publisher := &testSession{}, &testSession{}, &testSession{}
+	steps := []struct {
+		session  *testSession
+		method   string
+		args     []string
+		expected string
+	}{
+		{session, "SSUBSCRIBE", []string{"{user:1}.orders", "{user:1}.cart"}, "1) \"ssubscribe\"\n2) \"{user:1}.orders\"\n3) (integer) 1\n1) \"ssubscribe\"\n2) \"{user:1}.cart\"\n3) (integer) 2"},
+		{other, "PSUBSCRIBE", []string{"*"}, "1) \"psubscribe\"\n2) \"*\"\n3) (integer) 1"},
+		{session, "SUBSCRIBE", []string{"{user:1}.orders"}, "1) \"subscribe\"\n2) \"{user:1}.orders\"\n3) (integer) 1"},
+		{publisher, "SPUBLISH", []string{"{user:1}.orders", "created"}, "(integer) 1"},
+		{publisher, "PUBLISH", []string{"{user:1}.orders", "plain"}, "(integer) 2"},
+		{publisher, "SPUBLISH", []string{"{user:2}.orders", "created"}, "(integer) 0"},
+		{publisher, "PUBSUB", []string{"SHARDCHANNELS"}, "1) \"{user:1}.cart\"\n2) \"{user:1}.orders\""},
+		{publisher, "PUBSUB", []string{"CHANNELS"}, "1) \"{user:1}.orders\""},
+		{session, "SUNSUBSCRIBE", nil, "1) \"sunsubscribe\"\n2) \"{user:1}.cart\"\n3) (integer) 1\n1) \"sunsubscribe\"\n2) \"{user:1}.orders\"\n3) (integer) 0"},
+		{publisher, "SPUBLISH", []string{"{user:1}.orders", "created"}, "(integer) 0"},
+		{publisher, "PUBSUB", []string{"SHARDCHANNELS"}, "(empty array)"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(step.session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	expected := []string{"1) \"smessage\"\n2) \"{user:1}.orders\"\n3) \"created\"", "1) \"message\"\n2) \"{user:1}.orders\"\n3) \"plain\""}
+	if len(session.messages) != 2 || session.messages[0] != expected[0] || session.messages[1] != expected[1] {
+		t.Errorf("expected %v, got %v", expected, session.messages)
+	}
+	if _, err := d.HandleRequest(publisher, "SSUBSCRIBE", []string{"a", "b"}); err != errCrossSlot {
+		t.Errorf("expected %v, got %v", errCrossSlot, err)
+	}
+	if shards := d.(*databases).pubsub.shards; shards[keySlot("{user:1}.orders")] != nil {
+		t.Errorf("expected the empty slot to be released")
+	}
+}
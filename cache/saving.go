@@ -12,6 +12,47 @@ import (
 )
 
 func Save(c *cache, path, name string) (err error) {
+	var b []byte
+	b, err = json.Marshal(c)
+	if err != nil {
+		return
+	}
+	return writeSnapshot(path, name, b)
+}
+
+// Load replaces keys and expirations of the cache with the saved ones. The snapshot is decoded into a new cache
+// first, so that the cache is not changed if the snapshot is broken. Both mutexes must be locked before calling Load
+func Load(c *cache, path, name string) (err error) {
+	var b []byte
+	b, err = readSnapshot(path, name)
+	if err != nil {
+		return
+	}
+	loaded := NewCache().(*cache)
+	err = json.Unmarshal(b, loaded)
+	if err != nil {
+		return
+	}
+	c.replace(loaded)
+	return
+}
+
+// replace takes keys, expirations and search indexes of the loaded cache. Mutexes, blocked clients, watched keys,
+// notifications and tracking of the cache are kept. Watched keys are modified, all tracked keys are invalidated
+// and blocked clients are woken up to read the loaded keys
+func (c *cache) replace(loaded *cache) {
+	c.Fields, c.slots, c.timeSeries, c.indexes = loaded.Fields, loaded.slots, loaded.timeSeries, loaded.indexes
+	c.Exps.Expirations, c.Exps.Indexes = loaded.Exps.Expirations, loaded.Exps.Indexes
+	for key := range c.watched {
+		c.modified(key)
+	}
+	if c.tracking != nil {
+		c.tracking.invalidateAll()
+	}
+	c.signalAll()
+}
+
+func writeSnapshot(path, name string, b []byte) (err error) {
 	err = os.MkdirAll(path, 0777)
 	if err != nil {
 		return
@@ -23,15 +64,10 @@ func Save(c *cache, path, name string) (err error) {
 		return
 	}
 	defer f.Close()
-	var b []byte
-	b, err = json.Marshal(c)
-	if err != nil {
-		return
-	}
 	_, err = f.Write(b)
 	return
 }
-func Load(c *cache, path, name string) (err error) {
+func readSnapshot(path, name string) (b []byte, err error) {
 	var f *os.File
 	fullname := fmt.Sprintf("%v/%v", strings.TrimSuffix(path, "/"), name)
 	f, err = os.Open(fullname)
@@ -44,28 +80,41 @@ func Load(c *cache, path, name string) (err error) {
 	if err != nil {
 		return
 	}
-	err = json.Unmarshal(buf.Bytes(), &c)
-	if err != nil {
-		return
-	}
-	return
+	return buf.Bytes(), nil
 }
 func (c cache) MarshalJSON() ([]byte, error) {
 	res := make([]byte, 0)
 	res = append(res, []byte("{\"Fields\":{")...)
 	for key := range c.Fields {
 		res = append(res, []byte(fmt.Sprintf("\"%v\":", key))...)
-		inn, err := json.Marshal(c.Fields[key])
+		inn, err := marshalValue(c.Fields[key])
 		if err != nil {
 			return nil, err
 		}
 		res = append(res, inn...)
 		res = append(res, []byte(",")...)
 	}
-	res = append(res[:len(res)-1], []byte("},")...)
+	// an empty database has no trailing comma to replace
+	if len(c.Fields) != 0 {
+		res = res[:len(res)-1]
+	}
+	res = append(res, []byte("},")...)
+
+	// only definitions of search indexes are saved, indexes are rebuilt on loading
+	indexes := make([]*SearchIndex, 0, len(c.indexes))
+	for _, idx := range c.indexes {
+		indexes = append(indexes, idx)
+	}
+	inn, err := json.Marshal(indexes)
+	if err != nil {
+		return nil, err
+	}
+	res = append(res, []byte("\"Indexes\":")...)
+	res = append(res, inn...)
+	res = append(res, []byte(",")...)
 
 	res = append(res, []byte("\"Exps\":")...)
-	inn, err := json.Marshal(c.Exps)
+	inn, err = json.Marshal(c.Exps)
 	if err != nil {
 		return nil, err
 	}
@@ -77,7 +126,10 @@ func (c cache) MarshalJSON() ([]byte, error) {
 func (c *cache) UnmarshalJSON(b []byte) error {
 	*c = *(NewCache().(*cache))
 	temp := make(map[string]interface{})
-	err := json.Unmarshal(b, &temp)
+	// numbers are kept as json.Number, so that values are not rounded when they are encoded again
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	err := decoder.Decode(&temp)
 	if err != nil {
 		return err
 	}
@@ -95,24 +147,35 @@ func (c *cache) UnmarshalJSON(b []byte) error {
 			return err
 		}
 
+		var typed struct{ Type string }
+		err = json.Unmarshal(tempb, &typed)
+		if err == nil && typed.Type != "" {
+			value, err := unmarshalTyped(typed.Type, tempb)
+			if err != nil {
+				return err
+			}
+			c.write(key, value)
+			continue
+		}
+
 		var str string
 		err = json.Unmarshal(tempb, &str)
 		if err == nil {
-			c.Fields[key] = str
+			c.write(key, str)
 			continue
 		}
 
 		var rlist RList
 		err = json.Unmarshal(tempb, &rlist)
 		if err == nil {
-			c.Fields[key] = rlist
+			c.write(key, rlist)
 			continue
 		}
 
 		var hmap Hashmap
 		err = json.Unmarshal(tempb, &hmap)
 		if err == nil {
-			c.Fields[key] = hmap
+			c.write(key, hmap)
 			continue
 		} else {
 			return err
@@ -131,11 +194,28 @@ func (c *cache) UnmarshalJSON(b []byte) error {
 	exp := NewExpirations()
 	err = json.Unmarshal(tempb, &exp)
 	if err == nil {
-		*exp.m = sync.Mutex{}
+		exp.m = &sync.Mutex{}
 		c.Exps = exp
 	} else {
 		return err
 	}
+
+	// snapshots made before search indexes were added have no Indexes
+	if indexes, ok := temp["Indexes"]; ok {
+		tempb, err = json.Marshal(indexes)
+		if err != nil {
+			return err
+		}
+		var definitions []*SearchIndex
+		err = json.Unmarshal(tempb, &definitions)
+		if err != nil {
+			return err
+		}
+		for _, idx := range definitions {
+			c.buildIndex(idx)
+			c.indexes[idx.Name] = idx
+		}
+	}
 	return nil
 }
 func (l RList) MarshalJSON() ([]byte, error) {
@@ -167,3 +247,122 @@ func (l *RList) UnmarshalJSON(b []byte) error {
 	}
 	return nil
 }
+
+// snapshotTypes create empty values of the types that are saved together with their type name
+var snapshotTypes = map[string]func() interface{}{
+	"stream":      func() interface{} { return NewStream() },
+	"hyperloglog": func() interface{} { return NewHyperLogLog() },
+	"bloom":       func() interface{} { return &BloomFilter{} },
+	"cuckoo":      func() interface{} { return &CuckooFilter{} },
+	"cms":         func() interface{} { return &CountMinSketch{} },
+	"topk":        func() interface{} { return &TopK{} },
+	"json":        func() interface{} { return &JSONDocument{} },
+	"timeseries":  func() interface{} { return &TimeSeries{} },
+	"vectorset":   func() interface{} { return &VectorSet{} },
+}
+
+// unmarshalTyped decodes values that are saved together with their type name
+func unmarshalTyped(name string, b []byte) (interface{}, error) {
+	if valueType, ok := moduleTypesByName[name]; ok {
+		return unmarshalModuleValue(valueType, b)
+	}
+	newValue, ok := snapshotTypes[name]
+	if !ok {
+		return nil, errors.New(fmt.Sprintf("unknown type %v", name))
+	}
+	value := newValue()
+	err := json.Unmarshal(b, value)
+	return value, err
+}
+func (id StreamID) MarshalJSON() ([]byte, error) {
+	return json.Marshal(id.String())
+}
+func (id *StreamID) UnmarshalJSON(b []byte) error {
+	var str string
+	err := json.Unmarshal(b, &str)
+	if err != nil {
+		return err
+	}
+	*id, err = parseStreamID(str, 0)
+	return err
+}
+func (s *Stream) MarshalJSON() ([]byte, error) {
+	type stream Stream
+	return json.Marshal(struct {
+		Type string
+		*stream
+	}{"stream", (*stream)(s)})
+}
+func (h *HyperLogLog) MarshalJSON() ([]byte, error) {
+	type hyperLogLog HyperLogLog
+	return json.Marshal(struct {
+		Type string
+		*hyperLogLog
+	}{"hyperloglog", (*hyperLogLog)(h)})
+}
+func (b *BloomFilter) MarshalJSON() ([]byte, error) {
+	type bloomFilter BloomFilter
+	return json.Marshal(struct {
+		Type string
+		*bloomFilter
+	}{"bloom", (*bloomFilter)(b)})
+}
+func (c *CuckooFilter) MarshalJSON() ([]byte, error) {
+	type cuckooFilter CuckooFilter
+	return json.Marshal(struct {
+		Type string
+		*cuckooFilter
+	}{"cuckoo", (*cuckooFilter)(c)})
+}
+func (c *CountMinSketch) MarshalJSON() ([]byte, error) {
+	type countMinSketch CountMinSketch
+	return json.Marshal(struct {
+		Type string
+		*countMinSketch
+	}{"cms", (*countMinSketch)(c)})
+}
+func (t *TopK) MarshalJSON() ([]byte, error) {
+	type topK TopK
+	return json.Marshal(struct {
+		Type string
+		*topK
+	}{"topk", (*topK)(t)})
+}
+func (d *JSONDocument) MarshalJSON() ([]byte, error) {
+	return json.Marshal(struct {
+		Type  string
+		Value interface{}
+	}{"json", d.Value})
+}
+func (d *JSONDocument) UnmarshalJSON(b []byte) error {
+	var temp struct{ Value json.RawMessage }
+	err := json.Unmarshal(b, &temp)
+	if err != nil {
+		return err
+	}
+	d.Value, err = parseJSONValue(temp.Value)
+	return err
+}
+func (s *TimeSeries) MarshalJSON() ([]byte, error) {
+	type timeSeries TimeSeries
+	return json.Marshal(struct {
+		Type string
+		*timeSeries
+	}{"timeseries", (*timeSeries)(s)})
+}
+func (s *VectorSet) MarshalJSON() ([]byte, error) {
+	type vectorSet VectorSet
+	return json.Marshal(struct {
+		Type string
+		*vectorSet
+	}{"vectorset", (*vectorSet)(s)})
+}
+func (s *VectorSet) UnmarshalJSON(b []byte) error {
+	type vectorSet VectorSet
+	err := json.Unmarshal(b, (*vectorSet)(s))
+	if err != nil {
+		return err
+	}
+	s.buildIndex()
+	return nil
+}
@@ -0,0 +1,304 @@
+package cache
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gobwas/glob"
+)
+
+// keySlots is the number of slots keys are distributed over, cursors of SCAN commands are slot numbers
+const (
+	keySlots         = 16384
+	scanDefaultCount = 10
+)
+
+var errInvalidCursor = errors.New("invalid cursor")
+
+var crc16Table = func() (table [256]uint16) {
+	for i := range table {
+		crc := uint16(i) << 8
+		for j := 0; j < 8; j += 1 {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+		table[i] = crc
+	}
+	return
+}()
+
+// crc16 is CRC-16/XMODEM
+func crc16(s string) uint16 {
+	var crc uint16
+	for i := 0; i < len(s); i += 1 {
+		crc = crc<<8 ^ crc16Table[byte(crc>>8)^s[i]]
+	}
+	return crc
+}
+
+// keySlot returns the slot of the key. If the key contains a non empty {tag}, only the tag is hashed,
+// so that related keys can be put in the same slot
+func keySlot(key string) int {
+	if start := strings.IndexByte(key, '{'); start >= 0 {
+		if end := strings.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	return int(crc16(key)) % keySlots
+}
+
+// valueType returns the name of the type of the stored value
+func valueType(value interface{}) string {
+	switch value.(type) {
+	case string:
+		return "string"
+	case RList:
+		return "list"
+	case Hashmap:
+		return "hash"
+	case *Stream:
+		return "stream"
+	case *HyperLogLog:
+		return "hyperloglog"
+	case *BloomFilter:
+		return "bloom"
+	case *CuckooFilter:
+		return "cuckoo"
+	case *CountMinSketch:
+		return "cms"
+	case *TopK:
+		return "topk"
+	case *JSONDocument:
+		return "json"
+	case *TimeSeries:
+		return "timeseries"
+	case *VectorSet:
+		return "vectorset"
+	case nil:
+		return "none"
+	}
+	if valueType, ok := moduleType(value); ok {
+		return valueType.Name
+	}
+	return fmt.Sprintf("%T", value)
+}
+
+// scanOptions are the options of SCAN commands
+type scanOptions struct {
+	cursor   int
+	match    glob.Glob
+	count    int
+	kind     string
+	noValues bool
+}
+
+// parseScanOptions parses the cursor and the options. TYPE is allowed for SCAN and NOVALUES for HSCAN only
+func parseScanOptions(args []string, allowType, allowNoValues bool, formatErr error) (options scanOptions, err error) {
+	if len(args) == 0 {
+		err = formatErr
+		return
+	}
+	options.cursor, err = strconv.Atoi(args[0])
+	if err != nil || options.cursor < 0 || options.cursor >= keySlots {
+		err = errInvalidCursor
+		return
+	}
+	options.count = scanDefaultCount
+	for i := 1; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "NOVALUES" && allowNoValues {
+			options.noValues = true
+			continue
+		}
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		switch {
+		case option == "MATCH":
+			options.match, err = glob.Compile(args[i+1])
+			if err != nil {
+				return
+			}
+		case option == "COUNT":
+			options.count, err = strconv.Atoi(args[i+1])
+			if err != nil || options.count < 1 {
+				err = errors.New("value is out of range, must be positive")
+				return
+			}
+		case option == "TYPE" && allowType:
+			options.kind = strings.ToLower(args[i+1])
+		default:
+			err = formatErr
+			return
+		}
+		i += 1
+	}
+	return
+}
+func (o scanOptions) matches(name string) bool {
+	return o.match == nil || o.match.Match(name)
+}
+
+// Mutex must be locked before calling addToSlot
+func (c *cache) addToSlot(key string) {
+	slot := keySlot(key)
+	if c.slots[slot] == nil {
+		c.slots[slot] = make(map[string]bool)
+	}
+	c.slots[slot][key] = true
+}
+
+// Mutex must be locked before calling removeFromSlot
+func (c *cache) removeFromSlot(key string) {
+	slot := keySlot(key)
+	delete(c.slots[slot], key)
+	if len(c.slots[slot]) == 0 {
+		c.slots[slot] = nil
+	}
+}
+
+// Scan returns the next cursor and keys of whole slots starting from the cursor until at least count keys
+// were checked. The lock is held for one call only, keys present during the whole scan are returned exactly once
+func (c *cache) Scan(args []string) (response interface{}, err error) {
+	options, err := parseScanOptions(args, true, false, syntaxError("SCAN"))
+	if err != nil {
+		return
+	}
+	keys := make([]interface{}, 0, options.count)
+	checked := 0
+	slot := options.cursor
+	c.m.RLock()
+	for ; slot < keySlots && checked < options.count; slot += 1 {
+		names := make([]string, 0, len(c.slots[slot]))
+		for key := range c.slots[slot] {
+			names = append(names, key)
+		}
+		sort.Strings(names)
+		for _, key := range names {
+			if options.kind != "" && valueType(c.read(key)) != options.kind {
+				continue
+			}
+			if options.matches(key) {
+				c.tracked(key)
+				keys = append(keys, key)
+			}
+		}
+		checked += len(names)
+	}
+	c.m.RUnlock()
+	if slot == keySlots {
+		slot = 0
+	}
+	response = []interface{}{strconv.Itoa(slot), keys}
+	return
+}
+
+// slotHeap is a max-heap of slots, it keeps the lowest slots of fields seen by HScan
+type slotHeap []int
+
+func (h slotHeap) Len() int {
+	return len(h)
+}
+func (h slotHeap) Less(i, j int) bool {
+	return h[i] > h[j]
+}
+func (h slotHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+func (h *slotHeap) Push(val interface{}) {
+	*h = append(*h, val.(int))
+}
+func (h *slotHeap) Pop() interface{} {
+	n := len(*h)
+	res := (*h)[n-1]
+	*h = (*h)[:n-1]
+	return res
+}
+
+// HScan iterates fields of the hash the same way as Scan iterates keys, fields are ordered by their slots.
+// Hashes have no slot index, so every call hashes all fields under the read lock, but only the fields of the
+// returned slots are kept and sorted: the lowest count slots are found with a heap and the rest are skipped
+func (c *cache) HScan(args []string) (response interface{}, err error) {
+	options, err := parseScanOptions(args[1:], false, true, syntaxError("HSCAN"))
+	if err != nil {
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	stored := c.read(args[0])
+	var hmap Hashmap
+	switch stored.(type) {
+	case nil:
+		response = []interface{}{"0", []interface{}{}}
+		return
+	case Hashmap:
+		hmap = stored.(Hashmap)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+		return
+	}
+	lowest := make(slotHeap, 0, options.count)
+	for name := range hmap.Hashmap {
+		slot := keySlot(name)
+		if slot < options.cursor {
+			continue
+		}
+		if len(lowest) < options.count {
+			heap.Push(&lowest, slot)
+		} else if slot < lowest[0] {
+			lowest[0] = slot
+			heap.Fix(&lowest, 0)
+		}
+	}
+	if len(lowest) == 0 {
+		response = []interface{}{"0", []interface{}{}}
+		return
+	}
+	// the call stops only between slots, so all fields of the last returned slot are returned
+	last := lowest[0]
+	type slotField struct {
+		slot int
+		name string
+	}
+	fields := make([]slotField, 0, len(lowest))
+	next := 0
+	for name := range hmap.Hashmap {
+		slot := keySlot(name)
+		if slot < options.cursor {
+			continue
+		}
+		if slot > last {
+			if next == 0 || slot < next {
+				next = slot
+			}
+			continue
+		}
+		fields = append(fields, slotField{slot, name})
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		if fields[i].slot != fields[j].slot {
+			return fields[i].slot < fields[j].slot
+		}
+		return fields[i].name < fields[j].name
+	})
+	res := make([]interface{}, 0, len(fields))
+	for _, field := range fields {
+		if !options.matches(field.name) {
+			continue
+		}
+		res = append(res, field.name)
+		if !options.noValues {
+			res = append(res, hmap.Hashmap[field.name])
+		}
+	}
+	response = []interface{}{strconv.Itoa(next), res}
+	return
+}
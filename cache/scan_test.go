@@ -0,0 +1,152 @@
+package cache
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestKeySlot(t *testing.T) {
+	if crc := crc16("123456789"); crc != 0x31C3 {
+		t.Errorf("expected crc16 0x31C3, got %#x", crc)
+	}
+	if slot := keySlot("foo"); slot != 12182 {
+		t.Errorf("expected slot 12182, got %v", slot)
+	}
+	if keySlot("{user1000}.following") != keySlot("{user1000}.followers") {
+		t.Errorf("expected keys with the same tag to be in the same slot")
+	}
+	if keySlot("foo{}{bar}") != int(crc16("foo{}{bar}"))%keySlots {
+		t.Errorf("expected the whole key to be hashed when the first tag is empty")
+	}
+}
+
+// parseScan splits the response of a SCAN command into the cursor and the returned items
+func parseScan(t *testing.T, resp string) (cursor string, items []string) {
+	lines := strings.Split(resp, "\n")
+	cursor = strings.Trim(strings.TrimPrefix(lines[0], "1) "), "\"")
+	if lines[1] == "2) (empty array)" {
+		return
+	}
+	for i, line := range lines[1:] {
+		if i == 0 {
+			line = strings.TrimPrefix(line, "2) ")
+		}
+		line = strings.TrimSpace(line)
+		items = append(items, strings.Trim(line[strings.IndexByte(line, ' ')+1:], "\""))
+	}
+	return
+}
+
+func TestScan(t *testing.T) {
+	c := (NewCache()).(*cache)
+	for i := 0; i < 500; i += 1 {
+		c.Set([]string{fmt.Sprintf("key:%v", i), "value"})
+	}
+	c.HSet([]string{"hash:1", "field", "value"})
+
+	seen := make(map[string]int)
+	cursor := "0"
+	for calls := 0; calls == 0 || cursor != "0"; calls += 1 {
+		resp, err := text(c.Scan([]string{cursor, "COUNT", "20"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var keys []string
+		cursor, keys = parseScan(t, resp)
+		for _, key := range keys {
+			seen[key] += 1
+		}
+		// keys changed during the scan may or may not be returned
+		c.Del([]string{fmt.Sprintf("key:%v", 400+calls)})
+		c.Set([]string{fmt.Sprintf("new:%v", calls), "value"})
+		if calls > keySlots {
+			t.Fatal("scan did not finish")
+		}
+	}
+	for i := 0; i < 400; i += 1 {
+		if key := fmt.Sprintf("key:%v", i); seen[key] != 1 {
+			t.Errorf("expected %v to be returned once, got %v", key, seen[key])
+		}
+	}
+	if seen["hash:1"] != 1 {
+		t.Errorf("expected hash:1 to be returned once, got %v", seen["hash:1"])
+	}
+
+	resp, err := text(c.Scan([]string{"0", "COUNT", "1000000", "MATCH", "key:1?", "TYPE", "string"}))
+	if err != nil {
+		t.Error(err)
+	}
+	cursor, keys := parseScan(t, resp)
+	if cursor != "0" || len(keys) != 10 {
+		t.Errorf("expected 10 keys matching key:1?, got %v", resp)
+	}
+	resp, _ = text(c.Scan([]string{"0", "COUNT", "1000000", "TYPE", "HASH"}))
+	if resp != "1) \"0\"\n2) 1) \"hash:1\"" {
+		t.Errorf("expected only hash:1, got %v", resp)
+	}
+
+	for _, args := range [][]string{{"-1"}, {"16384"}, {"x"}, {"0", "COUNT", "0"}, {"0", "MATCH"}, {"0", "NOVALUES"}} {
+		_, err = text(c.Scan(args))
+		if err == nil {
+			t.Errorf("expected error on SCAN %v", args)
+		}
+	}
+}
+
+func TestHScan(t *testing.T) {
+	c := (NewCache()).(*cache)
+	args := []string{"hash"}
+	for i := 0; i < 100; i += 1 {
+		args = append(args, fmt.Sprintf("field:%v", i), fmt.Sprintf("value:%v", i))
+	}
+	c.HSet(args)
+
+	seen := make(map[string]int)
+	cursor := "0"
+	for calls := 0; calls == 0 || cursor != "0"; calls += 1 {
+		resp, err := text(c.HScan([]string{"hash", cursor, "COUNT", "7"}))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var items []string
+		cursor, items = parseScan(t, resp)
+		if cursor != "0" && len(items) < 14 {
+			t.Errorf("expected at least 7 fields before the end, got %v", len(items)/2)
+		}
+		for i := 0; i < len(items); i += 2 {
+			if items[i+1] != strings.Replace(items[i], "field", "value", 1) {
+				t.Errorf("unexpected value %v of %v", items[i+1], items[i])
+			}
+			seen[items[i]] += 1
+		}
+		if calls > 100 {
+			t.Fatal("scan did not finish")
+		}
+	}
+	if len(seen) != 100 {
+		t.Errorf("expected 100 fields, got %v", len(seen))
+	}
+	for field, count := range seen {
+		if count != 1 {
+			t.Errorf("expected %v to be returned once, got %v", field, count)
+		}
+	}
+
+	resp, err := text(c.HScan([]string{"hash", "0", "MATCH", "field:4?", "COUNT", "1000", "NOVALUES"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if _, items := parseScan(t, resp); len(items) != 10 || !strings.HasPrefix(items[0], "field:4") {
+		t.Errorf("expected 10 fields matching field:4?, got %v", resp)
+	}
+	resp, _ = text(c.HScan([]string{"missing", "0"}))
+	if resp != "1) \"0\"\n2) (empty array)" {
+		t.Errorf("expected empty scan, got %v", resp)
+	}
+	c.Set([]string{"string", "value"})
+	_, err = text(c.HScan([]string{"string", "0"}))
+	if err == nil {
+		t.Errorf("expected error on HSCAN of a string")
+	}
+}
@@ -0,0 +1,390 @@
+package cache
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	lua "github.com/yuin/gopher-lua"
+	"github.com/yuin/gopher-lua/parse"
+)
+
+var (
+	errNoScript   = errors.New("NOSCRIPT No matching script. Please use EVAL.")
+	errNotBusy    = errors.New("NOTBUSY No scripts in execution right now.")
+	errUnkillable = errors.New("UNKILLABLE Sorry the script already executed write commands against the dataset. You can't kill the script.")
+	errBusy       = errors.New("BUSY A script is running for too long. You can only call SCRIPT KILL.")
+	errKilled     = errors.New("Script killed by user with SCRIPT KILL...")
+)
+
+// scriptTimeLimit is the time after which other commands on the database of a running script fail with BUSY
+var scriptTimeLimit = 5 * time.Second
+
+// runningScript is a script being executed on a database, wrote is set atomically after its first write
+type runningScript struct {
+	started time.Time
+	cancel  context.CancelFunc
+	wrote   int32
+}
+
+// scripts are compiled scripts by their SHA1 digests and function libraries shared by all databases,
+// and the scripts running on each database
+type scripts struct {
+	m       *sync.RWMutex
+	protos  map[string]*lua.FunctionProto
+	running map[*cache]*runningScript
+	// libraries by name and by names of their functions
+	libraries map[string]*library
+	functions map[string]*library
+}
+
+func newScripts() scripts {
+	return scripts{&sync.RWMutex{}, make(map[string]*lua.FunctionProto), make(map[*cache]*runningScript),
+		make(map[string]*library), make(map[string]*library)}
+}
+
+// scriptSHA returns the hex encoded SHA1 digest of the script
+func scriptSHA(script string) string {
+	sum := sha1.Sum([]byte(script))
+	return hex.EncodeToString(sum[:])
+}
+
+// compileScript compiles the body of a script into a function without arguments
+func compileScript(name, script string) (proto *lua.FunctionProto, err error) {
+	chunk, err := parse.Parse(strings.NewReader(script), name)
+	if err == nil {
+		proto, err = lua.Compile(chunk, name)
+	}
+	if err != nil {
+		err = errors.New(fmt.Sprintf("Error compiling script: %v", err))
+	}
+	return
+}
+
+// load compiles the script and caches it by its digest. Returns the digest
+func (s *scripts) load(script string) (sha string, err error) {
+	sha = scriptSHA(script)
+	s.m.RLock()
+	_, ok := s.protos[sha]
+	s.m.RUnlock()
+	if ok {
+		return
+	}
+	proto, err := compileScript("@user_script", script)
+	if err != nil {
+		return
+	}
+	s.m.Lock()
+	s.protos[sha] = proto
+	s.m.Unlock()
+	return
+}
+
+// busy returns an error if a script has been running on the database for longer than the time limit
+func (s *scripts) busy(db *cache) error {
+	s.m.RLock()
+	defer s.m.RUnlock()
+	if running, ok := s.running[db]; ok && time.Since(running.started) > scriptTimeLimit {
+		return errBusy
+	}
+	return nil
+}
+
+// parseScriptArgs splits arguments of EVAL and EVALSHA following the script into keys and arguments
+func parseScriptArgs(args []string) (keys, argv []string, err error) {
+	numKeys, err := strconv.Atoi(args[0])
+	if err != nil || numKeys < 0 {
+		err = errors.New("Number of keys can't be negative")
+		return
+	}
+	if numKeys > len(args)-1 {
+		err = errors.New("Number of keys can't be greater than number of args")
+		return
+	}
+	return args[1 : numKeys+1], args[numKeys+1:], nil
+}
+
+// newScriptState creates an interpreter with the libraries available to scripts. Scripts have no access to files
+// and the output of the server and can not compile code at run time
+func newScriptState() *lua.LState {
+	L := lua.NewState(lua.Options{SkipOpenLibs: true})
+	for _, lib := range []struct {
+		name string
+		open lua.LGFunction
+	}{{lua.BaseLibName, lua.OpenBase}, {lua.TabLibName, lua.OpenTable}, {lua.StringLibName, lua.OpenString}, {lua.MathLibName, lua.OpenMath}} {
+		L.Push(L.NewFunction(lib.open))
+		L.Push(lua.LString(lib.name))
+		L.Call(1, 0)
+	}
+	for _, name := range []string{"dofile", "loadfile", "load", "loadstring", "print", "require", "module"} {
+		L.SetGlobal(name, lua.LNil)
+	}
+	return L
+}
+
+// stringTable returns the strings as a lua array
+func stringTable(L *lua.LState, values []string) *lua.LTable {
+	table := L.CreateTable(len(values), 0)
+	for _, value := range values {
+		table.Append(lua.LString(value))
+	}
+	return table
+}
+
+// cacheCall executes the command given by the arguments on the database. With protected set errors are returned
+// as tables with the err field, otherwise they are raised
+func cacheCall(view *cache, running *runningScript, protected bool) lua.LGFunction {
+	return func(L *lua.LState) int {
+		args := make([]string, L.GetTop())
+		for i := range args {
+			switch value := L.Get(i + 1).(type) {
+			case lua.LString, lua.LNumber:
+				args[i] = value.String()
+			default:
+				L.RaiseError("Lua cache lib command arguments must be strings or integers")
+			}
+		}
+		if len(args) == 0 {
+			L.RaiseError("Please specify at least one argument for this cache lib call")
+		}
+		method := strings.ToUpper(args[0])
+		var response interface{}
+		var err error
+		dirty := view.dirty
+		// commands executed by databases change other databases or the session and are not run on the view
+		if command, ok := commands[method]; ok && command.serve != nil {
+			err = errors.New(fmt.Sprintf("%v is not allowed in scripts", method))
+		} else {
+			response, err = view.call(method, args[1:])
+		}
+		if view.dirty != dirty {
+			atomic.StoreInt32(&running.wrote, 1)
+		}
+		if err != nil {
+			if !protected {
+				L.RaiseError("%v", err)
+			}
+			table := L.CreateTable(0, 1)
+			table.RawSetString("err", lua.LString(err.Error()))
+			L.Push(table)
+			return 1
+		}
+		L.Push(luaValue(L, response))
+		return 1
+	}
+}
+
+// run prepares an interpreter with the cache library and executes call, which leaves the result on the stack,
+// under the locks of the database, so that no other command is executed in between. The script may be stopped
+// by SCRIPT KILL until it writes anything. Keys read by the script are tracked for the session if it tracks keys
+func (s *scripts) run(db *cache, session Session, call func(L *lua.LState) error) (response interface{}, err error) {
+	db.Exps.m.Lock()
+	defer db.Exps.m.Unlock()
+	db.m.Lock()
+	defer db.m.Unlock()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	running := &runningScript{started: time.Now(), cancel: cancel}
+	s.m.Lock()
+	s.running[db] = running
+	s.m.Unlock()
+	defer func() {
+		s.m.Lock()
+		delete(s.running, db)
+		s.m.Unlock()
+	}()
+
+	view := db.unlocked()
+	if db.tracking != nil && db.tracking.tracks(session) {
+		view.reader = session
+	}
+	defer db.update(view)
+	L := newScriptState()
+	defer L.Close()
+	L.SetContext(ctx)
+	lib := L.NewTable()
+	L.SetFuncs(lib, map[string]lua.LGFunction{
+		"call":  cacheCall(view, running, false),
+		"pcall": cacheCall(view, running, true),
+	})
+	L.SetGlobal("cache", lib)
+	err = call(L)
+	if err != nil && ctx.Err() != nil {
+		err = errKilled
+		return
+	}
+	if err != nil {
+		if apiErr, ok := err.(*lua.ApiError); ok {
+			err = errors.New(fmt.Sprintf("Error running script: %v", apiErr.Object))
+		}
+		return
+	}
+	return scriptReply(L.Get(-1))
+}
+
+// scriptReply converts the value returned by a script into a reply. Numbers are truncated to integers, tables are
+// arrays up to the first nil unless they have the ok or the err field, which are a status and an error
+func scriptReply(value lua.LValue) (reply interface{}, err error) {
+	switch value.(type) {
+	case lua.LString:
+		return string(value.(lua.LString)), nil
+	case lua.LNumber:
+		return int64(value.(lua.LNumber)), nil
+	case lua.LBool:
+		if value.(lua.LBool) {
+			return int64(1), nil
+		}
+		return nil, nil
+	case *lua.LTable:
+		table := value.(*lua.LTable)
+		if e := table.RawGetString("err"); e.Type() == lua.LTString {
+			return nil, errors.New(e.String())
+		}
+		if ok := table.RawGetString("ok"); ok.Type() == lua.LTString {
+			return formatted(ok.String()), nil
+		}
+		items := []interface{}{}
+		for i := 1; table.RawGetInt(i) != lua.LNil; i += 1 {
+			var item interface{}
+			item, err = scriptReply(table.RawGetInt(i))
+			if err != nil {
+				return
+			}
+			items = append(items, item)
+		}
+		return items, nil
+	}
+	return nil, nil
+}
+
+// luaValue converts a reply of a command into a lua value, nil is false as in replies of redis.call and errors
+// are tables with the err field
+func luaValue(L *lua.LState, value interface{}) lua.LValue {
+	switch value.(type) {
+	case nil:
+		return lua.LFalse
+	case int:
+		return lua.LNumber(value.(int))
+	case int64:
+		return lua.LNumber(value.(int64))
+	case uint64:
+		return lua.LNumber(value.(uint64))
+	case error:
+		table := L.CreateTable(0, 1)
+		table.RawSetString("err", lua.LString(value.(error).Error()))
+		return table
+	case []interface{}:
+		table := L.CreateTable(len(value.([]interface{})), 0)
+		for _, item := range value.([]interface{}) {
+			table.Append(luaValue(L, item))
+		}
+		return table
+	}
+	return lua.LString(fmt.Sprint(value))
+}
+
+// Eval runs the script with the keys and the arguments, the script is cached for EVALSHA
+func (d *databases) Eval(session Session, args []string) (response interface{}, err error) {
+	sha, err := d.scripts.load(args[0])
+	if err != nil {
+		return
+	}
+	return d.EvalSHA(session, append([]string{sha}, args[1:]...))
+}
+
+// EvalSHA runs the cached script with the digest
+func (d *databases) EvalSHA(session Session, args []string) (response interface{}, err error) {
+	keys, argv, err := parseScriptArgs(args[1:])
+	if err != nil {
+		return
+	}
+	d.scripts.m.RLock()
+	proto, ok := d.scripts.protos[strings.ToLower(args[0])]
+	d.scripts.m.RUnlock()
+	if !ok {
+		err = errNoScript
+		return
+	}
+	db := d.db(session.DB())
+	if err = d.scripts.busy(db); err != nil {
+		return
+	}
+	return d.scripts.run(db, session, func(L *lua.LState) error {
+		L.SetGlobal("KEYS", stringTable(L, keys))
+		L.SetGlobal("ARGV", stringTable(L, argv))
+		L.Push(L.NewFunctionFromProto(proto))
+		return L.PCall(0, 1, nil)
+	})
+}
+
+// Script manages the script cache: LOAD caches a script without running it, EXISTS checks digests, FLUSH empties
+// the cache and KILL stops running scripts that have not written anything
+func (s *scripts) Script(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("SCRIPT")
+	switch strings.ToUpper(args[0]) {
+	case "LOAD":
+		if len(args) != 2 {
+			err = formatErr
+			return
+		}
+		return s.load(args[1])
+	case "EXISTS":
+		if len(args) < 2 {
+			err = formatErr
+			return
+		}
+		res := make([]interface{}, 0, len(args)-1)
+		s.m.RLock()
+		for _, sha := range args[1:] {
+			if _, ok := s.protos[strings.ToLower(sha)]; ok {
+				res = append(res, 1)
+			} else {
+				res = append(res, 0)
+			}
+		}
+		s.m.RUnlock()
+		response = res
+	case "FLUSH":
+		if len(args) > 2 || (len(args) == 2 && strings.ToUpper(args[1]) != "ASYNC" && strings.ToUpper(args[1]) != "SYNC") {
+			err = formatErr
+			return
+		}
+		s.m.Lock()
+		s.protos = make(map[string]*lua.FunctionProto)
+		s.m.Unlock()
+		response = formatted("OK")
+	case "KILL":
+		if len(args) != 1 {
+			err = formatErr
+			return
+		}
+		s.m.RLock()
+		defer s.m.RUnlock()
+		if len(s.running) == 0 {
+			err = errNotBusy
+			return
+		}
+		killed := false
+		for _, running := range s.running {
+			if atomic.LoadInt32(&running.wrote) == 0 {
+				running.cancel()
+				killed = true
+			}
+		}
+		if !killed {
+			err = errUnkillable
+			return
+		}
+		response = formatted("OK")
+	default:
+		err = formatErr
+	}
+	return
+}
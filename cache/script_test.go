@@ -0,0 +1,177 @@
+package cache
+
+import (
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestScript(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	sha := scriptSHA("return cache.call('GET', KEYS[1])")
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"EVAL", []string{"return cache.call('SET', KEYS[1], ARGV[1])", "1", "a", "1"}, "OK"},
+		{"SCRIPT", []string{"LOAD", "return cache.call('GET', KEYS[1])"}, sha},
+		{"EVALSHA", []string{sha, "1", "a"}, "1"},
+		{"SCRIPT", []string{"EXISTS", sha, "ffffffffffffffffffffffffffffffffffffffff"}, "1) (integer) 1\n2) (integer) 0"},
+		{"EVAL", []string{"cache.call('rpush', KEYS[1], 'x', 'y'); return {cache.call('lget', KEYS[1], 1), cache.call('rpush', KEYS[1], 'z')}", "1", "list"}, "1) \"y\"\n2) (integer) 3"},
+		{"EVAL", []string{"return {1, 'two', {3.7, false}, nil, 5}", "0"}, "1) (integer) 1\n2) \"two\"\n3) 1) (integer) 3\n   2) (nil)"},
+		{"EVAL", []string{"return cache.call('exists', 'a', 'list') + #ARGV", "0", "x", "y"}, "(integer) 4"},
+		{"EVAL", []string{"return {ok = 'DONE'}", "0"}, "DONE"},
+		{"EVAL", []string{"local r = cache.pcall('hset', 'a', 'f', 'v'); return r.err", "0"}, "Requested field is of type string"},
+		{"SCRIPT", []string{"FLUSH"}, "OK"},
+		{"SCRIPT", []string{"EXISTS", sha}, "1) (integer) 0"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	errors := []struct {
+		method string
+		args   []string
+	}{
+		{"EVALSHA", []string{sha, "0"}},
+		{"EVAL", []string{"return cache.call('HSET', 'a', 'f', 'v')", "0"}},
+		{"EVAL", []string{"return {err = 'custom'}", "0"}},
+		{"EVAL", []string{"return (", "0"}},
+		{"EVAL", []string{"return 1", "2", "a"}},
+		{"EVAL", []string{"return cache.call('SELECT', '1')", "0"}},
+		{"EVAL", []string{"return dofile('/etc/passwd')", "0"}},
+		{"EVAL", []string{"print('output')", "0"}},
+		{"EVAL", []string{"return loadstring('return 1')()", "0"}},
+		{"EVAL", []string{"return load(function() return nil end)", "0"}},
+		{"SCRIPT", []string{"KILL"}},
+	}
+	for _, step := range errors {
+		if _, err := d.HandleRequest(session, step.method, step.args); err == nil {
+			t.Errorf("%v %v: expected error", step.method, step.args)
+		}
+	}
+}
+
+func TestScriptAdminCommands(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"SAVE", []string{"scriptsave"}, "OK"},
+		{"SET", []string{"a", "1"}, "OK"},
+		{"EVAL", []string{"return cache.pcall('LOAD', 'scriptsave').err", "0"}, "LOAD is not allowed in scripts"},
+		{"EVAL", []string{"return cache.pcall('SAVE', 'scriptsave').err", "0"}, "SAVE is not allowed in scripts"},
+		{"EVAL", []string{"return cache.pcall('FLUSHALL').err", "0"}, "FLUSHALL is not allowed in scripts"},
+		{"GET", []string{"a"}, "1"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	// a snapshot of all databases is not a snapshot of one database, the database is kept
+	db := d.(*databases).db(0)
+	if _, err := text(db.Load([]string{"scriptsave"})); err == nil {
+		t.Errorf("expected error loading a snapshot of all databases")
+	}
+	if resp, err := text(db.Get([]string{"a"})); err != nil || resp != "1" {
+		t.Errorf("expected 1, got %v %v", resp, err)
+	}
+}
+
+func TestScriptReplyTypes(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	// values looking like formatted replies are passed to scripts as strings
+	for _, value := range []string{"(integer) 1", "(nil)", "(empty array)", "1) \"a\"\n2) \"b\""} {
+		d.HandleRequest(session, "SET", []string{"key", value})
+		resp, err := d.HandleRequest(session, "EVAL", []string{"local v = cache.call('GET', KEYS[1]); return type(v) .. ':' .. v", "1", "key"})
+		if err != nil || resp != "string:"+value {
+			t.Errorf("expected string %q, got %v %v", value, resp, err)
+		}
+	}
+	d.HandleRequest(session, "HSET", []string{"hash", "field", "multi\nline"})
+	steps := []struct {
+		script   string
+		expected string
+	}{
+		{"return type(cache.call('GET', 'missing'))", "boolean"},
+		{"return type(cache.call('EXISTS', 'key'))", "number"},
+		{"return cache.call('EXISTS', 'key') + 1", "(integer) 2"},
+		{"return cache.call('HSCAN', 'hash', 0)[2][2]", "multi\nline"},
+		{"return #cache.call('KEYS', 'nothing*')", "(integer) 0"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, "EVAL", []string{step.script, "0"})
+		if err != nil || resp != step.expected {
+			t.Errorf("%v: expected %v, got %v %v", step.script, step.expected, resp, err)
+		}
+	}
+}
+
+// waitScript waits until a script is running on the database
+func waitScript(t *testing.T, d *databases, db *cache) *runningScript {
+	for i := 0; i < 1000; i += 1 {
+		d.scripts.m.RLock()
+		running := d.scripts.running[db]
+		d.scripts.m.RUnlock()
+		if running != nil {
+			return running
+		}
+		time.Sleep(time.Millisecond)
+	}
+	t.Fatal("script did not start")
+	return nil
+}
+
+func TestScriptKill(t *testing.T) {
+	defer func(limit time.Duration) { scriptTimeLimit = limit }(scriptTimeLimit)
+	scriptTimeLimit = 50 * time.Millisecond
+	d := NewDatabases(2).(*databases)
+	session := &testSession{}
+
+	done := make(chan error)
+	go func() {
+		_, err := d.HandleRequest(&testSession{}, "EVAL", []string{"while true do end", "0"})
+		done <- err
+	}()
+	waitScript(t, d, d.dbs[0])
+	time.Sleep(2 * scriptTimeLimit)
+	if _, err := d.HandleRequest(session, "GET", []string{"a"}); err != errBusy {
+		t.Errorf("expected %v, got %v", errBusy, err)
+	}
+	if resp, err := d.HandleRequest(&testSession{db: 1}, "SET", []string{"a", "1"}); err != nil || resp != "OK" {
+		t.Errorf("expected other databases to be available, got %v %v", resp, err)
+	}
+	if resp, err := d.HandleRequest(session, "SCRIPT", []string{"KILL"}); err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	if err := <-done; err != errKilled {
+		t.Errorf("expected %v, got %v", errKilled, err)
+	}
+
+	go func() {
+		_, err := d.HandleRequest(&testSession{}, "EVAL", []string{"cache.call('SET', 'a', '1') while true do end", "0"})
+		done <- err
+	}()
+	running := waitScript(t, d, d.dbs[0])
+	for atomic.LoadInt32(&running.wrote) == 0 {
+		time.Sleep(time.Millisecond)
+	}
+	if _, err := d.HandleRequest(session, "SCRIPT", []string{"KILL"}); err != errUnkillable {
+		t.Errorf("expected %v, got %v", errUnkillable, err)
+	}
+	running.cancel()
+	<-done
+	if resp, _ := d.HandleRequest(session, "GET", []string{"a"}); resp != "1" {
+		t.Errorf("expected the write of the stopped script to stay, got %v", resp)
+	}
+}
@@ -0,0 +1,963 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+var errUnknownIndex = errors.New("Unknown Index name")
+
+// SearchField is a hash field included into a search index. TAG fields are split by Separator
+// and matched exactly ignoring case, NUMERIC fields are matched by ranges, TEXT fields by words.
+// Weight multiplies scores of TEXT matches, NoStem disables matching of other word forms.
+// VECTOR fields hold Dim numbers separated by commas and are searched by KNN queries with the Distance,
+// the Algorithm and its parameters of vector sets
+type SearchField struct {
+	Name           string
+	Type           string
+	Separator      string
+	Weight         float64
+	NoStem         bool
+	Algorithm      string
+	Dim            int
+	Distance       string
+	M              int
+	EfConstruction int
+}
+
+type numericEntry struct {
+	value float64
+	key   string
+}
+
+// searchDocument keeps indexed values of a key, so that they can be removed from the index
+// when the key changes
+type searchDocument struct {
+	numbers map[string]float64
+	tags    map[string][]string
+	terms   map[string]map[string]int
+	// field -> number of indexed words
+	lengths map[string]int
+}
+
+// SearchIndex indexes hashes with keys starting with one of the prefixes. The index is updated
+// whenever a key is written or deleted, only the definition is saved in snapshots.
+// StopWords replace the default stop words unless nil
+type SearchIndex struct {
+	Name      string
+	Prefixes  []string
+	Schema    []SearchField
+	StopWords []string
+	stopWords map[string]bool
+	docs      map[string]*searchDocument
+	// field -> tag -> keys
+	tags map[string]map[string]map[string]bool
+	// field -> entries sorted by value
+	numbers map[string][]numericEntry
+	// field -> term -> key -> frequency
+	terms map[string]map[string]map[string]int
+	// field -> stem -> terms
+	stems map[string]map[string]map[string]bool
+	// field -> number of indexed words in all documents
+	lengths map[string]int
+	// field -> vectors by keys
+	vectors map[string]*VectorSet
+}
+
+func NewSearchIndex(name string, prefixes []string, schema []SearchField, stopWords []string) *SearchIndex {
+	index := &SearchIndex{Name: name, Prefixes: prefixes, Schema: schema, StopWords: stopWords}
+	index.reset()
+	return index
+}
+
+func (idx *SearchIndex) reset() {
+	idx.docs = make(map[string]*searchDocument)
+	idx.tags = make(map[string]map[string]map[string]bool)
+	idx.numbers = make(map[string][]numericEntry)
+	idx.terms = make(map[string]map[string]map[string]int)
+	idx.stems = make(map[string]map[string]map[string]bool)
+	idx.lengths = make(map[string]int)
+	idx.vectors = make(map[string]*VectorSet)
+	for _, field := range idx.Schema {
+		if field.Type == "VECTOR" {
+			idx.vectors[field.Name] = NewVectorSet(field.Dim, field.Distance, field.Algorithm, field.M, field.EfConstruction)
+		}
+	}
+	stopWords := idx.StopWords
+	if stopWords == nil {
+		stopWords = defaultStopWords
+	}
+	idx.stopWords = make(map[string]bool, len(stopWords))
+	for _, word := range stopWords {
+		idx.stopWords[strings.ToLower(word)] = true
+	}
+}
+func (idx *SearchIndex) covers(key string) bool {
+	for _, prefix := range idx.Prefixes {
+		if strings.HasPrefix(key, prefix) {
+			return true
+		}
+	}
+	return false
+}
+func (idx *SearchIndex) field(name string) (SearchField, bool) {
+	for _, field := range idx.Schema {
+		if field.Name == name {
+			return field, true
+		}
+	}
+	return SearchField{}, false
+}
+
+// tokenize splits the text into lowercase words
+func tokenize(text string) []string {
+	return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+}
+func splitTags(value, separator string) []string {
+	var tags []string
+	for _, tag := range strings.Split(value, separator) {
+		tag = strings.ToLower(strings.TrimSpace(tag))
+		if tag != "" {
+			tags = append(tags, tag)
+		}
+	}
+	return tags
+}
+
+// add indexes the fields of the hash stored under the key
+func (idx *SearchIndex) add(key string, hash Hashmap) {
+	doc := &searchDocument{make(map[string]float64), make(map[string][]string), make(map[string]map[string]int), make(map[string]int)}
+	for _, field := range idx.Schema {
+		value, ok := hash.Read(field.Name)
+		if !ok {
+			continue
+		}
+		switch field.Type {
+		case "TAG":
+			tags := splitTags(value, field.Separator)
+			doc.tags[field.Name] = tags
+			if idx.tags[field.Name] == nil {
+				idx.tags[field.Name] = make(map[string]map[string]bool)
+			}
+			for _, tag := range tags {
+				if idx.tags[field.Name][tag] == nil {
+					idx.tags[field.Name][tag] = make(map[string]bool)
+				}
+				idx.tags[field.Name][tag][key] = true
+			}
+		case "NUMERIC":
+			number, err := strconv.ParseFloat(value, 64)
+			if err != nil {
+				continue
+			}
+			doc.numbers[field.Name] = number
+			entries := idx.numbers[field.Name]
+			i := sort.Search(len(entries), func(i int) bool {
+				return entries[i].value >= number
+			})
+			entries = append(entries, numericEntry{})
+			copy(entries[i+1:], entries[i:])
+			entries[i] = numericEntry{number, key}
+			idx.numbers[field.Name] = entries
+		case "TEXT":
+			frequencies := make(map[string]int)
+			for _, term := range tokenize(value) {
+				if !idx.stopWords[term] {
+					frequencies[term] += 1
+					doc.lengths[field.Name] += 1
+				}
+			}
+			doc.terms[field.Name] = frequencies
+			idx.lengths[field.Name] += doc.lengths[field.Name]
+			if idx.terms[field.Name] == nil {
+				idx.terms[field.Name] = make(map[string]map[string]int)
+				idx.stems[field.Name] = make(map[string]map[string]bool)
+			}
+			for term, frequency := range frequencies {
+				if idx.terms[field.Name][term] == nil {
+					idx.terms[field.Name][term] = make(map[string]int)
+					stem := field.stem(term)
+					if idx.stems[field.Name][stem] == nil {
+						idx.stems[field.Name][stem] = make(map[string]bool)
+					}
+					idx.stems[field.Name][stem][term] = true
+				}
+				idx.terms[field.Name][term][key] = frequency
+			}
+		case "VECTOR":
+			if vector, ok := parseHashVector(value, field.Dim); ok {
+				idx.vectors[field.Name].add(key, vector)
+			}
+		}
+	}
+	idx.docs[key] = doc
+}
+
+// remove deletes the key from the index
+func (idx *SearchIndex) remove(key string) {
+	doc, ok := idx.docs[key]
+	if !ok {
+		return
+	}
+	delete(idx.docs, key)
+	for _, set := range idx.vectors {
+		set.remove(key)
+	}
+	for field, tags := range doc.tags {
+		for _, tag := range tags {
+			delete(idx.tags[field][tag], key)
+			if len(idx.tags[field][tag]) == 0 {
+				delete(idx.tags[field], tag)
+			}
+		}
+	}
+	for field, number := range doc.numbers {
+		entries := idx.numbers[field]
+		for i := sort.Search(len(entries), func(i int) bool {
+			return entries[i].value >= number
+		}); i < len(entries) && entries[i].value == number; i += 1 {
+			if entries[i].key == key {
+				idx.numbers[field] = append(entries[:i], entries[i+1:]...)
+				break
+			}
+		}
+	}
+	for field, frequencies := range doc.terms {
+		idx.lengths[field] -= doc.lengths[field]
+		schemaField, _ := idx.field(field)
+		for term := range frequencies {
+			delete(idx.terms[field][term], key)
+			if len(idx.terms[field][term]) == 0 {
+				delete(idx.terms[field], term)
+				stem := schemaField.stem(term)
+				delete(idx.stems[field][stem], term)
+				if len(idx.stems[field][stem]) == 0 {
+					delete(idx.stems[field], stem)
+				}
+			}
+		}
+	}
+}
+
+// Mutex must be locked before calling reindex. Updates all indexes covering the key after it was
+// written, changed or deleted
+func (c *cache) reindex(key string) {
+	for _, idx := range c.indexes {
+		if !idx.covers(key) {
+			continue
+		}
+		idx.remove(key)
+		if hash, ok := c.read(key).(Hashmap); ok {
+			idx.add(key, hash)
+		}
+	}
+}
+
+// Mutex must be locked before calling buildIndex. Indexes all existing keys covered by the index
+func (c *cache) buildIndex(idx *SearchIndex) {
+	idx.reset()
+	for key, value := range c.Fields {
+		if hash, ok := value.(Hashmap); ok && idx.covers(key) {
+			idx.add(key, hash)
+		}
+	}
+}
+
+// FTCreate creates an index over hashes and indexes the existing keys
+func (c *cache) FTCreate(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("FT.CREATE")
+	i := 1
+	if strings.ToUpper(args[i]) == "ON" {
+		if i+1 >= len(args) || strings.ToUpper(args[i+1]) != "HASH" {
+			err = errors.New("Only HASH indexes are supported")
+			return
+		}
+		i += 2
+	}
+	prefixes := []string{""}
+	if i < len(args) && strings.ToUpper(args[i]) == "PREFIX" {
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		var count int
+		count, err = strconv.Atoi(args[i+1])
+		if err != nil || count < 1 || i+2+count > len(args) {
+			err = formatErr
+			return
+		}
+		prefixes = args[i+2 : i+2+count]
+		i += 2 + count
+	}
+	var stopWords []string
+	if i < len(args) && strings.ToUpper(args[i]) == "STOPWORDS" {
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		var count int
+		count, err = strconv.Atoi(args[i+1])
+		if err != nil || count < 0 || i+2+count > len(args) {
+			err = formatErr
+			return
+		}
+		stopWords = make([]string, count)
+		for j := range stopWords {
+			stopWords[j] = strings.ToLower(args[i+2+j])
+		}
+		i += 2 + count
+	}
+	if i >= len(args) || strings.ToUpper(args[i]) != "SCHEMA" {
+		err = formatErr
+		return
+	}
+	var schema []SearchField
+	for i += 1; i < len(args); i += 1 {
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		field := SearchField{Name: args[i], Type: strings.ToUpper(args[i+1])}
+		i += 1
+		switch field.Type {
+		case "TAG":
+			field.Separator = ","
+			if i+2 < len(args) && strings.ToUpper(args[i+1]) == "SEPARATOR" {
+				if len(args[i+2]) != 1 {
+					err = errors.New("Tag separator must be a single character")
+					return
+				}
+				field.Separator = args[i+2]
+				i += 2
+			}
+		case "TEXT":
+			field.Weight = 1
+			for i+1 < len(args) {
+				option := strings.ToUpper(args[i+1])
+				if option == "NOSTEM" {
+					field.NoStem = true
+					i += 1
+					continue
+				}
+				if option != "WEIGHT" || i+2 >= len(args) {
+					break
+				}
+				field.Weight, err = strconv.ParseFloat(args[i+2], 64)
+				if err != nil || field.Weight <= 0 {
+					err = errors.New("Weight must be a positive number")
+					return
+				}
+				i += 2
+			}
+		case "NUMERIC":
+		case "VECTOR":
+			field, i, err = parseVectorField(field, args, i)
+			if err != nil {
+				return
+			}
+		default:
+			err = errors.New(fmt.Sprintf("Unknown field type %v", args[i]))
+			return
+		}
+		if _, ok := (&SearchIndex{Schema: schema}).field(field.Name); ok {
+			err = errors.New(fmt.Sprintf("Duplicate field in schema - %v", field.Name))
+			return
+		}
+		schema = append(schema, field)
+	}
+	if len(schema) == 0 {
+		err = formatErr
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if _, ok := c.indexes[args[0]]; ok {
+		err = errors.New("Index already exists")
+		return
+	}
+	idx := NewSearchIndex(args[0], prefixes, schema, stopWords)
+	c.buildIndex(idx)
+	c.indexes[args[0]] = idx
+	response = formatted("OK")
+	return
+}
+func (c *cache) FTDropIndex(args []string) (response interface{}, err error) {
+	if len(args) != 1 && !(len(args) == 2 && strings.ToUpper(args[1]) == "DD") {
+		err = syntaxError("FT.DROPINDEX")
+		return
+	}
+	c.Exps.m.Lock()
+	defer c.Exps.m.Unlock()
+	c.m.Lock()
+	defer c.m.Unlock()
+	idx, ok := c.indexes[args[0]]
+	if !ok {
+		err = errUnknownIndex
+		return
+	}
+	delete(c.indexes, args[0])
+	// DD deletes the indexed keys as well, like DEL with their expirations
+	if len(args) == 2 {
+		for key := range idx.docs {
+			c.setExpiration(key, 0)
+			if c.delete(key) {
+				c.notify(genericClass, "del", key)
+			}
+		}
+	}
+	response = formatted("OK")
+	return
+}
+func (c *cache) FTInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	idx, ok := c.indexes[args[0]]
+	if !ok {
+		err = errUnknownIndex
+		return
+	}
+	prefixes := make([]interface{}, len(idx.Prefixes))
+	for i := range idx.Prefixes {
+		prefixes[i] = idx.Prefixes[i]
+	}
+	attributes := make([]interface{}, len(idx.Schema))
+	for i, field := range idx.Schema {
+		attribute := []interface{}{"identifier", field.Name, "type", field.Type}
+		switch field.Type {
+		case "TAG":
+			attribute = append(attribute, "SEPARATOR", field.Separator)
+		case "TEXT":
+			attribute = append(attribute, "WEIGHT", strconv.FormatFloat(field.weight(), 'f', -1, 64))
+			if field.NoStem {
+				attribute = append(attribute, "NOSTEM")
+			}
+		case "VECTOR":
+			attribute = append(attribute, "algorithm", field.Algorithm, "dim", field.Dim, "distance_metric", field.Distance)
+			if field.Algorithm == "HNSW" {
+				attribute = append(attribute, "M", field.M, "ef_construction", field.EfConstruction)
+			}
+		}
+		attributes[i] = attribute
+	}
+	info := []interface{}{
+		"index_name", idx.Name,
+		"prefixes", prefixes,
+		"attributes", attributes,
+	}
+	if idx.StopWords != nil {
+		stopWords := make([]interface{}, len(idx.StopWords))
+		for i := range idx.StopWords {
+			stopWords[i] = idx.StopWords[i]
+		}
+		info = append(info, "stopwords_list", stopWords)
+	}
+	response = append(info, "num_docs", len(idx.docs))
+	return
+}
+
+// queryParser evaluates a query on the index while parsing it. Terms separated by spaces are intersected,
+// | unites terms, - negates a term and parentheses group terms. A term is a word searched in TEXT fields,
+// @field:word, @field:(terms), @field:{tag | tag} or @field:[min max], where ( before a bound
+// makes it exclusive and -inf, +inf are allowed. * matches all documents. Words match other forms
+// of the word, word* matches words with the prefix and %word% words with one typo, %%word%% and
+// %%%word%%% allow two and three typos
+type queryParser struct {
+	idx   *SearchIndex
+	query string
+	pos   int
+	// matches are the TEXT terms used for scoring, terms under negation are not counted
+	matches map[textMatch]bool
+	negated int
+}
+
+func (p *queryParser) skipSpaces() {
+	for p.pos < len(p.query) && p.query[p.pos] == ' ' {
+		p.pos += 1
+	}
+}
+func (p *queryParser) peek() byte {
+	p.skipSpaces()
+	if p.pos >= len(p.query) {
+		return 0
+	}
+	return p.query[p.pos]
+}
+func (p *queryParser) errorf(format string, args ...interface{}) error {
+	return errors.New(fmt.Sprintf("Syntax error at offset %v: %v", p.pos, fmt.Sprintf(format, args...)))
+}
+
+// word reads characters until a space or a special character
+func (p *queryParser) word() string {
+	start := p.pos
+	for p.pos < len(p.query) && !strings.ContainsRune(" |()[]{}@:", rune(p.query[p.pos])) {
+		p.pos += 1
+	}
+	return p.query[start:p.pos]
+}
+func (p *queryParser) all() map[string]bool {
+	res := make(map[string]bool, len(p.idx.docs))
+	for key := range p.idx.docs {
+		res[key] = true
+	}
+	return res
+}
+func (p *queryParser) union(field string) (map[string]bool, error) {
+	res, err := p.intersection(field)
+	if err != nil {
+		return nil, err
+	}
+	for p.peek() == '|' {
+		p.pos += 1
+		other, err := p.intersection(field)
+		if err != nil {
+			return nil, err
+		}
+		for key := range other {
+			res[key] = true
+		}
+	}
+	return res, nil
+}
+func (p *queryParser) intersection(field string) (map[string]bool, error) {
+	var res map[string]bool
+	for next := p.peek(); next != 0 && next != '|' && next != ')'; next = p.peek() {
+		keys, err := p.term(field)
+		if err != nil {
+			return nil, err
+		}
+		if res == nil {
+			res = keys
+			continue
+		}
+		for key := range res {
+			if !keys[key] {
+				delete(res, key)
+			}
+		}
+	}
+	if res == nil {
+		return nil, p.errorf("empty expression")
+	}
+	return res, nil
+}
+func (p *queryParser) term(field string) (map[string]bool, error) {
+	switch p.peek() {
+	case '-':
+		p.pos += 1
+		p.negated += 1
+		keys, err := p.term(field)
+		p.negated -= 1
+		if err != nil {
+			return nil, err
+		}
+		res := p.all()
+		for key := range keys {
+			delete(res, key)
+		}
+		return res, nil
+	case '(':
+		p.pos += 1
+		res, err := p.union(field)
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, p.errorf("expected )")
+		}
+		p.pos += 1
+		return res, nil
+	case '@':
+		p.pos += 1
+		name := p.word()
+		if p.pos >= len(p.query) || p.query[p.pos] != ':' {
+			return nil, p.errorf("expected : after field name")
+		}
+		p.pos += 1
+		schemaField, ok := p.idx.field(name)
+		if !ok {
+			return nil, errors.New(fmt.Sprintf("Unknown field at offset %v near %v", p.pos, name))
+		}
+		switch schemaField.Type {
+		case "TAG":
+			return p.tags(name)
+		case "NUMERIC":
+			return p.numericRange(name)
+		default:
+			if p.peek() == '(' {
+				return p.term(name)
+			}
+			return p.text(name)
+		}
+	}
+	return p.text(field)
+}
+
+// parseWord splits the query word into the text to search and the kind of matching
+func (p *queryParser) parseWord(word string) (text string, prefix bool, distance int, err error) {
+	if strings.HasPrefix(word, "%") {
+		for distance < len(word)/2 && word[distance] == '%' && word[len(word)-1-distance] == '%' {
+			distance += 1
+		}
+		text = word[distance : len(word)-distance]
+		if distance == 0 || distance > maxFuzzyDistance || strings.Contains(text, "%") || len(tokenize(text)) != 1 {
+			err = p.errorf("bad fuzzy term %v", word)
+		}
+		return
+	}
+	if len(word) > 1 && strings.HasSuffix(word, "*") {
+		text = word[:len(word)-1]
+		if terms := tokenize(text); len(terms) != 1 || len([]rune(terms[0])) < minPrefixLength {
+			err = p.errorf("bad prefix %v", word)
+		}
+		return text, true, 0, err
+	}
+	return word, false, 0, nil
+}
+
+// text matches documents containing the word in the field or in any TEXT field if field is empty.
+// Words consisting of stop words only match all documents
+func (p *queryParser) text(field string) (map[string]bool, error) {
+	word := p.word()
+	if word == "" {
+		return nil, p.errorf("unexpected %q", p.query[p.pos])
+	}
+	if word == "*" && field == "" {
+		return p.all(), nil
+	}
+	text, prefix, distance, err := p.parseWord(word)
+	if err != nil {
+		return nil, err
+	}
+	res := make(map[string]bool)
+	var terms []string
+	for _, term := range tokenize(text) {
+		if prefix || distance > 0 || !p.idx.stopWords[term] {
+			terms = append(terms, term)
+		}
+	}
+	if len(terms) == 0 {
+		if len(tokenize(text)) != 0 {
+			return p.all(), nil
+		}
+		return res, nil
+	}
+	for _, schemaField := range p.idx.Schema {
+		if schemaField.Type != "TEXT" || (field != "" && schemaField.Name != field) {
+			continue
+		}
+		var matched map[string]bool
+		for _, term := range terms {
+			keys := make(map[string]bool)
+			for _, indexed := range p.idx.expand(schemaField, term, prefix, distance) {
+				if p.negated == 0 {
+					p.matches[textMatch{schemaField.Name, indexed}] = true
+				}
+				for key := range p.idx.terms[schemaField.Name][indexed] {
+					if matched == nil || matched[key] {
+						keys[key] = true
+					}
+				}
+			}
+			matched = keys
+		}
+		for key := range matched {
+			res[key] = true
+		}
+	}
+	return res, nil
+}
+func (p *queryParser) tags(field string) (map[string]bool, error) {
+	if p.peek() != '{' {
+		return nil, p.errorf("expected { after tag field")
+	}
+	end := strings.IndexByte(p.query[p.pos:], '}')
+	if end < 0 {
+		return nil, p.errorf("expected }")
+	}
+	res := make(map[string]bool)
+	for _, tag := range splitTags(p.query[p.pos+1:p.pos+end], "|") {
+		for key := range p.idx.tags[field][tag] {
+			res[key] = true
+		}
+	}
+	p.pos += end + 1
+	return res, nil
+}
+
+// parseBound parses a numeric range bound, ( makes the bound exclusive
+func parseBound(arg string) (value float64, exclusive bool, err error) {
+	if strings.HasPrefix(arg, "(") {
+		exclusive = true
+		arg = arg[1:]
+	}
+	switch strings.ToLower(arg) {
+	case "-inf":
+		return math.Inf(-1), exclusive, nil
+	case "+inf", "inf":
+		return math.Inf(1), exclusive, nil
+	}
+	value, err = strconv.ParseFloat(arg, 64)
+	return
+}
+func (p *queryParser) numericRange(field string) (map[string]bool, error) {
+	if p.peek() != '[' {
+		return nil, p.errorf("expected [ after numeric field")
+	}
+	end := strings.IndexByte(p.query[p.pos:], ']')
+	if end < 0 {
+		return nil, p.errorf("expected ]")
+	}
+	bounds := strings.Fields(p.query[p.pos+1 : p.pos+end])
+	if len(bounds) != 2 {
+		return nil, p.errorf("expected [min max]")
+	}
+	min, minExclusive, err := parseBound(bounds[0])
+	if err != nil {
+		return nil, p.errorf("bad lower range: %v", bounds[0])
+	}
+	max, maxExclusive, err := parseBound(bounds[1])
+	if err != nil {
+		return nil, p.errorf("bad upper range: %v", bounds[1])
+	}
+	p.pos += end + 1
+	res := make(map[string]bool)
+	entries := p.idx.numbers[field]
+	for i := sort.Search(len(entries), func(i int) bool {
+		return entries[i].value >= min
+	}); i < len(entries); i += 1 {
+		value := entries[i].value
+		if value > max || (maxExclusive && value == max) {
+			break
+		}
+		if minExclusive && value == min {
+			continue
+		}
+		res[entries[i].key] = true
+	}
+	return res, nil
+}
+
+// searchOptions are the options of FT.SEARCH
+type searchOptions struct {
+	noContent  bool
+	withScores bool
+	fields     []string
+	sortBy     string
+	desc       bool
+	offset     int
+	limit      int
+	// params are values of $name in KNN clauses
+	params map[string]string
+}
+
+func parseSearchOptions(args []string, formatErr error) (options searchOptions, err error) {
+	options.limit = 10
+	for i := 0; i < len(args); i += 1 {
+		switch strings.ToUpper(args[i]) {
+		case "NOCONTENT":
+			options.noContent = true
+		case "WITHSCORES":
+			options.withScores = true
+		case "RETURN":
+			if i+1 >= len(args) {
+				err = formatErr
+				return
+			}
+			var count int
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 0 || i+2+count > len(args) {
+				err = formatErr
+				return
+			}
+			options.fields = args[i+2 : i+2+count]
+			if count == 0 {
+				options.noContent = true
+			}
+			i += 1 + count
+		case "SORTBY":
+			if i+1 >= len(args) {
+				err = formatErr
+				return
+			}
+			options.sortBy = args[i+1]
+			i += 1
+			if i+1 < len(args) && (strings.ToUpper(args[i+1]) == "ASC" || strings.ToUpper(args[i+1]) == "DESC") {
+				options.desc = strings.ToUpper(args[i+1]) == "DESC"
+				i += 1
+			}
+		case "LIMIT":
+			if i+2 >= len(args) {
+				err = formatErr
+				return
+			}
+			options.offset, err = strconv.Atoi(args[i+1])
+			if err != nil || options.offset < 0 {
+				err = formatErr
+				return
+			}
+			options.limit, err = strconv.Atoi(args[i+2])
+			if err != nil || options.limit < 0 {
+				err = formatErr
+				return
+			}
+			i += 2
+		case "PARAMS":
+			if i+1 >= len(args) {
+				err = formatErr
+				return
+			}
+			var count int
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count < 0 || count%2 != 0 || i+2+count > len(args) {
+				err = formatErr
+				return
+			}
+			options.params = make(map[string]string, count/2)
+			for j := i + 2; j < i+2+count; j += 2 {
+				options.params[args[j]] = args[j+1]
+			}
+			i += 1 + count
+		default:
+			err = formatErr
+			return
+		}
+	}
+	return
+}
+
+// sortKeys orders the keys by the field, keys without the field go last. Without a field keys are sorted
+// by score if the query had text terms and by name otherwise
+func (c *cache) sortKeys(idx *SearchIndex, keys []string, scores map[string]float64, options searchOptions) error {
+	if options.sortBy == "" {
+		sort.Slice(keys, func(i, j int) bool {
+			if scores[keys[i]] != scores[keys[j]] {
+				return scores[keys[i]] > scores[keys[j]]
+			}
+			return keys[i] < keys[j]
+		})
+		return nil
+	}
+	field, ok := idx.field(options.sortBy)
+	if !ok {
+		return errors.New(fmt.Sprintf("Property `%v` not loaded nor in schema", options.sortBy))
+	}
+	values := make(map[string]string, len(keys))
+	for _, key := range keys {
+		hash := c.read(key).(Hashmap)
+		if value, ok := hash.Read(field.Name); ok {
+			values[key] = value
+		}
+	}
+	less := func(a, b string) bool {
+		if field.Type == "NUMERIC" {
+			return idx.docs[a].numbers[field.Name] < idx.docs[b].numbers[field.Name]
+		}
+		return values[a] < values[b]
+	}
+	sort.SliceStable(keys, func(i, j int) bool {
+		_, okI := values[keys[i]]
+		_, okJ := values[keys[j]]
+		if okI != okJ {
+			return okI
+		}
+		if !okI || (!less(keys[i], keys[j]) && !less(keys[j], keys[i])) {
+			return keys[i] < keys[j]
+		}
+		return less(keys[i], keys[j]) != options.desc
+	})
+	return nil
+}
+
+// FTSearch returns the number of matching documents followed by keys, their BM25 scores if requested and fields.
+// Queries with a KNN clause return the nearest matching documents ordered by distance unless SORTBY is given,
+// distances are returned as a field named by the clause
+func (c *cache) FTSearch(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("FT.SEARCH")
+	options, err := parseSearchOptions(args[2:], formatErr)
+	if err != nil {
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	idx, ok := c.indexes[args[0]]
+	if !ok {
+		err = errUnknownIndex
+		return
+	}
+	filter, clause := splitKNN(args[1])
+	var knn knnQuery
+	if clause != "" {
+		knn, err = idx.parseKNN(clause, options.params)
+		if err != nil {
+			return
+		}
+	}
+	parser := &queryParser{idx: idx, query: filter, matches: make(map[textMatch]bool)}
+	matched, err := parser.union("")
+	if err != nil {
+		return
+	}
+	if parser.peek() != 0 {
+		err = parser.errorf("unexpected %q", parser.query[parser.pos])
+		return
+	}
+	keys := make([]string, 0, len(matched))
+	var distances map[string]float64
+	if clause != "" {
+		keys, distances = idx.nearest(knn, matched)
+	} else {
+		for key := range matched {
+			keys = append(keys, key)
+		}
+	}
+	scores := make(map[string]float64, len(keys))
+	for _, key := range keys {
+		scores[key] = idx.score(key, parser.matches)
+	}
+	if clause == "" || options.sortBy != "" {
+		err = c.sortKeys(idx, keys, scores, options)
+		if err != nil {
+			return
+		}
+	}
+	res := []interface{}{len(keys)}
+	for i := options.offset; i < len(keys) && i < options.offset+options.limit; i += 1 {
+		res = append(res, keys[i])
+		if options.withScores {
+			res = append(res, strconv.FormatFloat(scores[keys[i]], 'f', -1, 64))
+		}
+		if options.noContent {
+			continue
+		}
+		hash := c.read(keys[i]).(Hashmap)
+		names := options.fields
+		if names == nil {
+			names = make([]string, 0, len(hash.Hashmap)+1)
+			for name := range hash.Hashmap {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			if clause != "" {
+				names = append([]string{knn.score}, names...)
+			}
+		}
+		fields := make([]interface{}, 0, len(names)*2)
+		for _, name := range names {
+			if distance, ok := distances[keys[i]]; ok && name == knn.score {
+				fields = append(fields, name, strconv.FormatFloat(distance, 'f', -1, 32))
+			} else if value, ok := hash.Read(name); ok {
+				fields = append(fields, name, value)
+			}
+		}
+		res = append(res, fields)
+	}
+	response = res
+	return
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func newSearchCache(t *testing.T) *cache {
+	c := (NewCache()).(*cache)
+	c.HSet([]string{"user:1", "name", "Anna Schmidt", "city", "Berlin", "age", "34", "roles", "admin,dev"})
+	c.HSet([]string{"user:2", "name", "Boris Petrov", "city", "Moscow", "age", "28", "roles", "dev"})
+	_, err := text(c.FTCreate([]string{"users", "ON", "HASH", "PREFIX", "1", "user:", "SCHEMA",
+		"name", "TEXT", "city", "TAG", "age", "NUMERIC", "roles", "TAG", "SEPARATOR", ","}))
+	if err != nil {
+		t.Error(err)
+	}
+	c.HSet([]string{"user:3", "name", "Clara Schmidt", "city", "berlin", "age", "30", "roles", "ops"})
+	c.HSet([]string{"order:1", "city", "Berlin", "age", "99"})
+	return c
+}
+
+func TestFTSearch(t *testing.T) {
+	c := newSearchCache(t)
+
+	tests := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"users", "@city:{Berlin} @age:[(30 +inf]", "NOCONTENT"}, "1) (integer) 1\n2) \"user:1\""},
+		{[]string{"users", "@city:{berlin | moscow}", "NOCONTENT", "SORTBY", "age", "DESC"}, "1) (integer) 3\n2) \"user:1\"\n3) \"user:3\"\n4) \"user:2\""},
+		{[]string{"users", "schmidt -@roles:{ops}", "NOCONTENT"}, "1) (integer) 1\n2) \"user:1\""},
+		{[]string{"users", "@name:(anna | boris)", "NOCONTENT"}, "1) (integer) 2\n2) \"user:1\"\n3) \"user:2\""},
+		{[]string{"users", "@roles:{dev} (@age:[-inf 29] | @name:anna)", "NOCONTENT"}, "1) (integer) 2\n2) \"user:1\"\n3) \"user:2\""},
+		{[]string{"users", "*", "NOCONTENT", "SORTBY", "age", "LIMIT", "1", "1"}, "1) (integer) 3\n2) \"user:3\""},
+		{[]string{"users", "@age:[28 28]", "RETURN", "2", "name", "missing"}, "1) (integer) 1\n2) \"user:2\"\n3) 1) \"name\"\n   2) \"Boris Petrov\""},
+	}
+	for _, test := range tests {
+		resp, err := text(c.FTSearch(test.args))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != test.expected {
+			t.Errorf("FT.SEARCH %v: expected:\n%v, got:\n%v", test.args, test.expected, resp)
+		}
+	}
+
+	for _, query := range []string{"@unknown:x", "@age:[1]", "(anna", "@city:berlin"} {
+		_, err := text(c.FTSearch([]string{"users", query}))
+		if err == nil {
+			t.Errorf("expected error on query %v", query)
+		}
+	}
+	_, err := text(c.FTSearch([]string{"missing", "*"}))
+	if err != errUnknownIndex {
+		t.Errorf("expected %v, got %v", errUnknownIndex, err)
+	}
+}
+
+func TestFTIndexUpdates(t *testing.T) {
+	c := newSearchCache(t)
+	search := func(query string) string {
+		resp, err := text(c.FTSearch([]string{"users", query, "NOCONTENT"}))
+		if err != nil {
+			t.Error(err)
+		}
+		return resp
+	}
+
+	c.HSet([]string{"user:2", "city", "Berlin"})
+	if resp := search("@city:{berlin}"); resp != "1) (integer) 3\n2) \"user:1\"\n3) \"user:2\"\n4) \"user:3\"" {
+		t.Errorf("expected HSET to update the index, got:\n%v", resp)
+	}
+	c.Del([]string{"user:1"})
+	c.Set([]string{"user:3", "not a hash"})
+	if resp := search("@city:{berlin}"); resp != "1) (integer) 1\n2) \"user:2\"" {
+		t.Errorf("expected DEL and SET to update the index, got:\n%v", resp)
+	}
+
+	c.Exps.m.Lock()
+	c.setExpiration("user:2", 10*time.Millisecond)
+	c.Exps.m.Unlock()
+	go c.StartCleaner()
+	time.Sleep(200 * time.Millisecond)
+	if resp := search("*"); resp != "1) (integer) 0" {
+		t.Errorf("expected expired key to be removed from the index, got:\n%v", resp)
+	}
+
+	_, err := text(c.FTDropIndex([]string{"users"}))
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = text(c.FTInfo([]string{"users"}))
+	if err != errUnknownIndex {
+		t.Errorf("expected %v, got %v", errUnknownIndex, err)
+	}
+}
+
+func TestFTDropIndexDD(t *testing.T) {
+	c := newSearchCache(t)
+	if _, err := text(c.Expire([]string{"user:3", "100"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := text(c.FTDropIndex([]string{"users", "DD"})); err != nil {
+		t.Fatal(err)
+	}
+	if resp, _ := text(c.Exists([]string{"user:3", "order:1"})); resp != "(integer) 1" {
+		t.Errorf("expected only the key outside of the index to be left, got %v", resp)
+	}
+	if _, ok := c.Exps.Indexes["user:3"]; ok || c.Exps.Len() != 0 {
+		t.Errorf("expected the expiration of the deleted key to be removed, got %v", c.Exps.Expirations)
+	}
+}
+
+func TestFTSaveLoad(t *testing.T) {
+	c := newSearchCache(t)
+
+	_, err := text(c.Save([]string{"searchsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"searchsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.FTSearch([]string{"users", "@age:[30 40]", "NOCONTENT", "SORTBY", "age"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 2\n2) \"user:3\"\n3) \"user:1\"" {
+		t.Errorf("expected 1) (integer) 2\n2) \"user:3\"\n3) \"user:1\", got %v", resp)
+	}
+	resp, _ = text(c.FTInfo([]string{"users"}))
+	if resp[len(resp)-len("(integer) 3"):] != "(integer) 3" {
+		t.Errorf("expected 3 documents, got %v", resp)
+	}
+
+	err = os.Remove("./saves/searchsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,201 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// parseVectorField parses the options of a VECTOR field following the type in the schema: the algorithm, the number
+// of attributes and the attributes TYPE, DIM, DISTANCE_METRIC, M and EF_CONSTRUCTION. Returns the index of the last
+// parsed argument
+func parseVectorField(field SearchField, args []string, i int) (SearchField, int, error) {
+	if i+2 >= len(args) {
+		return field, i, syntaxError("FT.CREATE")
+	}
+	field.Algorithm = strings.ToUpper(args[i+1])
+	if field.Algorithm != "FLAT" && field.Algorithm != "HNSW" {
+		return field, i, errors.New(fmt.Sprintf("Bad arguments for vector similarity algorithm: %v", args[i+1]))
+	}
+	count, err := strconv.Atoi(args[i+2])
+	if err != nil || count < 0 || count%2 != 0 || i+3+count > len(args) {
+		return field, i, errors.New("Bad number of arguments for vector similarity index")
+	}
+	field.M, field.EfConstruction = vectorDefaultM, vectorDefaultEfConstruction
+	for j := i + 3; j < i+3+count; j += 2 {
+		value := strings.ToUpper(args[j+1])
+		switch strings.ToUpper(args[j]) {
+		case "TYPE":
+			if value != "FLOAT32" {
+				return field, i, errors.New(fmt.Sprintf("Bad arguments for vector similarity TYPE: %v", args[j+1]))
+			}
+		case "DIM":
+			field.Dim, err = strconv.Atoi(value)
+			if err != nil || field.Dim < 1 {
+				return field, i, errors.New("Bad arguments for vector similarity DIM")
+			}
+		case "DISTANCE_METRIC":
+			if value != "COSINE" && value != "L2" && value != "IP" {
+				return field, i, errors.New(fmt.Sprintf("Bad arguments for vector similarity DISTANCE_METRIC: %v", args[j+1]))
+			}
+			field.Distance = value
+		case "M":
+			field.M, err = strconv.Atoi(value)
+			if err != nil || field.M < 2 || field.Algorithm != "HNSW" {
+				return field, i, errors.New("Bad arguments for vector similarity M")
+			}
+		case "EF_CONSTRUCTION":
+			field.EfConstruction, err = strconv.Atoi(value)
+			if err != nil || field.EfConstruction < 1 || field.Algorithm != "HNSW" {
+				return field, i, errors.New("Bad arguments for vector similarity EF_CONSTRUCTION")
+			}
+		default:
+			return field, i, errors.New(fmt.Sprintf("Bad arguments for vector similarity index: unknown argument %v", args[j]))
+		}
+	}
+	if field.Dim == 0 || field.Distance == "" {
+		return field, i, errors.New("Missing mandatory parameters: DIM and DISTANCE_METRIC are required for vector fields")
+	}
+	return field, i + 2 + count, nil
+}
+
+// parseHashVector parses a vector stored in a hash field as numbers separated by commas
+func parseHashVector(value string, dim int) ([]float32, bool) {
+	parts := strings.Split(value, ",")
+	if len(parts) != dim {
+		return nil, false
+	}
+	vector := make([]float32, dim)
+	for i, part := range parts {
+		number, err := strconv.ParseFloat(strings.TrimSpace(part), 32)
+		if err != nil {
+			return nil, false
+		}
+		vector[i] = float32(number)
+	}
+	return vector, true
+}
+
+// knnQuery is the vector part of a query like "filter=>[KNN k @field $param AS name EF_RUNTIME ef]", the filter
+// selects documents among which the k nearest ones are returned with their distances under the score name
+type knnQuery struct {
+	k      int
+	field  string
+	vector []float32
+	score  string
+	ef     int
+}
+
+// splitKNN splits the query into the filter and the KNN clause, which is empty for queries without vectors
+func splitKNN(query string) (filter, knn string) {
+	i := strings.Index(query, "=>")
+	if i < 0 {
+		return query, ""
+	}
+	return strings.TrimSpace(query[:i]), strings.TrimSpace(query[i+2:])
+}
+
+// parseKNN parses the KNN clause, vectors and the number of neighbours may be given as $name of PARAMS
+func (idx *SearchIndex) parseKNN(clause string, params map[string]string) (query knnQuery, err error) {
+	formatErr := errors.New(fmt.Sprintf("Syntax error: bad KNN clause %v", clause))
+	if !strings.HasPrefix(clause, "[") || !strings.HasSuffix(clause, "]") {
+		err = formatErr
+		return
+	}
+	tokens := strings.Fields(clause[1 : len(clause)-1])
+	if len(tokens) < 4 || len(tokens)%2 != 0 || strings.ToUpper(tokens[0]) != "KNN" || !strings.HasPrefix(tokens[2], "@") {
+		err = formatErr
+		return
+	}
+	param := func(token string) (string, error) {
+		if !strings.HasPrefix(token, "$") {
+			return token, nil
+		}
+		value, ok := params[token[1:]]
+		if !ok {
+			return "", errors.New(fmt.Sprintf("No such parameter `%v`", token[1:]))
+		}
+		return value, nil
+	}
+	k, err := param(tokens[1])
+	if err != nil {
+		return
+	}
+	query.k, err = strconv.Atoi(k)
+	if err != nil || query.k < 0 {
+		err = errors.New("Invalid KNN value")
+		return
+	}
+	query.field = tokens[2][1:]
+	field, ok := idx.field(query.field)
+	if !ok || field.Type != "VECTOR" {
+		err = errors.New(fmt.Sprintf("Expected a VECTOR field at `%v`", query.field))
+		return
+	}
+	blob, err := param(tokens[3])
+	if err != nil {
+		return
+	}
+	query.vector, ok = parseHashVector(blob, field.Dim)
+	if !ok {
+		err = errors.New(fmt.Sprintf("Error parsing vector similarity query: query vector must have %v numbers", field.Dim))
+		return
+	}
+	query.score, query.ef = "__"+query.field+"_score", vectorDefaultEf
+	for i := 4; i < len(tokens); i += 2 {
+		switch strings.ToUpper(tokens[i]) {
+		case "AS":
+			query.score = tokens[i+1]
+		case "EF_RUNTIME":
+			var ef string
+			ef, err = param(tokens[i+1])
+			if err != nil {
+				return
+			}
+			query.ef, err = strconv.Atoi(ef)
+			if err != nil || query.ef < 1 {
+				err = errors.New("Invalid EF_RUNTIME value")
+				return
+			}
+		default:
+			err = formatErr
+			return
+		}
+	}
+	return
+}
+
+// nearest returns up to k matched documents closest to the query vector ordered by distance. The index of the field
+// is used if all documents match, otherwise the matched documents are compared with the query one by one
+func (idx *SearchIndex) nearest(query knnQuery, matched map[string]bool) (keys []string, distances map[string]float64) {
+	set := idx.vectors[query.field]
+	var results []hnswCandidate
+	if query.k > 0 && len(matched) == len(idx.docs) {
+		results = set.search(query.vector, query.k, query.ef)
+	} else {
+		distance := set.distance()
+		for key := range matched {
+			if vector, ok := set.Vectors[key]; ok {
+				results = append(results, hnswCandidate{&hnswNode{element: key}, distance(query.vector, vector)})
+			}
+		}
+		sort.Slice(results, func(i, j int) bool {
+			if results[i].distance == results[j].distance {
+				return results[i].node.element < results[j].node.element
+			}
+			return results[i].distance < results[j].distance
+		})
+		if len(results) > query.k {
+			results = results[:query.k]
+		}
+	}
+	keys = make([]string, len(results))
+	distances = make(map[string]float64, len(results))
+	for i, result := range results {
+		keys[i] = result.node.element
+		distances[result.node.element] = result.distance
+	}
+	return
+}
@@ -0,0 +1,75 @@
+package cache
+
+import (
+	"strings"
+	"testing"
+)
+
+func newVectorSearchCache(t *testing.T, algorithm string) *cache {
+	c := (NewCache()).(*cache)
+	_, err := text(c.FTCreate([]string{"docs", "PREFIX", "1", "doc:", "SCHEMA", "genre", "TAG",
+		"vec", "VECTOR", algorithm, "6", "TYPE", "FLOAT32", "DIM", "2", "DISTANCE_METRIC", "L2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.HSet([]string{"doc:1", "vec", "0,0", "genre", "a"})
+	c.HSet([]string{"doc:2", "vec", "1,0", "genre", "b"})
+	c.HSet([]string{"doc:3", "vec", "5,5", "genre", "a"})
+	c.HSet([]string{"doc:4", "vec", "bad", "genre", "a"})
+	return c
+}
+
+func TestFTSearchKNN(t *testing.T) {
+	for _, algorithm := range []string{"FLAT", "HNSW"} {
+		c := newVectorSearchCache(t, algorithm)
+		resp, err := text(c.FTSearch([]string{"docs", "*=>[KNN 2 @vec $q]", "PARAMS", "2", "q", "1,0"}))
+		expected := "1) (integer) 2\n2) \"doc:2\"\n3) 1) \"__vec_score\"\n   2) \"0\"\n   3) \"genre\"\n   4) \"b\"\n   5) \"vec\"\n   6) \"1,0\"\n" +
+			"4) \"doc:1\"\n5) 1) \"__vec_score\"\n   2) \"1\"\n   3) \"genre\"\n   4) \"a\"\n   5) \"vec\"\n   6) \"0,0\""
+		if err != nil || resp != expected {
+			t.Errorf("%v: expected:\n%v, got:\n%v %v", algorithm, expected, resp, err)
+		}
+		resp, err = text(c.FTSearch([]string{"docs", "@genre:{a}=>[KNN 1 @vec $q AS dist]", "RETURN", "1", "dist", "PARAMS", "2", "q", "1,0"}))
+		if expected := "1) (integer) 1\n2) \"doc:1\"\n3) 1) \"dist\"\n   2) \"1\""; err != nil || resp != expected {
+			t.Errorf("%v: expected:\n%v, got:\n%v %v", algorithm, expected, resp, err)
+		}
+
+		// the index follows changes of the hashes
+		c.HSet([]string{"doc:2", "vec", "10,10"})
+		c.Del([]string{"doc:1"})
+		resp, err = text(c.FTSearch([]string{"docs", "*=>[KNN 1 @vec $q]", "NOCONTENT", "PARAMS", "2", "q", "1,0"}))
+		if expected := "1) (integer) 1\n2) \"doc:3\""; err != nil || resp != expected {
+			t.Errorf("%v: expected:\n%v, got:\n%v %v", algorithm, expected, resp, err)
+		}
+	}
+}
+
+func TestFTSearchKNNErrors(t *testing.T) {
+	c := newVectorSearchCache(t, "FLAT")
+	for _, args := range [][]string{
+		{"docs", "*=>[KNN 2 @genre $q]", "PARAMS", "2", "q", "0,0"},
+		{"docs", "*=>[KNN 2 @vec $missing]"},
+		{"docs", "*=>[KNN 2 @vec $q]", "PARAMS", "2", "q", "0,0,0"},
+		{"docs", "*=>[KNN x @vec $q]", "PARAMS", "2", "q", "0,0"},
+		{"docs", "*=>KNN 2 @vec $q", "PARAMS", "2", "q", "0,0"},
+		{"docs", "*", "PARAMS", "1", "q"},
+	} {
+		if _, err := text(c.FTSearch(args)); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+	for _, args := range [][]string{
+		{"other", "SCHEMA", "vec", "VECTOR", "FLAT", "2", "DIM", "2"},
+		{"other", "SCHEMA", "vec", "VECTOR", "FLAT", "4", "DIM", "2", "DISTANCE_METRIC", "HAMMING"},
+		{"other", "SCHEMA", "vec", "VECTOR", "IVF", "4", "DIM", "2", "DISTANCE_METRIC", "L2"},
+		{"other", "SCHEMA", "vec", "VECTOR", "FLAT", "6", "DIM", "2", "DISTANCE_METRIC", "L2", "M", "4"},
+		{"other", "SCHEMA", "vec", "VECTOR", "FLAT", "4", "DIM", "2"},
+	} {
+		if _, err := text(c.FTCreate(args)); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+	resp, _ := text(c.FTInfo([]string{"docs"}))
+	if expected := "\"distance_metric\"\n"; !strings.Contains(resp, expected) || !strings.Contains(resp, "\"L2\"") {
+		t.Errorf("expected vector attributes in:\n%v", resp)
+	}
+}
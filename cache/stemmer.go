@@ -0,0 +1,261 @@
+package cache
+
+// porterStemmer implements the Porter stemming algorithm for English words.
+// b[:k+1] is the current word, j marks the end of the stem after a successful ends
+type porterStemmer struct {
+	b []byte
+	k int
+	j int
+}
+
+// porterStem reduces the word to its stem, so that e.g. "connections" and "connected" both become "connect".
+// Words shorter than 3 letters and words with non ASCII letters are returned as is
+func porterStem(word string) string {
+	if len(word) <= 2 {
+		return word
+	}
+	for i := 0; i < len(word); i += 1 {
+		if word[i] < 'a' || word[i] > 'z' {
+			return word
+		}
+	}
+	s := &porterStemmer{b: []byte(word), k: len(word) - 1}
+	s.step1ab()
+	if s.k > 0 {
+		s.step1c()
+		s.step2()
+		s.step3()
+		s.step4()
+		s.step5()
+	}
+	return string(s.b[:s.k+1])
+}
+
+// cons tells if b[i] is a consonant
+func (s *porterStemmer) cons(i int) bool {
+	switch s.b[i] {
+	case 'a', 'e', 'i', 'o', 'u':
+		return false
+	case 'y':
+		return i == 0 || !s.cons(i-1)
+	}
+	return true
+}
+
+// m measures the number of vowel-consonant sequences in b[:j+1]
+func (s *porterStemmer) m() int {
+	n, i := 0, 0
+	for ; ; i += 1 {
+		if i > s.j {
+			return n
+		}
+		if !s.cons(i) {
+			break
+		}
+	}
+	for i += 1; ; i += 1 {
+		for ; ; i += 1 {
+			if i > s.j {
+				return n
+			}
+			if s.cons(i) {
+				break
+			}
+		}
+		n += 1
+		for i += 1; ; i += 1 {
+			if i > s.j {
+				return n
+			}
+			if !s.cons(i) {
+				break
+			}
+		}
+	}
+}
+func (s *porterStemmer) vowelInStem() bool {
+	for i := 0; i <= s.j; i += 1 {
+		if !s.cons(i) {
+			return true
+		}
+	}
+	return false
+}
+func (s *porterStemmer) doubleCons(i int) bool {
+	return i >= 1 && s.b[i] == s.b[i-1] && s.cons(i)
+}
+
+// cvc tells if b[i-2:i+1] is consonant-vowel-consonant and the last consonant is not w, x or y
+func (s *porterStemmer) cvc(i int) bool {
+	if i < 2 || !s.cons(i) || s.cons(i-1) || !s.cons(i-2) {
+		return false
+	}
+	ch := s.b[i]
+	return ch != 'w' && ch != 'x' && ch != 'y'
+}
+func (s *porterStemmer) ends(suffix string) bool {
+	l := len(suffix)
+	if l > s.k+1 || string(s.b[s.k-l+1:s.k+1]) != suffix {
+		return false
+	}
+	s.j = s.k - l
+	return true
+}
+func (s *porterStemmer) setTo(suffix string) {
+	s.b = append(s.b[:s.j+1], suffix...)
+	s.k = len(s.b) - 1
+}
+func (s *porterStemmer) replace(suffix string) {
+	if s.m() > 0 {
+		s.setTo(suffix)
+	}
+}
+
+// step1ab removes plurals and -ed or -ing
+func (s *porterStemmer) step1ab() {
+	if s.b[s.k] == 's' {
+		if s.ends("sses") {
+			s.k -= 2
+		} else if s.ends("ies") {
+			s.setTo("i")
+		} else if s.b[s.k-1] != 's' {
+			s.k -= 1
+		}
+	}
+	if s.ends("eed") {
+		if s.m() > 0 {
+			s.k -= 1
+		}
+	} else if (s.ends("ed") || s.ends("ing")) && s.vowelInStem() {
+		s.k = s.j
+		s.b = s.b[:s.k+1]
+		if s.ends("at") {
+			s.setTo("ate")
+		} else if s.ends("bl") {
+			s.setTo("ble")
+		} else if s.ends("iz") {
+			s.setTo("ize")
+		} else if s.doubleCons(s.k) {
+			if ch := s.b[s.k]; ch != 'l' && ch != 's' && ch != 'z' {
+				s.k -= 1
+			}
+		} else {
+			s.j = s.k
+			if s.m() == 1 && s.cvc(s.k) {
+				s.setTo("e")
+			}
+		}
+	}
+}
+
+// step1c turns terminal y to i when there is another vowel in the stem
+func (s *porterStemmer) step1c() {
+	if s.ends("y") && s.vowelInStem() {
+		s.b[s.k] = 'i'
+	}
+}
+
+// replaceFirst replaces the first matching suffix from pairs of suffix and replacement
+func (s *porterStemmer) replaceFirst(pairs ...string) {
+	for i := 0; i < len(pairs); i += 2 {
+		if s.ends(pairs[i]) {
+			s.replace(pairs[i+1])
+			return
+		}
+	}
+}
+
+// step2 maps double suffixes to single ones
+func (s *porterStemmer) step2() {
+	switch s.b[s.k-1] {
+	case 'a':
+		s.replaceFirst("ational", "ate", "tional", "tion")
+	case 'c':
+		s.replaceFirst("enci", "ence", "anci", "ance")
+	case 'e':
+		s.replaceFirst("izer", "ize")
+	case 'l':
+		s.replaceFirst("bli", "ble", "alli", "al", "entli", "ent", "eli", "e", "ousli", "ous")
+	case 'o':
+		s.replaceFirst("ization", "ize", "ation", "ate", "ator", "ate")
+	case 's':
+		s.replaceFirst("alism", "al", "iveness", "ive", "fulness", "ful", "ousness", "ous")
+	case 't':
+		s.replaceFirst("aliti", "al", "iviti", "ive", "biliti", "ble")
+	case 'g':
+		s.replaceFirst("logi", "log")
+	}
+}
+
+// step3 deals with -ic-, -full, -ness etc.
+func (s *porterStemmer) step3() {
+	switch s.b[s.k] {
+	case 'e':
+		s.replaceFirst("icate", "ic", "ative", "", "alize", "al")
+	case 'i':
+		s.replaceFirst("iciti", "ic")
+	case 'l':
+		s.replaceFirst("ical", "ic", "ful", "")
+	case 's':
+		s.replaceFirst("ness", "")
+	}
+}
+
+// step4 removes -ant, -ence etc. in context <c>vcvc<v>
+func (s *porterStemmer) step4() {
+	var suffixes []string
+	switch s.b[s.k-1] {
+	case 'a':
+		suffixes = []string{"al"}
+	case 'c':
+		suffixes = []string{"ance", "ence"}
+	case 'e':
+		suffixes = []string{"er"}
+	case 'i':
+		suffixes = []string{"ic"}
+	case 'l':
+		suffixes = []string{"able", "ible"}
+	case 'n':
+		suffixes = []string{"ant", "ement", "ment", "ent"}
+	case 'o':
+		if s.ends("ion") && s.j >= 0 && (s.b[s.j] == 's' || s.b[s.j] == 't') {
+			break
+		}
+		suffixes = []string{"ou"}
+	case 's':
+		suffixes = []string{"ism"}
+	case 't':
+		suffixes = []string{"ate", "iti"}
+	case 'u':
+		suffixes = []string{"ous"}
+	case 'v':
+		suffixes = []string{"ive"}
+	case 'z':
+		suffixes = []string{"ize"}
+	default:
+		return
+	}
+	matched := suffixes == nil
+	for _, suffix := range suffixes {
+		if s.ends(suffix) {
+			matched = true
+			break
+		}
+	}
+	if matched && s.m() > 1 {
+		s.k = s.j
+	}
+}
+
+// step5 removes a final -e and changes -ll to -l if the measure is large enough
+func (s *porterStemmer) step5() {
+	s.j = s.k
+	if s.b[s.k] == 'e' {
+		if a := s.m(); a > 1 || a == 1 && !s.cvc(s.k-1) {
+			s.k -= 1
+		}
+	}
+	if s.b[s.k] == 'l' && s.doubleCons(s.k) && s.m() > 1 {
+		s.k -= 1
+	}
+}
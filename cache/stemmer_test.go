@@ -0,0 +1,42 @@
+package cache
+
+import (
+	"testing"
+)
+
+func TestPorterStem(t *testing.T) {
+	words := map[string]string{
+		"caresses":       "caress",
+		"ponies":         "poni",
+		"ties":           "ti",
+		"cats":           "cat",
+		"feed":           "feed",
+		"agreed":         "agre",
+		"plastered":      "plaster",
+		"motoring":       "motor",
+		"sing":           "sing",
+		"conflated":      "conflat",
+		"troubled":       "troubl",
+		"sized":          "size",
+		"hopping":        "hop",
+		"falling":        "fall",
+		"filing":         "file",
+		"happy":          "happi",
+		"relational":     "relat",
+		"generalization": "gener",
+		"running":        "run",
+		"connections":    "connect",
+		"connected":      "connect",
+		"controll":       "control",
+		"electrical":     "electr",
+		"adoption":       "adopt",
+		"hopefulness":    "hope",
+		"go":             "go",
+		"größe":          "größe",
+	}
+	for word, expected := range words {
+		if stem := porterStem(word); stem != expected {
+			t.Errorf("%v: expected %v, got %v", word, expected, stem)
+		}
+	}
+}
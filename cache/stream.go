@@ -0,0 +1,651 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errStreamID = errors.New("Invalid stream ID specified as stream command argument")
+var errStreamIDTooSmall = errors.New("The ID specified in XADD is equal or smaller than the target stream top item")
+
+// StreamID identifies an entry of a stream: milliseconds part and sequence number
+type StreamID struct {
+	Ms  uint64
+	Seq uint64
+}
+
+var maxStreamID = StreamID{math.MaxUint64, math.MaxUint64}
+
+func (id StreamID) String() string {
+	return fmt.Sprintf("%v-%v", id.Ms, id.Seq)
+}
+func (id StreamID) Less(other StreamID) bool {
+	return id.Ms < other.Ms || (id.Ms == other.Ms && id.Seq < other.Seq)
+}
+func (id StreamID) next() (StreamID, bool) {
+	if id.Seq < math.MaxUint64 {
+		return StreamID{id.Ms, id.Seq + 1}, true
+	}
+	if id.Ms < math.MaxUint64 {
+		return StreamID{id.Ms + 1, 0}, true
+	}
+	return id, false
+}
+func (id StreamID) prev() (StreamID, bool) {
+	if id.Seq > 0 {
+		return StreamID{id.Ms, id.Seq - 1}, true
+	}
+	if id.Ms > 0 {
+		return StreamID{id.Ms - 1, math.MaxUint64}, true
+	}
+	return id, false
+}
+
+// parseStreamID parses "ms-seq" or "ms", in the latter case seq is set to defaultSeq
+func parseStreamID(s string, defaultSeq uint64) (id StreamID, err error) {
+	parts := strings.SplitN(s, "-", 2)
+	id.Ms, err = strconv.ParseUint(parts[0], 10, 64)
+	if err != nil {
+		err = errStreamID
+		return
+	}
+	if len(parts) == 1 {
+		id.Seq = defaultSeq
+		return
+	}
+	id.Seq, err = strconv.ParseUint(parts[1], 10, 64)
+	if err != nil {
+		err = errStreamID
+	}
+	return
+}
+
+// parseRangeID parses a range boundary of XRANGE and friends, supporting "-", "+" and exclusive "(" ids
+func parseRangeID(s string, start bool) (id StreamID, err error) {
+	switch s {
+	case "-":
+		return StreamID{}, nil
+	case "+":
+		return maxStreamID, nil
+	}
+	exclusive := strings.HasPrefix(s, "(")
+	s = strings.TrimPrefix(s, "(")
+	defaultSeq := uint64(0)
+	if !start {
+		defaultSeq = math.MaxUint64
+	}
+	id, err = parseStreamID(s, defaultSeq)
+	if err != nil || !exclusive {
+		return
+	}
+	var ok bool
+	if start {
+		id, ok = id.next()
+	} else {
+		id, ok = id.prev()
+	}
+	if !ok {
+		err = errors.New("invalid start or end ID for exclusive range")
+	}
+	return
+}
+
+type StreamEntry struct {
+	ID     StreamID
+	Fields []string
+}
+
+func (e StreamEntry) format() []interface{} {
+	fields := make([]interface{}, len(e.Fields))
+	for i := range e.Fields {
+		fields[i] = e.Fields[i]
+	}
+	return []interface{}{e.ID.String(), fields}
+}
+
+// Stream is an append-only log of entries ordered by their ids
+type Stream struct {
+	Entries []StreamEntry
+	LastID  StreamID
+	Groups  map[string]*ConsumerGroup
+}
+
+func NewStream() *Stream {
+	return &Stream{Entries: make([]StreamEntry, 0), Groups: make(map[string]*ConsumerGroup)}
+}
+
+// search returns the index of the first entry with id not less than the given one
+func (s *Stream) search(id StreamID) int {
+	return sort.Search(len(s.Entries), func(i int) bool {
+		return !s.Entries[i].ID.Less(id)
+	})
+}
+
+// nextID generates the id of a new entry, spec is "*", "ms-*" or an explicit id
+func (s *Stream) nextID(spec string) (id StreamID, err error) {
+	if spec == "*" {
+		ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+		if ms > s.LastID.Ms {
+			return StreamID{ms, 0}, nil
+		}
+		var ok bool
+		id, ok = s.LastID.next()
+		if !ok {
+			err = errors.New("The stream has exhausted the last possible ID, unable to add more items")
+		}
+		return
+	}
+	if strings.HasSuffix(spec, "-*") {
+		id.Ms, err = strconv.ParseUint(strings.TrimSuffix(spec, "-*"), 10, 64)
+		if err != nil {
+			err = errStreamID
+			return
+		}
+		if id.Ms == s.LastID.Ms {
+			if s.LastID.Seq == math.MaxUint64 {
+				err = errStreamIDTooSmall
+				return
+			}
+			id.Seq = s.LastID.Seq + 1
+		}
+	} else {
+		id, err = parseStreamID(spec, 0)
+		if err != nil {
+			return
+		}
+	}
+	if id == (StreamID{}) {
+		err = errors.New("The ID specified in XADD must be greater than 0-0")
+		return
+	}
+	if !s.LastID.Less(id) {
+		err = errStreamIDTooSmall
+	}
+	return
+}
+func (s *Stream) add(id StreamID, fields []string) {
+	entry := StreamEntry{id, make([]string, len(fields))}
+	copy(entry.Fields, fields)
+	s.Entries = append(s.Entries, entry)
+	s.LastID = id
+}
+
+// rangeEntries returns entries with ids between start and end inclusive, count <= 0 means no limit
+func (s *Stream) rangeEntries(start, end StreamID, count int, rev bool) []StreamEntry {
+	if end.Less(start) {
+		return nil
+	}
+	from := s.search(start)
+	to := s.search(end)
+	if to < len(s.Entries) && s.Entries[to].ID == end {
+		to += 1
+	}
+	res := make([]StreamEntry, 0)
+	if rev {
+		for i := to - 1; i >= from && (count <= 0 || len(res) < count); i -= 1 {
+			res = append(res, s.Entries[i])
+		}
+	} else {
+		for i := from; i < to && (count <= 0 || len(res) < count); i += 1 {
+			res = append(res, s.Entries[i])
+		}
+	}
+	return res
+}
+
+// streamTrim describes MAXLEN and MINID trimming strategies. Approximate trimming ("~")
+// is accepted for compatibility, but the stream is always trimmed exactly
+type streamTrim struct {
+	strategy  string
+	maxLen    int
+	minID     StreamID
+	limit     int
+	specified bool
+}
+
+// parseTrim parses trimming arguments starting at args[i] and returns the index after them
+func parseTrim(args []string, i int) (trim streamTrim, next int, err error) {
+	trim.strategy = strings.ToUpper(args[i])
+	i += 1
+	if i < len(args) && (args[i] == "=" || args[i] == "~") {
+		i += 1
+	}
+	if i >= len(args) {
+		err = errors.New("syntax error")
+		return
+	}
+	switch trim.strategy {
+	case "MAXLEN":
+		trim.maxLen, err = strconv.Atoi(args[i])
+		if err != nil || trim.maxLen < 0 {
+			err = errors.New("The MAXLEN argument must be >= 0.")
+			return
+		}
+	case "MINID":
+		trim.minID, err = parseStreamID(args[i], 0)
+		if err != nil {
+			return
+		}
+	}
+	i += 1
+	if i+1 < len(args) && strings.ToUpper(args[i]) == "LIMIT" {
+		trim.limit, err = strconv.Atoi(args[i+1])
+		if err != nil || trim.limit < 0 {
+			err = errors.New("The LIMIT argument must be >= 0.")
+			return
+		}
+		i += 2
+	}
+	trim.specified = true
+	next = i
+	return
+}
+
+// trim removes entries from the head of the stream, returns number of removed entries
+func (s *Stream) trim(trim streamTrim) int {
+	var n int
+	switch trim.strategy {
+	case "MAXLEN":
+		n = len(s.Entries) - trim.maxLen
+	case "MINID":
+		n = s.search(trim.minID)
+	}
+	if n <= 0 {
+		return 0
+	}
+	if trim.limit > 0 && n > trim.limit {
+		n = trim.limit
+	}
+	s.Entries = append(make([]StreamEntry, 0, len(s.Entries)-n), s.Entries[n:]...)
+	return n
+}
+
+// Mutex must be locked before calling getStream. Returns nil stream if create is false and key is not set
+func (c *cache) getStream(key string, create bool) (stream *Stream, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+		if create {
+			stream = NewStream()
+			c.write(key, stream)
+		}
+	case *Stream:
+		stream = stored.(*Stream)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) XAdd(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("XADD")
+	mkstream := true
+	var trim streamTrim
+	i := 1
+	for ; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "NOMKSTREAM" {
+			mkstream = false
+		} else if option == "MAXLEN" || option == "MINID" {
+			trim, i, err = parseTrim(args, i)
+			if err != nil {
+				return
+			}
+			i -= 1
+		} else {
+			break
+		}
+	}
+	if n := len(args) - i - 1; n < 2 || n%2 != 0 {
+		err = formatErr
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	stream, err := c.getStream(args[0], false)
+	if err != nil {
+		return
+	}
+	if stream == nil && !mkstream {
+		response = nil
+		return
+	}
+	// the key is created only after the id is validated, so that a failed XADD changes nothing
+	created := stream == nil
+	if created {
+		stream = NewStream()
+	}
+	id, err := stream.nextID(args[i])
+	if err != nil {
+		return
+	}
+	if created {
+		c.write(args[0], stream)
+	}
+	stream.add(id, args[i+1:])
+	if trim.specified {
+		stream.trim(trim)
+	}
+	c.modified(args[0])
+	c.notify(streamClass, "xadd", args[0])
+	c.signal(args[0])
+	response = id.String()
+	return
+}
+func (c *cache) XLen(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	stream, err := c.getStream(args[0], false)
+	if err != nil {
+		return
+	}
+	n := 0
+	if stream != nil {
+		n = len(stream.Entries)
+	}
+	response = int64(n)
+	return
+}
+func (c *cache) xrange(args []string, rev bool) (response interface{}, err error) {
+	if len(args) != 3 && len(args) != 5 {
+		if rev {
+			err = syntaxError("XREVRANGE")
+		} else {
+			err = syntaxError("XRANGE")
+		}
+		return
+	}
+	startArg, endArg := args[1], args[2]
+	if rev {
+		startArg, endArg = endArg, startArg
+	}
+	start, err := parseRangeID(startArg, true)
+	if err != nil {
+		return
+	}
+	end, err := parseRangeID(endArg, false)
+	if err != nil {
+		return
+	}
+	count := 0
+	if len(args) == 5 {
+		if strings.ToUpper(args[3]) != "COUNT" {
+			err = errors.New("syntax error")
+			return
+		}
+		count, err = strconv.Atoi(args[4])
+		if err != nil {
+			return
+		}
+		if count <= 0 {
+			response = nil
+			return
+		}
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	stream, err := c.getStream(args[0], false)
+	if err != nil {
+		return
+	}
+	items := make([]interface{}, 0)
+	if stream != nil {
+		for _, entry := range stream.rangeEntries(start, end, count, rev) {
+			items = append(items, entry.format())
+		}
+	}
+	response = items
+	return
+}
+func (c *cache) XRange(args []string) (response interface{}, err error) {
+	return c.xrange(args, false)
+}
+func (c *cache) XRevRange(args []string) (response interface{}, err error) {
+	return c.xrange(args, true)
+}
+func (c *cache) XTrim(args []string) (response interface{}, err error) {
+	strategy := strings.ToUpper(args[1])
+	if strategy != "MAXLEN" && strategy != "MINID" {
+		err = errors.New("syntax error")
+		return
+	}
+	trim, next, err := parseTrim(args, 1)
+	if err != nil {
+		return
+	}
+	if next != len(args) {
+		err = errors.New("syntax error")
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	stream, err := c.getStream(args[0], false)
+	if err != nil {
+		return
+	}
+	removed := 0
+	if stream != nil {
+		removed = stream.trim(trim)
+	}
+	if removed != 0 {
+		c.modified(args[0])
+		c.notify(streamClass, "xtrim", args[0])
+	}
+	response = int64(removed)
+	return
+}
+
+type streamReadOptions struct {
+	count   int
+	block   bool
+	timeout time.Duration
+	noack   bool
+	keys    []string
+	ids     []string
+}
+
+// parseStreamRead parses options of XREAD and XREADGROUP starting at args[i] up to the STREAMS keyword
+func parseStreamRead(args []string, i int, group bool) (opts streamReadOptions, err error) {
+	for ; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		switch {
+		case option == "COUNT" && i+1 < len(args):
+			opts.count, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				return
+			}
+			i += 1
+		case option == "BLOCK" && i+1 < len(args):
+			var ms int
+			ms, err = strconv.Atoi(args[i+1])
+			if err != nil || ms < 0 {
+				err = errors.New("timeout is negative")
+				return
+			}
+			opts.block = true
+			opts.timeout = time.Duration(ms) * time.Millisecond
+			i += 1
+		case option == "NOACK" && group:
+			opts.noack = true
+		case option == "STREAMS":
+			rest := args[i+1:]
+			if len(rest) == 0 || len(rest)%2 != 0 {
+				err = errors.New("Unbalanced XREAD list of streams: for each stream key an ID or '$' must be specified.")
+				return
+			}
+			opts.keys = rest[:len(rest)/2]
+			opts.ids = rest[len(rest)/2:]
+			return
+		default:
+			err = errors.New("syntax error")
+			return
+		}
+	}
+	err = errors.New("syntax error")
+	return
+}
+func (c *cache) XRead(args []string) (response interface{}, err error) {
+	opts, err := parseStreamRead(args, 0, false)
+	if err != nil {
+		return
+	}
+	// commands of a transaction never block
+	if c.transaction {
+		opts.block = false
+	}
+	// ids of the entries already seen, "$" is resolved once so blocking waits for new entries only
+	after := make([]StreamID, len(opts.keys))
+	c.m.RLock()
+	for i := range opts.keys {
+		if opts.ids[i] == "$" {
+			var stream *Stream
+			stream, err = c.getStream(opts.keys[i], false)
+			if err != nil {
+				c.m.RUnlock()
+				return
+			}
+			if stream != nil {
+				after[i] = stream.LastID
+			}
+			continue
+		}
+		after[i], err = parseStreamID(opts.ids[i], 0)
+		if err != nil {
+			c.m.RUnlock()
+			return
+		}
+	}
+	c.m.RUnlock()
+
+	var deadline <-chan time.Time
+	if opts.block && opts.timeout > 0 {
+		timer := time.NewTimer(opts.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		c.m.RLock()
+		items := make([]interface{}, 0)
+		for i := range opts.keys {
+			var stream *Stream
+			stream, err = c.getStream(opts.keys[i], false)
+			if err != nil {
+				c.m.RUnlock()
+				return
+			}
+			if stream == nil {
+				continue
+			}
+			start, ok := after[i].next()
+			if !ok {
+				continue
+			}
+			entries := stream.rangeEntries(start, maxStreamID, opts.count, false)
+			if len(entries) == 0 {
+				continue
+			}
+			formatted := make([]interface{}, len(entries))
+			for j := range entries {
+				formatted[j] = entries[j].format()
+			}
+			items = append(items, []interface{}{opts.keys[i], formatted})
+		}
+		if len(items) != 0 || !opts.block {
+			c.m.RUnlock()
+			if len(items) == 0 {
+				response = nil
+			} else {
+				response = items
+			}
+			return
+		}
+		w := c.wait(opts.keys)
+		c.m.RUnlock()
+		select {
+		case <-w.ch:
+			// signal removes the waiter only from the key which was written to
+			c.cancelWait(opts.keys, w)
+		case <-deadline:
+			c.cancelWait(opts.keys, w)
+			response = nil
+			return
+		}
+	}
+}
+
+// waiter is notified when any of the keys it waits for is written to
+type waiter struct {
+	ch   chan struct{}
+	once sync.Once
+}
+
+func (w *waiter) notify() {
+	w.once.Do(func() {
+		close(w.ch)
+	})
+}
+
+type waiters struct {
+	m     *sync.Mutex
+	byKey map[string][]*waiter
+}
+
+func newWaiters() waiters {
+	return waiters{&sync.Mutex{}, make(map[string][]*waiter)}
+}
+
+// c.m must be at least rlocked when calling wait so that no write can be missed
+func (c *cache) wait(keys []string) *waiter {
+	w := &waiter{ch: make(chan struct{})}
+	c.blocked.m.Lock()
+	for _, key := range keys {
+		c.blocked.byKey[key] = append(c.blocked.byKey[key], w)
+	}
+	c.blocked.m.Unlock()
+	return w
+}
+func (c *cache) cancelWait(keys []string, w *waiter) {
+	c.blocked.m.Lock()
+	defer c.blocked.m.Unlock()
+	for _, key := range keys {
+		list := c.blocked.byKey[key]
+		for i := range list {
+			if list[i] == w {
+				list = append(list[:i], list[i+1:]...)
+				break
+			}
+		}
+		if len(list) == 0 {
+			delete(c.blocked.byKey, key)
+		} else {
+			c.blocked.byKey[key] = list
+		}
+	}
+}
+
+// signal wakes up all clients blocked on the key. Mutex must be locked before calling signal
+func (c *cache) signal(key string) {
+	c.blocked.m.Lock()
+	defer c.blocked.m.Unlock()
+	for _, w := range c.blocked.byKey[key] {
+		w.notify()
+	}
+	delete(c.blocked.byKey, key)
+}
+
+// signalAll wakes up all blocked clients. Mutex must be locked before calling signalAll
+func (c *cache) signalAll() {
+	c.blocked.m.Lock()
+	defer c.blocked.m.Unlock()
+	for key, list := range c.blocked.byKey {
+		for _, w := range list {
+			w.notify()
+		}
+		delete(c.blocked.byKey, key)
+	}
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+func TestXAdd(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.XAdd([]string{"stream", "*", "field"}))
+	switch err.(type) {
+	case ArgsError:
+		break
+	default:
+		t.Error(err)
+	}
+
+	resp, err := text(c.XAdd([]string{"stream", "1-1", "field", "value"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1-1" {
+		t.Errorf("expected 1-1, got %v", resp)
+	}
+	_, err = text(c.XAdd([]string{"stream", "1-1", "field", "value"}))
+	if err == nil || err != errStreamIDTooSmall {
+		t.Error(err)
+	}
+	resp, err = text(c.XAdd([]string{"stream", "1-*", "field", "value"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1-2" {
+		t.Errorf("expected 1-2, got %v", resp)
+	}
+	_, err = text(c.XAdd([]string{"stream", "*", "field", "value"}))
+	if err != nil {
+		t.Error(err)
+	}
+
+	resp, err = text(c.XAdd([]string{"missing", "NOMKSTREAM", "*", "field", "value"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+	_, err = text(c.XAdd([]string{"new", "0-0", "field", "value"}))
+	if err == nil {
+		t.Error("expected error on 0-0 id")
+	}
+	if c.read("new") != nil {
+		t.Error("stream must not be created on error")
+	}
+
+	resp, err = text(c.XLen([]string{"stream"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 3" {
+		t.Errorf("expected (integer) 3, got %v", resp)
+	}
+
+	c.Set([]string{"string", "value"})
+	_, err = text(c.XAdd([]string{"string", "*", "field", "value"}))
+	if err == nil || err.Error() != "Requested field is of type string" {
+		t.Error(err)
+	}
+}
+
+func TestXRange(t *testing.T) {
+	c := (NewCache()).(*cache)
+	for _, id := range []string{"1-0", "2-0", "3-0"} {
+		c.XAdd([]string{"stream", id, "id", id})
+	}
+
+	resp, err := text(c.XRange([]string{"stream", "-", "+", "COUNT", "2"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) \"1-0\"\n   2) 1) \"id\"\n      2) \"1-0\"\n2) 1) \"2-0\"\n   2) 1) \"id\"\n      2) \"2-0\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XRange([]string{"stream", "(1-0", "2"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) \"2-0\"\n   2) 1) \"id\"\n      2) \"2-0\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XRevRange([]string{"stream", "+", "-", "COUNT", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) \"3-0\"\n   2) 1) \"id\"\n      2) \"3-0\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XRange([]string{"missing", "-", "+"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(empty array)" {
+		t.Errorf("expected (empty array), got %v", resp)
+	}
+}
+
+func TestXTrim(t *testing.T) {
+	c := (NewCache()).(*cache)
+	for _, id := range []string{"1-0", "2-0", "3-0", "4-0", "5-0"} {
+		c.XAdd([]string{"stream", id, "id", id})
+	}
+
+	resp, err := text(c.XTrim([]string{"stream", "MAXLEN", "~", "4"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.XTrim([]string{"stream", "MINID", "4"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 2" {
+		t.Errorf("expected (integer) 2, got %v", resp)
+	}
+
+	resp, err = text(c.XAdd([]string{"stream", "MAXLEN", "1", "6-0", "id", "6-0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err = text(c.XLen([]string{"stream"}))
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+}
+
+func TestXRead(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.XAdd([]string{"stream", "1-0", "field", "value"})
+
+	resp, err := text(c.XRead([]string{"STREAMS", "stream", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) \"stream\"\n   2) 1) 1) \"1-0\"\n         2) 1) \"field\"\n            2) \"value\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XRead([]string{"BLOCK", "50", "STREAMS", "stream", "$"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+
+	done := make(chan string)
+	go func() {
+		resp, err := text(c.XRead([]string{"BLOCK", "0", "STREAMS", "stream", "other", "$", "$"}))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.XAdd([]string{"stream", "2-0", "field", "value"})
+	select {
+	case resp = <-done:
+		expected = "1) 1) \"stream\"\n   2) 1) 1) \"2-0\"\n         2) 1) \"field\"\n            2) \"value\""
+		if resp != expected {
+			t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+		}
+	case <-time.After(time.Second):
+		t.Error("blocked XREAD was not woken up")
+	}
+	c.blocked.m.Lock()
+	if len(c.blocked.byKey) != 0 {
+		t.Errorf("expected no blocked clients after wake up, got %v", c.blocked.byKey)
+	}
+	c.blocked.m.Unlock()
+}
+
+func TestStreamSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.XAdd([]string{"stream", "1-0", "field", "value"})
+	c.XAdd([]string{"stream", "2-0", "field", "value"})
+	c.XTrim([]string{"stream", "MAXLEN", "1"})
+
+	_, err := text(c.Save([]string{"streamsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"streamsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.XRange([]string{"stream", "-", "+"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) \"2-0\"\n   2) 1) \"field\"\n      2) \"value\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	_, err = text(c.XAdd([]string{"stream", "2-0", "field", "value"}))
+	if err != errStreamIDTooSmall {
+		t.Errorf("expected %v, got %v", errStreamIDTooSmall, err)
+	}
+
+	err = os.Remove("./saves/streamsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,644 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PendingEntry is a message delivered to a consumer but not acknowledged yet
+type PendingEntry struct {
+	ID            StreamID
+	Consumer      string
+	DeliveryTime  time.Time
+	DeliveryCount int
+}
+
+type Consumer struct {
+	Name     string
+	SeenTime time.Time
+}
+
+// ConsumerGroup tracks the last entry delivered to the group and entries pending acknowledgement
+type ConsumerGroup struct {
+	LastDelivered StreamID
+	// Pending is sorted by entry id
+	Pending   []*PendingEntry
+	Consumers map[string]*Consumer
+}
+
+func NewConsumerGroup(lastDelivered StreamID) *ConsumerGroup {
+	return &ConsumerGroup{lastDelivered, make([]*PendingEntry, 0), make(map[string]*Consumer)}
+}
+
+func (g *ConsumerGroup) searchPending(id StreamID) int {
+	return sort.Search(len(g.Pending), func(i int) bool {
+		return !g.Pending[i].ID.Less(id)
+	})
+}
+func (g *ConsumerGroup) findPending(id StreamID) *PendingEntry {
+	i := g.searchPending(id)
+	if i < len(g.Pending) && g.Pending[i].ID == id {
+		return g.Pending[i]
+	}
+	return nil
+}
+func (g *ConsumerGroup) addPending(entry *PendingEntry) {
+	i := g.searchPending(entry.ID)
+	if i < len(g.Pending) && g.Pending[i].ID == entry.ID {
+		g.Pending[i] = entry
+		return
+	}
+	g.Pending = append(g.Pending, nil)
+	copy(g.Pending[i+1:], g.Pending[i:])
+	g.Pending[i] = entry
+}
+func (g *ConsumerGroup) removePending(id StreamID) bool {
+	i := g.searchPending(id)
+	if i < len(g.Pending) && g.Pending[i].ID == id {
+		g.Pending = append(g.Pending[:i], g.Pending[i+1:]...)
+		return true
+	}
+	return false
+}
+
+// consumer returns the consumer with the given name, creating it if needed
+func (g *ConsumerGroup) consumer(name string) *Consumer {
+	consumer, ok := g.Consumers[name]
+	if !ok {
+		consumer = &Consumer{name, time.Now()}
+		g.Consumers[name] = consumer
+	}
+	return consumer
+}
+
+// entry returns the stream entry with the given id, ok is false if it was deleted
+func (s *Stream) entry(id StreamID) (entry StreamEntry, ok bool) {
+	i := s.search(id)
+	if i < len(s.Entries) && s.Entries[i].ID == id {
+		return s.Entries[i], true
+	}
+	return
+}
+
+func noGroupError(key, group string) error {
+	return errors.New(fmt.Sprintf("NOGROUP No such key '%v' or consumer group '%v'", key, group))
+}
+
+// Mutex must be locked before calling getGroup
+func (c *cache) getGroup(key, group string) (stream *Stream, g *ConsumerGroup, err error) {
+	stream, err = c.getStream(key, false)
+	if err != nil {
+		return
+	}
+	if stream != nil {
+		g = stream.Groups[group]
+	}
+	if g == nil {
+		err = noGroupError(key, group)
+	}
+	return
+}
+
+// parseGroupID parses the id given to XGROUP CREATE and XGROUP SETID, "$" means the last entry
+func (s *Stream) parseGroupID(arg string) (StreamID, error) {
+	if arg == "$" {
+		return s.LastID, nil
+	}
+	return parseStreamID(arg, 0)
+}
+func (c *cache) XGroup(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("XGROUP")
+	subcommand := strings.ToUpper(args[0])
+	key, group := args[1], args[2]
+	c.m.Lock()
+	defer c.m.Unlock()
+	switch subcommand {
+	case "CREATE":
+		if len(args) != 4 && !(len(args) == 5 && strings.ToUpper(args[4]) == "MKSTREAM") {
+			err = formatErr
+			return
+		}
+		var stream *Stream
+		stream, err = c.getStream(key, len(args) == 5)
+		if err != nil {
+			return
+		}
+		if stream == nil {
+			err = errors.New("The XGROUP subcommand requires the key to exist. Note that for CREATE you may want to use the MKSTREAM option to create an empty stream automatically.")
+			return
+		}
+		if _, ok := stream.Groups[group]; ok {
+			err = errors.New("BUSYGROUP Consumer Group name already exists")
+			return
+		}
+		var id StreamID
+		id, err = stream.parseGroupID(args[3])
+		if err != nil {
+			return
+		}
+		stream.Groups[group] = NewConsumerGroup(id)
+		response = formatted("OK")
+	case "SETID":
+		if len(args) != 4 {
+			err = formatErr
+			return
+		}
+		var stream *Stream
+		var g *ConsumerGroup
+		stream, g, err = c.getGroup(key, group)
+		if err != nil {
+			return
+		}
+		g.LastDelivered, err = stream.parseGroupID(args[3])
+		if err != nil {
+			return
+		}
+		response = formatted("OK")
+	case "DESTROY":
+		if len(args) != 3 {
+			err = formatErr
+			return
+		}
+		var stream *Stream
+		stream, err = c.getStream(key, false)
+		if err != nil {
+			return
+		}
+		destroyed := 0
+		if stream != nil {
+			if _, ok := stream.Groups[group]; ok {
+				delete(stream.Groups, group)
+				destroyed = 1
+			}
+		}
+		response = int64(destroyed)
+	case "CREATECONSUMER":
+		if len(args) != 4 {
+			err = formatErr
+			return
+		}
+		var g *ConsumerGroup
+		_, g, err = c.getGroup(key, group)
+		if err != nil {
+			return
+		}
+		created := 0
+		if _, ok := g.Consumers[args[3]]; !ok {
+			g.consumer(args[3])
+			created = 1
+		}
+		response = int64(created)
+	case "DELCONSUMER":
+		if len(args) != 4 {
+			err = formatErr
+			return
+		}
+		var g *ConsumerGroup
+		_, g, err = c.getGroup(key, group)
+		if err != nil {
+			return
+		}
+		pending := make([]*PendingEntry, 0, len(g.Pending))
+		for _, entry := range g.Pending {
+			if entry.Consumer != args[3] {
+				pending = append(pending, entry)
+			}
+		}
+		response = int64(len(g.Pending) - len(pending))
+		g.Pending = pending
+		delete(g.Consumers, args[3])
+	default:
+		err = formatErr
+	}
+	if err == nil {
+		c.modified(key)
+		c.notify(streamClass, "xgroup-"+strings.ToLower(subcommand), key)
+	}
+	return
+}
+
+// readGroup delivers entries of one stream to the consumer. Mutex must be locked before calling readGroup
+func (s *Stream) readGroup(g *ConsumerGroup, consumer *Consumer, id string, opts streamReadOptions) (entries []interface{}, err error) {
+	now := time.Now()
+	consumer.SeenTime = now
+	entries = make([]interface{}, 0)
+	if id == ">" {
+		start, ok := g.LastDelivered.next()
+		if !ok {
+			return
+		}
+		for _, entry := range s.rangeEntries(start, maxStreamID, opts.count, false) {
+			g.LastDelivered = entry.ID
+			if !opts.noack {
+				g.addPending(&PendingEntry{entry.ID, consumer.Name, now, 1})
+			}
+			entries = append(entries, entry.format())
+		}
+		return
+	}
+	// history of the consumer: entries already delivered to it but not acknowledged
+	after, err := parseStreamID(id, 0)
+	if err != nil {
+		return
+	}
+	for i := g.searchPending(after); i < len(g.Pending); i += 1 {
+		if opts.count > 0 && len(entries) >= opts.count {
+			break
+		}
+		pending := g.Pending[i]
+		if pending.Consumer != consumer.Name || pending.ID == after {
+			continue
+		}
+		entry, ok := s.entry(pending.ID)
+		if !ok {
+			entries = append(entries, []interface{}{pending.ID.String(), nil})
+			continue
+		}
+		pending.DeliveryTime = now
+		pending.DeliveryCount += 1
+		entries = append(entries, entry.format())
+	}
+	return
+}
+func (c *cache) XReadGroup(args []string) (response interface{}, err error) {
+	if len(args) < 6 || strings.ToUpper(args[0]) != "GROUP" {
+		err = syntaxError("XREADGROUP")
+		return
+	}
+	group, consumerName := args[1], args[2]
+	opts, err := parseStreamRead(args, 3, true)
+	if err != nil {
+		return
+	}
+	// only new entries can be waited for, reading the history never blocks. Commands of a transaction never block
+	for _, id := range opts.ids {
+		if id != ">" || c.transaction {
+			opts.block = false
+		}
+	}
+
+	var deadline <-chan time.Time
+	if opts.block && opts.timeout > 0 {
+		timer := time.NewTimer(opts.timeout)
+		defer timer.Stop()
+		deadline = timer.C
+	}
+	for {
+		c.m.Lock()
+		items := make([]interface{}, 0)
+		for i := range opts.keys {
+			var stream *Stream
+			var g *ConsumerGroup
+			stream, g, err = c.getGroup(opts.keys[i], group)
+			if err != nil {
+				c.m.Unlock()
+				return
+			}
+			var entries []interface{}
+			entries, err = stream.readGroup(g, g.consumer(consumerName), opts.ids[i], opts)
+			if err != nil {
+				c.m.Unlock()
+				return
+			}
+			if len(entries) != 0 {
+				c.modified(opts.keys[i])
+			}
+			if len(entries) != 0 || opts.ids[i] != ">" {
+				items = append(items, []interface{}{opts.keys[i], entries})
+			}
+		}
+		if len(items) != 0 || !opts.block {
+			c.m.Unlock()
+			if len(items) == 0 {
+				response = nil
+			} else {
+				response = items
+			}
+			return
+		}
+		w := c.wait(opts.keys)
+		c.m.Unlock()
+		select {
+		case <-w.ch:
+			// signal removes the waiter only from the key which was written to
+			c.cancelWait(opts.keys, w)
+		case <-deadline:
+			c.cancelWait(opts.keys, w)
+			response = nil
+			return
+		}
+	}
+}
+func (c *cache) XAck(args []string) (response interface{}, err error) {
+	ids := make([]StreamID, len(args)-2)
+	for i := range ids {
+		ids[i], err = parseStreamID(args[i+2], 0)
+		if err != nil {
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	stream, err := c.getStream(args[0], false)
+	if err != nil {
+		return
+	}
+	acknowledged := 0
+	if stream != nil && stream.Groups[args[1]] != nil {
+		g := stream.Groups[args[1]]
+		for _, id := range ids {
+			if g.removePending(id) {
+				acknowledged += 1
+			}
+		}
+	}
+	if acknowledged != 0 {
+		c.modified(args[0])
+	}
+	response = int64(acknowledged)
+	return
+}
+func (c *cache) XPending(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("XPENDING")
+	if len(args) != 2 && len(args) != 5 && len(args) != 6 && len(args) != 7 && len(args) != 8 {
+		err = formatErr
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	_, g, err := c.getGroup(args[0], args[1])
+	if err != nil {
+		return
+	}
+	if len(args) == 2 {
+		if len(g.Pending) == 0 {
+			response = []interface{}{0, nil, nil, nil}
+			return
+		}
+		counts := make(map[string]int)
+		for _, entry := range g.Pending {
+			counts[entry.Consumer] += 1
+		}
+		names := make([]string, 0, len(counts))
+		for name := range counts {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		consumers := make([]interface{}, len(names))
+		for i, name := range names {
+			consumers[i] = []interface{}{name, strconv.Itoa(counts[name])}
+		}
+		response = []interface{}{
+			len(g.Pending),
+			g.Pending[0].ID.String(),
+			g.Pending[len(g.Pending)-1].ID.String(),
+			consumers,
+		}
+		return
+	}
+
+	rest := args[2:]
+	var minIdle time.Duration
+	if strings.ToUpper(rest[0]) == "IDLE" {
+		var ms int
+		ms, err = strconv.Atoi(rest[1])
+		if err != nil {
+			return
+		}
+		minIdle = time.Duration(ms) * time.Millisecond
+		rest = rest[2:]
+	}
+	if len(rest) != 3 && len(rest) != 4 {
+		err = formatErr
+		return
+	}
+	start, err := parseRangeID(rest[0], true)
+	if err != nil {
+		return
+	}
+	end, err := parseRangeID(rest[1], false)
+	if err != nil {
+		return
+	}
+	count, err := strconv.Atoi(rest[2])
+	if err != nil {
+		return
+	}
+	consumer := ""
+	if len(rest) == 4 {
+		consumer = rest[3]
+	}
+	now := time.Now()
+	items := make([]interface{}, 0)
+	for i := g.searchPending(start); i < len(g.Pending) && len(items) < count; i += 1 {
+		entry := g.Pending[i]
+		if end.Less(entry.ID) {
+			break
+		}
+		idle := now.Sub(entry.DeliveryTime)
+		if (consumer != "" && entry.Consumer != consumer) || idle < minIdle {
+			continue
+		}
+		items = append(items, []interface{}{
+			entry.ID.String(),
+			entry.Consumer,
+			int64(idle / time.Millisecond),
+			entry.DeliveryCount,
+		})
+	}
+	response = items
+	return
+}
+
+// claim transfers a pending entry to the consumer. Returns the claimed entry,
+// deleted is true if the entry no longer exists in the stream and was removed from the group
+func (s *Stream) claim(g *ConsumerGroup, pending *PendingEntry, consumer *Consumer, justID bool) (entry StreamEntry, deleted bool) {
+	var ok bool
+	entry, ok = s.entry(pending.ID)
+	if !ok {
+		g.removePending(pending.ID)
+		return entry, true
+	}
+	pending.Consumer = consumer.Name
+	pending.DeliveryTime = time.Now()
+	if !justID {
+		pending.DeliveryCount += 1
+	}
+	consumer.SeenTime = pending.DeliveryTime
+	return entry, false
+}
+func (c *cache) XClaim(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("XCLAIM")
+	minIdleMs, err := strconv.Atoi(args[3])
+	if err != nil {
+		return
+	}
+	minIdle := time.Duration(minIdleMs) * time.Millisecond
+	ids := make([]StreamID, 0)
+	i := 4
+	for ; i < len(args); i += 1 {
+		var id StreamID
+		id, err = parseStreamID(args[i], 0)
+		if err != nil {
+			break
+		}
+		ids = append(ids, id)
+	}
+	if len(ids) == 0 {
+		err = formatErr
+		return
+	}
+	err = nil
+	var deliveryTime *time.Time
+	retryCount := -1
+	force, justID := false, false
+	for ; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		switch {
+		case (option == "IDLE" || option == "TIME") && i+1 < len(args):
+			var ms int64
+			ms, err = strconv.ParseInt(args[i+1], 10, 64)
+			if err != nil {
+				return
+			}
+			var t time.Time
+			if option == "IDLE" {
+				t = time.Now().Add(-time.Duration(ms) * time.Millisecond)
+			} else {
+				t = time.Unix(0, ms*int64(time.Millisecond))
+			}
+			deliveryTime = &t
+			i += 1
+		case option == "RETRYCOUNT" && i+1 < len(args):
+			retryCount, err = strconv.Atoi(args[i+1])
+			if err != nil {
+				return
+			}
+			i += 1
+		case option == "FORCE":
+			force = true
+		case option == "JUSTID":
+			justID = true
+		default:
+			err = errors.New("syntax error")
+			return
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	stream, g, err := c.getGroup(args[0], args[1])
+	if err != nil {
+		return
+	}
+	consumer := g.consumer(args[2])
+	now := time.Now()
+	items := make([]interface{}, 0)
+	for _, id := range ids {
+		pending := g.findPending(id)
+		if pending == nil {
+			if _, ok := stream.entry(id); !force || !ok {
+				continue
+			}
+			pending = &PendingEntry{id, consumer.Name, now, 0}
+			g.addPending(pending)
+		} else if now.Sub(pending.DeliveryTime) < minIdle {
+			continue
+		}
+		entry, deleted := stream.claim(g, pending, consumer, justID)
+		if deleted {
+			continue
+		}
+		if deliveryTime != nil {
+			pending.DeliveryTime = *deliveryTime
+		}
+		if retryCount >= 0 {
+			pending.DeliveryCount = retryCount
+		}
+		if justID {
+			items = append(items, entry.ID.String())
+		} else {
+			items = append(items, entry.format())
+		}
+	}
+	if len(items) != 0 {
+		c.modified(args[0])
+	}
+	response = items
+	return
+}
+func (c *cache) XAutoClaim(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("XAUTOCLAIM")
+	if len(args) < 5 || len(args) > 8 {
+		err = formatErr
+		return
+	}
+	minIdleMs, err := strconv.Atoi(args[3])
+	if err != nil {
+		return
+	}
+	minIdle := time.Duration(minIdleMs) * time.Millisecond
+	start, err := parseRangeID(args[4], true)
+	if err != nil {
+		return
+	}
+	count := 100
+	justID := false
+	for i := 5; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "COUNT" && i+1 < len(args) {
+			count, err = strconv.Atoi(args[i+1])
+			if err != nil || count <= 0 {
+				err = errors.New("COUNT must be > 0")
+				return
+			}
+			i += 1
+		} else if option == "JUSTID" {
+			justID = true
+		} else {
+			err = formatErr
+			return
+		}
+	}
+
+	c.m.Lock()
+	defer c.m.Unlock()
+	stream, g, err := c.getGroup(args[0], args[1])
+	if err != nil {
+		return
+	}
+	consumer := g.consumer(args[2])
+	now := time.Now()
+	claimed := make([]interface{}, 0)
+	deletedIDs := make([]interface{}, 0)
+	next := StreamID{}
+	candidates := make([]*PendingEntry, 0)
+	for i := g.searchPending(start); i < len(g.Pending); i += 1 {
+		if len(candidates) == count {
+			next = g.Pending[i].ID
+			break
+		}
+		if now.Sub(g.Pending[i].DeliveryTime) >= minIdle {
+			candidates = append(candidates, g.Pending[i])
+		}
+	}
+	if len(candidates) != 0 {
+		c.modified(args[0])
+	}
+	for _, pending := range candidates {
+		entry, deleted := stream.claim(g, pending, consumer, justID)
+		if deleted {
+			deletedIDs = append(deletedIDs, pending.ID.String())
+		} else if justID {
+			claimed = append(claimed, entry.ID.String())
+		} else {
+			claimed = append(claimed, entry.format())
+		}
+	}
+	response = []interface{}{next.String(), claimed, deletedIDs}
+	return
+}
@@ -0,0 +1,225 @@
+package cache
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestXGroup(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.XGroup([]string{"CREATE", "stream", "group", "$"}))
+	if err == nil {
+		t.Error("expected error on missing stream")
+	}
+	resp, err := text(c.XGroup([]string{"CREATE", "stream", "group", "$", "MKSTREAM"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	_, err = text(c.XGroup([]string{"CREATE", "stream", "group", "$"}))
+	if err == nil || !strings.HasPrefix(err.Error(), "BUSYGROUP") {
+		t.Error(err)
+	}
+	resp, err = text(c.XGroup([]string{"CREATECONSUMER", "stream", "group", "alice"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.XGroup([]string{"DESTROY", "stream", "group"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	_, err = text(c.XReadGroup([]string{"GROUP", "group", "alice", "STREAMS", "stream", ">"}))
+	if err == nil || !strings.HasPrefix(err.Error(), "NOGROUP") {
+		t.Error(err)
+	}
+}
+
+func TestXReadGroup(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.XAdd([]string{"stream", "1-0", "field", "value1"})
+	c.XAdd([]string{"stream", "2-0", "field", "value2"})
+	c.XGroup([]string{"CREATE", "stream", "group", "0"})
+
+	resp, err := text(c.XReadGroup([]string{"GROUP", "group", "alice", "COUNT", "1", "STREAMS", "stream", ">"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) \"stream\"\n   2) 1) 1) \"1-0\"\n         2) 1) \"field\"\n            2) \"value1\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.XReadGroup([]string{"GROUP", "group", "bob", "STREAMS", "stream", ">"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) \"stream\"\n   2) 1) 1) \"2-0\"\n         2) 1) \"field\"\n            2) \"value2\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.XReadGroup([]string{"GROUP", "group", "bob", "BLOCK", "50", "STREAMS", "stream", ">"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+
+	c.XGroup([]string{"CREATE", "other", "group", "$", "MKSTREAM"})
+	done := make(chan string)
+	go func() {
+		resp, err := text(c.XReadGroup([]string{"GROUP", "group", "carol", "BLOCK", "0", "STREAMS", "stream", "other", ">", ">"}))
+		if err != nil {
+			t.Error(err)
+		}
+		done <- resp
+	}()
+	time.Sleep(50 * time.Millisecond)
+	c.XAdd([]string{"other", "1-0", "field", "value3"})
+	select {
+	case resp = <-done:
+		expected = "1) 1) \"other\"\n   2) 1) 1) \"1-0\"\n         2) 1) \"field\"\n            2) \"value3\""
+		if resp != expected {
+			t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+		}
+	case <-time.After(time.Second):
+		t.Error("blocked XREADGROUP was not woken up")
+	}
+	c.blocked.m.Lock()
+	if len(c.blocked.byKey) != 0 {
+		t.Errorf("expected no blocked clients after wake up, got %v", c.blocked.byKey)
+	}
+	c.blocked.m.Unlock()
+
+	// history of alice contains the only unacknowledged entry
+	resp, err = text(c.XReadGroup([]string{"GROUP", "group", "alice", "STREAMS", "stream", "0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) \"stream\"\n   2) 1) 1) \"1-0\"\n         2) 1) \"field\"\n            2) \"value1\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XPending([]string{"stream", "group"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) (integer) 2\n2) \"1-0\"\n3) \"2-0\"\n4) 1) 1) \"alice\"\n      2) \"1\"\n   2) 1) \"bob\"\n      2) \"1\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	resp, err = text(c.XAck([]string{"stream", "group", "1-0", "1-0", "5-0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.XPending([]string{"stream", "group", "-", "+", "10", "alice"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(empty array)" {
+		t.Errorf("expected (empty array), got %v", resp)
+	}
+}
+
+func TestXClaim(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.XAdd([]string{"stream", "1-0", "field", "value1"})
+	c.XAdd([]string{"stream", "2-0", "field", "value2"})
+	c.XAdd([]string{"stream", "3-0", "field", "value3"})
+	c.XGroup([]string{"CREATE", "stream", "group", "0"})
+	c.XReadGroup([]string{"GROUP", "group", "dead", "STREAMS", "stream", ">"})
+
+	resp, err := text(c.XClaim([]string{"stream", "group", "alice", "60000", "1-0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(empty array)" {
+		t.Errorf("expected (empty array), got %v", resp)
+	}
+	time.Sleep(20 * time.Millisecond)
+	resp, err = text(c.XClaim([]string{"stream", "group", "alice", "10", "1-0", "JUSTID"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"1-0\"" {
+		t.Errorf("expected 1) \"1-0\", got %v", resp)
+	}
+
+	c.XTrim([]string{"stream", "MINID", "3"})
+	time.Sleep(20 * time.Millisecond)
+	resp, err = text(c.XAutoClaim([]string{"stream", "group", "bob", "10", "0", "COUNT", "1", "JUSTID"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) \"2-0\"\n2) (empty array)\n3) 1) \"1-0\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.XAutoClaim([]string{"stream", "group", "bob", "10", "2-0"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) \"0-0\"\n2) 1) 1) \"3-0\"\n      2) 1) \"field\"\n         2) \"value3\"\n3) 1) \"2-0\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.XPending([]string{"stream", "group", "-", "+", "10"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if !strings.HasPrefix(resp, "1) 1) \"3-0\"\n   2) \"bob\"") {
+		t.Errorf("expected 3-0 to be pending for bob, got %v", resp)
+	}
+}
+
+func TestConsumerGroupSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.XAdd([]string{"stream", "1-0", "field", "value"})
+	c.XGroup([]string{"CREATE", "stream", "group", "0"})
+	c.XReadGroup([]string{"GROUP", "group", "alice", "STREAMS", "stream", ">"})
+
+	_, err := text(c.Save([]string{"groupsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"groupsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.XPending([]string{"stream", "group"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) (integer) 1\n2) \"1-0\"\n3) \"1-0\"\n4) 1) 1) \"alice\"\n      2) \"1\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.XReadGroup([]string{"GROUP", "group", "alice", "STREAMS", "stream", ">"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(nil)" {
+		t.Errorf("expected (nil), got %v", resp)
+	}
+
+	err = os.Remove("./saves/groupsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
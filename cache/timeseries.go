@@ -0,0 +1,776 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var (
+	errTSNotFound   = errors.New("TSDB: the key does not exist")
+	errRenameToRule = errors.New("TSDB: the new name is the source or a destination of a compaction rule of the key")
+)
+
+type TSSample struct {
+	Timestamp int64
+	Value     float64
+}
+
+func (s TSSample) format() []interface{} {
+	return []interface{}{s.Timestamp, strconv.FormatFloat(s.Value, 'f', -1, 64)}
+}
+
+// tsAggregator accumulates samples of one time bucket
+type tsAggregator struct {
+	Count int64
+	Sum   float64
+	Min   float64
+	Max   float64
+}
+
+func (a *tsAggregator) add(value float64) {
+	if a.Count == 0 || value < a.Min {
+		a.Min = value
+	}
+	if a.Count == 0 || value > a.Max {
+		a.Max = value
+	}
+	a.Count += 1
+	a.Sum += value
+}
+func (a *tsAggregator) result(aggregation string) float64 {
+	switch aggregation {
+	case "avg":
+		return a.Sum / float64(a.Count)
+	case "min":
+		return a.Min
+	case "max":
+		return a.Max
+	case "sum":
+		return a.Sum
+	default:
+		return float64(a.Count)
+	}
+}
+func parseAggregation(arg string) (string, error) {
+	aggregation := strings.ToLower(arg)
+	switch aggregation {
+	case "avg", "min", "max", "sum", "count":
+		return aggregation, nil
+	}
+	return "", errors.New("TSDB: Unknown aggregation type")
+}
+
+// bucketStart returns the start of the time bucket containing the timestamp
+func bucketStart(timestamp, bucket int64) int64 {
+	return timestamp - timestamp%bucket
+}
+
+// CompactionRule writes an aggregate of every finished time bucket of the series into Dest.
+// Samples older than the current bucket are not compacted
+type CompactionRule struct {
+	Dest        string
+	Aggregation string
+	Bucket      int64
+	Start       int64
+	Current     tsAggregator
+}
+
+// TimeSeries is a sorted list of samples. Samples older than Retention milliseconds counting from
+// the last sample are removed by the cleaner, 0 means samples are kept forever
+type TimeSeries struct {
+	Samples         []TSSample
+	Retention       int64
+	DuplicatePolicy string
+	Labels          map[string]string
+	Rules           []*CompactionRule
+	SourceKey       string
+}
+
+func NewTimeSeries(retention int64, duplicatePolicy string, labels map[string]string) *TimeSeries {
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	return &TimeSeries{Retention: retention, DuplicatePolicy: duplicatePolicy, Labels: labels}
+}
+
+func (s *TimeSeries) lastTimestamp() int64 {
+	if len(s.Samples) == 0 {
+		return 0
+	}
+	return s.Samples[len(s.Samples)-1].Timestamp
+}
+
+// add inserts the sample keeping samples sorted, an existing sample with the same timestamp
+// is resolved with the duplicate policy
+func (s *TimeSeries) add(sample TSSample, duplicatePolicy string) error {
+	if s.Retention != 0 && len(s.Samples) != 0 && sample.Timestamp < s.lastTimestamp()-s.Retention {
+		return errors.New("TSDB: Timestamp is older than retention")
+	}
+	i := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Timestamp >= sample.Timestamp
+	})
+	if i < len(s.Samples) && s.Samples[i].Timestamp == sample.Timestamp {
+		old := &s.Samples[i]
+		switch duplicatePolicy {
+		case "LAST":
+			old.Value = sample.Value
+		case "FIRST":
+		case "MIN":
+			old.Value = math.Min(old.Value, sample.Value)
+		case "MAX":
+			old.Value = math.Max(old.Value, sample.Value)
+		case "SUM":
+			old.Value += sample.Value
+		default:
+			return errors.New("TSDB: Error at upsert, update is not supported when DUPLICATE_POLICY is set to BLOCK mode")
+		}
+		return nil
+	}
+	s.Samples = append(s.Samples, TSSample{})
+	copy(s.Samples[i+1:], s.Samples[i:])
+	s.Samples[i] = sample
+	return nil
+}
+
+// trim removes samples that are out of the retention period
+func (s *TimeSeries) trim() {
+	if s.Retention == 0 || len(s.Samples) == 0 {
+		return
+	}
+	min := s.lastTimestamp() - s.Retention
+	i := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Timestamp >= min
+	})
+	if i != 0 {
+		s.Samples = append(s.Samples[:0:0], s.Samples[i:]...)
+	}
+}
+
+// trimmable returns true if the series has samples out of the retention period
+func (s *TimeSeries) trimmable() bool {
+	return s.Retention != 0 && len(s.Samples) != 0 && s.Samples[0].Timestamp < s.lastTimestamp()-s.Retention
+}
+
+// rangeSamples returns samples with timestamps between from and to inclusive
+func (s *TimeSeries) rangeSamples(from, to int64) []TSSample {
+	start := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Timestamp >= from
+	})
+	end := sort.Search(len(s.Samples), func(i int) bool {
+		return s.Samples[i].Timestamp > to
+	})
+	if start >= end {
+		return nil
+	}
+	return s.Samples[start:end]
+}
+
+// aggregate returns one sample per time bucket, timestamped with the start of the bucket
+func aggregate(samples []TSSample, aggregation string, bucket int64) []TSSample {
+	var res []TSSample
+	var current tsAggregator
+	start := int64(0)
+	for _, sample := range samples {
+		if current.Count != 0 && bucketStart(sample.Timestamp, bucket) != start {
+			res = append(res, TSSample{start, current.result(aggregation)})
+			current = tsAggregator{}
+		}
+		start = bucketStart(sample.Timestamp, bucket)
+		current.add(sample.Value)
+	}
+	if current.Count != 0 {
+		res = append(res, TSSample{start, current.result(aggregation)})
+	}
+	return res
+}
+
+// Mutex must be locked before calling getTimeSeries. Returns nil if key is not set
+func (c *cache) getTimeSeries(key string) (series *TimeSeries, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case *TimeSeries:
+		series = stored.(*TimeSeries)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+
+// Mutex must be locked before calling renameSeries. Compaction rules refer to their sources and destinations by name,
+// references to the renamed series are changed to the new name
+func (c *cache) renameSeries(series *TimeSeries, key, newKey string) {
+	if source, ok := c.read(series.SourceKey).(*TimeSeries); ok && series.SourceKey != "" {
+		for _, rule := range source.Rules {
+			if rule.Dest == key {
+				rule.Dest = newKey
+			}
+		}
+		c.modified(series.SourceKey)
+	}
+	for _, rule := range series.Rules {
+		if dest, ok := c.read(rule.Dest).(*TimeSeries); ok && dest.SourceKey == key {
+			dest.SourceKey = newKey
+			c.modified(rule.Dest)
+		}
+	}
+}
+
+// Mutex must be locked before calling compact. Passes the sample to compaction rules of the series,
+// finished buckets are added to the destination series
+func (c *cache) compact(series *TimeSeries, sample TSSample) {
+	for _, rule := range series.Rules {
+		start := bucketStart(sample.Timestamp, rule.Bucket)
+		if rule.Current.Count != 0 && start < rule.Start {
+			continue
+		}
+		if rule.Current.Count != 0 && start > rule.Start {
+			dest, err := c.getTimeSeries(rule.Dest)
+			if err == nil && dest != nil {
+				dest.add(TSSample{rule.Start, rule.Current.result(rule.Aggregation)}, "LAST")
+				c.modified(rule.Dest)
+				c.notify(moduleClass, "ts.add", rule.Dest)
+			}
+			rule.Current = tsAggregator{}
+		}
+		rule.Start = start
+		rule.Current.add(sample.Value)
+	}
+}
+
+// tsOptions are the options shared by TS.CREATE and TS.ADD
+type tsOptions struct {
+	retention       int64
+	duplicatePolicy string
+	onDuplicate     string
+	labels          map[string]string
+}
+
+func parseTSOptions(args []string, formatErr error) (options tsOptions, err error) {
+	for i := 0; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "LABELS" {
+			if (len(args)-i-1)%2 != 0 {
+				err = formatErr
+				return
+			}
+			options.labels = make(map[string]string)
+			for i += 1; i < len(args); i += 2 {
+				options.labels[args[i]] = args[i+1]
+			}
+			return
+		}
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		i += 1
+		switch option {
+		case "RETENTION":
+			options.retention, err = strconv.ParseInt(args[i], 10, 64)
+			if err != nil || options.retention < 0 {
+				err = errors.New("TSDB: invalid RETENTION value")
+				return
+			}
+		case "DUPLICATE_POLICY", "ON_DUPLICATE":
+			policy := strings.ToUpper(args[i])
+			switch policy {
+			case "BLOCK", "FIRST", "LAST", "MIN", "MAX", "SUM":
+			default:
+				err = errors.New("TSDB: Unknown DUPLICATE_POLICY")
+				return
+			}
+			if option == "ON_DUPLICATE" {
+				options.onDuplicate = policy
+			} else {
+				options.duplicatePolicy = policy
+			}
+		default:
+			err = formatErr
+			return
+		}
+	}
+	return
+}
+
+// Mutex must be locked before calling createTimeSeries
+func (c *cache) createTimeSeries(key string, options tsOptions) *TimeSeries {
+	policy := options.duplicatePolicy
+	if policy == "" {
+		policy = "BLOCK"
+	}
+	series := NewTimeSeries(options.retention, policy, options.labels)
+	c.write(key, series)
+	return series
+}
+func (c *cache) TSCreate(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("TS.CREATE")
+	options, err := parseTSOptions(args[1:], formatErr)
+	if err != nil {
+		return
+	}
+	if options.onDuplicate != "" {
+		err = formatErr
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.read(args[0]) != nil {
+		err = errors.New("TSDB: key already exists")
+		return
+	}
+	c.createTimeSeries(args[0], options)
+	response = formatted("OK")
+	return
+}
+
+// TSAdd adds a sample creating the series if needed, * as timestamp means the current time
+func (c *cache) TSAdd(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("TS.ADD")
+	var timestamp int64
+	if args[1] == "*" {
+		timestamp = time.Now().UnixNano() / int64(time.Millisecond)
+	} else {
+		timestamp, err = strconv.ParseInt(args[1], 10, 64)
+		if err != nil || timestamp < 0 {
+			err = errors.New("TSDB: invalid timestamp")
+			return
+		}
+	}
+	value, err := strconv.ParseFloat(args[2], 64)
+	if err != nil || math.IsNaN(value) {
+		err = errors.New("TSDB: invalid value")
+		return
+	}
+	options, err := parseTSOptions(args[3:], formatErr)
+	if err != nil {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	series, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	if series == nil {
+		series = c.createTimeSeries(args[0], options)
+	}
+	policy := options.onDuplicate
+	if policy == "" {
+		policy = series.DuplicatePolicy
+	}
+	sample := TSSample{timestamp, value}
+	err = series.add(sample, policy)
+	if err != nil {
+		return
+	}
+	c.modified(args[0])
+	c.notify(moduleClass, "ts.add", args[0])
+	c.compact(series, sample)
+	response = int64(timestamp)
+	return
+}
+func (c *cache) TSGet(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	series, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	if series == nil {
+		err = errTSNotFound
+		return
+	}
+	if len(series.Samples) == 0 {
+		response = nil
+		return
+	}
+	response = series.Samples[len(series.Samples)-1].format()
+	return
+}
+
+// tsRangeOptions are the options shared by TS.RANGE, TS.REVRANGE and TS.MRANGE
+type tsRangeOptions struct {
+	from        int64
+	to          int64
+	count       int
+	aggregation string
+	bucket      int64
+	withLabels  bool
+	filters     []string
+}
+
+func parseTimestamp(arg string, dflt int64) (int64, error) {
+	if arg == "-" || arg == "+" {
+		return dflt, nil
+	}
+	timestamp, err := strconv.ParseInt(arg, 10, 64)
+	if err != nil {
+		return 0, errors.New("TSDB: invalid timestamp")
+	}
+	return timestamp, nil
+}
+func parseTSRange(args []string, multi bool, formatErr error) (options tsRangeOptions, err error) {
+	if len(args) < 2 {
+		err = formatErr
+		return
+	}
+	options.from, err = parseTimestamp(args[0], 0)
+	if err != nil {
+		return
+	}
+	options.to, err = parseTimestamp(args[1], math.MaxInt64)
+	if err != nil {
+		return
+	}
+	options.count = -1
+	for i := 2; i < len(args); i += 1 {
+		switch strings.ToUpper(args[i]) {
+		case "COUNT":
+			if i+1 >= len(args) {
+				err = formatErr
+				return
+			}
+			options.count, err = strconv.Atoi(args[i+1])
+			if err != nil || options.count < 0 {
+				err = errors.New("TSDB: Couldn't parse COUNT")
+				return
+			}
+			i += 1
+		case "AGGREGATION":
+			if i+2 >= len(args) {
+				err = formatErr
+				return
+			}
+			options.aggregation, err = parseAggregation(args[i+1])
+			if err != nil {
+				return
+			}
+			options.bucket, err = strconv.ParseInt(args[i+2], 10, 64)
+			if err != nil || options.bucket <= 0 {
+				err = errors.New("TSDB: bucketDuration must be greater than zero")
+				return
+			}
+			i += 2
+		case "WITHLABELS":
+			if !multi {
+				err = formatErr
+				return
+			}
+			options.withLabels = true
+		case "FILTER":
+			if !multi || i+1 >= len(args) {
+				err = formatErr
+				return
+			}
+			options.filters = args[i+1:]
+			return
+		default:
+			err = formatErr
+			return
+		}
+	}
+	if multi {
+		err = errors.New("TSDB: missing FILTER argument")
+	}
+	return
+}
+
+// samples returns the samples of the series selected by the options
+func (options tsRangeOptions) samples(series *TimeSeries, reverse bool) []interface{} {
+	samples := series.rangeSamples(options.from, options.to)
+	if options.aggregation != "" {
+		samples = aggregate(samples, options.aggregation, options.bucket)
+	}
+	res := make([]interface{}, 0, len(samples))
+	for i := range samples {
+		if options.count >= 0 && len(res) == options.count {
+			break
+		}
+		if reverse {
+			i = len(samples) - 1 - i
+		}
+		res = append(res, samples[i].format())
+	}
+	return res
+}
+func (c *cache) tsRange(args []string, method string, reverse bool) (response interface{}, err error) {
+	options, err := parseTSRange(args[1:], false, syntaxError(method))
+	if err != nil {
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	series, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	if series == nil {
+		err = errTSNotFound
+		return
+	}
+	response = options.samples(series, reverse)
+	return
+}
+func (c *cache) TSRange(args []string) (response interface{}, err error) {
+	return c.tsRange(args, "TS.RANGE", false)
+}
+func (c *cache) TSRevRange(args []string) (response interface{}, err error) {
+	return c.tsRange(args, "TS.REVRANGE", true)
+}
+
+// tsFilter matches labels of a series. Supported forms are label=value, label!=value,
+// label=(value,value) and label!=(value,value), an empty value means the label is not set
+type tsFilter struct {
+	label  string
+	values []string
+	negate bool
+}
+
+func parseTSFilter(arg string) (filter tsFilter, err error) {
+	i := strings.IndexByte(arg, '=')
+	if i <= 0 {
+		err = errors.New("TSDB: failed parsing labels")
+		return
+	}
+	filter.label = arg[:i]
+	if strings.HasSuffix(filter.label, "!") {
+		filter.label = filter.label[:len(filter.label)-1]
+		filter.negate = true
+	}
+	value := arg[i+1:]
+	if strings.HasPrefix(value, "(") && strings.HasSuffix(value, ")") {
+		filter.values = strings.Split(value[1:len(value)-1], ",")
+	} else {
+		filter.values = []string{value}
+	}
+	return
+}
+func (f tsFilter) match(labels map[string]string) bool {
+	value := labels[f.label]
+	for _, v := range f.values {
+		if v == value {
+			return !f.negate
+		}
+	}
+	return f.negate
+}
+
+// TSMRange queries all series with labels matching the filters. At least one filter must
+// require a label value, so that the query does not match every series without the label
+func (c *cache) TSMRange(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("TS.MRANGE")
+	options, err := parseTSRange(args, true, formatErr)
+	if err != nil {
+		return
+	}
+	filters := make([]tsFilter, len(options.filters))
+	matcher := false
+	for i := range options.filters {
+		filters[i], err = parseTSFilter(options.filters[i])
+		if err != nil {
+			return
+		}
+		if !filters[i].negate && filters[i].values[0] != "" {
+			matcher = true
+		}
+	}
+	if !matcher {
+		err = errors.New("TSDB: please provide at least one matcher")
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	var keys []string
+	for key, value := range c.Fields {
+		series, ok := value.(*TimeSeries)
+		if !ok {
+			continue
+		}
+		matched := true
+		for _, filter := range filters {
+			if !filter.match(series.Labels) {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	res := make([]interface{}, len(keys))
+	for i, key := range keys {
+		series := c.read(key).(*TimeSeries)
+		labels := []interface{}{}
+		if options.withLabels {
+			labels = series.formatLabels()
+		}
+		res[i] = []interface{}{key, labels, options.samples(series, false)}
+	}
+	response = res
+	return
+}
+func (s *TimeSeries) formatLabels() []interface{} {
+	names := make([]string, 0, len(s.Labels))
+	for name := range s.Labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	labels := make([]interface{}, len(names))
+	for i, name := range names {
+		labels[i] = []interface{}{name, s.Labels[name]}
+	}
+	return labels
+}
+
+// TSCreateRule adds a compaction rule, both series must exist and the destination can not be compacted further
+func (c *cache) TSCreateRule(args []string) (response interface{}, err error) {
+	if len(args) != 5 || strings.ToUpper(args[2]) != "AGGREGATION" {
+		err = syntaxError("TS.CREATERULE")
+		return
+	}
+	aggregation, err := parseAggregation(args[3])
+	if err != nil {
+		return
+	}
+	bucket, err := strconv.ParseInt(args[4], 10, 64)
+	if err != nil || bucket <= 0 {
+		err = errors.New("TSDB: bucketDuration must be greater than zero")
+		return
+	}
+	if args[0] == args[1] {
+		err = errors.New("TSDB: the source key and destination key should be different")
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	source, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	dest, err := c.getTimeSeries(args[1])
+	if err != nil {
+		return
+	}
+	if source == nil || dest == nil {
+		err = errTSNotFound
+		return
+	}
+	if source.SourceKey != "" {
+		err = errors.New("TSDB: the source key is a destination of another rule")
+		return
+	}
+	if dest.SourceKey != "" || len(dest.Rules) != 0 {
+		err = errors.New("TSDB: the destination key already has a rule")
+		return
+	}
+	source.Rules = append(source.Rules, &CompactionRule{Dest: args[1], Aggregation: aggregation, Bucket: bucket})
+	dest.SourceKey = args[0]
+	c.modified(args[0])
+	c.modified(args[1])
+	c.notify(moduleClass, "ts.createrule:src", args[0])
+	c.notify(moduleClass, "ts.createrule:dest", args[1])
+	response = formatted("OK")
+	return
+}
+func (c *cache) TSDeleteRule(args []string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	source, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	if source == nil {
+		err = errTSNotFound
+		return
+	}
+	for i, rule := range source.Rules {
+		if rule.Dest == args[1] {
+			source.Rules = append(source.Rules[:i], source.Rules[i+1:]...)
+			if dest, _ := c.getTimeSeries(args[1]); dest != nil && dest.SourceKey == args[0] {
+				dest.SourceKey = ""
+			}
+			c.modified(args[0])
+			c.modified(args[1])
+			c.notify(moduleClass, "ts.deleterule:src", args[0])
+			c.notify(moduleClass, "ts.deleterule:dest", args[1])
+			response = formatted("OK")
+			return
+		}
+	}
+	err = errors.New("TSDB: compaction rule does not exist")
+	return
+}
+func (c *cache) TSInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	series, err := c.getTimeSeries(args[0])
+	if err != nil {
+		return
+	}
+	if series == nil {
+		err = errTSNotFound
+		return
+	}
+	first := int64(0)
+	if len(series.Samples) != 0 {
+		first = series.Samples[0].Timestamp
+	}
+	rules := make([]interface{}, len(series.Rules))
+	for i, rule := range series.Rules {
+		rules[i] = []interface{}{rule.Dest, rule.Bucket, rule.Aggregation}
+	}
+	var sourceKey interface{}
+	if series.SourceKey != "" {
+		sourceKey = series.SourceKey
+	}
+	response = []interface{}{
+		"totalSamples", len(series.Samples),
+		"firstTimestamp", first,
+		"lastTimestamp", series.lastTimestamp(),
+		"retentionTime", series.Retention,
+		"duplicatePolicy", series.DuplicatePolicy,
+		"labels", series.formatLabels(),
+		"sourceKey", sourceKey,
+		"rules", rules,
+	}
+	return
+}
+
+// trimRetention removes samples out of the retention period from all time series, called by the cleaner
+func (c *cache) trimRetention() {
+	// the exclusive lock is taken only if there is something to trim, so that the cleaner does not block readers
+	c.m.RLock()
+	trim := false
+	for key := range c.timeSeries {
+		if series, ok := c.read(key).(*TimeSeries); !ok || series.trimmable() {
+			trim = true
+			break
+		}
+	}
+	c.m.RUnlock()
+	if !trim {
+		return
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	for key := range c.timeSeries {
+		series, ok := c.read(key).(*TimeSeries)
+		if !ok {
+			// the series was deleted or overwritten
+			delete(c.timeSeries, key)
+			continue
+		}
+		samples := len(series.Samples)
+		series.trim()
+		if len(series.Samples) != samples {
+			c.modified(key)
+		}
+	}
+}
@@ -0,0 +1,243 @@
+package cache
+
+import (
+	"os"
+	"testing"
+)
+
+func TestTSAddRange(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	for _, sample := range [][]string{{"10", "1"}, {"30", "3"}, {"20", "2"}, {"40", "4"}, {"55", "5.5"}} {
+		_, err := text(c.TSAdd([]string{"latency", sample[0], sample[1]}))
+		if err != nil {
+			t.Error(err)
+		}
+	}
+	_, err := text(c.TSAdd([]string{"latency", "20", "7"}))
+	if err == nil {
+		t.Error("expected error on duplicate sample with BLOCK policy")
+	}
+	resp, err := text(c.TSAdd([]string{"latency", "20", "7", "ON_DUPLICATE", "MAX"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 20" {
+		t.Errorf("expected (integer) 20, got %v", resp)
+	}
+
+	resp, err = text(c.TSRange([]string{"latency", "15", "+", "COUNT", "3"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) (integer) 20\n   2) \"7\"\n2) 1) (integer) 30\n   2) \"3\"\n3) 1) (integer) 40\n   2) \"4\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.TSRevRange([]string{"latency", "-", "+", "COUNT", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) (integer) 55\n   2) \"5.5\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	expectedAggregations := map[string]string{
+		"avg":   "1) 1) (integer) 0\n   2) \"4\"\n2) 1) (integer) 30\n   2) \"4.166666666666667\"",
+		"min":   "1) 1) (integer) 0\n   2) \"1\"\n2) 1) (integer) 30\n   2) \"3\"",
+		"max":   "1) 1) (integer) 0\n   2) \"7\"\n2) 1) (integer) 30\n   2) \"5.5\"",
+		"sum":   "1) 1) (integer) 0\n   2) \"8\"\n2) 1) (integer) 30\n   2) \"12.5\"",
+		"count": "1) 1) (integer) 0\n   2) \"2\"\n2) 1) (integer) 30\n   2) \"3\"",
+	}
+	for aggregation, expected := range expectedAggregations {
+		resp, err = text(c.TSRange([]string{"latency", "-", "+", "AGGREGATION", aggregation, "30"}))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != expected {
+			t.Errorf("%v: expected:\n%v, got:\n%v", aggregation, expected, resp)
+		}
+	}
+	_, err = text(c.TSRange([]string{"latency", "-", "+", "AGGREGATION", "median", "30"}))
+	if err == nil {
+		t.Error("expected error on unknown aggregation")
+	}
+
+	resp, err = text(c.TSGet([]string{"latency"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 55\n2) \"5.5\"" {
+		t.Errorf("expected 1) (integer) 55\n2) \"5.5\", got %v", resp)
+	}
+	_, err = text(c.TSRange([]string{"missing", "-", "+"}))
+	if err != errTSNotFound {
+		t.Errorf("expected %v, got %v", errTSNotFound, err)
+	}
+}
+
+func TestTSRetention(t *testing.T) {
+	c := (NewCache()).(*cache)
+	_, err := text(c.TSCreate([]string{"series", "RETENTION", "100"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c.TSAdd([]string{"series", "100", "1"})
+	c.TSAdd([]string{"series", "150", "2"})
+	c.TSAdd([]string{"series", "250", "3"})
+	_, err = text(c.TSAdd([]string{"series", "100", "4"}))
+	if err == nil {
+		t.Error("expected error on sample older than retention")
+	}
+
+	c.trimRetention()
+	resp, _ := text(c.TSRange([]string{"series", "-", "+"}))
+	expected := "1) 1) (integer) 150\n   2) \"2\"\n2) 1) (integer) 250\n   2) \"3\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	if c.read("series").(*TimeSeries).trimmable() {
+		t.Errorf("expected nothing to trim on the next run")
+	}
+
+	c.Del([]string{"series"})
+	c.trimRetention()
+	if len(c.timeSeries) != 0 {
+		t.Errorf("expected deleted series to be forgotten, got %v", c.timeSeries)
+	}
+}
+
+func TestTSRenameRules(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TSCreate([]string{"raw"})
+	c.TSCreate([]string{"avg"})
+	if _, err := text(c.TSCreateRule([]string{"raw", "avg", "AGGREGATION", "avg", "10"})); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := text(c.Rename([]string{"raw", "avg"})); err != errRenameToRule {
+		t.Errorf("expected %v, got %v", errRenameToRule, err)
+	}
+	if _, err := text(c.Rename([]string{"raw", "source"})); err != nil {
+		t.Fatal(err)
+	}
+	if resp, err := text(c.RenameNX([]string{"avg", "dest"})); err != nil || resp != "(integer) 1" {
+		t.Fatalf("expected (integer) 1, got %v %v", resp, err)
+	}
+	source, dest := c.read("source").(*TimeSeries), c.read("dest").(*TimeSeries)
+	if len(source.Rules) != 1 || source.Rules[0].Dest != "dest" || dest.SourceKey != "source" {
+		t.Errorf("expected rules to refer to the new names, got %v %v", source.Rules[0].Dest, dest.SourceKey)
+	}
+	c.TSAdd([]string{"source", "1", "2"})
+	c.TSAdd([]string{"source", "11", "4"})
+	if resp, _ := text(c.TSRange([]string{"dest", "-", "+"})); resp != "1) 1) (integer) 0\n   2) \"2\"" {
+		t.Errorf("expected the compacted sample in the renamed destination, got %v", resp)
+	}
+}
+
+func TestTSCompaction(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TSCreate([]string{"raw"})
+	c.TSCreate([]string{"avg"})
+	_, err := text(c.TSCreateRule([]string{"raw", "missing", "AGGREGATION", "avg", "10"}))
+	if err != errTSNotFound {
+		t.Errorf("expected %v, got %v", errTSNotFound, err)
+	}
+	_, err = text(c.TSCreateRule([]string{"raw", "avg", "AGGREGATION", "avg", "10"}))
+	if err != nil {
+		t.Error(err)
+	}
+	_, err = text(c.TSCreateRule([]string{"avg", "raw", "AGGREGATION", "avg", "10"}))
+	if err == nil {
+		t.Error("expected error on rule from a compacted series")
+	}
+	for _, sample := range [][]string{{"1", "1"}, {"5", "3"}, {"12", "10"}, {"25", "1"}} {
+		c.TSAdd([]string{"raw", sample[0], sample[1]})
+	}
+	resp, err := text(c.TSRange([]string{"avg", "-", "+"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) (integer) 0\n   2) \"2\"\n2) 1) (integer) 10\n   2) \"10\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+
+	_, err = text(c.TSDeleteRule([]string{"raw", "avg"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c.TSAdd([]string{"raw", "40", "1"})
+	resp, _ = text(c.TSRange([]string{"avg", "-", "+"}))
+	if resp != expected {
+		t.Errorf("expected no compaction after deleting the rule, got:\n%v", resp)
+	}
+}
+
+func TestTSMRange(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TSAdd([]string{"api:users", "10", "100", "LABELS", "endpoint", "users", "type", "latency"})
+	c.TSAdd([]string{"api:orders", "10", "200", "LABELS", "endpoint", "orders", "type", "latency"})
+	c.TSAdd([]string{"api:errors", "10", "1", "LABELS", "type", "errors"})
+
+	resp, err := text(c.TSMRange([]string{"-", "+", "WITHLABELS", "FILTER", "type=latency", "endpoint!=orders"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) 1) \"api:users\"\n" +
+		"   2) 1) 1) \"endpoint\"\n" +
+		"         2) \"users\"\n" +
+		"      2) 1) \"type\"\n" +
+		"         2) \"latency\"\n" +
+		"   3) 1) 1) (integer) 10\n" +
+		"         2) \"100\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.TSMRange([]string{"-", "+", "FILTER", "type=(latency,errors)", "endpoint="}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected = "1) 1) \"api:errors\"\n   2) (empty array)\n   3) 1) 1) (integer) 10\n         2) \"1\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	_, err = text(c.TSMRange([]string{"-", "+", "FILTER", "endpoint!=users"}))
+	if err == nil {
+		t.Error("expected error on filter without matcher")
+	}
+}
+
+func TestTSSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TSCreate([]string{"raw", "RETENTION", "1000", "LABELS", "type", "latency"})
+	c.TSCreate([]string{"max"})
+	c.TSCreateRule([]string{"raw", "max", "AGGREGATION", "max", "10"})
+	c.TSAdd([]string{"raw", "1", "5"})
+
+	_, err := text(c.Save([]string{"tssave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"tssave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if !c.timeSeries["raw"] {
+		t.Error("expected loaded series to be checked by the cleaner")
+	}
+	c.TSAdd([]string{"raw", "11", "1"})
+	resp, err := text(c.TSRange([]string{"max", "-", "+"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) 1) (integer) 0\n   2) \"5\"" {
+		t.Errorf("expected 1) 1) (integer) 0\n   2) \"5\", got %v", resp)
+	}
+
+	err = os.Remove("./saves/tssave")
+	if err != nil {
+		t.Error(err)
+	}
+}
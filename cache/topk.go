@@ -0,0 +1,323 @@
+package cache
+
+import (
+	"container/heap"
+	"errors"
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	topKDefaultWidth = 8
+	topKDefaultDepth = 7
+	topKDefaultDecay = 0.9
+)
+
+var errTopKNotFound = errors.New("TopK: key does not exist")
+
+type TopKBucket struct {
+	Fingerprint uint64
+	Count       uint64
+}
+
+type TopKItem struct {
+	Item  string
+	Count uint64
+}
+
+// topKHeap is a min-heap of the tracked heavy hitters
+type topKHeap []TopKItem
+
+func (h topKHeap) Len() int {
+	return len(h)
+}
+func (h topKHeap) Less(i, j int) bool {
+	return h[i].Count < h[j].Count
+}
+func (h topKHeap) Swap(i, j int) {
+	h[i], h[j] = h[j], h[i]
+}
+func (h *topKHeap) Push(val interface{}) {
+	*h = append(*h, val.(TopKItem))
+}
+func (h *topKHeap) Pop() interface{} {
+	n := len(*h)
+	res := (*h)[n-1]
+	*h = (*h)[:n-1]
+	return res
+}
+
+// TopK tracks the K most frequent items using the HeavyKeeper algorithm: counters of colliding items
+// are decayed with probability Decay^count, so counters of frequent items are kept
+type TopK struct {
+	K       int
+	Width   uint64
+	Depth   uint64
+	Decay   float64
+	Buckets []TopKBucket
+	Heap    topKHeap
+}
+
+func NewTopK(k int, width, depth uint64, decay float64) *TopK {
+	return &TopK{k, width, depth, decay, make([]TopKBucket, width*depth), make(topKHeap, 0, k)}
+}
+
+func (t *TopK) find(item string) int {
+	for i := range t.Heap {
+		if t.Heap[i].Item == item {
+			return i
+		}
+	}
+	return -1
+}
+
+// IncrBy counts the item, returns the item expelled from the top list if any
+func (t *TopK) IncrBy(item string, increment uint64) (expelled string, ok bool) {
+	fp, h2 := bloomHashes(item)
+	maxCount := uint64(0)
+	for row := uint64(0); row < t.Depth; row += 1 {
+		bucket := &t.Buckets[row*t.Width+(fp+row*h2)%t.Width]
+		if bucket.Count == 0 {
+			bucket.Fingerprint = fp
+			bucket.Count = increment
+		} else if bucket.Fingerprint == fp {
+			bucket.Count += increment
+		} else {
+			for i := uint64(0); i < increment; i += 1 {
+				if rand.Float64() < math.Pow(t.Decay, float64(bucket.Count)) {
+					bucket.Count -= 1
+					if bucket.Count == 0 {
+						bucket.Fingerprint = fp
+						bucket.Count = increment - i
+						break
+					}
+				}
+			}
+		}
+		if bucket.Fingerprint == fp && bucket.Count > maxCount {
+			maxCount = bucket.Count
+		}
+	}
+	if i := t.find(item); i >= 0 {
+		if maxCount > t.Heap[i].Count {
+			t.Heap[i].Count = maxCount
+			heap.Fix(&t.Heap, i)
+		}
+		return
+	}
+	if maxCount == 0 {
+		return
+	}
+	if len(t.Heap) < t.K {
+		heap.Push(&t.Heap, TopKItem{item, maxCount})
+		return
+	}
+	if maxCount > t.Heap[0].Count {
+		expelled, ok = t.Heap[0].Item, true
+		t.Heap[0] = TopKItem{item, maxCount}
+		heap.Fix(&t.Heap, 0)
+	}
+	return
+}
+
+// Count returns the estimated count of the item
+func (t *TopK) Count(item string) uint64 {
+	fp, h2 := bloomHashes(item)
+	maxCount := uint64(0)
+	for row := uint64(0); row < t.Depth; row += 1 {
+		bucket := t.Buckets[row*t.Width+(fp+row*h2)%t.Width]
+		if bucket.Fingerprint == fp && bucket.Count > maxCount {
+			maxCount = bucket.Count
+		}
+	}
+	return maxCount
+}
+
+// Mutex must be locked before calling getTopK. Returns nil if key is not set
+func (c *cache) getTopK(key string) (topk *TopK, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case *TopK:
+		topk = stored.(*TopK)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+func (c *cache) TopKReserve(args []string) (response interface{}, err error) {
+	if len(args) != 2 && len(args) != 5 {
+		err = syntaxError("TOPK.RESERVE")
+		return
+	}
+	k, err := strconv.Atoi(args[1])
+	if err != nil || k < 1 {
+		err = errors.New("TopK: invalid k")
+		return
+	}
+	width, depth, decay := uint64(topKDefaultWidth), uint64(topKDefaultDepth), topKDefaultDecay
+	if len(args) == 5 {
+		width, err = strconv.ParseUint(args[2], 10, 64)
+		if err != nil || width == 0 {
+			err = errors.New("TopK: invalid width")
+			return
+		}
+		depth, err = strconv.ParseUint(args[3], 10, 64)
+		if err != nil || depth == 0 {
+			err = errors.New("TopK: invalid depth")
+			return
+		}
+		decay, err = strconv.ParseFloat(args[4], 64)
+		if err != nil || decay <= 0 || decay > 1 {
+			err = errors.New("TopK: invalid decay value. must be '<= 1' & '> 0'")
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	if c.read(args[0]) != nil {
+		err = errors.New("TopK: key already exists")
+		return
+	}
+	c.write(args[0], NewTopK(k, width, depth, decay))
+	response = formatted("OK")
+	return
+}
+func (c *cache) topKIncrBy(key string, items []string, increments []uint64, event string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	topk, err := c.getTopK(key)
+	if err != nil {
+		return
+	}
+	if topk == nil {
+		err = errTopKNotFound
+		return
+	}
+	expelled := make([]interface{}, len(items))
+	for i := range items {
+		if item, ok := topk.IncrBy(items[i], increments[i]); ok {
+			expelled[i] = item
+		}
+	}
+	c.modified(key)
+	c.notify(moduleClass, event, key)
+	response = expelled
+	return
+}
+func (c *cache) TopKAdd(args []string) (response interface{}, err error) {
+	increments := make([]uint64, len(args)-1)
+	for i := range increments {
+		increments[i] = 1
+	}
+	return c.topKIncrBy(args[0], args[1:], increments, "topk.add")
+}
+func (c *cache) TopKIncrBy(args []string) (response interface{}, err error) {
+	if len(args) < 3 || len(args)%2 == 0 {
+		err = syntaxError("TOPK.INCRBY")
+		return
+	}
+	items := make([]string, len(args)/2)
+	increments := make([]uint64, len(args)/2)
+	for i := range items {
+		items[i] = args[1+2*i]
+		increments[i], err = strconv.ParseUint(args[2+2*i], 10, 64)
+		if err != nil || increments[i] < 1 || increments[i] > 100000 {
+			err = errors.New("TopK: increment must be an integer greater or equal to 1 and less than or equal to 100,000")
+			return
+		}
+	}
+	return c.topKIncrBy(args[0], items, increments, "topk.incrby")
+}
+func (c *cache) TopKQuery(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	topk, err := c.getTopK(args[0])
+	if err != nil {
+		return
+	}
+	if topk == nil {
+		err = errTopKNotFound
+		return
+	}
+	found := make([]interface{}, len(args)-1)
+	for i, item := range args[1:] {
+		found[i] = 0
+		if topk.find(item) >= 0 {
+			found[i] = 1
+		}
+	}
+	response = found
+	return
+}
+func (c *cache) TopKCount(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	topk, err := c.getTopK(args[0])
+	if err != nil {
+		return
+	}
+	if topk == nil {
+		err = errTopKNotFound
+		return
+	}
+	counts := make([]interface{}, len(args)-1)
+	for i, item := range args[1:] {
+		counts[i] = topk.Count(item)
+	}
+	response = counts
+	return
+}
+func (c *cache) TopKList(args []string) (response interface{}, err error) {
+	if len(args) != 1 && !(len(args) == 2 && strings.ToUpper(args[1]) == "WITHCOUNT") {
+		err = syntaxError("TOPK.LIST")
+		return
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	topk, err := c.getTopK(args[0])
+	if err != nil {
+		return
+	}
+	if topk == nil {
+		err = errTopKNotFound
+		return
+	}
+	items := make([]TopKItem, len(topk.Heap))
+	copy(items, topk.Heap)
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Count > items[j].Count
+	})
+	list := make([]interface{}, 0, len(items)*2)
+	for _, item := range items {
+		list = append(list, item.Item)
+		if len(args) == 2 {
+			list = append(list, item.Count)
+		}
+	}
+	response = list
+	return
+}
+func (c *cache) TopKInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	topk, err := c.getTopK(args[0])
+	if err != nil {
+		return
+	}
+	if topk == nil {
+		err = errTopKNotFound
+		return
+	}
+	response = []interface{}{
+		"k", topk.K,
+		"width", topk.Width,
+		"depth", topk.Depth,
+		"decay", strconv.FormatFloat(topk.Decay, 'f', -1, 64),
+	}
+	return
+}
@@ -0,0 +1,106 @@
+package cache
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestTopKAdd(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	_, err := text(c.TopKAdd([]string{"topk", "item"}))
+	if err != errTopKNotFound {
+		t.Errorf("expected %v, got %v", errTopKNotFound, err)
+	}
+	resp, err := text(c.TopKReserve([]string{"topk", "2", "50", "5", "0.9"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "OK" {
+		t.Errorf("expected OK, got %v", resp)
+	}
+	resp, err = text(c.TopKAdd([]string{"topk", "a", "b", "a"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (nil)\n2) (nil)\n3) (nil)" {
+		t.Errorf("expected no expelled items, got %v", resp)
+	}
+	resp, err = text(c.TopKIncrBy([]string{"topk", "c", "5"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"b\"" {
+		t.Errorf("expected b to be expelled, got %v", resp)
+	}
+	resp, err = text(c.TopKList([]string{"topk", "WITHCOUNT"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"c\"\n2) (integer) 5\n3) \"a\"\n4) (integer) 2" {
+		t.Errorf("unexpected list %v", resp)
+	}
+	resp, err = text(c.TopKQuery([]string{"topk", "a", "b"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 1\n2) (integer) 0" {
+		t.Errorf("expected 1) (integer) 1\n2) (integer) 0, got %v", resp)
+	}
+	resp, err = text(c.TopKCount([]string{"topk", "a"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) (integer) 2" {
+		t.Errorf("expected 1) (integer) 2, got %v", resp)
+	}
+}
+
+func TestTopKHeavyHitters(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TopKReserve([]string{"topk", "3"})
+
+	// keys heavy0..heavy2 are much more frequent than the noise
+	for i := 0; i < 3000; i += 1 {
+		c.TopKAdd([]string{"topk", fmt.Sprintf("heavy%v", i%3), fmt.Sprintf("noise%v", i)})
+	}
+	resp, err := text(c.TopKList([]string{"topk"}))
+	if err != nil {
+		t.Error(err)
+	}
+	for i := 0; i < 3; i += 1 {
+		if !strings.Contains(resp, fmt.Sprintf("\"heavy%v\"", i)) {
+			t.Errorf("expected heavy%v in the list, got %v", i, resp)
+		}
+	}
+}
+
+func TestTopKSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.TopKReserve([]string{"topk", "2"})
+	c.TopKIncrBy([]string{"topk", "a", "3", "b", "2"})
+
+	_, err := text(c.Save([]string{"topksave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"topksave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	resp, err := text(c.TopKList([]string{"topk"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"a\"\n2) \"b\"" {
+		t.Errorf("expected 1) \"a\"\n2) \"b\", got %v", resp)
+	}
+
+	err = os.Remove("./saves/topksave")
+	if err != nil {
+		t.Error(err)
+	}
+}
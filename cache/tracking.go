@@ -0,0 +1,273 @@
+package cache
+
+import (
+	"errors"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+var errTrackingMode = errors.New("You can't switch BCAST mode on/off before disabling tracking for this client, and then re-enabling it with a different mode")
+
+// tracker is the tracking state of a session. In the default mode keys read by the session are remembered until
+// they are invalidated, in the broadcast mode the session is notified about changes of all keys with its prefixes
+type tracker struct {
+	on       bool
+	bcast    bool
+	keys     map[string]bool
+	prefixes map[string]bool
+}
+
+// tracking remembers sessions caching keys on their side and pushes invalidation messages to them when the keys
+// change or expire. Keys are tracked by name, a change of the key in any database invalidates it
+type tracking struct {
+	m        *sync.Mutex
+	keys     map[string]map[Session]bool
+	prefixes map[string]map[Session]bool
+	// trackers are kept until their connection is closed, so that the close callback is registered once
+	trackers map[Session]*tracker
+	// number of sessions with tracking on, writes skip the mutex while it is zero
+	active int32
+}
+
+func newTracking() *tracking {
+	return &tracking{m: &sync.Mutex{}, keys: make(map[string]map[Session]bool), prefixes: make(map[string]map[Session]bool), trackers: make(map[Session]*tracker)}
+}
+
+// invalidation formats the message pushed to tracking sessions, keys are nil if all keys are invalidated
+func invalidation(keys interface{}) string {
+	return formatArray([]interface{}{"invalidate", keys})
+}
+
+// tracker returns the tracking state of the session. Mutex must be locked before calling tracker
+func (t *tracking) tracker(session Session) *tracker {
+	tr, ok := t.trackers[session]
+	if !ok {
+		tr = &tracker{}
+		t.trackers[session] = tr
+		session.OnClose(func() {
+			t.m.Lock()
+			defer t.m.Unlock()
+			t.off(session, tr)
+			delete(t.trackers, session)
+		})
+	}
+	return tr
+}
+
+// off stops tracking of the session and forgets its keys and prefixes. Mutex must be locked before calling off
+func (t *tracking) off(session Session, tr *tracker) {
+	if !tr.on {
+		return
+	}
+	for key := range tr.keys {
+		delete(t.keys[key], session)
+		if len(t.keys[key]) == 0 {
+			delete(t.keys, key)
+		}
+	}
+	for prefix := range tr.prefixes {
+		delete(t.prefixes[prefix], session)
+		if len(t.prefixes[prefix]) == 0 {
+			delete(t.prefixes, prefix)
+		}
+	}
+	*tr = tracker{}
+	atomic.AddInt32(&t.active, -1)
+}
+
+// read remembers the keys read by the session if it tracks keys in the default mode. Keys are remembered before
+// they are read, so that a change between the read and the reply is not missed
+func (t *tracking) read(session Session, keys []string) {
+	if atomic.LoadInt32(&t.active) == 0 || len(keys) == 0 {
+		return
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	tr, ok := t.trackers[session]
+	if !ok || !tr.on || tr.bcast {
+		return
+	}
+	for _, key := range keys {
+		tr.keys[key] = true
+		if t.keys[key] == nil {
+			t.keys[key] = make(map[Session]bool)
+		}
+		t.keys[key][session] = true
+	}
+}
+
+// tracks returns true if the session tracks keys it reads
+func (t *tracking) tracks(session Session) bool {
+	if atomic.LoadInt32(&t.active) == 0 {
+		return false
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	tr, ok := t.trackers[session]
+	return ok && tr.on && !tr.bcast
+}
+
+// invalidate pushes the invalidation of the key to sessions which read it or track a prefix of it. The key is
+// forgotten by sessions in the default mode until they read it again
+func (t *tracking) invalidate(key string) {
+	if atomic.LoadInt32(&t.active) == 0 {
+		return
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	sessions := t.keys[key]
+	delete(t.keys, key)
+	for session := range sessions {
+		delete(t.trackers[session].keys, key)
+	}
+	for prefix, prefixSessions := range t.prefixes {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		if sessions == nil {
+			sessions = make(map[Session]bool)
+		}
+		for session := range prefixSessions {
+			sessions[session] = true
+		}
+	}
+	if len(sessions) == 0 {
+		return
+	}
+	message := invalidation([]interface{}{key})
+	for session := range sessions {
+		session.Send(message)
+	}
+}
+
+// invalidateAll pushes the invalidation of all keys to tracking sessions, when databases are flushed, swapped
+// or loaded
+func (t *tracking) invalidateAll() {
+	if atomic.LoadInt32(&t.active) == 0 {
+		return
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	t.keys = make(map[string]map[Session]bool)
+	message := invalidation(nil)
+	for session, tr := range t.trackers {
+		if !tr.on {
+			continue
+		}
+		if !tr.bcast {
+			tr.keys = make(map[string]bool)
+		}
+		session.Send(message)
+	}
+}
+
+// Client executes the CLIENT command of the session
+func (t *tracking) Client(session Session, args []string) (response interface{}, err error) {
+	switch strings.ToUpper(args[0]) {
+	case "TRACKING":
+		if len(args) > 1 {
+			return t.Tracking(session, args[1:])
+		}
+	case "TRACKINGINFO":
+		if len(args) == 1 {
+			return t.TrackingInfo(session)
+		}
+	}
+	err = syntaxError("CLIENT")
+	return
+}
+
+// Tracking turns tracking of keys by the session on or off. In the default mode keys read by the session are
+// tracked, with BCAST the session is notified about changes of keys starting with the prefixes or of all keys
+func (t *tracking) Tracking(session Session, args []string) (response interface{}, err error) {
+	formatErr := syntaxError("CLIENT")
+	on := false
+	switch strings.ToUpper(args[0]) {
+	case "ON":
+		on = true
+	case "OFF":
+	default:
+		err = formatErr
+		return
+	}
+	bcast := false
+	var prefixes []string
+	for i := 1; i < len(args); i += 1 {
+		switch strings.ToUpper(args[i]) {
+		case "BCAST":
+			bcast = true
+		case "PREFIX":
+			if i+1 == len(args) {
+				err = formatErr
+				return
+			}
+			prefixes = append(prefixes, args[i+1])
+			i += 1
+		default:
+			err = formatErr
+			return
+		}
+	}
+	if !on && (bcast || len(prefixes) > 0) {
+		err = formatErr
+		return
+	}
+	if len(prefixes) > 0 && !bcast {
+		err = errors.New("PREFIX option requires BCAST mode to be enabled")
+		return
+	}
+	t.m.Lock()
+	defer t.m.Unlock()
+	tr := t.tracker(session)
+	if !on {
+		t.off(session, tr)
+		response = formatted("OK")
+		return
+	}
+	if tr.on && tr.bcast != bcast {
+		err = errTrackingMode
+		return
+	}
+	if !tr.on {
+		*tr = tracker{on: true, bcast: bcast, keys: make(map[string]bool), prefixes: make(map[string]bool)}
+		atomic.AddInt32(&t.active, 1)
+	}
+	if bcast && len(prefixes) == 0 {
+		prefixes = []string{""}
+	}
+	for _, prefix := range prefixes {
+		tr.prefixes[prefix] = true
+		if t.prefixes[prefix] == nil {
+			t.prefixes[prefix] = make(map[Session]bool)
+		}
+		t.prefixes[prefix][session] = true
+	}
+	response = formatted("OK")
+	return
+}
+
+// TrackingInfo returns the tracking mode and the prefixes of the session
+func (t *tracking) TrackingInfo(session Session) (response interface{}, err error) {
+	t.m.Lock()
+	defer t.m.Unlock()
+	flags := []interface{}{"off"}
+	prefixes := make([]string, 0)
+	if tr, ok := t.trackers[session]; ok && tr.on {
+		flags = []interface{}{"on"}
+		if tr.bcast {
+			flags = append(flags, "bcast")
+		}
+		for prefix := range tr.prefixes {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	sort.Strings(prefixes)
+	items := make([]interface{}, len(prefixes))
+	for i := range prefixes {
+		items[i] = prefixes[i]
+	}
+	response = []interface{}{"flags", flags, "prefixes", items}
+	return
+}
@@ -0,0 +1,181 @@
+package cache
+
+import (
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestTracking(t *testing.T) {
+	d := NewDatabases(2)
+	reader, writer, bcast := &testSession{}, &testSession{}, &testSession{}
+	steps := []struct {
+		session  *testSession
+		method   string
+		args     []string
+		expected string
+	}{
+		{reader, "CLIENT", []string{"TRACKING", "ON"}, "OK"},
+		{bcast, "CLIENT", []string{"TRACKING", "ON", "BCAST", "PREFIX", "user:", "PREFIX", "session:"}, "OK"},
+		{writer, "SET", []string{"user:1", "a"}, "OK"},
+		{reader, "GET", []string{"user:1"}, "a"},
+		{reader, "HGET", []string{"hash", "field"}, "(nil)"},
+		{writer, "SET", []string{"user:1", "b"}, "OK"},
+		// the key is forgotten after the invalidation until it is read again
+		{writer, "SET", []string{"user:1", "c"}, "OK"},
+		{writer, "HSET", []string{"hash", "field", "value"}, "(integer) 1"},
+		{writer, "SET", []string{"other", "value"}, "OK"},
+		{reader, "CLIENT", []string{"TRACKINGINFO"}, "1) \"flags\"\n2) 1) \"on\"\n3) \"prefixes\"\n4) (empty array)"},
+		{bcast, "CLIENT", []string{"TRACKINGINFO"}, "1) \"flags\"\n2) 1) \"on\"\n   2) \"bcast\"\n3) \"prefixes\"\n4) 1) \"session:\"\n   2) \"user:\""},
+		{writer, "CLIENT", []string{"TRACKINGINFO"}, "1) \"flags\"\n2) 1) \"off\"\n3) \"prefixes\"\n4) (empty array)"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(step.session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	expected := []string{invalidation([]interface{}{"user:1"}), invalidation([]interface{}{"hash"})}
+	if !reflect.DeepEqual(reader.messages, expected) {
+		t.Errorf("expected %q, got %q", expected, reader.messages)
+	}
+	expected = []string{invalidation([]interface{}{"user:1"}), invalidation([]interface{}{"user:1"}), invalidation([]interface{}{"user:1"})}
+	if !reflect.DeepEqual(bcast.messages, expected) {
+		t.Errorf("expected %q, got %q", expected, bcast.messages)
+	}
+	if len(writer.messages) != 0 {
+		t.Errorf("expected no messages for a session without tracking, got %q", writer.messages)
+	}
+
+	reader.messages, bcast.messages = nil, nil
+	d.HandleRequest(reader, "GET", []string{"user:1"})
+	d.HandleRequest(writer, "FLUSHALL", nil)
+	if len(reader.messages) != 2 || reader.messages[0] != invalidation(nil) {
+		t.Errorf("expected invalidation of all keys in every database, got %q", reader.messages)
+	}
+
+	reader.messages = nil
+	d.HandleRequest(writer, "SET", []string{"user:1", "a"})
+	d.HandleRequest(reader, "GET", []string{"user:1"})
+	d.HandleRequest(reader, "CLIENT", []string{"TRACKING", "OFF"})
+	d.HandleRequest(writer, "SET", []string{"user:1", "b"})
+	if len(reader.messages) != 0 {
+		t.Errorf("expected no messages after tracking is off, got %q", reader.messages)
+	}
+	for _, closer := range bcast.closers {
+		closer()
+	}
+	bcast.messages = nil
+	d.HandleRequest(writer, "SET", []string{"user:1", "c"})
+	if len(bcast.messages) != 0 {
+		t.Errorf("expected no messages after the session is closed, got %q", bcast.messages)
+	}
+}
+
+func TestTrackingExpire(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	d.HandleRequest(session, "CLIENT", []string{"TRACKING", "ON"})
+	d.HandleRequest(session, "SET", []string{"key", "value", "EX", "100"})
+	d.HandleRequest(session, "GET", []string{"key"})
+	db := d.(*databases).db(0)
+	db.Exps.m.Lock()
+	db.setExpiresAt("key", time.Now().Add(-time.Second))
+	db.Exps.m.Unlock()
+	resp, err := d.HandleRequest(session, "GET", []string{"key"})
+	if err != nil || resp != "(nil)" {
+		t.Errorf("expected (nil), got %v %v", resp, err)
+	}
+	expected := []string{invalidation([]interface{}{"key"})}
+	if !reflect.DeepEqual(session.messages, expected) {
+		t.Errorf("expected %q, got %q", expected, session.messages)
+	}
+}
+
+func TestTrackingLoad(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	db := d.(*databases).db(0)
+	d.HandleRequest(session, "SET", []string{"key", "value"})
+	if _, err := text(db.Save([]string{"trackingsave"})); err != nil {
+		t.Fatal(err)
+	}
+	d.HandleRequest(session, "CLIENT", []string{"TRACKING", "ON"})
+	d.HandleRequest(session, "GET", []string{"key"})
+	if resp, err := text(db.Load([]string{"trackingsave"})); err != nil || resp != "OK" {
+		t.Fatalf("expected OK, got %v %v", resp, err)
+	}
+	expected := []string{invalidation(nil)}
+	if !reflect.DeepEqual(session.messages, expected) {
+		t.Errorf("expected %q, got %q", expected, session.messages)
+	}
+}
+
+func TestTrackingKeysRead(t *testing.T) {
+	d := NewDatabases(1)
+	reader, writer := &testSession{}, &testSession{}
+	requests := []struct {
+		session *testSession
+		method  string
+		args    []string
+	}{
+		{writer, "SET", []string{"script", "a"}},
+		{writer, "SET", []string{"queued", "a"}},
+		{writer, "SET", []string{"listed", "a"}},
+		{writer, "TS.ADD", []string{"series", "10", "1", "LABELS", "type", "latency"}},
+		{writer, "HSET", []string{"user:1", "name", "alice"}},
+		{writer, "FT.CREATE", []string{"users", "PREFIX", "1", "user:", "SCHEMA", "name", "TAG"}},
+		{writer, "XADD", []string{"stream", "1-1", "field", "value"}},
+		{reader, "CLIENT", []string{"TRACKING", "ON"}},
+		{reader, "EVAL", []string{"return cache.call('GET', KEYS[1])", "1", "script"}},
+		{reader, "MULTI", nil},
+		{reader, "GET", []string{"queued"}},
+		{reader, "EXEC", nil},
+		{reader, "KEYS", []string{"list*"}},
+		{reader, "TS.MRANGE", []string{"-", "+", "FILTER", "type=latency"}},
+		{reader, "FT.SEARCH", []string{"users", "@name:{alice}"}},
+		{reader, "XREAD", []string{"STREAMS", "stream", "0"}},
+	}
+	for _, request := range requests {
+		if _, err := d.HandleRequest(request.session, request.method, request.args); err != nil {
+			t.Fatalf("%v %v: %v", request.method, request.args, err)
+		}
+	}
+	if len(reader.messages) != 0 {
+		t.Fatalf("expected no messages before writes, got %q", reader.messages)
+	}
+	for _, key := range []string{"script", "queued", "listed", "series", "user:1", "stream"} {
+		reader.messages = nil
+		d.HandleRequest(writer, "DEL", []string{key})
+		expected := []string{invalidation([]interface{}{key})}
+		if !reflect.DeepEqual(reader.messages, expected) {
+			t.Errorf("expected %q, got %q", expected, reader.messages)
+		}
+	}
+}
+
+func TestTrackingErrors(t *testing.T) {
+	d := NewDatabases(1)
+	session := &testSession{}
+	for _, args := range [][]string{
+		{"TRACKING"},
+		{"TRACKING", "MAYBE"},
+		{"TRACKING", "ON", "PREFIX", "a"},
+		{"TRACKING", "ON", "BCAST", "PREFIX"},
+		{"TRACKING", "OFF", "BCAST"},
+		{"TRACKINGINFO", "extra"},
+		{"KILL"},
+	} {
+		if _, err := d.HandleRequest(session, "CLIENT", args); err == nil {
+			t.Errorf("%v: expected error", args)
+		}
+	}
+	d.HandleRequest(session, "CLIENT", []string{"TRACKING", "ON"})
+	if _, err := d.HandleRequest(session, "CLIENT", []string{"TRACKING", "ON", "BCAST"}); err != errTrackingMode {
+		t.Errorf("expected %v, got %v", errTrackingMode, err)
+	}
+	d.HandleRequest(session, "MULTI", nil)
+	if _, err := d.HandleRequest(session, "CLIENT", []string{"TRACKING", "OFF"}); err == nil {
+		t.Errorf("expected CLIENT to be rejected in transactions")
+	}
+}
@@ -0,0 +1,143 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"sync"
+)
+
+var (
+	errNestedMulti  = errors.New("MULTI calls can not be nested")
+	errExecAbort    = errors.New("EXECABORT Transaction discarded because of previous errors")
+	errWatchInMulti = errors.New("WATCH inside MULTI is not allowed")
+)
+
+// noLock replaces the locks of the cache while a transaction is executed, the real locks are held by exec
+type noLock struct{}
+
+func (noLock) Lock()    {}
+func (noLock) Unlock()  {}
+func (noLock) RLock()   {}
+func (noLock) RUnlock() {}
+
+// exec runs the commands one after another on copies of the databases given by indexes, so that no other command
+// is executed in between. Errors of single commands are returned as replies and do not stop the rest. Both mutexes
+// of the databases must be locked before calling exec
+func (d *databases) exec(session Session, queued [][]string, indexes []int) []interface{} {
+	view := *d
+	view.m = &sync.RWMutex{}
+	view.dbs = make([]*cache, len(d.dbs))
+	copy(view.dbs, d.dbs)
+	tracks := d.tracking.tracks(session)
+	for _, index := range indexes {
+		view.dbs[index] = d.dbs[index].unlocked()
+		if tracks {
+			view.dbs[index].reader = session
+		}
+	}
+	replies := make([]interface{}, len(queued))
+	for i, args := range queued {
+		var reply interface{}
+		var err error
+		if command := commands[args[0]]; command.serve != nil {
+			reply, err = command.serve(&view, session, args[1:])
+		} else {
+			reply, err = view.dbs[session.DB()].call(args[0], args[1:])
+		}
+		if err != nil {
+			reply = err
+		}
+		replies[i] = reply
+	}
+	for _, index := range indexes {
+		d.dbs[index].update(view.dbs[index])
+	}
+	return replies
+}
+
+// unlocked returns a copy of the cache sharing its data, but not its locks, on which commands run while the real
+// locks are held. Blocking commands of the copy return immediately
+func (c *cache) unlocked() *cache {
+	view := *c
+	view.m = noLock{}
+	view.Exps.m = noLock{}
+	view.transaction = true
+	return &view
+}
+
+// update takes the state of the copy returned by unlocked, commands like FLUSHDB replace maps and slices of the copy
+func (c *cache) update(view *cache) {
+	c.Fields, c.slots, c.timeSeries, c.indexes = view.Fields, view.slots, view.timeSeries, view.indexes
+	c.Exps.Expirations, c.Exps.Indexes = view.Exps.Expirations, view.Exps.Indexes
+	c.dirty = view.dirty
+}
+
+// Multi starts a transaction, following commands are queued until EXEC or DISCARD
+func (d *databases) Multi(session Session, args []string) (response interface{}, err error) {
+	if session.Queued() != nil {
+		err = errNestedMulti
+		return
+	}
+	session.Multi()
+	response = formatted("OK")
+	return
+}
+
+// transactionCommands are executed immediately inside of a transaction
+var transactionCommands = map[string]bool{"MULTI": true, "EXEC": true, "DISCARD": true}
+
+// transactionServes are commands executed by databases which are queued like other commands. EXEC locks all
+// databases to run them
+var transactionServes = map[string]bool{"EVAL": true, "EVALSHA": true, "FCALL": true, "SELECT": true, "MOVE": true,
+	"PUBLISH": true, "SPUBLISH": true}
+
+// enqueue queues the command of a transaction. WATCH is refused, other commands executed by databases which can
+// not be queued abort the transaction
+func (d *databases) enqueue(session Session, command *Command, args []string) (response interface{}, err error) {
+	if command.Name == "WATCH" {
+		err = errWatchInMulti
+		return
+	}
+	if command.serve != nil && !transactionServes[command.Name] {
+		err = errors.New(fmt.Sprintf("%v is not allowed in transactions", command.Name))
+		session.Abort()
+		return
+	}
+	session.Enqueue(append([]string{command.Name}, args...))
+	response = formatted("QUEUED")
+	return
+}
+
+// Exec executes the queued commands atomically and returns their replies, or nil if a watched key was modified.
+// Keys are unwatched in any case
+func (d *databases) Exec(session Session, args []string) (response interface{}, err error) {
+	queued := session.Queued()
+	if queued == nil {
+		err = errors.New("EXEC without MULTI")
+		return
+	}
+	aborted := session.Aborted()
+	session.Discard()
+	defer d.unwatch(session)
+	if aborted {
+		err = errExecAbort
+		return
+	}
+	replies, ok := d.execWatched(session, queued)
+	if !ok {
+		response = nil
+		return
+	}
+	response = replies
+	return
+}
+func (d *databases) Discard(session Session, args []string) (response interface{}, err error) {
+	if session.Queued() == nil {
+		err = errors.New("DISCARD without MULTI")
+		return
+	}
+	session.Discard()
+	d.unwatch(session)
+	response = formatted("OK")
+	return
+}
@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestTransaction(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"MULTI", nil, "OK"},
+		{"SET", []string{"a", "1"}, "QUEUED"},
+		{"RPUSH", []string{"list", "x", "y"}, "QUEUED"},
+		{"HSET", []string{"a", "f", "v"}, "QUEUED"},
+		{"GET", []string{"a"}, "QUEUED"},
+		{"FLUSHDB", nil, "QUEUED"},
+		{"SET", []string{"b", "2"}, "QUEUED"},
+		{"EXEC", nil, "1) OK\n2) (integer) 2\n3) (error) Requested field is of type string\n4) \"1\"\n5) OK\n6) OK"},
+		{"DBSIZE", nil, "(integer) 1"},
+		{"MULTI", nil, "OK"},
+		{"SET", []string{"c", "3"}, "QUEUED"},
+		{"DISCARD", nil, "OK"},
+		{"EXISTS", []string{"c"}, "(integer) 0"},
+		{"MULTI", nil, "OK"},
+		{"EXEC", nil, "(empty array)"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+
+	// errors while queueing abort the whole transaction
+	d.HandleRequest(session, "MULTI", nil)
+	d.HandleRequest(session, "SET", []string{"c", "3"})
+	if _, err := d.HandleRequest(session, "NOSUCHCOMMAND", nil); err == nil {
+		t.Errorf("expected error on unknown command")
+	}
+	if _, err := d.HandleRequest(session, "SWAPDB", []string{"0", "1"}); err == nil {
+		t.Errorf("expected error on SWAPDB in a transaction")
+	}
+	if _, err := d.HandleRequest(session, "MULTI", nil); err != errNestedMulti {
+		t.Errorf("expected %v, got %v", errNestedMulti, err)
+	}
+	if _, err := d.HandleRequest(session, "EXEC", nil); err != errExecAbort {
+		t.Errorf("expected %v, got %v", errExecAbort, err)
+	}
+	if resp, _ := d.HandleRequest(session, "EXISTS", []string{"c"}); resp != "(integer) 0" {
+		t.Errorf("expected the aborted transaction not to be executed, got %v", resp)
+	}
+	for _, method := range []string{"EXEC", "DISCARD"} {
+		if _, err := d.HandleRequest(session, method, nil); err == nil {
+			t.Errorf("expected error on %v without MULTI", method)
+		}
+	}
+}
+
+func TestTransactionDatabaseCommands(t *testing.T) {
+	d := NewDatabases(2)
+	session := &testSession{}
+	steps := []struct {
+		method   string
+		args     []string
+		expected string
+	}{
+		{"MULTI", nil, "OK"},
+		{"SET", []string{"a", "1"}, "QUEUED"},
+		{"MOVE", []string{"a", "1"}, "QUEUED"},
+		{"SELECT", []string{"1"}, "QUEUED"},
+		{"EVAL", []string{"return cache.call('GET', KEYS[1])", "1", "a"}, "QUEUED"},
+		{"PUBLISH", []string{"news", "hello"}, "QUEUED"},
+		{"SET", []string{"b", "2"}, "QUEUED"},
+		{"EXEC", nil, "1) OK\n2) (integer) 1\n3) OK\n4) \"1\"\n5) (integer) 0\n6) OK"},
+		{"DBSIZE", nil, "(integer) 2"},
+	}
+	for _, step := range steps {
+		resp, err := d.HandleRequest(session, step.method, step.args)
+		if err != nil || resp != step.expected {
+			t.Errorf("%v %v: expected %v, got %v %v", step.method, step.args, step.expected, resp, err)
+		}
+	}
+	if session.DB() != 1 {
+		t.Errorf("expected SELECT in the transaction to change the database, got %v", session.DB())
+	}
+
+	// WATCH is refused without discarding the transaction
+	d.HandleRequest(session, "MULTI", nil)
+	d.HandleRequest(session, "SET", []string{"c", "3"})
+	if _, err := d.HandleRequest(session, "WATCH", []string{"c"}); err != errWatchInMulti {
+		t.Errorf("expected %v, got %v", errWatchInMulti, err)
+	}
+	if resp, err := d.HandleRequest(session, "EXEC", nil); err != nil || resp != "1) OK" {
+		t.Errorf("expected the transaction to be executed, got %v %v", resp, err)
+	}
+}
+
+func TestTransactionIsolation(t *testing.T) {
+	d := NewDatabases(1)
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i += 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			session := &testSession{}
+			for j := 0; j < 50; j += 1 {
+				d.HandleRequest(session, "MULTI", nil)
+				d.HandleRequest(session, "RPUSH", []string{"list", "x"})
+				d.HandleRequest(session, "RPUSH", []string{"list", "x"})
+				resp, err := d.HandleRequest(session, "EXEC", nil)
+				lines := strings.Split(resp, "\n")
+				if err != nil || len(lines) != 2 {
+					t.Errorf("unexpected reply %v %v", resp, err)
+					return
+				}
+				// no other command may run between the queued commands
+				before, _ := strconv.Atoi(strings.TrimPrefix(lines[0], "1) (integer) "))
+				after, _ := strconv.Atoi(strings.TrimPrefix(lines[1], "2) (integer) "))
+				if after != before+1 {
+					t.Errorf("expected the pushes to be consecutive, got %v", resp)
+				}
+			}
+		}()
+	}
+	wg.Wait()
+	if length := d.(*databases).db(0).read("list").(RList).Value.Len(); length != 1000 {
+		t.Errorf("expected 1000 elements, got %v", length)
+	}
+}
@@ -0,0 +1,401 @@
+package cache
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	vectorDefaultM              = 16
+	vectorDefaultEfConstruction = 200
+	vectorDefaultEf             = 10
+	vectorDefaultCount          = 10
+)
+
+var errVectorNotFound = errors.New("key does not exist")
+
+// vector distances, lower values mean closer vectors
+func cosineDistance(a, b []float32) float64 {
+	var dot, normA, normB float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+		normA += float64(a[i]) * float64(a[i])
+		normB += float64(b[i]) * float64(b[i])
+	}
+	if normA == 0 || normB == 0 {
+		return 1
+	}
+	return 1 - dot/math.Sqrt(normA*normB)
+}
+func l2Distance(a, b []float32) float64 {
+	var sum float64
+	for i := range a {
+		d := float64(a[i]) - float64(b[i])
+		sum += d * d
+	}
+	return sum
+}
+func innerProductDistance(a, b []float32) float64 {
+	var dot float64
+	for i := range a {
+		dot += float64(a[i]) * float64(b[i])
+	}
+	return 1 - dot
+}
+
+// VectorSet stores float32 vectors of the same dimension by element name and answers nearest neighbour
+// queries. FLAT sets compare the query with every vector, HNSW sets use an approximate graph index,
+// which is not saved and is rebuilt on loading
+type VectorSet struct {
+	Dim            int
+	Distance       string
+	Algorithm      string
+	M              int
+	EfConstruction int
+	Vectors        map[string][]float32
+	index          *hnswIndex
+}
+
+func NewVectorSet(dim int, distance, algorithm string, m, efConstruction int) *VectorSet {
+	set := &VectorSet{dim, distance, algorithm, m, efConstruction, make(map[string][]float32), nil}
+	set.buildIndex()
+	return set
+}
+
+func (s *VectorSet) distance() func(a, b []float32) float64 {
+	switch s.Distance {
+	case "L2":
+		return l2Distance
+	case "IP":
+		return innerProductDistance
+	default:
+		return cosineDistance
+	}
+}
+
+// buildIndex creates the HNSW index of the set and adds all vectors to it in the order of element names
+func (s *VectorSet) buildIndex() {
+	if s.Algorithm != "HNSW" {
+		return
+	}
+	s.index = newHNSWIndex(s.M, s.EfConstruction, s.distance())
+	elements := make([]string, 0, len(s.Vectors))
+	for element := range s.Vectors {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+	for _, element := range elements {
+		s.index.insert(element, s.Vectors[element])
+	}
+}
+
+// add sets the vector of the element, returns false if the element existed
+func (s *VectorSet) add(element string, vector []float32) bool {
+	_, existed := s.Vectors[element]
+	s.Vectors[element] = vector
+	if s.index != nil {
+		s.index.remove(element)
+		s.index.insert(element, vector)
+	}
+	return !existed
+}
+func (s *VectorSet) remove(element string) bool {
+	if _, ok := s.Vectors[element]; !ok {
+		return false
+	}
+	delete(s.Vectors, element)
+	if s.index != nil {
+		s.index.remove(element)
+	}
+	return true
+}
+
+// search returns up to k elements closest to the query
+func (s *VectorSet) search(query []float32, k, ef int) []hnswCandidate {
+	if s.index != nil {
+		return s.index.search(query, k, ef)
+	}
+	distance := s.distance()
+	results := make([]hnswCandidate, 0, len(s.Vectors))
+	for element, vector := range s.Vectors {
+		results = append(results, hnswCandidate{&hnswNode{element: element}, distance(query, vector)})
+	}
+	sort.Slice(results, func(i, j int) bool {
+		if results[i].distance == results[j].distance {
+			return results[i].node.element < results[j].node.element
+		}
+		return results[i].distance < results[j].distance
+	})
+	if len(results) > k {
+		results = results[:k]
+	}
+	return results
+}
+
+// parseVector parses VALUES dim value [value ...] starting at args[i], returns the index of the next argument
+func parseVector(args []string, i int) (vector []float32, next int, err error) {
+	if i+1 >= len(args) || strings.ToUpper(args[i]) != "VALUES" {
+		err = errors.New("syntax error")
+		return
+	}
+	dim, err := strconv.Atoi(args[i+1])
+	if err != nil || dim < 1 || i+2+dim > len(args) {
+		err = errors.New("invalid vector dimension")
+		return
+	}
+	vector = make([]float32, dim)
+	for j := range vector {
+		var value float64
+		value, err = strconv.ParseFloat(args[i+2+j], 32)
+		if err != nil {
+			err = errors.New("invalid vector value")
+			return
+		}
+		vector[j] = float32(value)
+	}
+	return vector, i + 2 + dim, nil
+}
+
+// Mutex must be locked before calling getVectorSet. Returns nil if key is not set
+func (c *cache) getVectorSet(key string) (set *VectorSet, err error) {
+	stored := c.read(key)
+	switch stored.(type) {
+	case nil:
+	case *VectorSet:
+		set = stored.(*VectorSet)
+	default:
+		err = errors.New(fmt.Sprintf("Requested field is of type %T", stored))
+	}
+	return
+}
+
+// VAdd adds or updates the vector of the element. Options set the distance and the index of a new set
+func (c *cache) VAdd(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("VADD")
+	vector, i, err := parseVector(args, 1)
+	if err != nil {
+		return
+	}
+	if i >= len(args) {
+		err = formatErr
+		return
+	}
+	element := args[i]
+	distance, algorithm, m, efConstruction := "COSINE", "FLAT", vectorDefaultM, vectorDefaultEfConstruction
+	for i += 1; i < len(args); i += 2 {
+		if i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		value := strings.ToUpper(args[i+1])
+		switch strings.ToUpper(args[i]) {
+		case "DISTANCE":
+			if value != "COSINE" && value != "L2" && value != "IP" {
+				err = errors.New("unknown distance")
+				return
+			}
+			distance = value
+		case "ALGORITHM":
+			if value != "FLAT" && value != "HNSW" {
+				err = errors.New("unknown algorithm")
+				return
+			}
+			algorithm = value
+		case "M":
+			m, err = strconv.Atoi(value)
+			if err != nil || m < 2 {
+				err = errors.New("invalid M")
+				return
+			}
+		case "EF":
+			efConstruction, err = strconv.Atoi(value)
+			if err != nil || efConstruction < 1 {
+				err = errors.New("invalid EF")
+				return
+			}
+		default:
+			err = formatErr
+			return
+		}
+	}
+	c.m.Lock()
+	defer c.m.Unlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	if set == nil {
+		set = NewVectorSet(len(vector), distance, algorithm, m, efConstruction)
+		c.write(args[0], set)
+	}
+	if len(vector) != set.Dim {
+		err = errors.New(fmt.Sprintf("Vector dimension mismatch - got %v but set has %v", len(vector), set.Dim))
+		return
+	}
+	c.modified(args[0])
+	c.notify(moduleClass, "vadd", args[0])
+	response = int64(0)
+	if set.add(element, vector) {
+		response = int64(1)
+	}
+	return
+}
+func (c *cache) VRem(args []string) (response interface{}, err error) {
+	c.m.Lock()
+	defer c.m.Unlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	response = int64(0)
+	if set != nil && set.remove(args[1]) {
+		c.modified(args[0])
+		c.notify(moduleClass, "vrem", args[0])
+		response = int64(1)
+		if len(set.Vectors) == 0 {
+			c.delete(args[0])
+			c.notify(genericClass, "del", args[0])
+		}
+	}
+	return
+}
+
+// VSim returns elements closest to the given vector or to the vector of the given element
+func (c *cache) VSim(args []string) (response interface{}, err error) {
+	formatErr := syntaxError("VSIM")
+	var vector []float32
+	var element string
+	i := 3
+	if strings.ToUpper(args[1]) == "ELE" {
+		element = args[2]
+	} else {
+		vector, i, err = parseVector(args, 1)
+		if err != nil {
+			return
+		}
+	}
+	withScores, count, ef := false, vectorDefaultCount, vectorDefaultEf
+	for ; i < len(args); i += 1 {
+		option := strings.ToUpper(args[i])
+		if option == "WITHSCORES" {
+			withScores = true
+			continue
+		}
+		if (option != "COUNT" && option != "EF") || i+1 >= len(args) {
+			err = formatErr
+			return
+		}
+		var value int
+		value, err = strconv.Atoi(args[i+1])
+		if err != nil || value < 1 {
+			err = errors.New(fmt.Sprintf("invalid %v", option))
+			return
+		}
+		if option == "COUNT" {
+			count = value
+		} else {
+			ef = value
+		}
+		i += 1
+	}
+	c.m.RLock()
+	defer c.m.RUnlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	if set == nil {
+		response = nil
+		return
+	}
+	if vector == nil {
+		var ok bool
+		vector, ok = set.Vectors[element]
+		if !ok {
+			err = errors.New("element not found in set")
+			return
+		}
+	}
+	if len(vector) != set.Dim {
+		err = errors.New(fmt.Sprintf("Vector dimension mismatch - got %v but set has %v", len(vector), set.Dim))
+		return
+	}
+	results := set.search(vector, count, ef)
+	res := make([]interface{}, 0, len(results)*2)
+	for _, result := range results {
+		res = append(res, result.node.element)
+		if withScores {
+			res = append(res, strconv.FormatFloat(result.distance, 'f', -1, 32))
+		}
+	}
+	response = res
+	return
+}
+func (c *cache) VEmb(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	if set == nil {
+		response = nil
+		return
+	}
+	vector, ok := set.Vectors[args[1]]
+	if !ok {
+		response = nil
+		return
+	}
+	values := make([]interface{}, len(vector))
+	for i := range vector {
+		values[i] = strconv.FormatFloat(float64(vector[i]), 'f', -1, 32)
+	}
+	response = values
+	return
+}
+func (c *cache) VCard(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	count := 0
+	if set != nil {
+		count = len(set.Vectors)
+	}
+	response = int64(count)
+	return
+}
+func (c *cache) VInfo(args []string) (response interface{}, err error) {
+	c.m.RLock()
+	defer c.m.RUnlock()
+	set, err := c.getVectorSet(args[0])
+	if err != nil {
+		return
+	}
+	if set == nil {
+		err = errVectorNotFound
+		return
+	}
+	info := []interface{}{
+		"size", len(set.Vectors),
+		"dim", set.Dim,
+		"distance", set.Distance,
+		"algorithm", set.Algorithm,
+	}
+	if set.index != nil {
+		levels := 0
+		if set.index.entry != nil {
+			levels = len(set.index.entry.neighbors)
+		}
+		info = append(info, "m", set.M, "ef-construction", set.EfConstruction, "levels", levels)
+	}
+	response = info
+	return
+}
@@ -0,0 +1,131 @@
+package cache
+
+import (
+	"os"
+	"testing"
+)
+
+func TestVAddSim(t *testing.T) {
+	c := (NewCache()).(*cache)
+
+	for element, values := range map[string][]string{
+		"north": {"0", "1"},
+		"east":  {"1", "0"},
+		"ne":    {"1", "1"},
+		"far":   {"10", "10"},
+	} {
+		args := append([]string{"points", "VALUES", "2"}, values...)
+		resp, err := text(c.VAdd(append(args, element, "DISTANCE", "L2")))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != "(integer) 1" {
+			t.Errorf("expected (integer) 1, got %v", resp)
+		}
+	}
+	_, err := text(c.VAdd([]string{"points", "VALUES", "3", "1", "2", "3", "bad"}))
+	if err == nil {
+		t.Error("expected error on dimension mismatch")
+	}
+
+	resp, err := text(c.VSim([]string{"points", "VALUES", "2", "0.75", "0.625", "COUNT", "2", "WITHSCORES"}))
+	if err != nil {
+		t.Error(err)
+	}
+	expected := "1) \"ne\"\n2) \"0.203125\"\n3) \"east\"\n4) \"0.453125\""
+	if resp != expected {
+		t.Errorf("expected:\n%v, got:\n%v", expected, resp)
+	}
+	resp, err = text(c.VSim([]string{"points", "ELE", "far", "COUNT", "2"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"far\"\n2) \"ne\"" {
+		t.Errorf("expected 1) \"far\"\n2) \"ne\", got %v", resp)
+	}
+
+	resp, err = text(c.VEmb([]string{"points", "ne"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"1\"\n2) \"1\"" {
+		t.Errorf("expected 1) \"1\"\n2) \"1\", got %v", resp)
+	}
+	resp, err = text(c.VRem([]string{"points", "ne"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 1" {
+		t.Errorf("expected (integer) 1, got %v", resp)
+	}
+	resp, err = text(c.VCard([]string{"points"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "(integer) 3" {
+		t.Errorf("expected (integer) 3, got %v", resp)
+	}
+}
+
+func TestVectorDistances(t *testing.T) {
+	a, b := []float32{1, 0}, []float32{0.5, 0.5}
+	tests := []struct {
+		distance func(a, b []float32) float64
+		expected float64
+	}{
+		{cosineDistance, 0.29289321881345254},
+		{l2Distance, 0.5},
+		{innerProductDistance, 0.5},
+	}
+	for _, test := range tests {
+		if d := test.distance(a, b); d-test.expected > 1e-9 || test.expected-d > 1e-9 {
+			t.Errorf("expected %v, got %v", test.expected, d)
+		}
+	}
+	c := (NewCache()).(*cache)
+	c.VAdd([]string{"docs", "VALUES", "2", "1", "0", "a", "DISTANCE", "IP"})
+	c.VAdd([]string{"docs", "VALUES", "2", "2", "2", "b"})
+	resp, err := text(c.VSim([]string{"docs", "VALUES", "2", "1", "0", "COUNT", "1"}))
+	if err != nil {
+		t.Error(err)
+	}
+	if resp != "1) \"b\"" {
+		t.Errorf("expected 1) \"b\", got %v", resp)
+	}
+}
+
+func TestVectorSaveLoad(t *testing.T) {
+	c := (NewCache()).(*cache)
+	c.VAdd([]string{"flat", "VALUES", "2", "1", "0", "a"})
+	c.VAdd([]string{"hnsw", "VALUES", "2", "1", "0", "a", "ALGORITHM", "HNSW", "M", "4"})
+	c.VAdd([]string{"hnsw", "VALUES", "2", "0", "1", "b"})
+
+	_, err := text(c.Save([]string{"vectorsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	c = (NewCache()).(*cache)
+	_, err = text(c.Load([]string{"vectorsave"}))
+	if err != nil {
+		t.Error(err)
+	}
+	for _, key := range []string{"flat", "hnsw"} {
+		resp, err := text(c.VSim([]string{key, "VALUES", "2", "0.9", "0.1", "COUNT", "1"}))
+		if err != nil {
+			t.Error(err)
+		}
+		if resp != "1) \"a\"" {
+			t.Errorf("%v: expected 1) \"a\", got %v", key, resp)
+		}
+	}
+	resp, _ := text(c.VInfo([]string{"hnsw"}))
+	expected := "1) \"size\"\n2) (integer) 2\n3) \"dim\"\n4) (integer) 2\n5) \"distance\"\n6) \"COSINE\"\n7) \"algorithm\"\n8) \"HNSW\""
+	if len(resp) < len(expected) || resp[:len(expected)] != expected {
+		t.Errorf("expected info to start with:\n%v, got:\n%v", expected, resp)
+	}
+
+	err = os.Remove("./saves/vectorsave")
+	if err != nil {
+		t.Error(err)
+	}
+}
@@ -0,0 +1,153 @@
+package cache
+
+import (
+	"sort"
+	"sync"
+)
+
+// watchedKey is a key watched by at least one session
+type watchedKey struct {
+	version  uint64
+	watchers int
+}
+
+// watch is a key watched by a session together with the database and the version seen by WATCH
+type watch struct {
+	index   int
+	db      *cache
+	key     string
+	version uint64
+}
+
+// sessionWatches are the keys watched by each session. Sessions are kept until their connection is closed,
+// so that the close callback is registered once
+type sessionWatches struct {
+	m         *sync.Mutex
+	bySession map[Session][]watch
+}
+
+// Mutex must be locked before calling modified. Counts the modification, changes the version of the key if it is watched
+// and invalidates the key in clients tracking it
+func (c *cache) modified(key string) {
+	c.dirty += 1
+	if w, ok := c.watched[key]; ok {
+		w.version += 1
+	}
+	if c.tracking != nil {
+		c.tracking.invalidate(key)
+	}
+}
+
+// Mutex must be locked before calling watch. Returns the current version of the key
+func (c *cache) watch(key string) uint64 {
+	w, ok := c.watched[key]
+	if !ok {
+		w = &watchedKey{}
+		c.watched[key] = w
+	}
+	w.watchers += 1
+	return w.version
+}
+
+// Mutex must be locked before calling unwatch
+func (c *cache) unwatch(key string) {
+	w, ok := c.watched[key]
+	if !ok {
+		return
+	}
+	w.watchers -= 1
+	if w.watchers == 0 {
+		delete(c.watched, key)
+	}
+}
+
+// Watch remembers versions of the keys, EXEC of the session fails if any of them is modified before it
+func (d *databases) Watch(session Session, args []string) (response interface{}, err error) {
+	index := session.DB()
+	db := d.db(index)
+	watches := make([]watch, len(args))
+	db.m.Lock()
+	for i, key := range args {
+		watches[i] = watch{index, db, key, db.watch(key)}
+	}
+	db.m.Unlock()
+	d.watches.m.Lock()
+	if _, ok := d.watches.bySession[session]; !ok {
+		session.OnClose(func() {
+			d.unwatch(session)
+			d.watches.m.Lock()
+			delete(d.watches.bySession, session)
+			d.watches.m.Unlock()
+		})
+	}
+	d.watches.bySession[session] = append(d.watches.bySession[session], watches...)
+	d.watches.m.Unlock()
+	response = formatted("OK")
+	return
+}
+
+// Unwatch forgets all keys watched by the session
+func (d *databases) Unwatch(session Session, args []string) (response interface{}, err error) {
+	d.unwatch(session)
+	response = formatted("OK")
+	return
+}
+func (d *databases) unwatch(session Session) {
+	d.watches.m.Lock()
+	watches := d.watches.bySession[session]
+	if watches != nil {
+		d.watches.bySession[session] = nil
+	}
+	d.watches.m.Unlock()
+	for _, w := range watches {
+		w.db.m.Lock()
+		w.db.unwatch(w.key)
+		w.db.m.Unlock()
+	}
+}
+
+// execWatched executes the queued commands on the selected database, ok is false if a watched key was modified,
+// or its database was swapped or loaded. Databases of watched keys stay locked until the commands are executed
+func (d *databases) execWatched(session Session, queued [][]string) (replies []interface{}, ok bool) {
+	d.watches.m.Lock()
+	watches := d.watches.bySession[session]
+	d.watches.m.Unlock()
+	d.m.RLock()
+	defer d.m.RUnlock()
+	selected := map[int]bool{session.DB(): true}
+	// commands executed by databases may select or change any database
+	for _, command := range queued {
+		if commands[command[0]].serve != nil {
+			for index := range d.dbs {
+				selected[index] = true
+			}
+			break
+		}
+	}
+	for _, w := range watches {
+		if d.dbs[w.index] != w.db {
+			return
+		}
+		selected[w.index] = true
+	}
+	// databases are locked in the order of their indexes
+	indexes := make([]int, 0, len(selected))
+	for index := range selected {
+		indexes = append(indexes, index)
+	}
+	sort.Ints(indexes)
+	for _, index := range indexes {
+		d.dbs[index].Exps.m.Lock()
+		defer d.dbs[index].Exps.m.Unlock()
+	}
+	for _, index := range indexes {
+		d.dbs[index].m.Lock()
+		defer d.dbs[index].m.Unlock()
+	}
+	for _, w := range watches {
+		if watched, exists := w.db.watched[w.key]; !exists || watched.version != w.version {
+			return
+		}
+	}
+	return d.exec(session, queued, indexes), true
+}
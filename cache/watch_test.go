@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+// watchedExec runs WATCH key, then the modification by another session, then a transaction setting "result"
+func watchedExec(t *testing.T, d Databases, session *testSession, key string, modify func()) string {
+	if resp, err := d.HandleRequest(session, "WATCH", []string{key, "other"}); err != nil || resp != "OK" {
+		t.Errorf("expected OK, got %v %v", resp, err)
+	}
+	modify()
+	d.HandleRequest(session, "MULTI", nil)
+	d.HandleRequest(session, "SET", []string{"result", "done"})
+	resp, err := d.HandleRequest(session, "EXEC", nil)
+	if err != nil {
+		t.Error(err)
+	}
+	return resp
+}
+
+func TestWatch(t *testing.T) {
+	d := NewDatabases(2)
+	session, other := &testSession{}, &testSession{}
+	d.HandleRequest(other, "SET", []string{"key", "value"})
+	d.HandleRequest(other, "RPUSH", []string{"list", "a"})
+	db := d.(*databases).db(0)
+
+	modifications := []struct {
+		name   string
+		key    string
+		modify func()
+	}{
+		{"SET", "key", func() { d.HandleRequest(other, "SET", []string{"key", "changed"}) }},
+		{"RPUSH", "list", func() { d.HandleRequest(other, "RPUSH", []string{"list", "b"}) }},
+		{"HSET of a missing key", "hash", func() { d.HandleRequest(other, "HSET", []string{"hash", "f", "v"}) }},
+		{"DEL", "hash", func() { d.HandleRequest(other, "DEL", []string{"hash"}) }},
+		{"EXPIRE", "list", func() { d.HandleRequest(other, "EXPIRE", []string{"list", "100"}) }},
+		{"FLUSHDB", "list", func() { d.HandleRequest(other, "FLUSHDB", nil) }},
+		{"expiration", "temp", func() {
+			d.HandleRequest(other, "SET", []string{"temp", "value"})
+			db.Exps.m.Lock()
+			db.setExpiresAt("temp", time.Now().Add(-time.Second))
+			db.Exps.m.Unlock()
+			db.clean()
+		}},
+		{"SWAPDB", "key", func() { d.HandleRequest(other, "SWAPDB", []string{"0", "1"}) }},
+	}
+	for _, m := range modifications {
+		d.HandleRequest(other, "DEL", []string{"result"})
+		if resp := watchedExec(t, d, session, m.key, m.modify); resp != "(nil)" {
+			t.Errorf("%v: expected (nil), got %v", m.name, resp)
+		}
+		if resp, _ := d.HandleRequest(other, "EXISTS", []string{"result"}); resp != "(integer) 0" {
+			t.Errorf("%v: expected the transaction not to be executed", m.name)
+		}
+	}
+
+	unchanged := []struct {
+		name   string
+		modify func()
+	}{
+		{"no change", func() {}},
+		{"reading", func() { d.HandleRequest(other, "GET", []string{"key"}) }},
+		{"other key", func() { d.HandleRequest(other, "SET", []string{"another", "value"}) }},
+		{"other database", func() {
+			d.HandleRequest(other, "SELECT", []string{"1"})
+			d.HandleRequest(other, "SET", []string{"key", "value"})
+			d.HandleRequest(other, "SELECT", []string{"0"})
+		}},
+		{"UNWATCH", func() {
+			d.HandleRequest(session, "UNWATCH", nil)
+			d.HandleRequest(other, "SET", []string{"key", "changed"})
+		}},
+	}
+	for _, m := range unchanged {
+		if resp := watchedExec(t, d, session, "key", m.modify); resp != "1) OK" {
+			t.Errorf("%v: expected 1) OK, got %v", m.name, resp)
+		}
+	}
+	// the transaction itself does not fail the next one
+	if resp := watchedExec(t, d, session, "result", func() {}); resp != "1) OK" {
+		t.Errorf("expected 1) OK, got %v", resp)
+	}
+
+	d.HandleRequest(session, "WATCH", []string{"key"})
+	d.HandleRequest(session, "MULTI", nil)
+	if _, err := d.HandleRequest(session, "WATCH", []string{"key"}); err == nil {
+		t.Errorf("expected error on WATCH inside MULTI")
+	}
+	d.HandleRequest(session, "DISCARD", nil)
+	for _, c := range d.(*databases).dbs {
+		if len(c.watched) != 0 {
+			t.Errorf("expected keys to be unwatched after EXEC and DISCARD, got %v", c.watched)
+		}
+	}
+}
+
+func TestWatchLoad(t *testing.T) {
+	d := NewDatabases(1)
+	session, other := &testSession{}, &testSession{}
+	d.HandleRequest(other, "SET", []string{"key", "value"})
+	d.HandleRequest(other, "SAVE", []string{"watchsave"})
+	defer os.Remove("./saves/watchsave")
+	if resp := watchedExec(t, d, session, "key", func() { d.HandleRequest(other, "LOAD", []string{"watchsave"}) }); resp != "(nil)" {
+		t.Errorf("expected (nil) after LOAD, got %v", resp)
+	}
+
+	d.HandleRequest(session, "WATCH", []string{"key"})
+	if len(session.closers) != 1 {
+		t.Fatalf("expected one close callback, got %v", len(session.closers))
+	}
+	session.closers[0]()
+	if watched := d.(*databases).db(0).watched; len(watched) != 0 {
+		t.Errorf("expected keys to be unwatched when the connection is closed, got %v", watched)
+	}
+}
@@ -6,63 +6,519 @@ import (
 	"strings"
 )
 
+// joinArgs joins the arguments by spaces, arguments that contain spaces or start with a quote are quoted
+func joinArgs(args []string) string {
+	parts := make([]string, len(args))
+	for i, arg := range args {
+		if arg == "" || strings.Contains(arg, " ") || arg[0] == '"' || arg[0] == '\'' {
+			arg = `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(arg) + `"`
+		}
+		parts[i] = arg
+	}
+	return strings.Join(parts, " ")
+}
+
 func Keys(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("KEYS %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("KEYS %v\r\n", joinArgs(args))))
 	return err
 }
 func Del(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("DEL %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("DEL %v\r\n", joinArgs(args))))
 	return err
 }
 func Get(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("GET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("GET %v\r\n", joinArgs(args))))
 	return err
 }
 func Set(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("SET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("SET %v\r\n", joinArgs(args))))
 	return err
 }
 func HGet(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("HGET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("HGET %v\r\n", joinArgs(args))))
 	return err
 }
 func HSet(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("HSET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("HSET %v\r\n", joinArgs(args))))
 	return err
 }
 func LPush(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("LPUSH %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("LPUSH %v\r\n", joinArgs(args))))
 	return err
 }
 func RPush(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("RPUSH %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("RPUSH %v\r\n", joinArgs(args))))
 	return err
 }
 func LPop(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("LPOP %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("LPOP %v\r\n", joinArgs(args))))
 	return err
 }
 func RPop(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("RPOP %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("RPOP %v\r\n", joinArgs(args))))
 	return err
 }
 func LGet(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("LGET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("LGET %v\r\n", joinArgs(args))))
 	return err
 }
 func LSet(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("LSET %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("LSET %v\r\n", joinArgs(args))))
 	return err
 }
 func Expire(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("EXPIRE %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("EXPIRE %v\r\n", joinArgs(args))))
 	return err
 }
 func Save(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("SAVE %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("SAVE %v\r\n", joinArgs(args))))
 	return err
 }
 func Load(conn net.Conn, args []string) error {
-	_, err := conn.Write([]byte(fmt.Sprintf("LOAD %v\r\n", strings.Trim(fmt.Sprint(args), "[]"))))
+	_, err := conn.Write([]byte(fmt.Sprintf("LOAD %v\r\n", joinArgs(args))))
+	return err
+}
+func XAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XADD %v\r\n", joinArgs(args))))
+	return err
+}
+func XLen(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XLEN %v\r\n", joinArgs(args))))
+	return err
+}
+func XRange(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XRANGE %v\r\n", joinArgs(args))))
+	return err
+}
+func XRevRange(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XREVRANGE %v\r\n", joinArgs(args))))
+	return err
+}
+func XTrim(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XTRIM %v\r\n", joinArgs(args))))
+	return err
+}
+func XRead(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XREAD %v\r\n", joinArgs(args))))
+	return err
+}
+func XGroup(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XGROUP %v\r\n", joinArgs(args))))
+	return err
+}
+func XReadGroup(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XREADGROUP %v\r\n", joinArgs(args))))
+	return err
+}
+func XAck(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XACK %v\r\n", joinArgs(args))))
+	return err
+}
+func XPending(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XPENDING %v\r\n", joinArgs(args))))
+	return err
+}
+func XClaim(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XCLAIM %v\r\n", joinArgs(args))))
+	return err
+}
+func XAutoClaim(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("XAUTOCLAIM %v\r\n", joinArgs(args))))
+	return err
+}
+func PFAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PFADD %v\r\n", joinArgs(args))))
+	return err
+}
+func PFCount(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PFCOUNT %v\r\n", joinArgs(args))))
+	return err
+}
+func PFMerge(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PFMERGE %v\r\n", joinArgs(args))))
+	return err
+}
+func BFReserve(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.RESERVE %v\r\n", joinArgs(args))))
+	return err
+}
+func BFAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.ADD %v\r\n", joinArgs(args))))
+	return err
+}
+func BFMAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.MADD %v\r\n", joinArgs(args))))
+	return err
+}
+func BFExists(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.EXISTS %v\r\n", joinArgs(args))))
+	return err
+}
+func BFMExists(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.MEXISTS %v\r\n", joinArgs(args))))
+	return err
+}
+func BFInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BF.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func CFReserve(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.RESERVE %v\r\n", joinArgs(args))))
+	return err
+}
+func CFAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.ADD %v\r\n", joinArgs(args))))
+	return err
+}
+func CFAddNX(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.ADDNX %v\r\n", joinArgs(args))))
+	return err
+}
+func CFDel(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.DEL %v\r\n", joinArgs(args))))
+	return err
+}
+func CFExists(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.EXISTS %v\r\n", joinArgs(args))))
+	return err
+}
+func CFCount(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.COUNT %v\r\n", joinArgs(args))))
+	return err
+}
+func CFInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CF.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSInitByDim(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.INITBYDIM %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSInitByProb(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.INITBYPROB %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSIncrBy(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.INCRBY %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSQuery(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.QUERY %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSMerge(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.MERGE %v\r\n", joinArgs(args))))
+	return err
+}
+func CMSInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CMS.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKReserve(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.RESERVE %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.ADD %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKIncrBy(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.INCRBY %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKQuery(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.QUERY %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKCount(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.COUNT %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKList(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.LIST %v\r\n", joinArgs(args))))
+	return err
+}
+func TopKInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOPK.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func SetBit(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SETBIT %v\r\n", joinArgs(args))))
+	return err
+}
+func GetBit(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("GETBIT %v\r\n", joinArgs(args))))
+	return err
+}
+func BitCount(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BITCOUNT %v\r\n", joinArgs(args))))
+	return err
+}
+func BitPos(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BITPOS %v\r\n", joinArgs(args))))
+	return err
+}
+func BitOp(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BITOP %v\r\n", joinArgs(args))))
+	return err
+}
+func BitField(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BITFIELD %v\r\n", joinArgs(args))))
+	return err
+}
+func BitFieldRO(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("BITFIELD_RO %v\r\n", joinArgs(args))))
+	return err
+}
+func JSONSet(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("JSON.SET %v\r\n", joinArgs(args))))
+	return err
+}
+func JSONGet(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("JSON.GET %v\r\n", joinArgs(args))))
+	return err
+}
+func JSONDel(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("JSON.DEL %v\r\n", joinArgs(args))))
+	return err
+}
+func JSONNumIncrBy(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("JSON.NUMINCRBY %v\r\n", joinArgs(args))))
+	return err
+}
+func JSONArrAppend(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("JSON.ARRAPPEND %v\r\n", joinArgs(args))))
+	return err
+}
+func TSCreate(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.CREATE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.ADD %v\r\n", joinArgs(args))))
+	return err
+}
+func TSGet(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.GET %v\r\n", joinArgs(args))))
+	return err
+}
+func TSRange(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.RANGE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSRevRange(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.REVRANGE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSMRange(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.MRANGE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSCreateRule(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.CREATERULE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSDeleteRule(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.DELETERULE %v\r\n", joinArgs(args))))
+	return err
+}
+func TSInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TS.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func VAdd(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VADD %v\r\n", joinArgs(args))))
+	return err
+}
+func VRem(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VREM %v\r\n", joinArgs(args))))
+	return err
+}
+func VSim(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VSIM %v\r\n", joinArgs(args))))
+	return err
+}
+func VEmb(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VEMB %v\r\n", joinArgs(args))))
+	return err
+}
+func VCard(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VCARD %v\r\n", joinArgs(args))))
+	return err
+}
+func VInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("VINFO %v\r\n", joinArgs(args))))
+	return err
+}
+func FTCreate(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FT.CREATE %v\r\n", joinArgs(args))))
+	return err
+}
+func FTSearch(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FT.SEARCH %v\r\n", joinArgs(args))))
+	return err
+}
+func FTDropIndex(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FT.DROPINDEX %v\r\n", joinArgs(args))))
+	return err
+}
+func FTInfo(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FT.INFO %v\r\n", joinArgs(args))))
+	return err
+}
+func Scan(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SCAN %v\r\n", joinArgs(args))))
+	return err
+}
+func HScan(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("HSCAN %v\r\n", joinArgs(args))))
+	return err
+}
+func Exists(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("EXISTS %v\r\n", joinArgs(args))))
+	return err
+}
+func Type(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TYPE %v\r\n", joinArgs(args))))
+	return err
+}
+func Rename(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("RENAME %v\r\n", joinArgs(args))))
+	return err
+}
+func RenameNX(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("RENAMENX %v\r\n", joinArgs(args))))
+	return err
+}
+func Copy(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("COPY %v\r\n", joinArgs(args))))
+	return err
+}
+func RandomKey(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("RANDOMKEY %v\r\n", joinArgs(args))))
+	return err
+}
+func DBSize(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("DBSIZE %v\r\n", joinArgs(args))))
+	return err
+}
+func Touch(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("TOUCH %v\r\n", joinArgs(args))))
+	return err
+}
+func Unlink(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("UNLINK %v\r\n", joinArgs(args))))
+	return err
+}
+func FlushDB(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FLUSHDB %v\r\n", joinArgs(args))))
+	return err
+}
+func FlushAll(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FLUSHALL %v\r\n", joinArgs(args))))
+	return err
+}
+func Select(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SELECT %v\r\n", joinArgs(args))))
+	return err
+}
+func SwapDB(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SWAPDB %v\r\n", joinArgs(args))))
+	return err
+}
+func Move(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("MOVE %v\r\n", joinArgs(args))))
+	return err
+}
+func Multi(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("MULTI %v\r\n", joinArgs(args))))
+	return err
+}
+func Exec(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("EXEC %v\r\n", joinArgs(args))))
+	return err
+}
+func Discard(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("DISCARD %v\r\n", joinArgs(args))))
+	return err
+}
+func Watch(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("WATCH %v\r\n", joinArgs(args))))
+	return err
+}
+func Unwatch(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("UNWATCH %v\r\n", joinArgs(args))))
+	return err
+}
+func Subscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func PSubscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PSUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func Unsubscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("UNSUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func PUnsubscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PUNSUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func Publish(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PUBLISH %v\r\n", joinArgs(args))))
+	return err
+}
+func PubSub(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("PUBSUB %v\r\n", joinArgs(args))))
+	return err
+}
+func SSubscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SSUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func SUnsubscribe(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SUNSUBSCRIBE %v\r\n", joinArgs(args))))
+	return err
+}
+func SPublish(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SPUBLISH %v\r\n", joinArgs(args))))
+	return err
+}
+func Config(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CONFIG %v\r\n", joinArgs(args))))
+	return err
+}
+func Eval(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("EVAL %v\r\n", joinArgs(args))))
+	return err
+}
+func EvalSHA(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("EVALSHA %v\r\n", joinArgs(args))))
+	return err
+}
+func Script(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("SCRIPT %v\r\n", joinArgs(args))))
+	return err
+}
+func FCall(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FCALL %v\r\n", joinArgs(args))))
+	return err
+}
+func Function(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("FUNCTION %v\r\n", joinArgs(args))))
+	return err
+}
+func Command(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("COMMAND %v\r\n", joinArgs(args))))
+	return err
+}
+func Help(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("HELP %v\r\n", joinArgs(args))))
+	return err
+}
+func Client(conn net.Conn, args []string) error {
+	_, err := conn.Write([]byte(fmt.Sprintf("CLIENT %v\r\n", joinArgs(args))))
 	return err
 }
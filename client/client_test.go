@@ -93,6 +93,450 @@ func TestClient(t *testing.T) {
 	if err != nil {
 		t.Error(err)
 	}
+	err = XAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XLen(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XRange(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XRevRange(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XTrim(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XRead(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XGroup(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XReadGroup(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XAck(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XPending(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XClaim(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = XAutoClaim(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PFAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PFCount(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PFMerge(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFReserve(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFMAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFExists(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFMExists(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BFInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFReserve(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFAddNX(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFDel(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFExists(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFCount(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CFInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSInitByDim(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSInitByProb(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSIncrBy(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSQuery(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSMerge(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = CMSInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKReserve(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKIncrBy(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKQuery(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKCount(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKList(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TopKInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = SetBit(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = GetBit(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BitCount(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BitPos(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BitOp(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BitField(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = BitFieldRO(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = JSONSet(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = JSONGet(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = JSONDel(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = JSONNumIncrBy(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = JSONArrAppend(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSCreate(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSGet(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSRange(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSRevRange(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSMRange(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSCreateRule(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSDeleteRule(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = TSInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VAdd(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VRem(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VSim(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VEmb(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VCard(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = VInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FTCreate(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FTSearch(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FTDropIndex(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FTInfo(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Scan(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = HScan(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Exists(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Type(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Rename(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = RenameNX(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Copy(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = RandomKey(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = DBSize(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Touch(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Unlink(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FlushDB(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FlushAll(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Select(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = SwapDB(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Move(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Multi(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Exec(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Discard(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Watch(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Unwatch(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Subscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PSubscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Unsubscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PUnsubscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Publish(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = PubSub(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = SSubscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = SUnsubscribe(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = SPublish(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Config(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Eval(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = EvalSHA(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Script(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = FCall(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Function(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Command(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Help(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
+	err = Client(clientConn, []string{"name"})
+	if err != nil {
+		t.Error(err)
+	}
 }
 
 type mockServer struct {
@@ -125,3 +569,11 @@ func (s *mockServer) serve() {
 		}
 	}
 }
+
+func TestJoinArgs(t *testing.T) {
+	args := []string{"users", "@city:{berlin} @age:[30 +inf]", `"quoted"`, "", `{"a":"b"}`}
+	expected := `users "@city:{berlin} @age:[30 +inf]" "\"quoted\"" "" {"a":"b"}`
+	if joined := joinArgs(args); joined != expected {
+		t.Errorf("expected %v, got %v", expected, joined)
+	}
+}
@@ -0,0 +1,187 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+)
+
+// pushPrefix starts messages pushed by the server between replies, replies starting with it have it doubled
+const pushPrefix = ">"
+
+// invalidationPrefix starts pushed messages sent by the server when keys tracked by the connection change
+const invalidationPrefix = "1) \"invalidate\"\n2) "
+
+// NearCache keeps values read by GET in memory of the process. It owns the connection and turns tracking on,
+// so that the server pushes invalidation messages when the keys change or expire and cached values are dropped.
+// Replies are returned as they are sent by the server
+type NearCache struct {
+	conn net.Conn
+	// requests serializes requests, so that replies are received in the order of requests
+	requests *sync.Mutex
+	replies  chan string
+	// m protects values and the key read by the request in flight
+	m      *sync.Mutex
+	values map[string]string
+	// reading is the key of GET in flight, invalidated is set if an invalidation of it is received before the reply
+	reading     string
+	pending     bool
+	invalidated bool
+	// err is the error which stopped receiving, it is set before replies are closed
+	err error
+}
+
+// NewNearCache turns tracking on for the connection. Without prefixes the server tracks keys read by the
+// connection, with prefixes it notifies about changes of all keys starting with them
+func NewNearCache(conn net.Conn, prefixes ...string) (*NearCache, error) {
+	n := &NearCache{
+		conn:     conn,
+		requests: &sync.Mutex{},
+		replies:  make(chan string, 1),
+		m:        &sync.Mutex{},
+		values:   make(map[string]string),
+	}
+	go n.receive(bufio.NewReader(conn))
+	args := []string{"TRACKING", "ON"}
+	if len(prefixes) > 0 {
+		args = append(args, "BCAST")
+		for _, prefix := range prefixes {
+			args = append(args, "PREFIX", prefix)
+		}
+	}
+	resp, err := n.Do("CLIENT", args)
+	if err != nil {
+		return nil, err
+	}
+	if resp != "OK" {
+		return nil, errors.New(resp)
+	}
+	return n, nil
+}
+
+// readReply reads one reply, lines of arrays are separated by \n and replies end with \r\n
+func readReply(reader *bufio.Reader) (string, error) {
+	var builder strings.Builder
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return "", err
+		}
+		builder.WriteString(line)
+		if strings.HasSuffix(line, "\r\n") {
+			return strings.TrimSuffix(builder.String(), "\r\n"), nil
+		}
+	}
+}
+
+// receive reads replies and pushed messages until the connection is closed, pushed messages other than
+// invalidations are skipped
+func (n *NearCache) receive(reader *bufio.Reader) {
+	for {
+		text, err := readReply(reader)
+		if err != nil {
+			n.err = err
+			close(n.replies)
+			return
+		}
+		switch {
+		case strings.HasPrefix(text, pushPrefix+pushPrefix):
+			text = text[len(pushPrefix):]
+		case strings.HasPrefix(text, pushPrefix):
+			if message := text[len(pushPrefix):]; strings.HasPrefix(message, invalidationPrefix) {
+				n.invalidate(message[len(invalidationPrefix):])
+			}
+			continue
+		}
+		n.replies <- text
+	}
+}
+
+// invalidate drops the keys of the invalidation message, (nil) drops all keys
+func (n *NearCache) invalidate(keys string) {
+	n.m.Lock()
+	defer n.m.Unlock()
+	if keys == "(nil)" {
+		n.values = make(map[string]string)
+		n.invalidated = n.pending
+		return
+	}
+	for _, line := range strings.Split(keys, "\n") {
+		line = strings.TrimLeft(line, " ")
+		if i := strings.Index(line, ") "); i >= 0 {
+			line = line[i+2:]
+		}
+		key := strings.TrimSuffix(strings.TrimPrefix(line, "\""), "\"")
+		delete(n.values, key)
+		if n.pending && key == n.reading {
+			n.invalidated = true
+		}
+	}
+}
+
+// request sends the command and waits for its reply. Requests must be locked before calling request
+func (n *NearCache) request(method string, args []string) (string, error) {
+	_, err := n.conn.Write([]byte(fmt.Sprintf("%v %v\r\n", method, joinArgs(args))))
+	if err != nil {
+		return "", err
+	}
+	text, ok := <-n.replies
+	if !ok {
+		return "", n.err
+	}
+	return text, nil
+}
+
+// Get returns the value of the key from memory, or reads it from the server and keeps it until it is invalidated
+func (n *NearCache) Get(key string) (string, error) {
+	n.m.Lock()
+	value, ok := n.values[key]
+	n.m.Unlock()
+	if ok {
+		return value, nil
+	}
+	n.requests.Lock()
+	defer n.requests.Unlock()
+	n.m.Lock()
+	n.reading, n.pending, n.invalidated = key, true, false
+	n.m.Unlock()
+	value, err := n.request("GET", []string{key})
+	n.m.Lock()
+	defer n.m.Unlock()
+	if err == nil && !n.invalidated {
+		n.values[key] = value
+	}
+	n.reading, n.pending, n.invalidated = "", false, false
+	return value, err
+}
+
+// Do sends any command over the connection of the near cache. Values of keys named by the arguments are dropped
+// first, so that the connection does not read its own stale writes before their invalidation arrives
+func (n *NearCache) Do(method string, args []string) (string, error) {
+	n.m.Lock()
+	for _, arg := range args {
+		delete(n.values, arg)
+	}
+	n.m.Unlock()
+	n.requests.Lock()
+	defer n.requests.Unlock()
+	return n.request(method, args)
+}
+
+// Len returns the number of values kept in memory
+func (n *NearCache) Len() int {
+	n.m.Lock()
+	defer n.m.Unlock()
+	return len(n.values)
+}
+
+// Close closes the connection, cached values are not used afterwards
+func (n *NearCache) Close() error {
+	n.m.Lock()
+	n.values = make(map[string]string)
+	n.m.Unlock()
+	return n.conn.Close()
+}
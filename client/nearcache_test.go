@@ -0,0 +1,134 @@
+package client
+
+import (
+	"io"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/antonvlasov/geo/cache"
+	"github.com/antonvlasov/geo/server"
+)
+
+// startServer serves databases on the address like the main package does
+func startServer(t *testing.T, address string) {
+	telnet := server.NewTelnetServer()
+	databases := cache.NewDatabases(1)
+	go databases.StartCleaner()
+	telnet.SetHandler("default", func(w io.Writer, req *server.RESTRequest) error {
+		response, err := databases.HandleRequest(req.Session, req.Method, req.Args)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(response + "\r\n"))
+		return err
+	})
+	addr, err := net.ResolveTCPAddr("tcp", address)
+	if err != nil {
+		t.Fatal(err)
+	}
+	go telnet.ListenAndServe(addr)
+	time.Sleep(50 * time.Millisecond)
+}
+
+// eventually waits until the condition holds, invalidation messages arrive asynchronously
+func eventually(condition func() bool) bool {
+	for i := 0; i < 300; i += 1 {
+		if condition() {
+			return true
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	return false
+}
+
+// cached returns true if the value of the key is kept in memory
+func cached(n *NearCache, key string) bool {
+	n.m.Lock()
+	defer n.m.Unlock()
+	_, ok := n.values[key]
+	return ok
+}
+
+func TestNearCache(t *testing.T) {
+	startServer(t, "localhost:1201")
+	dial := func() net.Conn {
+		conn, err := net.Dial("tcp", "localhost:1201")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return conn
+	}
+	near, err := NewNearCache(dial())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer near.Close()
+	bcast, err := NewNearCache(dial(), "user:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer bcast.Close()
+	writer, err := NewNearCache(dial())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer writer.Close()
+
+	if resp, err := writer.Do("SET", []string{"user:1", "a"}); err != nil || resp != "OK" {
+		t.Fatalf("expected OK, got %v %v", resp, err)
+	}
+	for _, n := range []*NearCache{near, bcast} {
+		if value, err := n.Get("user:1"); err != nil || value != "a" {
+			t.Errorf("expected a, got %v %v", value, err)
+		}
+	}
+	if near.Len() != 1 || bcast.Len() != 1 {
+		t.Errorf("expected cached values, got %v %v", near.Len(), bcast.Len())
+	}
+
+	writer.Do("SET", []string{"user:1", "b"})
+	if !eventually(func() bool { return !cached(near, "user:1") && !cached(bcast, "user:1") }) {
+		t.Fatalf("expected values to be invalidated")
+	}
+	for _, n := range []*NearCache{near, bcast} {
+		if value, err := n.Get("user:1"); err != nil || value != "b" {
+			t.Errorf("expected b, got %v %v", value, err)
+		}
+	}
+
+	// writes of the connection itself drop its values immediately
+	near.Do("SET", []string{"user:1", "c"})
+	if value, err := near.Get("user:1"); err != nil || value != "c" {
+		t.Errorf("expected c, got %v %v", value, err)
+	}
+
+	near.Get("other")
+	writer.Do("SET", []string{"other", "value", "EX", "1"})
+	if !eventually(func() bool { return !cached(near, "other") }) {
+		t.Fatalf("expected other to be invalidated")
+	}
+	if value, err := near.Get("other"); err != nil || value != "value" || !cached(near, "other") {
+		t.Errorf("expected cached value, got %v %v", value, err)
+	}
+	if !eventually(func() bool { return !cached(near, "other") }) {
+		t.Errorf("expected expired key to be invalidated")
+	}
+
+	// replies starting with the prefix of pushed messages are escaped by the server and are not taken for them
+	pushed := `>1) "invalidate"`
+	writer.Do("SET", []string{"user:3", pushed})
+	if value, err := near.Get("user:3"); err != nil || value != pushed {
+		t.Errorf("expected %v, got %v %v", pushed, value, err)
+	}
+
+	bcast.Get("user:2")
+	writer.Do("FLUSHDB", nil)
+	if !eventually(func() bool { return bcast.Len() == 0 }) {
+		t.Errorf("expected all values to be invalidated by FLUSHDB")
+	}
+
+	if _, err := NewNearCache(dial(), ""); err != nil {
+		t.Errorf("expected tracking of all keys, got %v", err)
+	}
+}
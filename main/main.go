@@ -11,10 +11,10 @@ import (
 
 func Run(port int) error {
 	CacheServer := server.NewTelnetServer()
-	cache := cache.NewCache()
-	go cache.StartCleaner()
+	databases := cache.NewDatabases(cache.DefaultDatabases)
+	go databases.StartCleaner()
 	handler := func(w io.Writer, req *server.RESTRequest) error {
-		response, err := cache.HandleRequest(req.Method, req.Args)
+		response, err := databases.HandleRequest(req.Session, req.Method, req.Args)
 		if err != nil {
 			return err
 		}
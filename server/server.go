@@ -9,6 +9,30 @@ import (
 	"strings"
 )
 
+// messageBuffer is the number of pushed messages a connection can fall behind before new ones are dropped
+const messageBuffer = 1024
+
+// PushPrefix starts messages pushed to a connection between replies, like published messages and invalidations.
+// Replies starting with it are sent with the prefix doubled, so that they are never taken for pushed messages
+const PushPrefix = ">"
+
+// replyWriter writes replies to the connection, every Write is a whole reply
+type replyWriter struct {
+	conn net.Conn
+}
+
+func (w replyWriter) Write(p []byte) (int, error) {
+	if !strings.HasPrefix(string(p), PushPrefix) {
+		return w.conn.Write(p)
+	}
+	// the reply is written at once, so that a pushed message can not get between the prefix and the reply
+	_, err := w.conn.Write(append([]byte(PushPrefix), p...))
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
 type TelnetServer interface {
 	SetHandler(method string, h func(w io.Writer, req *RESTRequest) error)
 	HandleRequest(w io.Writer, request *RESTRequest) error
@@ -31,6 +55,9 @@ func (this *telnetServer) SetHandler(method string, h func(w io.Writer, req *RES
 	this.handlers[method] = h
 }
 func (this *telnetServer) HandleRequest(w io.Writer, request *RESTRequest) error {
+	if request.Session == nil {
+		request.Session = &Session{}
+	}
 	if request.Method == "" {
 		w.Write([]byte("\r\n"))
 		return nil
@@ -67,9 +94,21 @@ func (this *telnetServer) ListenAndServe(addr *net.TCPAddr) error {
 		defer conn.Close()
 		go func(conn net.Conn) {
 			connReader := bufio.NewReader(conn)
+			session := &Session{messages: make(chan string, messageBuffer)}
+			replies := replyWriter{conn}
+			// messages pushed to the session are written by a separate goroutine, so that senders never wait for the connection
+			go func() {
+				for message := range session.messages {
+					_, err := conn.Write([]byte(PushPrefix + message + "\r\n"))
+					if err != nil {
+						return
+					}
+				}
+			}()
 			for {
 				bytes, err := connReader.ReadBytes('\n')
 				if err == io.EOF {
+					session.close()
 					break
 				}
 				if err != nil {
@@ -79,15 +118,16 @@ func (this *telnetServer) ListenAndServe(addr *net.TCPAddr) error {
 
 				req, err := RESTParse(msg)
 				if err != nil {
-					_, err = conn.Write([]byte(err.Error() + "\r\n"))
+					_, err = replies.Write([]byte(err.Error() + "\r\n"))
 					if err != nil {
 						log.Fatal(err)
 					}
 					continue
 				}
-				err = this.HandleRequest(conn, &req)
+				req.Session = session
+				err = this.HandleRequest(replies, &req)
 				if err != nil {
-					_, err = conn.Write([]byte(err.Error() + "\r\n"))
+					_, err = replies.Write([]byte(err.Error() + "\r\n"))
 					if err != nil {
 						log.Fatal(err)
 					}
@@ -98,13 +138,126 @@ func (this *telnetServer) ListenAndServe(addr *net.TCPAddr) error {
 }
 
 type RESTRequest struct {
-	Method string
-	Args   []string
+	Method  string
+	Args    []string
+	Session *Session
+}
+
+// Session is the state of a connection kept between its requests
+type Session struct {
+	db int
+	// commands received after MULTI, nil outside of a transaction
+	queued  [][]string
+	aborted bool
+	// called when the connection is closed
+	closers []func()
+	// messages pushed to the connection, nil if the session has no connection
+	messages chan string
+}
+
+// DB returns the number of the selected database
+func (s *Session) DB() int {
+	return s.db
+}
+func (s *Session) SetDB(db int) {
+	s.db = db
 }
 
+// Multi starts a transaction
+func (s *Session) Multi() {
+	s.queued = make([][]string, 0)
+	s.aborted = false
+}
+
+// Queued returns the commands queued in the transaction, nil if there is no transaction
+func (s *Session) Queued() [][]string {
+	return s.queued
+}
+func (s *Session) Enqueue(command []string) {
+	s.queued = append(s.queued, command)
+}
+
+// Abort marks the transaction as failed, so that it is discarded on EXEC
+func (s *Session) Abort() {
+	s.aborted = true
+}
+func (s *Session) Aborted() bool {
+	return s.aborted
+}
+
+// Discard ends the transaction
+func (s *Session) Discard() {
+	s.queued = nil
+	s.aborted = false
+}
+
+// OnClose registers a function called when the connection is closed
+func (s *Session) OnClose(f func()) {
+	s.closers = append(s.closers, f)
+}
+func (s *Session) close() {
+	for _, f := range s.closers {
+		f()
+	}
+	if s.messages != nil {
+		close(s.messages)
+	}
+}
+
+// Send pushes the message to the connection. The message is dropped if the buffer of the connection is full
+func (s *Session) Send(message string) bool {
+	select {
+	case s.messages <- message:
+		return true
+	default:
+		return false
+	}
+}
+
+var escapes = map[byte]byte{'n': '\n', 'r': '\r', 't': '\t'}
+
+// RESTParse splits the request by spaces. An argument starting with a double or a single quote lasts
+// until the closing quote and may contain spaces, \" and \\ are unescaped inside double quotes, \n, \r and \t
+// are replaced by the characters, so that multiline values like function libraries can be sent. The quotes themselves
+// are removed, JSON strings are sent in single quotes like '"value"' to keep their double quotes
 func RESTParse(request string) (req RESTRequest, err error) {
 	request = strings.Trim(request, " ")
-	parts := strings.Split(request, " ")
+	parts := make([]string, 0)
+	for len(request) != 0 {
+		quote := request[0]
+		if quote != '"' && quote != '\'' {
+			end := strings.IndexByte(request, ' ')
+			if end < 0 {
+				end = len(request)
+			}
+			parts = append(parts, request[:end])
+			request = strings.TrimLeft(request[end:], " ")
+			continue
+		}
+		var part strings.Builder
+		i := 1
+		for ; i < len(request) && request[i] != quote; i += 1 {
+			if quote == '"' && request[i] == '\\' && i+1 < len(request) {
+				i += 1
+				if c, ok := escapes[request[i]]; ok {
+					part.WriteByte(c)
+					continue
+				}
+			}
+			part.WriteByte(request[i])
+		}
+		if i >= len(request) {
+			return req, errors.New("unbalanced quotes in request")
+		}
+		if i+1 < len(request) && request[i+1] != ' ' {
+			return req, errors.New("closing quote must be followed by a space")
+		}
+		parts = append(parts, part.String())
+		request = strings.TrimLeft(request[i+1:], " ")
+	}
+	if len(parts) == 0 {
+		parts = append(parts, "")
+	}
 	return RESTRequest{
 		Method: parts[0],
 		Args:   parts[1:],
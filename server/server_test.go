@@ -69,10 +69,10 @@ func echoHandler(w io.Writer, req *RESTRequest) error {
 }
 func Run(port int) error {
 	CacheServer := NewTelnetServer()
-	cache := cache.NewCache()
-	go cache.StartCleaner()
+	databases := cache.NewDatabases(cache.DefaultDatabases)
+	go databases.StartCleaner()
 	handler := func(w io.Writer, req *RESTRequest) error {
-		response, err := cache.HandleRequest(req.Method, req.Args)
+		response, err := databases.HandleRequest(req.Session, req.Method, req.Args)
 		if err != nil {
 			return err
 		}
@@ -227,3 +227,95 @@ func TestMultConn(t *testing.T) {
 	}
 	wg.Wait()
 }
+
+func TestRESTParse(t *testing.T) {
+	tests := []struct {
+		request  string
+		expected []string
+	}{
+		{"GET key", []string{"GET", "key"}},
+		{"  SET  key   value ", []string{"SET", "key", "value"}},
+		{`FT.SEARCH users "@city:{berlin} @age:[30 +inf]" LIMIT 0 1`, []string{"FT.SEARCH", "users", "@city:{berlin} @age:[30 +inf]", "LIMIT", "0", "1"}},
+		{`SET key "say \"hi\" \\o/"`, []string{"SET", "key", `say "hi" \o/`}},
+		{`SET key 'single "quoted"' ""`, []string{"SET", "key", `single "quoted"`, ""}},
+		{`JSON.SET key $ {"a":"b"}`, []string{"JSON.SET", "key", "$", `{"a":"b"}`}},
+		{`FUNCTION LOAD "#!lua name=lib\nreturn\t1" '\n'`, []string{"FUNCTION", "LOAD", "#!lua name=lib\nreturn\t1", `\n`}},
+	}
+	for _, test := range tests {
+		req, err := RESTParse(test.request)
+		if err != nil {
+			t.Error(err)
+		}
+		parts := append([]string{req.Method}, req.Args...)
+		if fmt.Sprintf("%q", parts) != fmt.Sprintf("%q", test.expected) {
+			t.Errorf("%v: expected %q, got %q", test.request, test.expected, parts)
+		}
+	}
+	for _, request := range []string{`SET key "value`, `SET key "a"b`} {
+		_, err := RESTParse(request)
+		if err == nil {
+			t.Errorf("expected error on %v", request)
+		}
+	}
+}
+
+// TestJSONString sends JSON strings through the REST parser, they are passed in single quotes or with escaped
+// double quotes, because double quotes around an argument are removed
+func TestJSONString(t *testing.T) {
+	server := NewTelnetServer()
+	databases := cache.NewDatabases(1)
+	server.SetHandler("default", func(w io.Writer, req *RESTRequest) error {
+		response, err := databases.HandleRequest(req.Session, req.Method, req.Args)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write([]byte(response + "\r\n"))
+		return err
+	})
+	session := &Session{}
+	steps := []struct {
+		request  string
+		expected string
+	}{
+		{`JSON.SET response $ {"user":{"name":"anton"},"tags":["a"]}`, "OK"},
+		{`JSON.ARRAPPEND response .tags '"b"'`, "(integer) 2"},
+		{`JSON.SET response $.user.name '"bob smith"'`, "OK"},
+		{`JSON.SET response .tags[0] "\"c\""`, "OK"},
+		{`JSON.GET response $.user.name .tags`, `{"$.user.name":["bob smith"],".tags":["c","b"]}`},
+	}
+	for _, step := range steps {
+		req, err := RESTParse(step.request)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Session = session
+		var w strings.Builder
+		err = server.HandleRequest(&w, &req)
+		if err != nil || w.String() != step.expected+"\r\n" {
+			t.Errorf("%v: expected %v, got %q %v", step.request, step.expected, w.String(), err)
+		}
+	}
+}
+
+func TestSessionSend(t *testing.T) {
+	session := &Session{messages: make(chan string, 2)}
+	closed := false
+	session.OnClose(func() {
+		closed = true
+	})
+	for i, expected := range []bool{true, true, false} {
+		if sent := session.Send(fmt.Sprintf("message %v", i)); sent != expected {
+			t.Errorf("message %v: expected %v, got %v", i, expected, sent)
+		}
+	}
+	if message := <-session.messages; message != "message 0" {
+		t.Errorf("expected message 0, got %v", message)
+	}
+	session.close()
+	if !closed {
+		t.Errorf("expected close callbacks to be called")
+	}
+	if (&Session{}).Send("message") {
+		t.Errorf("expected messages to a session without connection to be dropped")
+	}
+}